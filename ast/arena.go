@@ -0,0 +1,127 @@
+package ast
+
+// arenaBlockSize is the number of nodes of a type allocated at once
+const arenaBlockSize = 256
+
+// Arena allocates the most common nodes in blocks, which reduces the number
+// of allocations when parsing many documents, e.g. short comments. Set
+// parser.Options.Arena to parse with an arena.
+//
+// Reset frees all the nodes at once, so that their memory is reused for
+// the next document. The nodes, e.g. the tree of the previous document,
+// must not be used after Reset.
+//
+// Methods of a nil *Arena allocate nodes individually. The zero value is an
+// empty arena. An Arena must not be used concurrently.
+type Arena struct {
+	texts   [][]Text
+	paras   [][]Paragraph
+	emphs   [][]Emph
+	strongs [][]Strong
+	codes   [][]Code
+	links   [][]Link
+
+	nTexts, nParas, nEmphs, nStrongs, nCodes, nLinks int
+}
+
+// Reset frees all the nodes allocated by a for reuse.
+func (a *Arena) Reset() {
+	a.nTexts, a.nParas, a.nEmphs, a.nStrongs, a.nCodes, a.nLinks = 0, 0, 0, 0, 0, 0
+}
+
+// arenaSlot returns the block and the index in it of the n-th node of a
+// type, and true if a new block is needed
+func arenaSlot(n, blocks int) (block, i int, grow bool) {
+	block, i = n/arenaBlockSize, n%arenaBlockSize
+	return block, i, block == blocks
+}
+
+// NewText returns an empty Text node.
+func (a *Arena) NewText() *Text {
+	if a == nil {
+		return &Text{}
+	}
+	b, i, grow := arenaSlot(a.nTexts, len(a.texts))
+	if grow {
+		a.texts = append(a.texts, make([]Text, arenaBlockSize))
+	}
+	a.nTexts++
+	node := &a.texts[b][i]
+	*node = Text{}
+	return node
+}
+
+// NewParagraph returns an empty Paragraph node.
+func (a *Arena) NewParagraph() *Paragraph {
+	if a == nil {
+		return &Paragraph{}
+	}
+	b, i, grow := arenaSlot(a.nParas, len(a.paras))
+	if grow {
+		a.paras = append(a.paras, make([]Paragraph, arenaBlockSize))
+	}
+	a.nParas++
+	node := &a.paras[b][i]
+	*node = Paragraph{}
+	return node
+}
+
+// NewEmph returns an empty Emph node.
+func (a *Arena) NewEmph() *Emph {
+	if a == nil {
+		return &Emph{}
+	}
+	b, i, grow := arenaSlot(a.nEmphs, len(a.emphs))
+	if grow {
+		a.emphs = append(a.emphs, make([]Emph, arenaBlockSize))
+	}
+	a.nEmphs++
+	node := &a.emphs[b][i]
+	*node = Emph{}
+	return node
+}
+
+// NewStrong returns an empty Strong node.
+func (a *Arena) NewStrong() *Strong {
+	if a == nil {
+		return &Strong{}
+	}
+	b, i, grow := arenaSlot(a.nStrongs, len(a.strongs))
+	if grow {
+		a.strongs = append(a.strongs, make([]Strong, arenaBlockSize))
+	}
+	a.nStrongs++
+	node := &a.strongs[b][i]
+	*node = Strong{}
+	return node
+}
+
+// NewCode returns an empty Code node.
+func (a *Arena) NewCode() *Code {
+	if a == nil {
+		return &Code{}
+	}
+	b, i, grow := arenaSlot(a.nCodes, len(a.codes))
+	if grow {
+		a.codes = append(a.codes, make([]Code, arenaBlockSize))
+	}
+	a.nCodes++
+	node := &a.codes[b][i]
+	*node = Code{}
+	return node
+}
+
+// NewLink returns an empty Link node.
+func (a *Arena) NewLink() *Link {
+	if a == nil {
+		return &Link{}
+	}
+	b, i, grow := arenaSlot(a.nLinks, len(a.links))
+	if grow {
+		a.links = append(a.links, make([]Link, arenaBlockSize))
+	}
+	a.nLinks++
+	node := &a.links[b][i]
+	*node = Link{}
+	return node
+}
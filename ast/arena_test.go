@@ -0,0 +1,33 @@
+package ast
+
+import "testing"
+
+func TestArena(t *testing.T) {
+	var nilArena *Arena
+	if text := nilArena.NewText(); text == nil {
+		t.Fatalf("nil arena returned nil")
+	}
+
+	a := &Arena{}
+	var texts []*Text
+	for i := 0; i < arenaBlockSize+10; i++ {
+		text := a.NewText()
+		text.Literal = []byte("text")
+		texts = append(texts, text)
+	}
+	for i, text := range texts[1:] {
+		if text == texts[i] {
+			t.Fatalf("node %d allocated twice", i+1)
+		}
+	}
+	para := a.NewParagraph()
+	AppendChild(para, texts[0])
+
+	a.Reset()
+	if text := a.NewText(); text != texts[0] || text.Literal != nil || text.Parent != nil {
+		t.Errorf("want the first node reused and cleared, got %p %+v", text, text)
+	}
+	if p := a.NewParagraph(); p != para || len(p.Children) != 0 {
+		t.Errorf("want the first paragraph reused and cleared, got %p %+v", p, p)
+	}
+}
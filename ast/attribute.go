@@ -3,8 +3,58 @@ package ast
 // An attribute can be attached to block elements. They are specified as
 // {#id .classs key="value"} where quotes for values are mandatory, multiple
 // key/value pairs are separated by whitespace.
+//
+// Attributes can also be set on any node, block or inline, with
+// SetAttribute and AddClass, e.g. by a transform before rendering. The HTML
+// renderer adds them to the element it emits for the node.
 type Attribute struct {
 	ID      []byte
 	Classes [][]byte
 	Attrs   map[string][]byte
 }
+
+// GetAttribute returns the attribute of node n, or nil if it has none.
+func GetAttribute(n Node) *Attribute {
+	if c := n.AsContainer(); c != nil {
+		return c.Attribute
+	}
+	if l := n.AsLeaf(); l != nil {
+		return l.Attribute
+	}
+	return nil
+}
+
+// getOrCreateAttribute returns the attribute of node n, creating it if needed.
+func getOrCreateAttribute(n Node) *Attribute {
+	if attr := GetAttribute(n); attr != nil {
+		return attr
+	}
+	attr := &Attribute{}
+	if c := n.AsContainer(); c != nil {
+		c.Attribute = attr
+	} else if l := n.AsLeaf(); l != nil {
+		l.Attribute = attr
+	}
+	return attr
+}
+
+// SetAttribute sets attribute key of node n to value, e.g. "role" or
+// "data-line".
+func SetAttribute(n Node, key string, value []byte) {
+	attr := getOrCreateAttribute(n)
+	if attr.Attrs == nil {
+		attr.Attrs = map[string][]byte{}
+	}
+	attr.Attrs[key] = value
+}
+
+// AddClass adds class to the classes of node n, unless it already has it.
+func AddClass(n Node, class []byte) {
+	attr := getOrCreateAttribute(n)
+	for _, c := range attr.Classes {
+		if string(c) == string(class) {
+			return
+		}
+	}
+	attr.Classes = append(attr.Classes, class)
+}
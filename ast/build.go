@@ -0,0 +1,166 @@
+package ast
+
+// Constructors of nodes for building a tree programmatically, e.g. to
+// generate a document and render it:
+//
+//	doc := ast.NewDocument(
+//		ast.NewHeading(1, ast.NewText("Changelog")),
+//		ast.NewList(false,
+//			ast.NewListItem(ast.NewParagraph(ast.NewText("Fixed a bug"))),
+//		),
+//	)
+//	html := markdown.Render(doc, html.NewRenderer(opts))
+//
+// Children are appended to the new node with AppendChild, which sets their
+// parent.
+
+func appendChildren(parent Node, children []Node) {
+	for _, child := range children {
+		AppendChild(parent, child)
+	}
+}
+
+// NewDocument returns a document with children
+func NewDocument(children ...Node) *Document {
+	n := &Document{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewParagraph returns a paragraph with inline children
+func NewParagraph(children ...Node) *Paragraph {
+	n := &Paragraph{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewHeading returns a heading of level 1 to 6 with inline children
+func NewHeading(level int, children ...Node) *Heading {
+	n := &Heading{Level: level}
+	appendChildren(n, children)
+	return n
+}
+
+// NewBlockQuote returns a block quote with block children
+func NewBlockQuote(children ...Node) *BlockQuote {
+	n := &BlockQuote{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewList returns a tight list of items, ordered or bulleted. It sets list
+// flags of items. Set Tight to false to render paragraphs of items in <p>.
+func NewList(ordered bool, items ...*ListItem) *List {
+	n := &List{Tight: true}
+	if ordered {
+		n.ListFlags = ListTypeOrdered
+		n.Delimiter = '.'
+	} else {
+		n.BulletChar = '*'
+	}
+	for i, item := range items {
+		item.ListFlags = n.ListFlags
+		if i == 0 {
+			item.ListFlags |= ListItemBeginningOfList
+		}
+		if i == len(items)-1 {
+			item.ListFlags |= ListItemEndOfList
+		}
+		item.Tight = n.Tight
+		item.BulletChar = n.BulletChar
+		item.Delimiter = n.Delimiter
+		AppendChild(n, item)
+	}
+	return n
+}
+
+// NewListItem returns a list item with block children, to be passed to
+// NewList
+func NewListItem(children ...Node) *ListItem {
+	n := &ListItem{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewCodeBlock returns a fenced code block of code in language lang, which
+// can be empty
+func NewCodeBlock(lang, code string) *CodeBlock {
+	n := &CodeBlock{
+		IsFenced:    true,
+		FenceChar:   '`',
+		FenceLength: 3,
+	}
+	n.Literal = []byte(code)
+	if lang != "" {
+		n.Info = []byte(lang)
+		n.Language = []byte(lang)
+	}
+	return n
+}
+
+// NewHorizontalRule returns a horizontal rule
+func NewHorizontalRule() *HorizontalRule {
+	return &HorizontalRule{}
+}
+
+// NewText returns a text node of s
+func NewText(s string) *Text {
+	n := &Text{}
+	n.Literal = []byte(s)
+	return n
+}
+
+// NewEmph returns an emphasis of children
+func NewEmph(children ...Node) *Emph {
+	n := &Emph{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewStrong returns a strong emphasis of children
+func NewStrong(children ...Node) *Strong {
+	n := &Strong{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewDel returns a strikethrough of children
+func NewDel(children ...Node) *Del {
+	n := &Del{}
+	appendChildren(n, children)
+	return n
+}
+
+// NewCode returns an inline code span of s
+func NewCode(s string) *Code {
+	n := &Code{}
+	n.Literal = []byte(s)
+	return n
+}
+
+// NewLink returns a link to dest with an optional title, whose text is
+// children
+func NewLink(dest, title string, children ...Node) *Link {
+	n := &Link{Destination: []byte(dest)}
+	if title != "" {
+		n.Title = []byte(title)
+	}
+	appendChildren(n, children)
+	return n
+}
+
+// NewImage returns an image of dest with an optional title, whose
+// alternative text is children
+func NewImage(dest, title string, children ...Node) *Image {
+	n := &Image{Destination: []byte(dest)}
+	if title != "" {
+		n.Title = []byte(title)
+	}
+	appendChildren(n, children)
+	return n
+}
+
+// NewHardbreak returns a hard line break
+func NewHardbreak() *Hardbreak {
+	return &Hardbreak{}
+}
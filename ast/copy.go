@@ -0,0 +1,140 @@
+package ast
+
+import (
+	"reflect"
+)
+
+// Cloner is implemented by custom nodes that need to control how Copy
+// copies them, e.g. because they have unexported fields or data that
+// can't be copied by reflection.
+//
+// Clone returns a deep copy of the node's own data. The copy must have no
+// parent and no children, Copy sets them.
+type Cloner interface {
+	Clone() Node
+}
+
+// Copy returns a deep copy of the tree rooted at n. The copy has no parent
+// and shares no memory with n: Literal, Content, attributes and other
+// fields are copied. Node fields referring to a node in the tree, e.g.
+// Link.Footnote, refer to its copy, other references are kept.
+//
+// Nodes implementing Cloner are copied with Clone, other nodes with
+// reflection, which copies exported fields.
+func Copy(n Node) Node {
+	copies := map[Node]Node{}
+	res := copyTree(n, nil, copies)
+	for _, c := range copies {
+		relinkNodeFields(reflect.ValueOf(c), copies)
+	}
+	return res
+}
+
+func copyTree(n Node, parent Node, copies map[Node]Node) Node {
+	var c Node
+	if cloner, ok := n.(Cloner); ok {
+		c = cloner.Clone()
+	} else {
+		c = copyNode(n)
+	}
+	copies[n] = c
+	c.SetParent(parent)
+	if children := n.GetChildren(); len(children) > 0 {
+		newChildren := make([]Node, len(children))
+		for i, child := range children {
+			newChildren[i] = copyTree(child, c, copies)
+		}
+		c.SetChildren(newChildren)
+	}
+	return c
+}
+
+// copyNode returns a deep copy of n without parent and children
+func copyNode(n Node) Node {
+	v := reflect.ValueOf(n)
+	if v.Kind() != reflect.Ptr {
+		return deepCopy(v).Interface().(Node)
+	}
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(deepCopy(v.Elem()))
+	return c.Interface().(Node)
+}
+
+// deepCopy returns a copy of v with copies of its slices, maps and
+// pointers, except for Parent and Children fields and fields of type Node,
+// which are zeroed or kept
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			switch {
+			case f.PkgPath != "":
+				// unexported, copied as is
+			case f.Name == "Parent" || f.Name == "Children":
+				c.Field(i).Set(reflect.Zero(f.Type))
+			case f.Type == nodeType:
+				// a reference to another node, see relinkNodeFields
+			default:
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// relinkNodeFields changes Node fields of the struct pointed to by v that
+// refer to a copied node to refer to its copy
+func relinkNodeFields(v reflect.Value, copies map[Node]Node) {
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v = v.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		switch {
+		case f.Type == nodeType && f.Name != "Parent":
+			if ref, ok := v.Field(i).Interface().(Node); ok {
+				if c, ok := copies[ref]; ok {
+					v.Field(i).Set(reflect.ValueOf(c))
+				}
+			}
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			relinkNodeFields(v.Field(i).Addr(), copies)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ast
+
+import (
+	"testing"
+)
+
+// template is a custom node with unexported data, copied with Clone
+type template struct {
+	Container
+	vars map[string]string
+}
+
+func (t *template) Clone() Node {
+	c := &template{vars: map[string]string{}}
+	for k, v := range t.vars {
+		c.vars[k] = v
+	}
+	return c
+}
+
+func TestCopy(t *testing.T) {
+	doc := testDoc("a", "b")
+	h := &Heading{Level: 1, HeadingID: "h"}
+	AddClass(h, []byte("x"))
+	AppendChild(doc, h)
+	item := &ListItem{}
+	AppendChild(doc, item)
+	link := &Link{Destination: []byte("/url"), NoteID: 1, Footnote: item}
+	AppendChild(doc.Children[0], link)
+	tmpl := &template{vars: map[string]string{"a": "1"}}
+	AppendChild(doc, tmpl)
+	AppendChild(tmpl, &Text{Leaf: Leaf{Literal: []byte("t")}})
+
+	c := Copy(doc).(*Document)
+	if !Equal(doc, c) {
+		t.Fatalf("copy not equal:\n%s\n%s", ToString(doc), ToString(c))
+	}
+	if c.GetParent() != nil {
+		t.Errorf("copy has a parent")
+	}
+	WalkFunc(c, func(node Node, entering bool) WalkStatus {
+		for _, child := range node.GetChildren() {
+			if child.GetParent() != node {
+				t.Errorf("%T: wrong parent", child)
+			}
+		}
+		return GoToNext
+	})
+
+	// no aliasing
+	text := doc.Children[0].GetChildren()[0].(*Text)
+	cText := c.Children[0].GetChildren()[0].(*Text)
+	text.Literal[0] = 'z'
+	if string(cText.Literal) != "a" {
+		t.Errorf("literal is aliased")
+	}
+	ch := c.Children[2].(*Heading)
+	h.Attribute.Classes[0][0] = 'y'
+	if string(ch.Attribute.Classes[0]) != "x" {
+		t.Errorf("attribute is aliased")
+	}
+
+	// references to copied nodes are relinked
+	cLink := c.Children[0].GetChildren()[1].(*Link)
+	if cLink.Footnote != c.Children[3] {
+		t.Errorf("footnote not relinked")
+	}
+
+	cTmpl := c.Children[4].(*template)
+	tmpl.vars["a"] = "2"
+	if cTmpl.vars["a"] != "1" || len(cTmpl.Children) != 1 {
+		t.Errorf("custom node not cloned: %v", cTmpl)
+	}
+
+	// copy of a subtree
+	cp := Copy(doc.Children[1])
+	if cp.GetParent() != nil || !Equal(cp, doc.Children[1]) {
+		t.Errorf("wrong copy of a paragraph")
+	}
+}
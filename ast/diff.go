@@ -0,0 +1,135 @@
+package ast
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Equal returns true if trees rooted at a and b are structurally equal:
+// nodes have the same type, the same fields and equal children. Parent
+// pointers and fields referring to other nodes, e.g. Link.Footnote, are not
+// compared. A nil and an empty Literal or Content are equal.
+func Equal(a, b Node) bool {
+	if !nodeEqual(a, b) {
+		return false
+	}
+	ac, bc := a.GetChildren(), b.GetChildren()
+	if len(ac) != len(bc) {
+		return false
+	}
+	for i := range ac {
+		if !Equal(ac[i], bc[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeEqual returns true if a and b have the same type and fields,
+// ignoring children
+func nodeEqual(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	for va.Kind() == reflect.Ptr {
+		if va.IsNil() || vb.IsNil() {
+			return va.IsNil() == vb.IsNil()
+		}
+		va, vb = va.Elem(), vb.Elem()
+	}
+	return fieldsEqual(va, vb)
+}
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// fieldsEqual compares fields of structs a and b, descending into embedded
+// Container and Leaf
+func fieldsEqual(a, b reflect.Value) bool {
+	if a.Kind() != reflect.Struct {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "Parent" || f.Name == "Children" || f.Type == nodeType {
+			continue
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if f.PkgPath != "" {
+			// unexported field of a custom node, can't be compared
+			continue
+		}
+		switch {
+		case f.Type == bytesType:
+			if !bytes.Equal(fa.Bytes(), fb.Bytes()) {
+				return false
+			}
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			if !fieldsEqual(fa, fb) {
+				return false
+			}
+		default:
+			if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Change is a difference between two trees reported by Diff. Old is nil for
+// an inserted node, New is nil for a removed node. If both are set, Old was
+// replaced by New.
+type Change struct {
+	Old Node
+	New Node
+}
+
+// Diff returns the changes that turn the tree rooted at old into the tree
+// rooted at new, as a list of changed subtrees in document order.
+//
+// Nodes of the same type with equal fields are compared child by child,
+// so a change is reported for the smallest subtree containing it. Children
+// equal at the start and the end are matched first. If the remaining
+// children of old and new differ in number, they are reported as removed
+// and inserted, otherwise they are compared pairwise.
+//
+// Diff of trees of two versions of a document tells which parts need to be
+// rendered again.
+func Diff(old, new Node) []Change {
+	return diff(nil, old, new)
+}
+
+func diff(changes []Change, old, new Node) []Change {
+	if !nodeEqual(old, new) {
+		return append(changes, Change{Old: old, New: new})
+	}
+	oc, nc := old.GetChildren(), new.GetChildren()
+	// skip equal children at the start and the end
+	for len(oc) > 0 && len(nc) > 0 && Equal(oc[0], nc[0]) {
+		oc, nc = oc[1:], nc[1:]
+	}
+	for len(oc) > 0 && len(nc) > 0 && Equal(oc[len(oc)-1], nc[len(nc)-1]) {
+		oc, nc = oc[:len(oc)-1], nc[:len(nc)-1]
+	}
+	if len(oc) == len(nc) {
+		for i := range oc {
+			changes = diff(changes, oc[i], nc[i])
+		}
+		return changes
+	}
+	for _, n := range oc {
+		changes = append(changes, Change{Old: n})
+	}
+	for _, n := range nc {
+		changes = append(changes, Change{New: n})
+	}
+	return changes
+}
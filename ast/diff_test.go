@@ -0,0 +1,82 @@
+package ast
+
+import (
+	"testing"
+)
+
+// testDoc returns a document with a paragraph of each of texts
+func testDoc(texts ...string) *Document {
+	doc := &Document{}
+	for _, s := range texts {
+		p := &Paragraph{}
+		AppendChild(p, &Text{Leaf: Leaf{Literal: []byte(s)}})
+		AppendChild(doc, p)
+	}
+	return doc
+}
+
+func TestEqual(t *testing.T) {
+	a := testDoc("a", "b")
+	if !Equal(a, testDoc("a", "b")) {
+		t.Errorf("want equal documents")
+	}
+	if Equal(a, testDoc("a", "c")) || Equal(a, testDoc("a")) {
+		t.Errorf("want different documents")
+	}
+	if Equal(&Heading{Level: 1}, &Heading{Level: 2}) {
+		t.Errorf("want different heading levels")
+	}
+	if Equal(&Emph{}, &Strong{}) {
+		t.Errorf("want different node types")
+	}
+	if !Equal(&Text{}, &Text{Leaf: Leaf{Literal: []byte{}}}) {
+		t.Errorf("want empty and nil literal equal")
+	}
+	h := &Heading{Level: 1}
+	AddClass(h, []byte("x"))
+	if Equal(h, &Heading{Level: 1}) {
+		t.Errorf("want different attributes")
+	}
+	// links to footnotes are not compared
+	if !Equal(&Link{NoteID: 1, Footnote: &ListItem{}}, &Link{NoteID: 1}) {
+		t.Errorf("want links equal")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := testDoc("a", "b", "c")
+	if changes := Diff(old, testDoc("a", "b", "c")); len(changes) != 0 {
+		t.Errorf("want no changes, got %v", changes)
+	}
+
+	// changed text is reported as its smallest subtree
+	new := testDoc("a", "x", "c")
+	changes := Diff(old, new)
+	oldText := old.Children[1].GetChildren()[0]
+	newText := new.Children[1].GetChildren()[0]
+	if len(changes) != 1 || changes[0].Old != oldText || changes[0].New != newText {
+		t.Errorf("want changed text, got %v", changes)
+	}
+
+	// inserted paragraph
+	new = testDoc("a", "b", "x", "c")
+	changes = Diff(old, new)
+	if len(changes) != 1 || changes[0].Old != nil || changes[0].New != new.Children[2] {
+		t.Errorf("want inserted paragraph, got %v", changes)
+	}
+
+	// removed paragraph
+	new = testDoc("b", "c")
+	changes = Diff(old, new)
+	if len(changes) != 1 || changes[0].Old != old.Children[0] || changes[0].New != nil {
+		t.Errorf("want removed paragraph, got %v", changes)
+	}
+
+	// replaced node of a different type
+	new = testDoc("a", "b", "c")
+	Replace(new.Children[1], &Heading{Level: 2})
+	changes = Diff(old, new)
+	if len(changes) != 1 || changes[0].Old != old.Children[1] || changes[0].New != new.Children[1] {
+		t.Errorf("want replaced paragraph, got %v", changes)
+	}
+}
@@ -72,6 +72,18 @@ type Node interface {
 	SetChildren(newChildren []Node)
 }
 
+// CustomData is implemented by nodes of types defined outside of this
+// package, e.g. by parser extensions. Renderers don't know such nodes and
+// pass them to renderers registered for them, see e.g.
+// html.RendererOptions.NodeRenderers.
+type CustomData interface {
+	Node
+
+	// NodeName returns the name of the node type, e.g. "Admonition".
+	// It's used when printing the tree.
+	NodeName() string
+}
+
 // Container is a type of node that can contain children
 type Container struct {
 	Parent   Node
@@ -169,6 +181,8 @@ type DocumentMatter struct {
 // BlockQuote represents markdown block quote node
 type BlockQuote struct {
 	Container
+
+	Cite []byte // URL of the source of the quote, if known
 }
 
 // Aside represents an markdown aside node.
@@ -176,6 +190,42 @@ type Aside struct {
 	Container
 }
 
+// Directive represents a generic fenced container, e.g.
+//
+//	::: warning Be careful
+//	Content of the container.
+//	:::
+type Directive struct {
+	Container
+
+	Name []byte // name of the container, e.g. "warning"
+	Args []byte // rest of the opening line, e.g. "Be careful"
+}
+
+// Component represents a block-level MDX-style component, a capitalized
+// HTML-like tag, e.g.
+//
+//	<Tabs default="one">
+//	Content of the *component*.
+//	</Tabs>
+//
+// The content is parsed as markdown blocks, children of the node.
+type Component struct {
+	Container
+
+	Name        []byte          // tag name, e.g. "Tabs"
+	Props       []ComponentProp // props in source order
+	SelfClosing bool            // written as <Name ... />, without content
+}
+
+// ComponentProp is a prop of a Component. Value is nil for a prop without a
+// value, the unquoted text of a quoted value with entities decoded, or an
+// expression including its braces, e.g. "{count + 1}".
+type ComponentProp struct {
+	Name  []byte
+	Value []byte
+}
+
 // List represents markdown list node
 type List struct {
 	Container
@@ -185,6 +235,7 @@ type List struct {
 	BulletChar      byte   // '*', '+' or '-' in bullet lists
 	Delimiter       byte   // '.' or ')' after the number in ordered lists
 	Start           int    // for ordered lists this indicates the starting number if > 0
+	Numbering       byte   // '1', 'a', 'A', 'i' or 'I', the numbering of ordered lists
 	RefLink         []byte // If not nil, turns this list item into a footnote item and triggers different rendering
 	IsFootnotesList bool   // This is a list of footnotes
 }
@@ -199,6 +250,8 @@ type ListItem struct {
 	Delimiter       byte   // '.' or ')' after the number in ordered lists
 	RefLink         []byte // If not nil, turns this list item into a footnote item and triggers different rendering
 	IsFootnotesList bool   // This is a list of footnotes
+	IsTask          bool   // This is a task list item starting with [ ] or [x]
+	Checked         bool   // The task is done, [x]
 }
 
 // Paragraph represents markdown paragraph node
@@ -206,6 +259,38 @@ type Paragraph struct {
 	Container
 }
 
+// LineBlock represents a block of lines with preserved line breaks, e.g.
+//
+//	| The Lord of the Rings
+//	|    by J. R. R. Tolkien
+//
+// Its children are the inline content of the lines, separated by Hardbreak
+// nodes. Leading spaces of the lines are NonBlockingSpace nodes.
+type LineBlock struct {
+	Container
+}
+
+// Ruby represents a ruby annotation of East Asian text, e.g. {漢字|かんじ} or
+// [漢字]{かんじ}. Its children are the annotated base text.
+type Ruby struct {
+	Container
+
+	Annotation []byte // text of the annotation, e.g. the reading かんじ
+}
+
+// Kbd represents keyboard input, e.g. the key [[Enter]]. A key combination,
+// e.g. kbd:[Ctrl+C], is a Kbd of the Kbd nodes of its keys separated by
+// "+" text.
+type Kbd struct {
+	Container
+}
+
+// Dfn represents the defining instance of a term, e.g. a glossary term
+// marked by markdown.LinkGlossary. Its children are the term.
+type Dfn struct {
+	Container
+}
+
 // Math represents markdown MathAjax inline node
 type Math struct {
 	Leaf
@@ -250,11 +335,12 @@ type Del struct {
 type Link struct {
 	Container
 
-	Destination []byte // Destination is what goes into a href
-	Title       []byte // Title is the tooltip thing that goes in a title attribute
-	NoteID      int    // NoteID contains a serial number of a footnote, zero if it's not a footnote
-	Footnote    Node   // If it's a footnote, this is a direct link to the footnote Node. Otherwise nil.
-	DeferredID  []byte // If a deferred link this holds the original ID.
+	Destination    []byte // Destination is what goes into a href
+	Title          []byte // Title is the tooltip thing that goes in a title attribute
+	TitleDelimiter byte   // '"', '\'' or '(' that started Title in the source, 0 if there's no title
+	NoteID         int    // NoteID contains a serial number of a footnote, zero if it's not a footnote
+	Footnote       Node   // If it's a footnote, this is a direct link to the footnote Node. Otherwise nil.
+	DeferredID     []byte // If a deferred link this holds the original ID.
 }
 
 // CrossReference is a reference node.
@@ -262,6 +348,7 @@ type CrossReference struct {
 	Container
 
 	Destination []byte // Destination is where the reference points to
+	Target      Node   // If resolved by the parser (see parser.CrossReferences), the node with ID Destination, e.g. a Heading
 }
 
 // Citation is a citation node.
@@ -277,8 +364,9 @@ type Citation struct {
 type Image struct {
 	Container
 
-	Destination []byte // Destination is what goes into a href
-	Title       []byte // Title is the tooltip thing that goes in a title attribute
+	Destination    []byte // Destination is what goes into a href
+	Title          []byte // Title is the tooltip thing that goes in a title attribute
+	TitleDelimiter byte   // '"', '\'' or '(' that started Title in the source, 0 if there's no title
 }
 
 // Text represents markdown text node
@@ -300,6 +388,15 @@ type CodeBlock struct {
 	FenceChar   byte
 	FenceLength int
 	FenceOffset int
+
+	// Language is the first word of the info string, e.g. python in
+	// ```python title="example.py" linenos
+	Language []byte
+	// FenceAttrs holds key=value attributes of the info string following
+	// the language, optionally in braces, e.g. title and linenos in
+	// ```python title="example.py" linenos or hl_lines in
+	// ```go {hl_lines=[2,5-7]}. Attributes without value are set to nil.
+	FenceAttrs map[string][]byte
 }
 
 // Softbreak represents markdown softbreak node
@@ -366,7 +463,7 @@ type Caption struct {
 	Container
 }
 
-// CaptionFigure is a node (blockquote or codeblock) that has a caption
+// CaptionFigure is a node (blockquote, codeblock or image) that has a caption
 type CaptionFigure struct {
 	Container
 
@@ -418,12 +515,87 @@ func removeNodeFromArray(a []Node, node Node) []Node {
 // AppendChild appends child to children of parent
 // It panics if either node is nil.
 func AppendChild(parent Node, child Node) {
-	RemoveFromTree(child)
+	Remove(child)
 	child.SetParent(parent)
 	newChildren := append(parent.GetChildren(), child)
 	parent.SetChildren(newChildren)
 }
 
+// PrependChild inserts child as the first child of parent.
+// It panics if either node is nil.
+func PrependChild(parent Node, child Node) {
+	Remove(child)
+	child.SetParent(parent)
+	newChildren := append([]Node{child}, parent.GetChildren()...)
+	parent.SetChildren(newChildren)
+}
+
+// Remove removes node n from its parent. Unlike RemoveFromTree, n keeps
+// its children so it can be inserted somewhere else in the tree.
+func Remove(n Node) {
+	p := n.GetParent()
+	if p == nil {
+		return
+	}
+	newChildren := removeNodeFromArray(p.GetChildren(), n)
+	if newChildren != nil {
+		p.SetChildren(newChildren)
+	}
+	n.SetParent(nil)
+}
+
+// Replace replaces node old with node new in the children of old's parent.
+// It does nothing if old has no parent.
+func Replace(old Node, new Node) {
+	p := old.GetParent()
+	if p == nil || old == new {
+		return
+	}
+	Remove(new)
+	a := p.GetChildren()
+	for i, child := range a {
+		if child == old {
+			a[i] = new
+			new.SetParent(p)
+			old.SetParent(nil)
+			return
+		}
+	}
+}
+
+// InsertBefore inserts node n as a sibling directly before node sibling.
+// It does nothing if sibling has no parent.
+func InsertBefore(sibling Node, n Node) {
+	insertSibling(sibling, n, 0)
+}
+
+// InsertAfter inserts node n as a sibling directly after node sibling.
+// It does nothing if sibling has no parent.
+func InsertAfter(sibling Node, n Node) {
+	insertSibling(sibling, n, 1)
+}
+
+func insertSibling(sibling Node, n Node, offset int) {
+	p := sibling.GetParent()
+	if p == nil || sibling == n {
+		return
+	}
+	Remove(n)
+	a := p.GetChildren()
+	for i, child := range a {
+		if child == sibling {
+			i += offset
+			newChildren := make([]Node, 0, len(a)+1)
+			newChildren = append(newChildren, a[:i]...)
+			newChildren = append(newChildren, n)
+			newChildren = append(newChildren, a[i:]...)
+			n.SetParent(p)
+			p.SetChildren(newChildren)
+			return
+		}
+	}
+}
+
 // RemoveFromTree removes this node from tree
 func RemoveFromTree(n Node) {
 	if n.GetParent() == nil {
@@ -557,3 +729,25 @@ func WalkFunc(n Node, f NodeVisitorFunc) {
 	visitor := NodeVisitorFunc(f)
 	Walk(n, visitor)
 }
+
+// WalkStackFunc is a callback of WalkWithStack. ancestors are the
+// ancestors of node, from n passed to WalkWithStack to the parent of node.
+// The slice is re-used during the walk, it must be copied to be kept.
+type WalkStackFunc func(node Node, ancestors []Node, entering bool) WalkStatus
+
+// WalkWithStack is like WalkFunc but also passes the ancestors of every
+// node to f, e.g. to check if a node is inside a table cell or a footnote
+// without following Parent pointers.
+func WalkWithStack(n Node, f WalkStackFunc) {
+	var ancestors []Node
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if !entering {
+			ancestors = ancestors[:len(ancestors)-1]
+		}
+		status := f(node, ancestors, entering)
+		if entering && node.AsContainer() != nil {
+			ancestors = append(ancestors, node)
+		}
+		return status
+	})
+}
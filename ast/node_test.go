@@ -0,0 +1,246 @@
+package ast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTreeManipulation(t *testing.T) {
+	doc := &Document{}
+	a := &Paragraph{}
+	b := &Paragraph{}
+	c := &Paragraph{}
+	text := &Text{Leaf: Leaf{Literal: []byte("text")}}
+	AppendChild(doc, a)
+	AppendChild(doc, c)
+	AppendChild(a, text)
+
+	InsertBefore(c, b)
+	checkChildren(t, doc, a, b, c)
+
+	// moving a node keeps its children
+	InsertAfter(c, a)
+	checkChildren(t, doc, b, c, a)
+	checkChildren(t, a, text)
+
+	// moving a leaf node
+	AppendChild(c, text)
+	checkChildren(t, a)
+	checkChildren(t, c, text)
+
+	h := &Heading{Level: 1}
+	Replace(b, h)
+	checkChildren(t, doc, h, c, a)
+	if b.GetParent() != nil {
+		t.Errorf("replaced node still has a parent")
+	}
+
+	PrependChild(doc, b)
+	checkChildren(t, doc, b, h, c, a)
+
+	Remove(c)
+	checkChildren(t, doc, b, h, a)
+	checkChildren(t, c, text)
+	if c.GetParent() != nil {
+		t.Errorf("removed node still has a parent")
+	}
+}
+
+func checkChildren(t *testing.T, parent Node, want ...Node) {
+	t.Helper()
+	got := parent.GetChildren()
+	if len(got) != len(want) {
+		t.Fatalf("want %d children, got %d", len(want), len(got))
+	}
+	for i, child := range got {
+		if child != want[i] {
+			t.Errorf("child %d: want %T %p, got %T %p", i, want[i], want[i], child, child)
+		}
+		if child.GetParent() != parent {
+			t.Errorf("child %d: wrong parent", i)
+		}
+	}
+}
+
+func TestTypedVisitor(t *testing.T) {
+	doc := &Document{}
+	h := &Heading{Level: 1}
+	p := &Paragraph{}
+	AppendChild(doc, h)
+	AppendChild(doc, p)
+	AppendChild(p, &Text{})
+	AppendChild(p, &Kbd{})
+
+	var headings, keys, others int
+	v := &TypedVisitor{
+		Kbd: func(node *Kbd, entering bool) WalkStatus {
+			if entering {
+				keys++
+			}
+			return GoToNext
+		},
+		Heading: func(node *Heading, entering bool) WalkStatus {
+			if entering {
+				node.Level++
+				headings++
+			}
+			return GoToNext
+		},
+		Default: func(node Node, entering bool) WalkStatus {
+			if entering {
+				others++
+			}
+			return GoToNext
+		},
+	}
+	Walk(doc, v)
+	if headings != 1 || h.Level != 2 {
+		t.Errorf("want 1 heading of level 2, got %d of level %d", headings, h.Level)
+	}
+	if keys != 1 {
+		t.Errorf("want 1 key, got %d", keys)
+	}
+	if others != 3 {
+		t.Errorf("want 3 other nodes, got %d", others)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	doc := &Document{}
+	h := &Heading{Level: 1}
+	p := &Paragraph{}
+	link := &Link{Destination: []byte("/url")}
+	goCode := &CodeBlock{Info: []byte("go {.class}")}
+	AppendChild(doc, h)
+	AppendChild(doc, p)
+	AppendChild(p, link)
+	AppendChild(doc, goCode)
+	AppendChild(doc, &CodeBlock{})
+
+	if got := Headings(doc); len(got) != 1 || got[0] != h {
+		t.Errorf("want 1 heading, got %v", got)
+	}
+	if got := Links(doc); len(got) != 1 || got[0] != link {
+		t.Errorf("want 1 link, got %v", got)
+	}
+	if got := CodeBlocks(doc, "go"); len(got) != 1 || got[0] != goCode {
+		t.Errorf("want 1 go code block, got %v", got)
+	}
+	if got := CodeBlocks(doc, ""); len(got) != 2 {
+		t.Errorf("want 2 code blocks, got %v", got)
+	}
+	isContainer := func(node Node) bool { return node.AsContainer() != nil }
+	if got := FindAll(doc, isContainer); len(got) != 4 {
+		t.Errorf("want 4 containers, got %v", got)
+	}
+	if got := FindFirst(p, isContainer); got != p {
+		t.Errorf("want paragraph, got %v", got)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	emph := &Emph{}
+	text := &Text{}
+	if GetAttribute(emph) != nil || GetAttribute(text) != nil {
+		t.Fatalf("want no attributes")
+	}
+	AddClass(emph, []byte("a"))
+	AddClass(emph, []byte("a"))
+	AddClass(emph, []byte("b"))
+	SetAttribute(text, "role", []byte("note"))
+
+	if attr := GetAttribute(emph); attr == nil || len(attr.Classes) != 2 {
+		t.Errorf("want 2 classes, got %v", attr)
+	}
+	if attr := GetAttribute(text); attr == nil || string(attr.Attrs["role"]) != "note" {
+		t.Errorf("want role attribute, got %v", attr)
+	}
+}
+
+func TestTaskStats(t *testing.T) {
+	task := func(checked bool, children ...Node) *ListItem {
+		item := NewListItem(children...)
+		item.IsTask = true
+		item.Checked = checked
+		return item
+	}
+	nested := NewList(false, task(true), task(false), task(false))
+	outer := NewList(true, NewListItem(), task(true, nested), task(true))
+	doc := NewDocument(outer, NewList(false, NewListItem()))
+
+	stats := TaskStats(doc)
+	if stats.Checked != 3 || stats.Unchecked != 2 || stats.Total() != 5 {
+		t.Errorf("want 3/5 tasks done, got %d/%d", stats.Checked, stats.Total())
+	}
+	want := []ListTasks{
+		{outer, TaskCount{Checked: 2}},
+		{nested, TaskCount{Checked: 1, Unchecked: 2}},
+	}
+	if len(stats.Lists) != len(want) {
+		t.Fatalf("want %d lists, got %d", len(want), len(stats.Lists))
+	}
+	for i, l := range stats.Lists {
+		if l != want[i] {
+			t.Errorf("list %d: want %+v, got %+v", i, want[i].TaskCount, l.TaskCount)
+		}
+	}
+}
+
+func TestHeadingText(t *testing.T) {
+	note := NewLink("", "")
+	note.NoteID = 1
+	h := NewHeading(2,
+		NewText("Using "), NewCode("go test"), NewText(" with "),
+		NewEmph(NewText("race")), NewHardbreak(),
+		NewLink("/race", "", NewText("detector")), note, &HTMLSpan{Leaf: Leaf{Literal: []byte("<br>")}},
+	)
+	got := HeadingText(h)
+	if want := "Using go test with race detector"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+type badgeNode struct {
+	Leaf
+}
+
+func (n *badgeNode) NodeName() string { return "Badge" }
+
+func TestPrintCustomData(t *testing.T) {
+	badge := &badgeNode{}
+	badge.Literal = []byte("new")
+	got := ToString(NewParagraph(badge))
+	if want := "Paragraph\n  Badge 'new'\n"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestWalkWithStack(t *testing.T) {
+	cell := &TableCell{}
+	AppendChild(cell, NewEmph(NewText("cell")))
+	row := &TableRow{}
+	AppendChild(row, cell)
+	table := &Table{}
+	AppendChild(table, row)
+	doc := NewDocument(NewParagraph(NewText("para")), table)
+
+	var got []string
+	WalkWithStack(doc, func(node Node, ancestors []Node, entering bool) WalkStatus {
+		if _, ok := node.(*Text); ok {
+			var names []string
+			for _, a := range ancestors {
+				names = append(names, getNodeType(a))
+			}
+			got = append(got, strings.Join(names, "/"))
+		}
+		if _, ok := node.(*Paragraph); ok && !entering && len(ancestors) != 1 {
+			t.Errorf("want 1 ancestor of paragraph when exiting, got %d", len(ancestors))
+		}
+		return GoToNext
+	})
+	want := []string{"Document/Paragraph", "Document/Table/TableRow/TableCell/Emph"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
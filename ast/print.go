@@ -74,6 +74,9 @@ func shortenString(s string, maxLen int) string {
 // get a short name of the type of v which excludes package name
 // and strips "()" from the end
 func getNodeType(node Node) string {
+	if custom, ok := node.(CustomData); ok {
+		return custom.NodeName()
+	}
 	s := fmt.Sprintf("%T", node)
 	s = strings.TrimSuffix(s, "()")
 	if idx := strings.Index(s, "."); idx != -1 {
@@ -0,0 +1,175 @@
+package ast
+
+import (
+	"bytes"
+)
+
+// FindAll returns all nodes in the tree rooted at n (including n) for which
+// match returns true, in document order.
+func FindAll(n Node, match func(node Node) bool) []Node {
+	var res []Node
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if entering && match(node) {
+			res = append(res, node)
+		}
+		return GoToNext
+	})
+	return res
+}
+
+// FindFirst returns the first node in the tree rooted at n (including n)
+// for which match returns true or nil if there's no such node.
+func FindFirst(n Node, match func(node Node) bool) Node {
+	var res Node
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if entering && match(node) {
+			res = node
+			return Terminate
+		}
+		return GoToNext
+	})
+	return res
+}
+
+// Headings returns all headings in the tree rooted at n
+func Headings(n Node) []*Heading {
+	var res []*Heading
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if h, ok := node.(*Heading); ok && entering {
+			res = append(res, h)
+		}
+		return GoToNext
+	})
+	return res
+}
+
+// HeadingText returns the text of heading n flattened to plain text, e.g. for
+// building navigation menus. Text of code spans, emphasis and links is kept,
+// footnote references, citations and raw HTML are dropped.
+func HeadingText(n Node) string {
+	var buf bytes.Buffer
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		switch node := node.(type) {
+		case *Link:
+			if node.NoteID != 0 {
+				return SkipChildren
+			}
+		case *Text, *Code, *Math:
+			buf.Write(node.AsLeaf().Literal)
+		case *Softbreak, *Hardbreak, *NonBlockingSpace:
+			buf.WriteByte(' ')
+		}
+		return GoToNext
+	})
+	return string(bytes.TrimSpace(buf.Bytes()))
+}
+
+// Links returns all links in the tree rooted at n. Footnote references are
+// links too, they have non-zero NoteID.
+func Links(n Node) []*Link {
+	var res []*Link
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if l, ok := node.(*Link); ok && entering {
+			res = append(res, l)
+		}
+		return GoToNext
+	})
+	return res
+}
+
+// Images returns all images in the tree rooted at n
+func Images(n Node) []*Image {
+	var res []*Image
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		if img, ok := node.(*Image); ok && entering {
+			res = append(res, img)
+		}
+		return GoToNext
+	})
+	return res
+}
+
+// CodeBlocks returns all code blocks in the tree rooted at n whose language
+// (first word of the info string) is lang. If lang is empty, it returns
+// all code blocks.
+func CodeBlocks(n Node, lang string) []*CodeBlock {
+	var res []*CodeBlock
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		cb, ok := node.(*CodeBlock)
+		if !ok || !entering {
+			return GoToNext
+		}
+		if lang == "" || codeBlockLang(cb) == lang {
+			res = append(res, cb)
+		}
+		return GoToNext
+	})
+	return res
+}
+
+func codeBlockLang(cb *CodeBlock) string {
+	info := cb.Info
+	if i := bytes.IndexAny(info, "\t "); i >= 0 {
+		info = info[:i]
+	}
+	return string(info)
+}
+
+// TaskCount is the number of checked and unchecked task list items
+type TaskCount struct {
+	Checked   int
+	Unchecked int
+}
+
+// Total returns the number of tasks
+func (c TaskCount) Total() int {
+	return c.Checked + c.Unchecked
+}
+
+func (c *TaskCount) add(item *ListItem) {
+	if item.Checked {
+		c.Checked++
+	} else {
+		c.Unchecked++
+	}
+}
+
+// ListTasks is the number of tasks in a list, not counting tasks of lists
+// nested in it
+type ListTasks struct {
+	List *List
+	TaskCount
+}
+
+// TaskStatistics is the number of task list items in a document
+type TaskStatistics struct {
+	TaskCount             // all tasks
+	Lists     []ListTasks // lists with tasks, in the order of their first tasks
+}
+
+// TaskStats counts task list items (see parser.TaskLists) in the tree rooted
+// at n, e.g. to show "3/7 done"
+func TaskStats(n Node) *TaskStatistics {
+	stats := &TaskStatistics{}
+	lists := map[*List]int{}
+	WalkFunc(n, func(node Node, entering bool) WalkStatus {
+		item, ok := node.(*ListItem)
+		if !ok || !entering || !item.IsTask {
+			return GoToNext
+		}
+		stats.add(item)
+		list, ok := item.Parent.(*List)
+		if !ok {
+			return GoToNext
+		}
+		i, ok := lists[list]
+		if !ok {
+			i = len(stats.Lists)
+			lists[list] = i
+			stats.Lists = append(stats.Lists, ListTasks{List: list})
+		}
+		stats.Lists[i].add(item)
+		return GoToNext
+	})
+	return stats
+}
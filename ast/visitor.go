@@ -0,0 +1,256 @@
+package ast
+
+// TypedVisitor is a NodeVisitor that dispatches to a callback specific to
+// the type of the node, which avoids writing a type switch in every visitor.
+// Callbacks that are nil are skipped; nodes without a callback, including
+// nodes of types defined outside of this package, are passed to Default, if
+// set.
+//
+//	v := &ast.TypedVisitor{
+//		Heading: func(node *ast.Heading, entering bool) ast.WalkStatus {
+//			if entering {
+//				node.Level++
+//			}
+//			return ast.GoToNext
+//		},
+//	}
+//	ast.Walk(doc, v)
+type TypedVisitor struct {
+	Default func(node Node, entering bool) WalkStatus
+
+	Document         func(node *Document, entering bool) WalkStatus
+	DocumentMatter   func(node *DocumentMatter, entering bool) WalkStatus
+	BlockQuote       func(node *BlockQuote, entering bool) WalkStatus
+	Aside            func(node *Aside, entering bool) WalkStatus
+	Directive        func(node *Directive, entering bool) WalkStatus
+	Component        func(node *Component, entering bool) WalkStatus
+	List             func(node *List, entering bool) WalkStatus
+	ListItem         func(node *ListItem, entering bool) WalkStatus
+	Paragraph        func(node *Paragraph, entering bool) WalkStatus
+	LineBlock        func(node *LineBlock, entering bool) WalkStatus
+	Ruby             func(node *Ruby, entering bool) WalkStatus
+	Kbd              func(node *Kbd, entering bool) WalkStatus
+	Dfn              func(node *Dfn, entering bool) WalkStatus
+	Math             func(node *Math, entering bool) WalkStatus
+	MathBlock        func(node *MathBlock, entering bool) WalkStatus
+	Heading          func(node *Heading, entering bool) WalkStatus
+	HorizontalRule   func(node *HorizontalRule, entering bool) WalkStatus
+	Emph             func(node *Emph, entering bool) WalkStatus
+	Strong           func(node *Strong, entering bool) WalkStatus
+	Del              func(node *Del, entering bool) WalkStatus
+	Link             func(node *Link, entering bool) WalkStatus
+	CrossReference   func(node *CrossReference, entering bool) WalkStatus
+	Citation         func(node *Citation, entering bool) WalkStatus
+	Image            func(node *Image, entering bool) WalkStatus
+	Text             func(node *Text, entering bool) WalkStatus
+	HTMLBlock        func(node *HTMLBlock, entering bool) WalkStatus
+	CodeBlock        func(node *CodeBlock, entering bool) WalkStatus
+	Softbreak        func(node *Softbreak, entering bool) WalkStatus
+	Hardbreak        func(node *Hardbreak, entering bool) WalkStatus
+	NonBlockingSpace func(node *NonBlockingSpace, entering bool) WalkStatus
+	Code             func(node *Code, entering bool) WalkStatus
+	HTMLSpan         func(node *HTMLSpan, entering bool) WalkStatus
+	Table            func(node *Table, entering bool) WalkStatus
+	TableCell        func(node *TableCell, entering bool) WalkStatus
+	TableHeader      func(node *TableHeader, entering bool) WalkStatus
+	TableBody        func(node *TableBody, entering bool) WalkStatus
+	TableRow         func(node *TableRow, entering bool) WalkStatus
+	TableFooter      func(node *TableFooter, entering bool) WalkStatus
+	Caption          func(node *Caption, entering bool) WalkStatus
+	CaptionFigure    func(node *CaptionFigure, entering bool) WalkStatus
+	Callout          func(node *Callout, entering bool) WalkStatus
+	Index            func(node *Index, entering bool) WalkStatus
+	Subscript        func(node *Subscript, entering bool) WalkStatus
+	Superscript      func(node *Superscript, entering bool) WalkStatus
+	Footnotes        func(node *Footnotes, entering bool) WalkStatus
+}
+
+// Visit calls the callback for the type of node
+func (v *TypedVisitor) Visit(node Node, entering bool) WalkStatus {
+	switch node := node.(type) {
+	case *Document:
+		if v.Document != nil {
+			return v.Document(node, entering)
+		}
+	case *DocumentMatter:
+		if v.DocumentMatter != nil {
+			return v.DocumentMatter(node, entering)
+		}
+	case *BlockQuote:
+		if v.BlockQuote != nil {
+			return v.BlockQuote(node, entering)
+		}
+	case *Aside:
+		if v.Aside != nil {
+			return v.Aside(node, entering)
+		}
+	case *Directive:
+		if v.Directive != nil {
+			return v.Directive(node, entering)
+		}
+	case *Component:
+		if v.Component != nil {
+			return v.Component(node, entering)
+		}
+	case *List:
+		if v.List != nil {
+			return v.List(node, entering)
+		}
+	case *ListItem:
+		if v.ListItem != nil {
+			return v.ListItem(node, entering)
+		}
+	case *Paragraph:
+		if v.Paragraph != nil {
+			return v.Paragraph(node, entering)
+		}
+	case *LineBlock:
+		if v.LineBlock != nil {
+			return v.LineBlock(node, entering)
+		}
+	case *Ruby:
+		if v.Ruby != nil {
+			return v.Ruby(node, entering)
+		}
+	case *Kbd:
+		if v.Kbd != nil {
+			return v.Kbd(node, entering)
+		}
+	case *Dfn:
+		if v.Dfn != nil {
+			return v.Dfn(node, entering)
+		}
+	case *Math:
+		if v.Math != nil {
+			return v.Math(node, entering)
+		}
+	case *MathBlock:
+		if v.MathBlock != nil {
+			return v.MathBlock(node, entering)
+		}
+	case *Heading:
+		if v.Heading != nil {
+			return v.Heading(node, entering)
+		}
+	case *HorizontalRule:
+		if v.HorizontalRule != nil {
+			return v.HorizontalRule(node, entering)
+		}
+	case *Emph:
+		if v.Emph != nil {
+			return v.Emph(node, entering)
+		}
+	case *Strong:
+		if v.Strong != nil {
+			return v.Strong(node, entering)
+		}
+	case *Del:
+		if v.Del != nil {
+			return v.Del(node, entering)
+		}
+	case *Link:
+		if v.Link != nil {
+			return v.Link(node, entering)
+		}
+	case *CrossReference:
+		if v.CrossReference != nil {
+			return v.CrossReference(node, entering)
+		}
+	case *Citation:
+		if v.Citation != nil {
+			return v.Citation(node, entering)
+		}
+	case *Image:
+		if v.Image != nil {
+			return v.Image(node, entering)
+		}
+	case *Text:
+		if v.Text != nil {
+			return v.Text(node, entering)
+		}
+	case *HTMLBlock:
+		if v.HTMLBlock != nil {
+			return v.HTMLBlock(node, entering)
+		}
+	case *CodeBlock:
+		if v.CodeBlock != nil {
+			return v.CodeBlock(node, entering)
+		}
+	case *Softbreak:
+		if v.Softbreak != nil {
+			return v.Softbreak(node, entering)
+		}
+	case *Hardbreak:
+		if v.Hardbreak != nil {
+			return v.Hardbreak(node, entering)
+		}
+	case *NonBlockingSpace:
+		if v.NonBlockingSpace != nil {
+			return v.NonBlockingSpace(node, entering)
+		}
+	case *Code:
+		if v.Code != nil {
+			return v.Code(node, entering)
+		}
+	case *HTMLSpan:
+		if v.HTMLSpan != nil {
+			return v.HTMLSpan(node, entering)
+		}
+	case *Table:
+		if v.Table != nil {
+			return v.Table(node, entering)
+		}
+	case *TableCell:
+		if v.TableCell != nil {
+			return v.TableCell(node, entering)
+		}
+	case *TableHeader:
+		if v.TableHeader != nil {
+			return v.TableHeader(node, entering)
+		}
+	case *TableBody:
+		if v.TableBody != nil {
+			return v.TableBody(node, entering)
+		}
+	case *TableRow:
+		if v.TableRow != nil {
+			return v.TableRow(node, entering)
+		}
+	case *TableFooter:
+		if v.TableFooter != nil {
+			return v.TableFooter(node, entering)
+		}
+	case *Caption:
+		if v.Caption != nil {
+			return v.Caption(node, entering)
+		}
+	case *CaptionFigure:
+		if v.CaptionFigure != nil {
+			return v.CaptionFigure(node, entering)
+		}
+	case *Callout:
+		if v.Callout != nil {
+			return v.Callout(node, entering)
+		}
+	case *Index:
+		if v.Index != nil {
+			return v.Index(node, entering)
+		}
+	case *Subscript:
+		if v.Subscript != nil {
+			return v.Subscript(node, entering)
+		}
+	case *Superscript:
+		if v.Superscript != nil {
+			return v.Superscript(node, entering)
+		}
+	case *Footnotes:
+		if v.Footnotes != nil {
+			return v.Footnotes(node, entering)
+		}
+	}
+	if v.Default != nil {
+		return v.Default(node, entering)
+	}
+	return GoToNext
+}
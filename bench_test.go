@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Benchmarks of parsing and HTML rendering of documents of different sizes
+// and TestAllocs catching regressions of the number of allocations. Compare
+// runs with e.g.:
+//
+//	go test -run=NONE -bench=. -benchmem -count=10 > old.txt
+//	benchstat old.txt new.txt
+
+// benchCorpus is a document used by the benchmarks
+type benchCorpus struct {
+	name string
+	load func() ([]byte, error)
+}
+
+var benchCorpora = []benchCorpus{
+	// a typical README
+	{"readme", func() ([]byte, error) {
+		return ioutil.ReadFile("README.md")
+	}},
+	// a long document using most of the syntax
+	{"syntax", func() ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join("testdata", "Markdown Documentation - Syntax.text"))
+	}},
+	// a book-sized document of 1MB
+	{"book", loadBook},
+}
+
+// loadBook returns the reference tests concatenated into a 1MB document
+func loadBook() ([]byte, error) {
+	var buf bytes.Buffer
+	for buf.Len() < 1<<20 {
+		for _, name := range refFiles {
+			d, err := ioutil.ReadFile(filepath.Join("testdata", name+".text"))
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(d)
+			buf.WriteString("\n\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func newBenchParser() *parser.Parser {
+	return parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs | parser.Footnotes)
+}
+
+func newBenchRenderer() *html.Renderer {
+	return html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})
+}
+
+// runBenchCorpora runs fn as a sub-benchmark for each corpus
+func runBenchCorpora(b *testing.B, fn func(b *testing.B, d []byte)) {
+	for _, c := range benchCorpora {
+		d, err := c.load()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(c.name, func(b *testing.B) {
+			b.SetBytes(int64(len(d)))
+			b.ReportAllocs()
+			fn(b, d)
+		})
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	runBenchCorpora(b, func(b *testing.B, d []byte) {
+		for n := 0; n < b.N; n++ {
+			newBenchParser().Parse(d)
+		}
+	})
+}
+
+func BenchmarkParseArena(b *testing.B) {
+	runBenchCorpora(b, func(b *testing.B, d []byte) {
+		arena := &ast.Arena{}
+		for n := 0; n < b.N; n++ {
+			p := newBenchParser()
+			p.Opts.Arena = arena
+			p.Parse(d)
+			arena.Reset()
+		}
+	})
+}
+
+func BenchmarkRenderHTML(b *testing.B) {
+	runBenchCorpora(b, func(b *testing.B, d []byte) {
+		doc := newBenchParser().Parse(d)
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			benchResultAnchor = string(Render(doc, newBenchRenderer()))
+		}
+	})
+}
+
+func BenchmarkToHTML(b *testing.B) {
+	runBenchCorpora(b, func(b *testing.B, d []byte) {
+		for n := 0; n < b.N; n++ {
+			benchResultAnchor = string(ToHTML(d, newBenchParser(), newBenchRenderer()))
+		}
+	})
+}
+
+// TestAllocs fails if parsing or rendering the syntax corpus allocates
+// much more than expected. The limits are per KB of input, about twice the
+// current numbers so that they hold across Go versions, and catch only
+// regressions like allocating per byte. Benchmarks track the exact numbers.
+func TestAllocs(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("skipping allocation counts in short mode or with the race detector")
+	}
+	d, err := benchCorpora[1].load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb := float64(len(d)) / 1024
+	var doc ast.Node
+	arena := &ast.Arena{}
+	tests := []struct {
+		name  string
+		perKB float64
+		fn    func()
+	}{
+		{"parse", 100, func() {
+			doc = newBenchParser().Parse(d)
+		}},
+		{"parse with arena", 100, func() {
+			p := newBenchParser()
+			p.Opts.Arena = arena
+			doc = p.Parse(d)
+			arena.Reset()
+		}},
+		{"render", 25, func() {
+			Render(doc, newBenchRenderer())
+		}},
+	}
+	allocs := map[string]float64{}
+	for _, test := range tests {
+		test.fn()
+		n := testing.AllocsPerRun(10, test.fn)
+		allocs[test.name] = n
+		t.Logf("%s: %.0f allocs, %.1f per KB", test.name, n, n/kb)
+		if n/kb > test.perKB {
+			t.Errorf("%s: %.1f allocs per KB, want at most %.0f", test.name, n/kb, test.perKB)
+		}
+	}
+	// the arena saves about 40% of allocations
+	if ratio := allocs["parse with arena"] / allocs["parse"]; ratio > 0.8 {
+		t.Errorf("parse with arena: %.2f of allocs without it, want at most 0.8", ratio)
+	}
+}
@@ -1,6 +1,7 @@
 package markdown
 
 import (
+	"io"
 	"testing"
 
 	"github.com/gomarkdown/markdown/html"
@@ -37,6 +38,25 @@ func TestPrefixHeaderIdExtensionWithPrefixAndSuffix(t *testing.T) {
 	})
 }
 
+func TestHeadingLevelOffset(t *testing.T) {
+	tests := []string{
+		"# Header 1\n",
+		"<h2>Header 1</h2>\n",
+
+		"#### Header 4\n",
+		"<h4>Header 4</h4>\n",
+
+		"###### Header 6\n",
+		"<h4>Header 6</h4>\n",
+	}
+	doTestsParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			HeadingLevelOffset: 1,
+			MaxHeadingLevel:    4,
+		},
+	})
+}
+
 func TestPrefixAutoHeaderIdExtension(t *testing.T) {
 	tests := readTestFile2(t, "PrefixAutoHeaderIdExtension.tests")
 	doTestsBlock(t, tests, parser.AutoHeadingIDs)
@@ -91,6 +111,16 @@ func TestOrderedList(t *testing.T) {
 	doTestsBlock(t, tests, 0)
 }
 
+func TestFancyLists(t *testing.T) {
+	tests := readTestFile2(t, "FancyLists.tests")
+	doTestsBlock(t, tests, parser.FancyLists|parser.OrderedListStart)
+}
+
+func TestTaskLists(t *testing.T) {
+	tests := readTestFile2(t, "TaskLists.tests")
+	doTestsBlock(t, tests, parser.CommonExtensions|parser.TaskLists)
+}
+
 func TestDefinitionList(t *testing.T) {
 	tests := readTestFile2(t, "DefinitionList.tests")
 	doTestsBlock(t, tests, parser.DefinitionLists)
@@ -126,6 +156,22 @@ func TestTable(t *testing.T) {
 	doTestsBlock(t, tests, parser.Tables)
 }
 
+func TestTableHeaderless(t *testing.T) {
+	tests := readTestFile2(t, "TableHeaderless.tests")
+	doTestsBlock(t, tests, parser.Tables)
+}
+
+func TestTableWrapper(t *testing.T) {
+	tests := readTestFile2(t, "TableWrapper.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.Tables,
+		Flags:      html.UseXHTML | html.TableColumns,
+		RendererOptions: html.RendererOptions{
+			TableWrapperClass: "table-wrapper",
+		},
+	})
+}
+
 func TestUnorderedListWith_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 	tests := readTestFile2(t, "UnorderedListWith_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK.tests")
 	doTestsBlock(t, tests, parser.NoEmptyLineBeforeBlock)
@@ -141,6 +187,59 @@ func TestFencedCodeBlock_EXTENSION_NO_EMPTY_LINE_BEFORE_BLOCK(t *testing.T) {
 	doTestsBlock(t, tests, parser.FencedCode|parser.NoEmptyLineBeforeBlock)
 }
 
+func TestCodeLineNumbers(t *testing.T) {
+	tests := readTestFile2(t, "CodeLineNumbers.tests")
+	doTestsBlock(t, tests, parser.FencedCode)
+
+	tests = readTestFile2(t, "CodeLineNumbersFlag.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.FencedCode,
+		Flags:      html.CodeLineNumbers,
+	})
+}
+
+func TestQuoteAttribution(t *testing.T) {
+	tests := readTestFile2(t, "QuoteAttribution.tests")
+	doTestsBlock(t, tests, parser.QuoteAttribution)
+
+	tests = readTestFile2(t, "NoQuoteFigures.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.QuoteAttribution,
+		Flags:      html.NoQuoteFigures,
+	})
+}
+
+func TestQuoteCite(t *testing.T) {
+	tests := readTestFile2(t, "QuoteCite.tests")
+	doTestsBlock(t, tests, parser.QuoteCite|parser.QuoteAttribution|parser.Attributes)
+
+	tests = []string{
+		"> %cite: javascript:alert(1)\n> Quoted\n",
+		"<blockquote>\n<p>Quoted</p>\n</blockquote>\n",
+	}
+	doTestsParam(t, tests, TestParams{extensions: parser.QuoteCite, Flags: html.Safelink})
+}
+
+func TestImageFigures(t *testing.T) {
+	tests := readTestFile2(t, "ImageFigures.tests")
+	doTestsBlock(t, tests, parser.ImageFigures)
+}
+
+func TestDirectives(t *testing.T) {
+	tests := readTestFile2(t, "Directives.tests")
+	doTestsBlock(t, tests, parser.Directives|parser.FencedCode)
+}
+
+func TestLineBlocks(t *testing.T) {
+	tests := readTestFile2(t, "LineBlocks.tests")
+	doTestsBlock(t, tests, parser.LineBlocks|parser.Tables)
+}
+
+func TestComponents(t *testing.T) {
+	tests := readTestFile2(t, "Components.tests")
+	doTestsBlock(t, tests, parser.Components|parser.FencedCode)
+}
+
 func TestMathBlock(t *testing.T) {
 	tests := readTestFile2(t, "MathBlock.tests")
 	doTestsBlock(t, tests, parser.CommonExtensions)
@@ -171,6 +270,121 @@ func TestTOC(t *testing.T) {
 	doTestsParam(t, tests, TestParams{
 		Flags: html.UseXHTML | html.TOC,
 	})
+
+	tests = readTestFile2(t, "TOCLevels.tests")
+	doTestsParam(t, tests, TestParams{
+		Flags: html.UseXHTML | html.TOC,
+		RendererOptions: html.RendererOptions{
+			TOCLevelMin: 2,
+			TOCLevelMax: 3,
+		},
+	})
+
+	tests = readTestFile2(t, "TOCHeadingIDs.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.AutoHeadingIDs | parser.HeadingIDs,
+		Flags:      html.TOC,
+		RendererOptions: html.RendererOptions{
+			HeadingIDPrefix: "doc-",
+			TOCListTag:      "ol",
+			TOCLabel:        "Table of contents",
+			TOCClass:        "toc",
+		},
+	})
+
+	tests = []string{
+		"# Über uns\n\n## *Kontakt*\n",
+		`<nav>
+
+<ul>
+<li><a href="#uber-uns">Über uns</a>
+<ul>
+<li><a href="#kontakt"><em>Kontakt</em></a></li>
+</ul></li>
+</ul>
+
+</nav>
+
+<h1 id="uber-uns">Über uns</h1>
+
+<h2 id="kontakt"><em>Kontakt</em></h2>
+`,
+	}
+	doTestsParam(t, tests, TestParams{
+		Flags: html.TOC,
+		RendererOptions: html.RendererOptions{
+			SlugifyFunc: parser.NewSlugify(parser.SlugOptions{Transliterate: true}),
+		},
+	})
+}
+
+func TestNumberHeadings(t *testing.T) {
+	tests := readTestFile2(t, "NumberHeadings.tests")
+	doTestsParam(t, tests, TestParams{
+		Flags: html.UseXHTML | html.TOC | html.NumberHeadings,
+	})
+
+	tests = readTestFile2(t, "NumberHeadingsStart.tests")
+	doTestsParam(t, tests, TestParams{
+		Flags: html.NumberHeadings,
+		RendererOptions: html.RendererOptions{
+			HeadingNumberStartLevel: 2,
+			HeadingNumberSeparator:  "-",
+		},
+	})
+}
+
+func TestCrossReferences(t *testing.T) {
+	tests := readTestFile2(t, "CrossReferences.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions | parser.CrossReferences,
+		Flags:      html.UseXHTML | html.NumberHeadings,
+	})
+
+	tests = []string{
+		"# Title\n\n## Install `go` {#install}\n\nSee [@install].\n",
+		"<h1>Title</h1>\n\n<h2 id=\"doc-install\">Install <code>go</code></h2>\n\n<p>See <a href=\"#doc-install\">Install go</a>.</p>\n",
+	}
+	doTestsParam(t, tests, TestParams{
+		extensions:      parser.CommonExtensions | parser.CrossReferences,
+		RendererOptions: html.RendererOptions{HeadingIDPrefix: "doc-"},
+	})
+
+	// citations of heading IDs become cross references
+	tests = []string{
+		"# Title\n\n## Install {#install}\n\nSee [@install] and [@knuth].\n",
+		"<h1>Title</h1>\n\n<h2 id=\"install\"><span class=\"secno\">1</span> Install</h2>\n\n<p>See <a href=\"#install\">sec. 1</a> and <cite class=\"informative\"><a href=\"#knuth\"><sup>[knuth]</sup></a></cite>.</p>\n",
+	}
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions | parser.Citations | parser.CrossReferences,
+		Flags:      html.NumberHeadings,
+		RendererOptions: html.RendererOptions{
+			HeadingNumberStartLevel: 2,
+			CrossReferenceFormat:    "sec. %s",
+		},
+	})
+}
+
+func TestHashHeadingIDs(t *testing.T) {
+	tests := readTestFile2(t, "HashHeadingIDs.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.AutoHeadingIDs,
+		Flags:      html.HashHeadingIDs,
+	})
+}
+
+func TestWrapSections(t *testing.T) {
+	tests := readTestFile2(t, "WrapSections.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.AutoHeadingIDs | parser.Footnotes,
+		Flags:      html.WrapSections,
+	})
+
+	tests = []string{
+		"# One\n\ntext\n",
+		"<section>\n<h1>One</h1>\n\n<p>text</p>\n\n</section>\n",
+	}
+	doTestsParam(t, tests, TestParams{Flags: html.WrapSections})
 }
 
 func TestCompletePage(t *testing.T) {
@@ -178,6 +392,137 @@ func TestCompletePage(t *testing.T) {
 	doTestsParam(t, tests, TestParams{Flags: html.UseXHTML | html.CompletePage})
 }
 
+func TestXHTMLStrict(t *testing.T) {
+	tests := readTestFile2(t, "XHTMLStrict.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions,
+		Flags:      html.CommonFlags | html.CompletePage | html.XHTMLStrict,
+		RendererOptions: html.RendererOptions{
+			Title: `"Title"`,
+		},
+	})
+}
+
+func TestCompletePageHead(t *testing.T) {
+	tests := readTestFile2(t, "CompletePageHead.tests")
+	headHook := func(w io.Writer) {
+		io.WriteString(w, "  <script src=\"app.js\"></script>\n")
+	}
+	opts := html.RendererOptions{
+		Lang:     "en",
+		HeadHook: headHook,
+	}
+	doTestsParam(t, tests, TestParams{Flags: html.CompletePage, RendererOptions: opts})
+}
+
+func TestCompletePageAccessible(t *testing.T) {
+	tests := []string{
+		"# Title\n\ntext\n",
+		`<!DOCTYPE html>
+<html>
+<head>
+  <title></title>
+  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go">
+  <meta charset="utf-8">
+</head>
+<body>
+
+<a class="skip-link" href="#main">Skip to content</a>
+<main id="main">
+
+<h1>Title</h1>
+
+<p>text</p>
+
+</main>
+</body>
+</html>
+`,
+	}
+	doTestsParam(t, tests, TestParams{Flags: html.CompletePage | html.Accessible})
+
+	// the ID of <main> doesn't clash with heading IDs
+	tests = []string{
+		"# Main\n",
+		`<!DOCTYPE html>
+<html>
+<head>
+  <title></title>
+  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go">
+  <meta charset="utf-8">
+</head>
+<body>
+
+<a class="skip-link" href="#contenu">Aller au contenu</a>
+<main id="contenu">
+
+<h1 id="main">Main</h1>
+
+</main>
+</body>
+</html>
+`,
+
+		"# Contenu\n",
+		`<!DOCTYPE html>
+<html>
+<head>
+  <title></title>
+  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go">
+  <meta charset="utf-8">
+</head>
+<body>
+
+<a class="skip-link" href="#contenu">Aller au contenu</a>
+<main id="contenu">
+
+<h1 id="contenu-1">Contenu</h1>
+
+</main>
+</body>
+</html>
+`,
+	}
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.AutoHeadingIDs,
+		Flags:      html.CompletePage | html.Accessible,
+		RendererOptions: html.RendererOptions{
+			SkipLinkText: "Aller au contenu",
+			MainID:       "contenu",
+		},
+	})
+}
+
+func TestCompletePageMeta(t *testing.T) {
+	tests := readTestFile2(t, "CompletePageMeta.tests")
+	opts := html.RendererOptions{
+		Meta: map[string]string{
+			"title":       "Fish & Chips",
+			"description": `How to make "proper" chips`,
+			"canonical":   "https://example.com/chips?a=1&b=2",
+			"author":      "Jane",
+		},
+	}
+	doTestsParam(t, tests, TestParams{Flags: html.CompletePage, RendererOptions: opts})
+
+	tests = readTestFile2(t, "CompletePageMetaMapping.tests")
+	opts = html.RendererOptions{
+		Title: "Site title",
+		Meta: map[string]string{
+			"name":    "Post",
+			"summary": "Summary",
+			"cover":   "/cover.png",
+			"url":     "https://example.com/post",
+		},
+		MetaMapping: &html.MetaMapping{
+			Title:       "name",
+			Description: "summary",
+			Image:       "cover",
+		},
+	}
+	doTestsParam(t, tests, TestParams{Flags: html.CompletePage, RendererOptions: opts})
+}
+
 func TestSpaceHeadings(t *testing.T) {
 	tests := readTestFile2(t, "SpaceHeadings.tests")
 	doTestsParam(t, tests, TestParams{extensions: parser.SpaceHeadings})
@@ -194,3 +539,35 @@ func TestLists(t *testing.T) {
 	exts := parser.CommonExtensions
 	doTestsParam(t, tests, TestParams{extensions: exts})
 }
+
+func TestCompactOutput(t *testing.T) {
+	tests := readTestFile2(t, "CompactOutput.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions | parser.Footnotes,
+		Flags:      html.CompactOutput,
+	})
+}
+
+func TestLooseLists(t *testing.T) {
+	tests := readTestFile2(t, "LooseLists.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions,
+		Flags:      html.LooseLists,
+	})
+}
+
+func TestTightLists(t *testing.T) {
+	tests := readTestFile2(t, "TightLists.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions,
+		Flags:      html.TightLists,
+	})
+}
+
+func TestIndentedOutput(t *testing.T) {
+	tests := readTestFile2(t, "IndentedOutput.tests")
+	doTestsParam(t, tests, TestParams{
+		extensions: parser.CommonExtensions | parser.Footnotes,
+		Flags:      html.IndentedOutput,
+	})
+}
@@ -0,0 +1,14 @@
+/*
+Package chat implements a renderer of parsed markdown document to the
+markup of chat services: Slack mrkdwn or Telegram MarkdownV2.
+
+	renderer := chat.NewRenderer(chat.RendererOptions{Flavor: chat.Telegram})
+	doc := markdown.Parse(md, nil)
+	text := markdown.Render(doc, renderer)
+
+Chat markup is much simpler than markdown: headings are rendered as bold
+text, lists use bullets or numbers written as text, table rows are rendered
+as lines of cells separated by " | " and raw HTML is dropped. Text is escaped
+according to the rules of the flavor.
+*/
+package chat
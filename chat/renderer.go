@@ -0,0 +1,366 @@
+package chat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Flavor is the markup dialect of a chat service.
+type Flavor int
+
+// Supported flavors.
+const (
+	Slack    Flavor = iota // Slack mrkdwn, see https://api.slack.com/reference/surfaces/formatting
+	Telegram               // Telegram MarkdownV2, see https://core.telegram.org/bots/api#markdownv2-style
+)
+
+// RendererOptions is a collection of parameters of the chat renderer.
+type RendererOptions struct {
+	Flavor Flavor
+
+	// Bullet is the text prepended to items of unordered lists, defaults
+	// to "• "
+	Bullet string
+}
+
+// Renderer renders to Slack or Telegram markup.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	opts RendererOptions
+}
+
+// NewRenderer returns a chat renderer
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Bullet == "" {
+		opts.Bullet = "• "
+	}
+	return &Renderer{
+		opts: opts,
+	}
+}
+
+// telegramSpecial are the characters that must be escaped with a backslash
+// in Telegram MarkdownV2 text
+const telegramSpecial = "_*[]()~`>#+-=|{}.!\\"
+
+// escape writes text escaped for the flavor
+func (r *Renderer) escape(w io.Writer, text []byte) {
+	var buf bytes.Buffer
+	for _, c := range text {
+		switch {
+		case r.opts.Flavor == Telegram && strings.IndexByte(telegramSpecial, c) >= 0:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case r.opts.Flavor == Slack && c == '&':
+			buf.WriteString("&amp;")
+		case r.opts.Flavor == Slack && c == '<':
+			buf.WriteString("&lt;")
+		case r.opts.Flavor == Slack && c == '>':
+			buf.WriteString("&gt;")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+// escapeCode writes text of code spans and blocks. Telegram only requires
+// escaping of ` and \ in code, Slack escapes are the same as in text.
+func (r *Renderer) escapeCode(w io.Writer, text []byte) {
+	if r.opts.Flavor == Slack {
+		r.escape(w, text)
+		return
+	}
+	var buf bytes.Buffer
+	for _, c := range text {
+		if c == '`' || c == '\\' {
+			buf.WriteByte('\\')
+		}
+		buf.WriteByte(c)
+	}
+	w.Write(buf.Bytes())
+}
+
+// escapeURL writes destination of a link
+func (r *Renderer) escapeURL(w io.Writer, dest []byte) {
+	var buf bytes.Buffer
+	for _, c := range dest {
+		switch {
+		case r.opts.Flavor == Telegram && (c == ')' || c == '\\'):
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case r.opts.Flavor == Slack && c == '|':
+			buf.WriteString("%7C")
+		case r.opts.Flavor == Slack && c == '>':
+			buf.WriteString("%3E")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+func (r *Renderer) outs(w io.Writer, s string) {
+	io.WriteString(w, s)
+}
+
+// blockSeparator separates block node from the preceding block: by an
+// empty line or, inside list items, by a new line.
+func (r *Renderer) blockSeparator(w io.Writer, node ast.Node) {
+	if ast.GetPrevNode(node) == nil {
+		return
+	}
+	if _, ok := node.GetParent().(*ast.ListItem); ok {
+		r.outs(w, "\n")
+		return
+	}
+	r.outs(w, "\n\n")
+}
+
+// renderChildren renders children of node to a buffer
+func (r *Renderer) renderChildren(node ast.Node) []byte {
+	var buf bytes.Buffer
+	for _, child := range node.GetChildren() {
+		ast.WalkFunc(child, func(n ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(&buf, n, entering)
+		})
+	}
+	return buf.Bytes()
+}
+
+// prefixLines writes d with first prepended to the first line and prefix to
+// the following ones
+func prefixLines(w io.Writer, d []byte, first, prefix string) {
+	for i, line := range bytes.Split(d, []byte{'\n'}) {
+		if i == 0 {
+			io.WriteString(w, first)
+		} else {
+			io.WriteString(w, "\n"+prefix)
+		}
+		w.Write(line)
+	}
+}
+
+func (r *Renderer) blockQuote(w io.Writer, node *ast.BlockQuote) {
+	r.blockSeparator(w, node)
+	prefix := ">"
+	if r.opts.Flavor == Slack {
+		prefix = "> "
+	}
+	prefixLines(w, r.renderChildren(node), prefix, prefix)
+}
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem) {
+	if ast.GetPrevNode(node) != nil {
+		r.outs(w, "\n")
+	}
+	marker := r.opts.Bullet
+	list, _ := node.Parent.(*ast.List)
+	switch {
+	case node.ListFlags&ast.ListTypeTerm != 0:
+		marker = ""
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		marker = "    "
+	case node.ListFlags&ast.ListTypeOrdered != 0 && list != nil:
+		n := list.Start
+		if n == 0 {
+			n = 1
+		}
+		for _, child := range list.Children {
+			if child == node {
+				break
+			}
+			n++
+		}
+		marker = strconv.Itoa(n) + "."
+		if r.opts.Flavor == Telegram {
+			marker = strconv.Itoa(n) + `\.`
+		}
+		marker += " "
+	}
+	d := r.renderChildren(node)
+	if node.ListFlags&ast.ListTypeTerm != 0 {
+		r.outs(w, "*")
+		w.Write(d)
+		r.outs(w, "*")
+		return
+	}
+	prefixLines(w, d, marker, "    ")
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	r.blockSeparator(w, node)
+	r.outs(w, "```")
+	if r.opts.Flavor == Telegram {
+		lang := node.Language
+		if lang == nil && node.FenceAttrs == nil {
+			lang = node.Info
+		}
+		if i := bytes.IndexAny(lang, "\t "); i >= 0 {
+			lang = lang[:i]
+		}
+		r.escapeCode(w, lang)
+	}
+	r.outs(w, "\n")
+	r.escapeCode(w, bytes.TrimSuffix(node.Literal, []byte{'\n'}))
+	r.outs(w, "\n```")
+}
+
+// link renders a link with its text rendered as children
+func (r *Renderer) link(w io.Writer, dest []byte, entering bool) {
+	switch {
+	case r.opts.Flavor == Slack && entering:
+		r.outs(w, "<")
+		r.escapeURL(w, dest)
+		r.outs(w, "|")
+	case r.opts.Flavor == Slack:
+		r.outs(w, ">")
+	case entering:
+		r.outs(w, "[")
+	default:
+		r.outs(w, "](")
+		r.escapeURL(w, dest)
+		r.outs(w, ")")
+	}
+}
+
+func (r *Renderer) tableCell(w io.Writer, node *ast.TableCell, entering bool) {
+	if !entering || ast.GetPrevNode(node) == nil {
+		return
+	}
+	if r.opts.Flavor == Telegram {
+		r.outs(w, ` \| `)
+	} else {
+		r.outs(w, " | ")
+	}
+}
+
+// RenderNode renders a markdown node to chat markup
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+	case *ast.Text:
+		r.escape(w, node.Literal)
+	case *ast.Softbreak, *ast.Hardbreak:
+		r.outs(w, "\n")
+	case *ast.NonBlockingSpace:
+		r.outs(w, " ")
+	case *ast.Emph:
+		r.outs(w, "_")
+	case *ast.Strong:
+		r.outs(w, "*")
+	case *ast.Del:
+		r.outs(w, "~")
+	case *ast.BlockQuote:
+		if entering {
+			r.blockQuote(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Aside, *ast.Directive, *ast.Component:
+		if entering {
+			r.blockSeparator(w, node)
+		}
+	case *ast.Link:
+		if node.NoteID != 0 {
+			if entering {
+				r.escape(w, []byte(fmt.Sprintf("[%d]", node.NoteID)))
+			}
+			return ast.SkipChildren
+		}
+		r.link(w, node.Destination, entering)
+	case *ast.CrossReference:
+		// render the text of the reference
+	case *ast.Citation:
+		for _, c := range node.Destination {
+			r.escape(w, []byte("["+string(c)+"]"))
+		}
+	case *ast.Image:
+		r.link(w, node.Destination, entering)
+	case *ast.Code:
+		r.outs(w, "`")
+		r.escapeCode(w, node.Literal)
+		r.outs(w, "`")
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Caption, *ast.Paragraph, *ast.LineBlock:
+		if entering {
+			r.blockSeparator(w, node)
+		}
+	case *ast.CaptionFigure, *ast.Document, *ast.DocumentMatter, *ast.Footnotes:
+		// do nothing
+	case *ast.HTMLSpan, *ast.HTMLBlock:
+		// raw HTML has no meaning in chat
+	case *ast.Heading:
+		if entering {
+			r.blockSeparator(w, node)
+		}
+		r.outs(w, "*")
+	case *ast.HorizontalRule:
+		r.blockSeparator(w, node)
+		r.outs(w, "———")
+	case *ast.List:
+		if entering {
+			r.blockSeparator(w, node)
+		}
+	case *ast.ListItem:
+		if entering {
+			r.listItem(w, node)
+		}
+		return ast.SkipChildren
+	case *ast.Table:
+		if entering {
+			r.blockSeparator(w, node)
+		}
+	case *ast.TableCell:
+		r.tableCell(w, node, entering)
+	case *ast.TableHeader, *ast.TableBody, *ast.TableFooter:
+		if entering && ast.GetPrevNode(node) != nil {
+			r.outs(w, "\n")
+		}
+	case *ast.TableRow:
+		if entering && ast.GetPrevNode(node) != nil {
+			r.outs(w, "\n")
+		}
+	case *ast.Math:
+		r.outs(w, "`")
+		r.escapeCode(w, node.Literal)
+		r.outs(w, "`")
+	case *ast.MathBlock:
+		if entering {
+			r.blockSeparator(w, node)
+			r.outs(w, "```\n")
+			r.escapeCode(w, bytes.TrimSuffix(node.Literal, []byte{'\n'}))
+			r.outs(w, "\n```")
+		}
+	case *ast.Callout:
+		r.escape(w, []byte("<"+string(node.ID)+">"))
+	case *ast.Index:
+		// there is no in-text representation.
+	case *ast.Kbd:
+		// render the text only
+	case *ast.Dfn:
+		r.outs(w, "_")
+	case *ast.Ruby:
+		if !entering {
+			r.escape(w, []byte("("+string(node.Annotation)+")"))
+		}
+	case *ast.Subscript, *ast.Superscript:
+		r.escape(w, node.AsLeaf().Literal)
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader does nothing, chat messages have no header
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+}
+
+// RenderFooter does nothing, chat messages have no footer
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+}
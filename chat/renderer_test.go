@@ -0,0 +1,51 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+const input = `# Release 1.2!
+
+Some *emphasis* & **strong** ~~old~~ text with a [link](http://example.com/a_b) and ` + "`a\\b`" + `.
+
+- one
+- two
+  1. nested
+
+> quoted
+> text
+
+` + "```go" + `
+if a < b {}
+` + "```" + `
+`
+
+func render(input string, opts RendererOptions) string {
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	return string(markdown.ToHTML([]byte(input), p, NewRenderer(opts)))
+}
+
+func TestSlack(t *testing.T) {
+	exp := "*Release 1.2!*\n\n" +
+		"Some _emphasis_ &amp; *strong* ~old~ text with a <http://example.com/a_b|link> and `a\\b`.\n\n" +
+		"• one\n• two\n    1. nested\n\n" +
+		"> quoted\n> text\n\n" +
+		"```\nif a &lt; b {}\n```"
+	if got := render(input, RendererOptions{Flavor: Slack}); got != exp {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", input, exp, got)
+	}
+}
+
+func TestTelegram(t *testing.T) {
+	exp := "*Release 1\\.2\\!*\n\n" +
+		"Some _emphasis_ & *strong* ~old~ text with a [link](http://example.com/a_b) and `a\\\\b`\\.\n\n" +
+		"• one\n• two\n    1\\. nested\n\n" +
+		">quoted\n>text\n\n" +
+		"```go\nif a < b {}\n```"
+	if got := render(input, RendererOptions{Flavor: Telegram}); got != exp {
+		t.Errorf("\nInput   [%#v]\nExpected[%#v]\nGot     [%#v]\n", input, exp, got)
+	}
+}
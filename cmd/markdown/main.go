@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/chat"
+	"github.com/gomarkdown/markdown/docbook"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/man"
+	"github.com/gomarkdown/markdown/md"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// This converts markdown files (or stdin) to HTML or other formats.
+// Usage: markdown [flags] [markdown-file ...]
+
+var (
+	flagFormat       = flag.String("format", "html", "output format: html, md, man, docbook, slack or telegram")
+	flagOutput       = flag.String("o", "", "output file, defaults to stdout")
+	flagExt          = flag.String("ext", "CommonExtensions", "comma-separated parser extensions, e.g. CommonExtensions,Footnotes,AutoHeadingIDs")
+	flagTOC          = flag.Bool("toc", false, "generate a table of contents (html)")
+	flagCompletePage = flag.Bool("complete-page", false, "generate a complete HTML page with <html>, <head> and <body> (html)")
+	flagCSS          = flag.String("css", "", "URL of a CSS file linked from the page (html, with -complete-page)")
+	flagTitle        = flag.String("title", "", "title of the document (html, docbook, man)")
+	flagUnsafe       = flag.Bool("unsafe", false, "render raw HTML in markdown, it's skipped by default (html)")
+	flagSmartypants  = flag.Bool("smartypants", false, "use smart punctuation (html)")
+	flagXHTML        = flag.Bool("xhtml", false, "generate XHTML instead of HTML (html)")
+)
+
+func usageAndExit(msg string) {
+	if msg != "" {
+		fmt.Fprintf(os.Stderr, "%s\n", msg)
+	}
+	fmt.Fprintf(os.Stderr, "Usage: markdown [flags] [markdown-file ...]\n")
+	fmt.Fprintf(os.Stderr, "Reads stdin if no file is given.\n\n")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
+
+func newRenderer(format string) (markdown.Renderer, error) {
+	switch format {
+	case "html":
+		flags := html.CommonFlags
+		if !*flagSmartypants {
+			flags = 0
+		}
+		if *flagTOC {
+			flags |= html.TOC
+		}
+		if *flagCompletePage {
+			flags |= html.CompletePage
+		}
+		if !*flagUnsafe {
+			flags |= html.SkipHTML
+		}
+		if *flagXHTML {
+			flags |= html.UseXHTML
+		}
+		return html.NewRenderer(html.RendererOptions{
+			Flags: flags,
+			Title: *flagTitle,
+			CSS:   *flagCSS,
+		}), nil
+	case "md":
+		return md.NewRenderer(), nil
+	case "man":
+		return man.NewRenderer(man.RendererOptions{Title: *flagTitle}), nil
+	case "docbook":
+		return docbook.NewRenderer(docbook.RendererOptions{Title: *flagTitle}), nil
+	case "slack":
+		return chat.NewRenderer(chat.RendererOptions{Flavor: chat.Slack}), nil
+	case "telegram":
+		return chat.NewRenderer(chat.RendererOptions{Flavor: chat.Telegram}), nil
+	}
+	return nil, fmt.Errorf("unknown format '%s'", format)
+}
+
+func readInput(fileNames []string) ([]byte, error) {
+	if len(fileNames) == 0 {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	var res []byte
+	for _, fileName := range fileNames {
+		d, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if len(res) > 0 && res[len(res)-1] != '\n' {
+			res = append(res, '\n')
+		}
+		res = append(res, d...)
+	}
+	return res, nil
+}
+
+func main() {
+	flag.Usage = func() { usageAndExit("") }
+	flag.Parse()
+
+	exts, err := parser.ParseExtensions(*flagExt)
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	r, err := newRenderer(*flagFormat)
+	if err != nil {
+		usageAndExit(err.Error())
+	}
+	d, err := readInput(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't read input, error: '%s'\n", err)
+		os.Exit(1)
+	}
+
+	p := parser.NewWithExtensions(exts)
+	res := markdown.ToHTML(d, p, r)
+
+	if *flagOutput == "" {
+		os.Stdout.Write(res)
+		return
+	}
+	if err := ioutil.WriteFile(*flagOutput, res, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't write '%s', error: '%s'\n", *flagOutput, err)
+		os.Exit(1)
+	}
+}
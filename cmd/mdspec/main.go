@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/gomarkdown/markdown/spec"
+)
+
+// This runs examples of CommonMark or GFM spec and prints which ones pass.
+// Usage: mdspec [-gfm] [-v] <spec.json>
+
+var (
+	flagGFM     = flag.Bool("gfm", false, "enable GFM extensions (tables, strikethrough, autolinks, task lists, tagfilter)")
+	flagVerbose = flag.Bool("v", false, "print markdown, expected and rendered HTML of failed examples")
+)
+
+func usageAndExit() {
+	fmt.Printf("Usage: mdspec [-gfm] [-v] <spec.json>\n")
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		usageAndExit()
+	}
+	fileName := flag.Arg(0)
+	f, err := os.Open(fileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't open '%s', error: '%s'\n", fileName, err)
+		os.Exit(1)
+	}
+	examples, err := spec.Load(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't read '%s', error: '%s'\n", fileName, err)
+		os.Exit(1)
+	}
+
+	exts := parser.NoExtensions
+	flags := html.UseXHTML
+	if *flagGFM {
+		exts = parser.Tables | parser.Strikethrough | parser.Autolink | parser.TaskLists
+		flags |= html.TagFilter
+	}
+	newParser := func() *parser.Parser {
+		return parser.NewWithExtensions(exts)
+	}
+	r := html.NewRenderer(html.RendererOptions{Flags: flags})
+
+	report := spec.Run(examples, newParser, r)
+	for _, res := range report.Results {
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("%s example %d (%s)\n", status, res.Example.Example, res.Section)
+		if !res.Passed && *flagVerbose {
+			fmt.Printf("markdown:\n%s\nexpected:\n%s\ngot:\n%s\n", res.Markdown, res.HTML, res.Got)
+		}
+	}
+	fmt.Printf("\npassed %d of %d examples (%.1f%%)\n", report.Passed(), len(report.Results), report.Percent())
+}
@@ -0,0 +1,115 @@
+//go:build js && wasm
+// +build js,wasm
+
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// This exposes markdown to HTML conversion to JavaScript, e.g. to preview
+// markdown in a browser exactly as it's rendered on the server.
+//
+// Build with:
+//
+//	GOOS=js GOARCH=wasm go build -o markdown.wasm ./cmd/wasm
+//
+// and load markdown.wasm with wasm_exec.js from $(go env GOROOT)/lib/wasm
+// (misc/wasm before Go 1.24).
+// It defines a global markdown object:
+//
+//	markdown.render(input, {extensions: "CommonExtensions,Footnotes", toc: true})
+//
+// returns HTML of markdown input. Options are optional:
+//
+//	extensions   comma-separated parser extensions, defaults to "CommonExtensions"
+//	toc          generate a table of contents
+//	completePage generate a complete HTML page
+//	title        title of the page (with completePage)
+//	css          URL of a CSS file linked from the page (with completePage)
+//	unsafe       render raw HTML in markdown, it's skipped by default
+//	smartypants  use smart punctuation
+//	xhtml        generate XHTML instead of HTML
+//
+// If options are invalid, e.g. an extension is unknown, or parsing fails,
+// an Error is returned instead of HTML.
+
+func optBool(opts js.Value, name string) bool {
+	v := opts.Get(name)
+	return v.Type() == js.TypeBoolean && v.Bool()
+}
+
+func optString(opts js.Value, name string, def string) string {
+	v := opts.Get(name)
+	if v.Type() != js.TypeString {
+		return def
+	}
+	return v.String()
+}
+
+func render(input string, opts js.Value) (string, error) {
+	if opts.Type() != js.TypeObject {
+		opts = js.Global().Get("Object").New()
+	}
+	exts, err := parser.ParseExtensions(optString(opts, "extensions", "CommonExtensions"))
+	if err != nil {
+		return "", err
+	}
+
+	var flags html.Flags
+	if optBool(opts, "smartypants") {
+		flags |= html.CommonFlags
+	}
+	if optBool(opts, "toc") {
+		flags |= html.TOC
+	}
+	if optBool(opts, "completePage") {
+		flags |= html.CompletePage
+	}
+	if !optBool(opts, "unsafe") {
+		flags |= html.SkipHTML
+	}
+	if optBool(opts, "xhtml") {
+		flags |= html.UseXHTML
+	}
+	r := html.NewRenderer(html.RendererOptions{
+		Flags: flags,
+		Title: optString(opts, "title", ""),
+		CSS:   optString(opts, "css", ""),
+	})
+	p := parser.NewWithExtensions(exts)
+	// a panic would end the program, making markdown.render unusable
+	p.Opts.RecoverFromPanics = true
+	doc := p.Parse([]byte(input))
+	if err := p.Err(); err != nil {
+		return "", err
+	}
+	return string(markdown.Render(doc, r)), nil
+}
+
+func main() {
+	renderFunc := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) == 0 {
+			return js.Global().Get("Error").New("markdown.render: missing input")
+		}
+		opts := js.Undefined()
+		if len(args) > 1 {
+			opts = args[1]
+		}
+		res, err := render(args[0].String(), opts)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return res
+	})
+	obj := js.Global().Get("Object").New()
+	obj.Set("render", renderFunc)
+	js.Global().Set("markdown", obj)
+
+	// keep the program running so that render can be called
+	select {}
+}
@@ -0,0 +1,16 @@
+/*
+Package docbook implements a renderer of parsed markdown document to DocBook 5
+XML, which can be processed by DocBook toolchains (e.g. to PDF or EPUB).
+
+	opts := docbook.RendererOptions{
+		Title: "User Guide",
+	}
+	renderer := docbook.NewRenderer(opts)
+	doc := markdown.Parse(md, nil)
+	xml := markdown.Render(doc, renderer)
+
+Headings start nested <section> elements, e.g. a level 2 heading following
+a level 1 heading starts a section inside the section of the level 1 heading.
+Raw HTML and horizontal rules have no DocBook equivalent and are dropped.
+*/
+package docbook
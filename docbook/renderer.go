@@ -0,0 +1,457 @@
+package docbook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// RendererOptions is a collection of parameters of the root element of
+// the DocBook document.
+type RendererOptions struct {
+	Root  string // Name of the root element, defaults to "article"
+	Title string // Title of the document, optional
+	Lang  string // Language of the document, emitted as xml:lang, optional
+}
+
+// Renderer renders to DocBook 5 XML.
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	opts RendererOptions
+
+	// levels of headings of currently open sections
+	sections []int
+}
+
+// NewRenderer returns a DocBook renderer
+func NewRenderer(opts RendererOptions) *Renderer {
+	if opts.Root == "" {
+		opts.Root = "article"
+	}
+	return &Renderer{
+		opts: opts,
+	}
+}
+
+var escaper = [256]string{
+	'&': "&amp;",
+	'<': "&lt;",
+	'>': "&gt;",
+	'"': "&quot;",
+}
+
+// escape writes text escaped for XML
+func escape(w io.Writer, text []byte) {
+	start := 0
+	for i, c := range text {
+		if esc := escaper[c]; esc != "" {
+			w.Write(text[start:i])
+			io.WriteString(w, esc)
+			start = i + 1
+		}
+	}
+	w.Write(text[start:])
+}
+
+func escapeString(s string) string {
+	var buf bytes.Buffer
+	escape(&buf, []byte(s))
+	return buf.String()
+}
+
+func (r *Renderer) outs(w io.Writer, s string) {
+	io.WriteString(w, s)
+}
+
+func (r *Renderer) outOneOf(w io.Writer, first bool, s1, s2 string) {
+	if first {
+		r.outs(w, s1)
+	} else {
+		r.outs(w, s2)
+	}
+}
+
+// closeSections closes open sections of headings with level >= level
+func (r *Renderer) closeSections(w io.Writer, level int) {
+	for len(r.sections) > 0 && r.sections[len(r.sections)-1] >= level {
+		r.sections = r.sections[:len(r.sections)-1]
+		r.outs(w, "</section>\n")
+	}
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	if !entering {
+		r.outs(w, "</title>\n")
+		return
+	}
+	r.closeSections(w, node.Level)
+	r.sections = append(r.sections, node.Level)
+	if node.HeadingID != "" {
+		r.outs(w, `<section xml:id="`+escapeString(node.HeadingID)+`">`+"\n")
+	} else {
+		r.outs(w, "<section>\n")
+	}
+	r.outs(w, "<title>")
+}
+
+func (r *Renderer) paragraph(w io.Writer, node *ast.Paragraph, entering bool) {
+	if item, ok := node.Parent.(*ast.ListItem); ok && item.ListFlags&ast.ListTypeTerm != 0 {
+		// a <term> can't contain paragraphs
+		return
+	}
+	r.outOneOf(w, entering, "<para>", "</para>\n")
+}
+
+func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
+	switch {
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		r.outOneOf(w, entering, "<variablelist>\n", "</variablelist>\n")
+	case node.ListFlags&ast.ListTypeOrdered != 0:
+		if !entering {
+			r.outs(w, "</orderedlist>\n")
+		} else if node.Start > 1 {
+			r.outs(w, `<orderedlist startingnumber="`+strconv.Itoa(node.Start)+`">`+"\n")
+		} else {
+			r.outs(w, "<orderedlist>\n")
+		}
+	default:
+		r.outOneOf(w, entering, "<itemizedlist>\n", "</itemizedlist>\n")
+	}
+}
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if node.ListFlags&ast.ListTypeTerm != 0 {
+		r.outOneOf(w, entering, "<varlistentry>\n<term>", "</term>\n")
+		return
+	}
+	// inline content, e.g. of footnotes, must be in a paragraph
+	wrap := !isBlock(ast.GetFirstChild(node))
+	if entering {
+		r.outs(w, "<listitem>")
+		if wrap {
+			r.outs(w, "<para>")
+		} else {
+			r.outs(w, "\n")
+		}
+		return
+	}
+	if wrap {
+		r.outs(w, "</para>")
+	}
+	r.outs(w, "</listitem>\n")
+	if node.ListFlags&ast.ListTypeDefinition != 0 {
+		next, _ := ast.GetNextNode(node).(*ast.ListItem)
+		if next == nil || next.ListFlags&ast.ListTypeTerm != 0 {
+			r.outs(w, "</varlistentry>\n")
+		}
+	}
+}
+
+func isBlock(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Paragraph, *ast.List, *ast.CodeBlock, *ast.BlockQuote, *ast.Aside,
+		*ast.Directive, *ast.Table, *ast.HTMLBlock, *ast.Heading, *ast.HorizontalRule,
+		*ast.MathBlock, *ast.CaptionFigure, *ast.LineBlock:
+		return true
+	}
+	return false
+}
+
+// directive renders containers named like admonitions (e.g. "warning") as
+// the admonition, titled with the arguments, and others as a sidebar
+func (r *Renderer) directive(w io.Writer, node *ast.Directive, entering bool) {
+	tag := "sidebar"
+	switch name := string(node.Name); name {
+	case "note", "tip", "important", "caution", "warning":
+		tag = name
+	}
+	if !entering {
+		r.outs(w, "</"+tag+">\n")
+		return
+	}
+	r.outs(w, "<"+tag)
+	if tag == "sidebar" {
+		r.outs(w, ` role="`)
+		escape(w, node.Name)
+		r.outs(w, `"`)
+	}
+	r.outs(w, ">\n")
+	if len(node.Args) > 0 {
+		r.outs(w, "<title>")
+		escape(w, node.Args)
+		r.outs(w, "</title>\n")
+	}
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	lang := node.Language
+	if lang == nil && node.FenceAttrs == nil {
+		lang = node.Info
+	}
+	if i := bytes.IndexAny(lang, "\t "); i >= 0 {
+		lang = lang[:i]
+	}
+	if len(lang) > 0 {
+		r.outs(w, `<programlisting language="`)
+		escape(w, lang)
+		r.outs(w, `">`)
+	} else {
+		r.outs(w, "<programlisting>")
+	}
+	escape(w, node.Literal)
+	r.outs(w, "</programlisting>\n")
+}
+
+func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) ast.WalkStatus {
+	if node.NoteID != 0 {
+		if entering {
+			r.outs(w, fmt.Sprintf("<superscript>%d</superscript>", node.NoteID))
+		}
+		return ast.SkipChildren
+	}
+	if entering {
+		r.outs(w, `<link xlink:href="`)
+		escape(w, node.Destination)
+		r.outs(w, `">`)
+	} else {
+		r.outs(w, "</link>")
+	}
+	return ast.GoToNext
+}
+
+func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) {
+	// an image on its own, e.g. in a figure, is a block
+	_, inFigure := node.Parent.(*ast.CaptionFigure)
+	tag := "inlinemediaobject"
+	if inFigure {
+		tag = "mediaobject"
+	}
+	if !entering {
+		r.outs(w, "</phrase></textobject></"+tag+">")
+		if inFigure {
+			r.outs(w, "\n")
+		}
+		return
+	}
+	r.outs(w, "<"+tag+`><imageobject><imagedata fileref="`)
+	escape(w, node.Destination)
+	r.outs(w, `"/></imageobject><textobject><phrase>`)
+}
+
+func (r *Renderer) table(w io.Writer, node *ast.Table, entering bool) {
+	if !entering {
+		r.outs(w, "</tgroup>\n</informaltable>\n")
+		return
+	}
+	cols := 0
+	row := ast.FindFirst(node, func(n ast.Node) bool {
+		_, ok := n.(*ast.TableRow)
+		return ok
+	})
+	if row != nil {
+		cols = len(row.GetChildren())
+	}
+	r.outs(w, "<informaltable>\n")
+	r.outs(w, `<tgroup cols="`+strconv.Itoa(cols)+`">`+"\n")
+}
+
+func (r *Renderer) tableCell(w io.Writer, node *ast.TableCell, entering bool) {
+	if !entering {
+		r.outs(w, "</entry>\n")
+		return
+	}
+	if align := node.Align.String(); align != "" {
+		r.outs(w, `<entry align="`+align+`">`)
+	} else {
+		r.outs(w, "<entry>")
+	}
+}
+
+func (r *Renderer) index(w io.Writer, node *ast.Index) {
+	r.outs(w, "<indexterm")
+	if node.Primary {
+		r.outs(w, ` significance="preferred"`)
+	}
+	r.outs(w, "><primary>")
+	escape(w, node.Item)
+	r.outs(w, "</primary>")
+	if len(node.Subitem) > 0 {
+		r.outs(w, "<secondary>")
+		escape(w, node.Subitem)
+		r.outs(w, "</secondary>")
+	}
+	r.outs(w, "</indexterm>")
+}
+
+// isKeyCombo returns true if node is a Kbd of a combination of keys
+func isKeyCombo(node ast.Node) bool {
+	if _, ok := node.(*ast.Kbd); !ok {
+		return false
+	}
+	for _, child := range node.GetChildren() {
+		if _, ok := child.(*ast.Kbd); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderNode renders a markdown node to DocBook
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+	case *ast.Text:
+		// a keycombo has only keycaps, without separators
+		if !isKeyCombo(node.Parent) {
+			escape(w, node.Literal)
+		}
+	case *ast.Softbreak, *ast.Hardbreak:
+		// DocBook has no line breaks
+		r.outs(w, "\n")
+	case *ast.NonBlockingSpace:
+		r.outs(w, "&#160;")
+	case *ast.Emph:
+		r.outOneOf(w, entering, "<emphasis>", "</emphasis>")
+	case *ast.Strong:
+		r.outOneOf(w, entering, `<emphasis role="strong">`, "</emphasis>")
+	case *ast.Del:
+		r.outOneOf(w, entering, `<emphasis role="strikethrough">`, "</emphasis>")
+	case *ast.BlockQuote:
+		r.outOneOf(w, entering, "<blockquote>\n", "</blockquote>\n")
+	case *ast.Aside:
+		r.outOneOf(w, entering, "<sidebar>\n", "</sidebar>\n")
+	case *ast.Directive:
+		r.directive(w, node, entering)
+	case *ast.Component:
+		// render the content only
+	case *ast.LineBlock:
+		// line breaks are preserved in a literallayout
+		r.outOneOf(w, entering, "<literallayout>", "</literallayout>\n")
+	case *ast.Link:
+		return r.link(w, node, entering)
+	case *ast.CrossReference:
+		if entering {
+			r.outs(w, `<xref linkend="`)
+			escape(w, node.Destination)
+			r.outs(w, `"/>`)
+		}
+		return ast.SkipChildren
+	case *ast.Citation:
+		for _, c := range node.Destination {
+			r.outs(w, "<citation>")
+			escape(w, c)
+			r.outs(w, "</citation>")
+		}
+	case *ast.Image:
+		r.image(w, node, entering)
+	case *ast.Code:
+		r.outs(w, "<literal>")
+		escape(w, node.Literal)
+		r.outs(w, "</literal>")
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Caption:
+		r.outOneOf(w, entering, `<para role="caption">`, "</para>\n")
+	case *ast.CaptionFigure:
+		r.outOneOf(w, entering, "<informalfigure>\n", "</informalfigure>\n")
+	case *ast.Document:
+		// do nothing
+	case *ast.Paragraph:
+		r.paragraph(w, node, entering)
+	case *ast.HTMLSpan, *ast.HTMLBlock:
+		// raw HTML has no meaning in DocBook
+	case *ast.Heading:
+		r.heading(w, node, entering)
+	case *ast.HorizontalRule:
+		// there is no DocBook equivalent
+	case *ast.List:
+		r.list(w, node, entering)
+	case *ast.ListItem:
+		r.listItem(w, node, entering)
+	case *ast.Table:
+		r.table(w, node, entering)
+	case *ast.TableCell:
+		r.tableCell(w, node, entering)
+	case *ast.TableHeader:
+		r.outOneOf(w, entering, "<thead>\n", "</thead>\n")
+	case *ast.TableBody:
+		r.outOneOf(w, entering, "<tbody>\n", "</tbody>\n")
+	case *ast.TableFooter:
+		r.outOneOf(w, entering, "<tfoot>\n", "</tfoot>\n")
+	case *ast.TableRow:
+		r.outOneOf(w, entering, "<row>\n", "</row>\n")
+	case *ast.Math:
+		r.outs(w, "<inlineequation><mathphrase>")
+		escape(w, node.Literal)
+		r.outs(w, "</mathphrase></inlineequation>")
+	case *ast.MathBlock:
+		if entering {
+			r.outs(w, "<informalequation><mathphrase>")
+			escape(w, node.Literal)
+			r.outs(w, "</mathphrase></informalequation>\n")
+		}
+	case *ast.DocumentMatter:
+		// do nothing
+	case *ast.Callout:
+		r.outs(w, "&lt;")
+		escape(w, node.ID)
+		r.outs(w, "&gt;")
+	case *ast.Index:
+		r.index(w, node)
+	case *ast.Kbd:
+		if isKeyCombo(node) {
+			r.outOneOf(w, entering, "<keycombo>", "</keycombo>")
+		} else {
+			r.outOneOf(w, entering, "<keycap>", "</keycap>")
+		}
+	case *ast.Dfn:
+		r.outOneOf(w, entering, "<firstterm>", "</firstterm>")
+	case *ast.Ruby:
+		// DocBook has no ruby, the annotation follows the base text
+		if !entering {
+			r.outs(w, "(")
+			escape(w, node.Annotation)
+			r.outs(w, ")")
+		}
+	case *ast.Subscript:
+		r.outs(w, "<subscript>")
+		escape(w, node.Literal)
+		r.outs(w, "</subscript>")
+	case *ast.Superscript:
+		r.outs(w, "<superscript>")
+		escape(w, node.Literal)
+		r.outs(w, "</superscript>")
+	case *ast.Footnotes:
+		if entering {
+			r.closeSections(w, 0)
+			r.sections = append(r.sections, 0)
+			r.outs(w, "<section>\n<title>Notes</title>\n")
+		}
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader writes the XML declaration and opens the root element
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+	r.sections = nil
+	r.outs(w, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	r.outs(w, "<"+r.opts.Root+` xmlns="http://docbook.org/ns/docbook" xmlns:xlink="http://www.w3.org/1999/xlink" version="5.0"`)
+	if r.opts.Lang != "" {
+		r.outs(w, ` xml:lang="`+escapeString(r.opts.Lang)+`"`)
+	}
+	r.outs(w, ">\n")
+	if r.opts.Title != "" {
+		r.outs(w, "<info><title>"+escapeString(r.opts.Title)+"</title></info>\n")
+	}
+}
+
+// RenderFooter closes open sections and the root element
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	r.closeSections(w, 0)
+	r.outs(w, "</"+r.opts.Root+">\n")
+}
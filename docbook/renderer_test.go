@@ -0,0 +1,111 @@
+package docbook
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestRenderer(t *testing.T) {
+	input := `# Intro {#intro}
+
+Some *emphasis* & **strong** text with a [link](http://example.com) and ` + "`code`" + `.
+
+## Details
+
+1. one
+2. two
+
+Term
+: definition
+
+` + "```go" + `
+if a < b {}
+` + "```" + `
+
+# Next
+
+| A | B |
+|---|--:|
+| 1 | 2 |
+`
+	exp := `<?xml version="1.0" encoding="UTF-8"?>
+<article xmlns="http://docbook.org/ns/docbook" xmlns:xlink="http://www.w3.org/1999/xlink" version="5.0">
+<info><title>Guide &amp; Co</title></info>
+<section xml:id="intro">
+<title>Intro</title>
+<para>Some <emphasis>emphasis</emphasis> &amp; <emphasis role="strong">strong</emphasis> text with a <link xlink:href="http://example.com">link</link> and <literal>code</literal>.</para>
+<section>
+<title>Details</title>
+<orderedlist>
+<listitem>
+<para>one</para>
+</listitem>
+<listitem>
+<para>two</para>
+</listitem>
+</orderedlist>
+<variablelist>
+<varlistentry>
+<term>Term</term>
+<listitem>
+<para>definition</para>
+</listitem>
+</varlistentry>
+</variablelist>
+<programlisting language="go">if a &lt; b {}
+</programlisting>
+</section>
+</section>
+<section>
+<title>Next</title>
+<informaltable>
+<tgroup cols="2">
+<thead>
+<row>
+<entry>A</entry>
+<entry align="right">B</entry>
+</row>
+</thead>
+<tbody>
+<row>
+<entry>1</entry>
+<entry align="right">2</entry>
+</row>
+</tbody>
+</tgroup>
+</informaltable>
+</section>
+</article>
+`
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	r := NewRenderer(RendererOptions{Title: "Guide & Co"})
+	got := string(markdown.ToHTML([]byte(input), p, r))
+	if got != exp {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", exp, got)
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	input := "# A\n\ntext[^1]\n\n[^1]: a *note*\n"
+	exp := `<?xml version="1.0" encoding="UTF-8"?>
+<article xmlns="http://docbook.org/ns/docbook" xmlns:xlink="http://www.w3.org/1999/xlink" version="5.0">
+<section>
+<title>A</title>
+<para>text<superscript>1</superscript></para>
+</section>
+<section>
+<title>Notes</title>
+<orderedlist>
+<listitem><para>a <emphasis>note</emphasis></para></listitem>
+</orderedlist>
+</section>
+</article>
+`
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Footnotes)
+	got := string(markdown.ToHTML([]byte(input), p, NewRenderer(RendererOptions{})))
+	if got != exp {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", exp, got)
+	}
+}
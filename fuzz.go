@@ -2,8 +2,11 @@
 
 package markdown
 
-// Fuzz is to be used by https://github.com/dvyukov/go-fuzz
+// Fuzz is to be used by https://github.com/dvyukov/go-fuzz. With Go 1.18+
+// use the fuzz targets of fuzz_test.go instead, e.g.:
+//
+//	go test -run=NONE -fuzz=FuzzParse
 func Fuzz(data []byte) int {
-	Parse(data, nil)
+	ToHTML(data, nil, nil)
 	return 0
 }
@@ -5,25 +5,28 @@ import (
 	"time"
 )
 
-// crashes found with go-fuzz
+// crashes found with go-fuzz, also seeds of fuzz targets in fuzz_test.go
+var crashInputs = []string{
+	": \n\n0\n00",
+	">>>0```\n\n:\n```",
+	">0```\n: \n\n0\n```",
+	">>>>0```\n\n:\n```",
+	"0\n\n:\n00",
+	">>0```\n\n:\n```",
+	"[0]:<",
+	">0\n>\n:\n00",
+	": : \n\n\t0\n00",
+	"0\n: : \n\n\t0\n00",
+	"0\n\n:\n00",
+	"0\n\n: [0]:<",
+	"[0]:<",
+	"<",
+	"[@]",
+	"[@a;]",
+}
 
 func TestCrash1(t *testing.T) {
-	tests := []string{
-		": \n\n0\n00",
-		">>>0```\n\n:\n```",
-		">0```\n: \n\n0\n```",
-		">>>>0```\n\n:\n```",
-		"0\n\n:\n00",
-		">>0```\n\n:\n```",
-		"[0]:<",
-		">0\n>\n:\n00",
-		": : \n\n\t0\n00",
-		"0\n: : \n\n\t0\n00",
-		"0\n\n:\n00",
-		"0\n\n: [0]:<",
-		"[0]:<",
-	}
-	for _, test := range tests {
+	for _, test := range crashInputs {
 		Parse([]byte(test), nil)
 	}
 }
@@ -0,0 +1,69 @@
+//go:build go1.18
+// +build go1.18
+
+package markdown
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gomarkdown/markdown/chat"
+	"github.com/gomarkdown/markdown/docbook"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/man"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// fuzzExtensions enables most of the syntax, to reach as much of the parser
+// as possible
+const fuzzExtensions = parser.CommonExtensions | parser.Footnotes | parser.Attributes |
+	parser.SuperSubscript | parser.Mmark | parser.MathJax | parser.FancyLists | parser.TaskLists |
+	parser.CrossReferences | parser.IndexTerms | parser.LineBlocks | parser.Ruby | parser.Kbd
+
+// addFuzzSeeds adds the crash inputs and the markdown test files as the
+// seed corpus of f
+func addFuzzSeeds(f *testing.F) {
+	for _, s := range crashInputs {
+		f.Add([]byte(s))
+	}
+	f.Add([]byte("[[[[[[\n\t: ]]]]]]\n\n: \n\n:(()"))
+	f.Add([]byte(":\x00\x00\x00\x01V\n>* \x00\x80e\n\t* \n\n:\t"))
+	f.Add([]byte("\xa2 \n\t: \n: "))
+	for _, name := range refFiles {
+		d, err := ioutil.ReadFile(filepath.Join("testdata", name+".text"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(d)
+	}
+}
+
+func newFuzzParser() *parser.Parser {
+	return parser.NewWithExtensions(fuzzExtensions)
+}
+
+func FuzzParse(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		newFuzzParser().Parse(data)
+	})
+}
+
+func FuzzRenderHTML(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opts := html.RendererOptions{Flags: html.CommonFlags | html.FootnoteReturnLinks | html.TOC}
+		ToHTML(data, newFuzzParser(), html.NewRenderer(opts))
+	})
+}
+
+func FuzzRenderers(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc := newFuzzParser().Parse(data)
+		Render(doc, man.NewRenderer(man.RendererOptions{}))
+		Render(doc, docbook.NewRenderer(docbook.RendererOptions{}))
+		Render(doc, chat.NewRenderer(chat.RendererOptions{}))
+	})
+}
@@ -0,0 +1,196 @@
+package markdown
+
+import (
+	"bytes"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// GlossaryOptions configures LinkGlossary
+type GlossaryOptions struct {
+	// Terms maps glossary terms to destinations of their links, e.g.
+	// "AST" to "/glossary#ast". Terms are matched as whole words, case
+	// sensitive.
+	Terms map[string]string
+
+	// Dfn marks terms with ast.Dfn nodes, e.g. <dfn> in HTML, instead of
+	// linking them
+	Dfn bool
+
+	// OptOutClass is the class of <span> and of blocks (see
+	// parser.Attributes) whose text isn't linked. If blank, "no-glossary"
+	// is used.
+	OptOutClass string
+}
+
+// LinkGlossary links the first occurrence of each glossary term in each
+// section of doc, which starts at a heading, to its destination. Text in
+// headings, links, images and code isn't linked, nor is text inside
+// <span class="no-glossary"> or in blocks with that class.
+func LinkGlossary(doc ast.Node, opts *GlossaryOptions) {
+	optOutClass := opts.OptOutClass
+	if optOutClass == "" {
+		optOutClass = "no-glossary"
+	}
+	terms := make([]string, 0, len(opts.Terms))
+	for term := range opts.Terms {
+		if term != "" {
+			terms = append(terms, term)
+		}
+	}
+	// longer terms first so that "syntax tree" wins over "syntax"
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+
+	type sectionText struct {
+		text    *ast.Text
+		section int
+	}
+	var texts []sectionText
+	section := 0
+	optOutSpans := 0 // depth of <span> nesting inside an opt-out span
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		if hasClass(node, optOutClass) {
+			return ast.SkipChildren
+		}
+		switch node := node.(type) {
+		case *ast.Heading:
+			section++
+			return ast.SkipChildren
+		case *ast.Link, *ast.Image, *ast.CodeBlock:
+			return ast.SkipChildren
+		case *ast.HTMLSpan:
+			optOutSpans = optOutSpanDepth(node.Literal, optOutSpans, optOutClass)
+		case *ast.Text:
+			if optOutSpans == 0 {
+				texts = append(texts, sectionText{node, section})
+			}
+		}
+		return ast.GoToNext
+	})
+
+	linked := map[int]map[string]bool{}
+	for _, t := range texts {
+		seen := linked[t.section]
+		if seen == nil {
+			seen = map[string]bool{}
+			linked[t.section] = seen
+		}
+		linkGlossaryText(t.text, terms, seen, opts)
+	}
+}
+
+// hasClass returns true if node has block attribute class
+func hasClass(node ast.Node, class string) bool {
+	attr := ast.GetAttribute(node)
+	if attr == nil {
+		return false
+	}
+	for _, c := range attr.Classes {
+		if string(c) == class {
+			return true
+		}
+	}
+	return false
+}
+
+// optOutSpanDepth returns the depth of <span> nesting inside an opt-out
+// span after raw HTML span
+func optOutSpanDepth(span []byte, depth int, class string) int {
+	switch {
+	case bytes.HasPrefix(span, []byte("</span")):
+		if depth > 0 {
+			depth--
+		}
+	case bytes.HasPrefix(span, []byte("<span")):
+		if depth > 0 {
+			depth++
+		} else if bytes.Contains(span, []byte(`class="`+class+`"`)) {
+			depth = 1
+		}
+	}
+	return depth
+}
+
+// linkGlossaryText splits text at the first occurrences of terms not in
+// seen and links them
+func linkGlossaryText(text *ast.Text, terms []string, seen map[string]bool, opts *GlossaryOptions) {
+	for {
+		start, term := findGlossaryTerm(text.Literal, terms, seen)
+		if term == "" {
+			return
+		}
+		seen[term] = true
+		end := start + len(term)
+		rest := &ast.Text{}
+		rest.Literal = text.Literal[end:]
+		termText := ast.NewText(term)
+		text.Literal = text.Literal[:start]
+
+		var node ast.Node
+		if opts.Dfn {
+			dfn := &ast.Dfn{}
+			ast.AppendChild(dfn, termText)
+			node = dfn
+		} else {
+			node = ast.NewLink(opts.Terms[term], "", termText)
+		}
+		ast.InsertAfter(text, node)
+		ast.InsertAfter(node, rest)
+		text = rest
+	}
+}
+
+// findGlossaryTerm returns the first occurrence in d of a whole-word term
+// not in seen
+func findGlossaryTerm(d []byte, terms []string, seen map[string]bool) (int, string) {
+	first, found := -1, ""
+	for _, term := range terms {
+		if seen[term] {
+			continue
+		}
+		for off := 0; off < len(d); {
+			i := bytes.Index(d[off:], []byte(term))
+			if i < 0 {
+				break
+			}
+			i += off
+			if first >= 0 && i >= first {
+				break
+			}
+			if isWordAt(d, i, i+len(term)) {
+				first, found = i, term
+				break
+			}
+			off = i + 1
+		}
+	}
+	return first, found
+}
+
+// isWordAt returns true if d[start:end] isn't part of a longer word
+func isWordAt(d []byte, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRune(d[:start])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if end < len(d) {
+		r, _ := utf8.DecodeRune(d[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/man"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestLinkGlossary(t *testing.T) {
+	input := `# Parsing
+
+The parser builds an AST, a syntax tree. Every AST node has a *syntax* type.
+Unlike ASTs, the ` + "`AST`" + ` in code or [an AST link](/x) isn't linked.
+
+<span class="no-glossary">The syntax of <b>the</b> AST</span> is skipped, syntax tree isn't.
+
+{.no-glossary}
+AST in a skipped block.
+
+# Rendering
+
+The AST is rendered.
+`
+	terms := map[string]string{
+		"AST":         "/glossary#ast",
+		"syntax tree": "/glossary#syntax-tree",
+		"syntax":      "/glossary#syntax",
+	}
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Attributes)
+	doc := Parse([]byte(input), p)
+	LinkGlossary(doc, &GlossaryOptions{Terms: terms})
+	got := string(Render(doc, html.NewRenderer(html.RendererOptions{})))
+	exp := `<h1>Parsing</h1>
+
+<p>The parser builds an <a href="/glossary#ast">AST</a>, a <a href="/glossary#syntax-tree">syntax tree</a>. Every AST node has a <em><a href="/glossary#syntax">syntax</a></em> type.
+Unlike ASTs, the <code>AST</code> in code or <a href="/x">an AST link</a> isn't linked.</p>
+
+<p><span class="no-glossary">The syntax of <b>the</b> AST</span> is skipped, syntax tree isn't.</p>
+
+<p class="no-glossary">AST in a skipped block.</p>
+
+<h1>Rendering</h1>
+
+<p>The <a href="/glossary#ast">AST</a> is rendered.</p>
+`
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+
+	doc = Parse([]byte("An AST.\n"), nil)
+	LinkGlossary(doc, &GlossaryOptions{Terms: terms, Dfn: true})
+	got = string(Render(doc, html.NewRenderer(html.RendererOptions{})))
+	if exp := "<p>An <dfn>AST</dfn>.</p>\n"; got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+
+	// terms are nodes rather than raw HTML
+	got = string(Render(doc, html.NewRenderer(html.RendererOptions{Flags: html.SkipHTML})))
+	if exp := "<p>An <dfn>AST</dfn>.</p>\n"; got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+	got = string(Render(doc, man.NewRenderer(man.RendererOptions{})))
+	if exp := "An \\fIAST\\fP."; !strings.Contains(got, exp) {
+		t.Errorf("\nExpected to contain [%#v]\nGot     [%#v]", exp, got)
+	}
+}
@@ -0,0 +1,29 @@
+package html
+
+import (
+	"bytes"
+	"sync"
+)
+
+// bufPool holds temporary buffers, reused to reduce garbage when rendering
+// many documents
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// buffers that grew larger than this aren't reused
+const maxPooledBufferSize = 64 << 10
+
+func getBuffer() *bytes.Buffer {
+	return bufPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
@@ -1,10 +1,15 @@
 package html
 
 import (
+	"bytes"
 	"html"
 	"io"
+	"strconv"
+	"unicode/utf8"
 )
 
+// Escaper maps the characters escaped by EscapeHTML to their escape
+// sequences.
 var Escaper = [256][]byte{
 	'&': []byte("&amp;"),
 	'<': []byte("&lt;"),
@@ -30,9 +35,81 @@ func EscapeHTML(w io.Writer, d []byte) {
 	}
 }
 
-func escLink(w io.Writer, text []byte) {
+// EscapeFlags select escaping done by EscapeHTMLFlags in addition to
+// EscapeHTML's.
+type EscapeFlags int
+
+// Escaping options, see RendererOptions.EscapeFlags.
+const (
+	EscapeSingleQuotes EscapeFlags = 1 << iota // Escape ' as &#39;
+	EscapeNonASCII                             // Replace non-ASCII characters with numeric character references, for ASCII-only output
+	EscapeSkipEntities                         // Don't escape the & of valid character references, e.g. &copy;, so they aren't escaped twice
+)
+
+// EscapeHTMLFlags writes html-escaped d to w. It escapes &, <, > and "
+// characters like EscapeHTML and adjusts the escaping according to flags.
+func EscapeHTMLFlags(w io.Writer, d []byte, flags EscapeFlags) {
+	if flags == 0 {
+		EscapeHTML(w, d)
+		return
+	}
+	var start, end int
+	n := len(d)
+	for end < n {
+		c := d[end]
+		escSeq := Escaper[c]
+		size := 1
+		switch {
+		case c == '&' && flags&EscapeSkipEntities != 0:
+			if l := entityLen(d[end:]); l > 0 {
+				escSeq = nil
+				size = l
+			}
+		case c == '\'' && flags&EscapeSingleQuotes != 0:
+			escSeq = []byte("&#39;")
+		case c >= utf8.RuneSelf && flags&EscapeNonASCII != 0:
+			var r rune
+			r, size = utf8.DecodeRune(d[end:])
+			escSeq = []byte("&#x" + strconv.FormatInt(int64(r), 16) + ";")
+		}
+		if escSeq != nil {
+			w.Write(d[start:end])
+			w.Write(escSeq)
+			start = end + size
+		}
+		end += size
+	}
+	if start < n {
+		w.Write(d[start:])
+	}
+}
+
+// entityLen returns the length of the character reference d starts with or
+// 0 if it doesn't start with one.
+func entityLen(d []byte) int {
+	// the longest named entity, &CounterClockwiseContourIntegral;, is 33
+	// bytes long
+	end := bytes.IndexByte(d, ';')
+	if end == -1 || end > 32 || !isEntity(d[:end+1]) {
+		return 0
+	}
+	return end + 1
+}
+
+func escLink(w io.Writer, text []byte, flags EscapeFlags) {
 	unesc := html.UnescapeString(string(text))
-	EscapeHTML(w, []byte(unesc))
+	EscapeHTMLFlags(w, []byte(unesc), flags&^EscapeSkipEntities)
+}
+
+// obfuscate writes every character of d as a decimal character reference
+func obfuscate(w io.Writer, d []byte) {
+	for _, r := range string(d) {
+		io.WriteString(w, "&#"+strconv.Itoa(int(r))+";")
+	}
+}
+
+func obfuscateLink(w io.Writer, link []byte) {
+	obfuscate(w, []byte(html.UnescapeString(string(link))))
 }
 
 // Escape writes the text to w, but skips the escape character.
@@ -48,3 +125,77 @@ func Escape(w io.Writer, text []byte) {
 		w.Write([]byte{text[i]})
 	}
 }
+
+// isEntity returns true if d is a single valid character reference: a
+// named entity known to the html package or a decimal (up to 7 digits) or
+// hexadecimal (up to 6 digits) numeric one, as defined by CommonMark.
+func isEntity(d []byte) bool {
+	n := len(d)
+	if n < 3 || d[0] != '&' || d[n-1] != ';' {
+		return false
+	}
+	name := d[1 : n-1]
+	if name[0] != '#' {
+		s := string(d)
+		return html.UnescapeString(s) != s
+	}
+	name = name[1:]
+	max, isDigit := 7, isdigit
+	if len(name) > 0 && (name[0] == 'x' || name[0] == 'X') {
+		name = name[1:]
+		max, isDigit = 6, isHexDigit
+	}
+	if len(name) == 0 || len(name) > max {
+		return false
+	}
+	for _, c := range name {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlEntities replaces named HTML entities in d, which are not defined in XML,
+// with the characters they stand for. Entities predefined in XML and numeric
+// entities are kept, & of unknown entities is escaped.
+func xmlEntities(d []byte) []byte {
+	if bytes.IndexByte(d, '&') == -1 {
+		return d
+	}
+	var buf bytes.Buffer
+	for len(d) > 0 {
+		i := bytes.IndexByte(d, '&')
+		if i == -1 {
+			buf.Write(d)
+			break
+		}
+		buf.Write(d[:i])
+		d = d[i:]
+		end := bytes.IndexByte(d, ';')
+		if end == -1 || end > 32 {
+			buf.WriteString("&amp;")
+			d = d[1:]
+			continue
+		}
+		entity := string(d[:end+1])
+		switch entity {
+		case "&amp;", "&lt;", "&gt;", "&quot;", "&apos;":
+			buf.WriteString(entity)
+		default:
+			unesc := html.UnescapeString(entity)
+			if entity[1] != '#' && unesc == entity {
+				// unknown entity
+				buf.WriteString("&amp;")
+				d = d[1:]
+				continue
+			}
+			if entity[1] != '#' {
+				entity = unesc
+			}
+			buf.WriteString(entity)
+		}
+		d = d[end+1:]
+	}
+	return buf.Bytes()
+}
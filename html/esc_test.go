@@ -0,0 +1,43 @@
+package html
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestXMLEntities(t *testing.T) {
+	tests := []string{
+		"abc", "abc",
+		"&ldquo;a&rdquo; &amp; &lt;b&gt;", "“a” &amp; &lt;b&gt;",
+		"&#160;&#x41;", "&#160;&#x41;",
+		"&nbsp;", " ",
+		"&bogus; & a", "&amp;bogus; &amp; a",
+	}
+	for i := 0; i < len(tests); i += 2 {
+		got := string(xmlEntities([]byte(tests[i])))
+		if got != tests[i+1] {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", tests[i], tests[i+1], got)
+		}
+	}
+}
+
+func TestEscapeHTMLFlags(t *testing.T) {
+	tests := []struct {
+		flags EscapeFlags
+		in    string
+		want  string
+	}{
+		{0, `<a href="x">it's & ü</a>`, "&lt;a href=&quot;x&quot;&gt;it's &amp; ü&lt;/a&gt;"},
+		{EscapeSingleQuotes, `"it's"`, "&quot;it&#39;s&quot;"},
+		{EscapeNonASCII, "ü😀 x", "&#xfc;&#x1f600; x"},
+		{EscapeSkipEntities, "&copy; &#65; &amp; & &bogus; &#xZZ;", "&copy; &#65; &amp; &amp; &amp;bogus; &amp;#xZZ;"},
+		{EscapeNonASCII | EscapeSkipEntities, "&copy;©", "&copy;&#xa9;"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		EscapeHTMLFlags(&buf, []byte(test.in), test.flags)
+		if got := buf.String(); got != test.want {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", test.in, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package html
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// HTMLPolicy decides which raw HTML in markdown is passed through to the
+// output. Raw HTML blocks and spans with elements or comments not allowed by
+// the policy are dropped, as are those with event handler attributes, e.g.
+// onclick, or with URLs, e.g. in href or src, with a scheme other than
+// http, https, ftp, mailto or tel.
+type HTMLPolicy struct {
+	AllowElements []string // Names of allowed elements (case-insensitive), e.g. "div" or "br"
+	AllowComments bool     // Allow comments like <!-- more -->
+}
+
+// allows returns true if all the tags and comments in raw HTML d are allowed.
+func (p *HTMLPolicy) allows(d []byte) bool {
+	for i := 0; i < len(d); i++ {
+		if d[i] != '<' {
+			continue
+		}
+		if bytes.HasPrefix(d[i:], []byte("<!--")) {
+			if !p.AllowComments {
+				return false
+			}
+			n := commentLen(d[i:])
+			if n < 0 {
+				return true
+			}
+			if n == 0 {
+				return false
+			}
+			i += n - 1
+			continue
+		}
+
+		j := i + 1
+		closing := j < len(d) && d[j] == '/'
+		if closing {
+			j++
+		}
+		if j < len(d) && (d[j] == '!' || d[j] == '?') {
+			// doctype, CDATA or processing instruction
+			return false
+		}
+		if j >= len(d) || !isLetter(d[j]) {
+			// not a tag, e.g. "a < b"
+			continue
+		}
+		start := j
+		for j < len(d) && !isTagNameEnd(d[j]) {
+			j++
+		}
+		if !p.allowsElement(string(d[start:j])) {
+			return false
+		}
+		end, ok := safeAttributes(d[j:])
+		if !ok && !closing {
+			return false
+		}
+		i = j + end - 1
+	}
+	return true
+}
+
+// isTagNameEnd returns true if c ends a tag or attribute name
+func isTagNameEnd(c byte) bool {
+	return isSpace(c) || c == '/' || c == '>'
+}
+
+func skipSpace(d []byte, i int) int {
+	for i < len(d) && isSpace(d[i]) {
+		i++
+	}
+	return i
+}
+
+// safeAttributes parses the attributes of a tag in d, following its name.
+// It returns the end of the tag, and false if an attribute is an event
+// handler, e.g. onclick, or a URL with a scheme not in policySchemes.
+func safeAttributes(d []byte) (int, bool) {
+	i := 0
+	for i < len(d) {
+		c := d[i]
+		switch {
+		case c == '>':
+			return i + 1, true
+		case isSpace(c) || c == '/':
+			i++
+			continue
+		}
+		start := i
+		for i < len(d) && !isTagNameEnd(d[i]) && d[i] != '=' {
+			i++
+		}
+		name := strings.ToLower(string(d[start:i]))
+		if strings.HasPrefix(name, "on") {
+			return i, false
+		}
+		j := skipSpace(d, i)
+		if j >= len(d) || d[j] != '=' {
+			continue
+		}
+		j = skipSpace(d, j+1)
+		if j >= len(d) {
+			return j, true
+		}
+		var value []byte
+		if q := d[j]; q == '"' || q == '\'' {
+			end := bytes.IndexByte(d[j+1:], q)
+			if end < 0 {
+				end = len(d) - j - 1
+			}
+			value = d[j+1 : j+1+end]
+			i = j + end + 2
+		} else {
+			i = j
+			for i < len(d) && !isSpace(d[i]) && d[i] != '>' {
+				i++
+			}
+			value = d[j:i]
+		}
+		if urlAttributes[name] && !isSafeURL(value) {
+			return i, false
+		}
+	}
+	return len(d), true
+}
+
+// urlAttributes are the attributes with URL values checked by HTMLPolicy
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true,
+	"poster": true, "cite": true, "background": true, "data": true,
+	"xlink:href": true,
+}
+
+// policySchemes are the URL schemes allowed by HTMLPolicy in attributes
+var policySchemes = []string{"http", "https", "ftp", "mailto", "tel"}
+
+// isSafeURL returns true if attribute value v, with character references
+// decoded, is a URL without a scheme or with one of policySchemes.
+// Browsers ignore control characters and spaces in schemes, so these
+// are dropped too.
+func isSafeURL(v []byte) bool {
+	u := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(string(v)))
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 || strings.IndexAny(u[:colon], "/?#") >= 0 {
+		return true
+	}
+	for _, scheme := range policySchemes {
+		if strings.EqualFold(u[:colon], scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *HTMLPolicy) allowsElement(name string) bool {
+	for _, el := range p.AllowElements {
+		if strings.EqualFold(el, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// commentLen returns the length of the comment at the start of d, 0 if it
+// isn't well-formed or -1 if it isn't closed. Browsers end comments early at
+// <!-->, <!---> and --!>, so markup following these would be live, and the
+// HTML spec also disallows <!-- and a trailing <!- in comments.
+func commentLen(d []byte) int {
+	if !bytes.HasPrefix(d, []byte("<!--")) {
+		return 0
+	}
+	end := bytes.Index(d[4:], []byte("-->"))
+	if end < 0 {
+		return -1
+	}
+	text := d[4 : 4+end]
+	if bytes.HasPrefix(text, []byte(">")) || bytes.HasPrefix(text, []byte("->")) ||
+		bytes.Contains(text, []byte("--!>")) || bytes.Contains(text, []byte("<!--")) ||
+		bytes.HasSuffix(text, []byte("<!-")) {
+		return 0
+	}
+	return 4 + end + 3
+}
+
+// htmlComments returns the well-formed comments in raw HTML d, separated by
+// newlines, or nil if there are none. A comment ends at the first -->, so
+// the markup between two comments isn't mistaken for a comment.
+func htmlComments(d []byte) []byte {
+	var res []byte
+	for {
+		start := bytes.Index(d, []byte("<!--"))
+		if start < 0 {
+			return res
+		}
+		n := commentLen(d[start:])
+		if n < 0 {
+			return res
+		}
+		if n == 0 {
+			// skip the malformed comment, it's markup to browsers
+			d = d[start+4:]
+			continue
+		}
+		if res != nil {
+			res = append(res, '\n')
+		}
+		res = append(res, d[start:start+n]...)
+		d = d[start+n:]
+	}
+}
+
+// tagFilterElements are the elements disallowed by GFM tagfilter extension
+var tagFilterElements = []string{
+	"title", "textarea", "style", "xmp", "iframe",
+	"noembed", "noframes", "script", "plaintext",
+}
+
+// filterTags returns raw HTML d with '<' of tags of tagFilterElements
+// replaced with &lt;, like GFM tagfilter extension does.
+func filterTags(d []byte) []byte {
+	var res []byte
+	last := 0
+	for i := 0; i < len(d); i++ {
+		if d[i] != '<' || !isFilteredTag(d[i+1:]) {
+			continue
+		}
+		res = append(res, d[last:i]...)
+		res = append(res, "&lt;"...)
+		last = i + 1
+	}
+	if res == nil {
+		return d
+	}
+	return append(res, d[last:]...)
+}
+
+// isFilteredTag returns true if d, following '<', starts an opening or
+// closing tag of one of tagFilterElements.
+func isFilteredTag(d []byte) bool {
+	if len(d) > 0 && d[0] == '/' {
+		d = d[1:]
+	}
+	for _, name := range tagFilterElements {
+		n := len(name)
+		if len(d) <= n || !bytes.EqualFold(d[:n], []byte(name)) {
+			continue
+		}
+		c := d[n]
+		if isSpace(c) || c == '>' || (c == '/' && len(d) > n+1 && d[n+1] == '>') {
+			return true
+		}
+	}
+	return false
+}
@@ -3,13 +3,17 @@ package html
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"io"
-	"regexp"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
 )
 
 // Flags control optional behavior of HTML renderer.
@@ -40,69 +44,300 @@ const (
 	SmartypantsAngledQuotes                   // Enable angled double quotes (with Smartypants) for double quotes rendering
 	SmartypantsQuotesNBSP                     // Enable « French guillemets » (with Smartypants)
 	TOC                                       // Generate a table of contents
+	NoopenerLinks                             // Only link with rel="noopener"
+	XHTMLStrict                               // Generate well-formed XHTML suitable for EPUB3 (implies UseXHTML)
+	HardWraps                                 // Render newlines inside paragraphs as line breaks, like GFM comments
+	CodeLineNumbers                           // Emit line numbers in code blocks
+	NoQuoteFigures                            // Render quote captions (e.g. parser.QuoteAttribution) inside <blockquote> instead of a <figure>
+	NumberHeadings                            // Prefix headings (and their TOC entries) with section numbers, e.g. 1.2.3
+	HashHeadingIDs                            // Make duplicate heading IDs unique with a hash of their enclosing headings instead of a counter
+	FootnoteARIA                              // Add aria-describedby and DPUB-ARIA roles to footnote references, list and return links
+	WrapSections                              // Wrap each top-level heading and the content up to the next heading of the same or higher level in a <section>
+	TableColumns                              // Emit a <colgroup> with a <col> per table column, styled with the column alignment
+	CompactOutput                             // Don't emit newlines between blocks, for byte-minimal output
+	IndentedOutput                            // Put each block on its own line, indented by two spaces for each enclosing block
+	PreserveEntities                          // Output valid character references in text, e.g. &copy; or &#x1F600;, as they are instead of escaping the &
+	SkipFootnotes                             // Skip footnote references and the list of footnotes
+	ObfuscateEmail                            // Encode mailto links and their text as character references to make harvesting addresses harder
+	LooseLists                                // Always wrap paragraphs in list items in <p> tags, regardless of blank lines between the items
+	TightLists                                // Never wrap paragraphs in list items in <p> tags, regardless of blank lines between the items
+	TagFilter                                 // Escape raw HTML tags disallowed by GFM tagfilter extension, e.g. <script> and <iframe>
+	Accessible                                // Add ARIA roles and labels to footnotes and a skip link to complete pages, implies FootnoteARIA
+	KeepHTMLComments                          // Keep comments, e.g. <!-- more -->, of raw HTML skipped by SkipHTML flag or HTMLPolicy
 
 	CommonFlags Flags = Smartypants | SmartypantsFractions | SmartypantsDashes | SmartypantsLatexDashes
 )
 
-var (
-	htmlTagRe = regexp.MustCompile("(?i)^" + htmlTag)
-)
+// AbsolutePrefixPolicy is a bit set of kinds of relative URLs prefixed with
+// RendererOptions.AbsolutePrefix.
+type AbsolutePrefixPolicy int
 
+// Kinds of relative URLs
 const (
-	htmlTag = "(?:" + openTag + "|" + closeTag + "|" + htmlComment + "|" +
-		processingInstruction + "|" + declaration + "|" + cdata + ")"
-	closeTag              = "</" + tagName + "\\s*[>]"
-	openTag               = "<" + tagName + attribute + "*" + "\\s*/?>"
-	attribute             = "(?:" + "\\s+" + attributeName + attributeValueSpec + "?)"
-	attributeValue        = "(?:" + unquotedValue + "|" + singleQuotedValue + "|" + doubleQuotedValue + ")"
-	attributeValueSpec    = "(?:" + "\\s*=" + "\\s*" + attributeValue + ")"
-	attributeName         = "[a-zA-Z_:][a-zA-Z0-9:._-]*"
-	cdata                 = "<!\\[CDATA\\[[\\s\\S]*?\\]\\]>"
-	declaration           = "<![A-Z]+" + "\\s+[^>]*>"
-	doubleQuotedValue     = "\"[^\"]*\""
-	htmlComment           = "<!---->|<!--(?:-?[^>-])(?:-?[^-])*-->"
-	processingInstruction = "[<][?].*?[?][>]"
-	singleQuotedValue     = "'[^']*'"
-	tagName               = "[A-Za-z][A-Za-z0-9-]*"
-	unquotedValue         = "[^\"'=<>`\\x00-\\x20]+"
+	PrefixRootRelative AbsolutePrefixPolicy = 1 << iota // "/path"
+	PrefixFragments                                     // "#id"
+	PrefixDotRelative                                   // "./path" and "../path"
 )
 
+// MetaMapping names the fields of RendererOptions.Meta used in the <head>
+// section of a complete page. Empty names aren't used.
+type MetaMapping struct {
+	Title       string // <title> (unless RendererOptions.Title is set) and og:title
+	Description string // <meta name="description"> and og:description
+	Canonical   string // <link rel="canonical"> and og:url
+	Image       string // og:image
+}
+
+// DefaultMetaMapping is the MetaMapping used if RendererOptions.MetaMapping
+// is nil
+var DefaultMetaMapping = MetaMapping{
+	Title:       "title",
+	Description: "description",
+	Canonical:   "canonical",
+	Image:       "image",
+}
+
 // RenderNodeFunc allows reusing most of Renderer logic and replacing
 // rendering of some nodes. If it returns false, Renderer.RenderNode
 // will execute its logic. If it returns true, Renderer.RenderNode will
 // skip rendering this node and will return WalkStatus
 type RenderNodeFunc func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
 
+// CitationResolverFunc returns HTML of the bibliography entry of a citation
+// key. If ok is false, the key is unknown and the citation isn't linked.
+type CitationResolverFunc func(key []byte) (entry []byte, ok bool)
+
+// FootnoteIDFunc returns the id of footnote number noteID, whose reference
+// in the source is ref, e.g. "a" for [^a]. The id is used in fn:id anchors
+// of footnotes and fnref:id anchors of their references.
+type FootnoteIDFunc func(noteID int, ref []byte) string
+
+// FootnoteFunc renders the reference ref to a footnote, whose content is
+// note (the item of the list of footnotes), e.g. as the footnote text in
+// parentheses.
+type FootnoteFunc func(w io.Writer, ref *ast.Link, note ast.Node)
+
+// LinkAttrsFunc returns values of rel and target attributes of a link
+// to dest. Empty values are not emitted.
+type LinkAttrsFunc func(dest []byte) (rel []string, target string)
+
+// LinkWrapperFunc returns the destination an external link to dest is
+// rendered with, e.g. dest routed through a redirect service, and
+// attributes added to the link as they are, e.g. rel="sponsored". Values
+// of a rel attribute are merged with rel values of LinkAttrsHook or flags.
+type LinkWrapperFunc func(dest []byte) (newDest []byte, extraAttrs []string)
+
+// ImageSizeFunc returns the width and height, in pixels, of the image at
+// dest, as given in markdown. If ok is false, the size is unknown.
+type ImageSizeFunc func(dest []byte) (w, h int, ok bool)
+
+// ImageSource is a <source> of a <picture>, e.g. a smaller or AVIF version
+// of an image
+type ImageSource struct {
+	Srcset string // e.g. "/img/cat-640.avif 640w, /img/cat-1280.avif 1280w"
+	Type   string // MIME type, e.g. "image/avif", optional
+	Media  string // media query, e.g. "(min-width: 800px)", optional
+	Sizes  string // e.g. "(max-width: 600px) 100vw, 50vw", optional
+}
+
+// ImageSourcesFunc returns sources of the image at dest, as given in
+// markdown. If it returns any, the image is rendered as a <picture> with
+// the sources followed by the <img> as a fallback. URLs in Srcset are
+// used as they are, without AbsolutePrefix.
+type ImageSourcesFunc func(dest []byte) []ImageSource
+
+// URLEmbedFunc returns HTML embedding the content at url, e.g. an iframe of
+// a video or a card of a tweet. If ok is false, url isn't embedded.
+type URLEmbedFunc func(url []byte) (embed []byte, ok bool)
+
+// DiagramFunc renders a diagram from src of a fenced code block in one of
+// RendererOptions.DiagramLanguages, e.g. as <div class="mermaid"> or as
+// inline SVG. If it returns false, the code block is rendered as usual.
+type DiagramFunc func(w io.Writer, lang string, src []byte) bool
+
 // RendererOptions is a collection of supplementary parameters tweaking
 // the behavior of various parts of HTML renderer.
 type RendererOptions struct {
 	// Prepend this text to each relative URL.
 	AbsolutePrefix string
+	// Prepend this text to each relative image URL instead of
+	// AbsolutePrefix, e.g. a CDN base URL.
+	ImageAbsolutePrefix string
+	// AbsolutePrefixPolicy selects the kinds of relative URLs prefixed with
+	// AbsolutePrefix and ImageAbsolutePrefix. If 0, it's
+	// PrefixRootRelative | PrefixFragments.
+	AbsolutePrefixPolicy AbsolutePrefixPolicy
+	// AllowedLinkProtocols is a list of (case-insensitive) URL prefixes of
+	// links and images that are considered safe if Safelink flag is set.
+	// If nil, DefaultLinkProtocols is used. Other protocols can be
+	// opted into e.g. "tel:", "xmpp:" or "data:image/png;".
+	AllowedLinkProtocols []string
+	// ElementClasses maps names of elements to classes added to them, e.g.
+	// "table" to "table table-striped", to style the output with CSS
+	// frameworks. Classes of block attributes follow them.
+	ElementClasses map[string]string
+	// EscapeFlags adjust escaping of text, titles and attribute values,
+	// e.g. EscapeNonASCII for ASCII-only output. If 0, &, <, > and " are
+	// escaped like EscapeHTML does.
+	EscapeFlags EscapeFlags
 	// Add this text to each footnote anchor, to ensure uniqueness.
 	FootnoteAnchorPrefix string
+	// FootnoteIDFunc, if set, creates ids of footnote anchors instead of
+	// the default FootnoteAnchorPrefix followed by the slugified reference,
+	// e.g. numbers to keep footnote text out of URLs.
+	FootnoteIDFunc FootnoteIDFunc
+	// SlugifyFunc, if set, creates the IDs the renderer derives from text:
+	// of footnote anchors if FootnoteIDFunc isn't set, and of headings
+	// without an ID listed in the table of contents, instead of toc_N. Use
+	// the parser.Options.SlugifyFunc for consistent IDs, e.g.
+	// parser.NewSlugify(parser.SlugOptions{Transliterate: true}).
+	SlugifyFunc parser.SlugifyFunc
+	// FootnoteHook, if set, is called for every footnote reference instead
+	// of rendering a link to the footnote, and the list of footnotes isn't
+	// rendered. Allows e.g. inlining footnotes in feeds, where fragment
+	// links don't resolve.
+	FootnoteHook FootnoteFunc
 	// Show this text inside the <a> tag for a footnote return link, if the
 	// FootnoteReturnLinks flag is enabled. If blank, the string
 	// <sup>[return]</sup> is used.
 	FootnoteReturnLinkContents string
+	// FootnoteReturnLinkLabel is the aria-label of footnote return links if
+	// Accessible flag is set. If blank, "Back to content" is used.
+	FootnoteReturnLinkLabel string
+	// SkipLinkText is the text of the skip link added to complete pages if
+	// Accessible flag is set. If blank, "Skip to content" is used.
+	SkipLinkText string
+	// MainID is the ID of the <main> element the skip link points to. It's
+	// made unique among heading IDs. If blank, "main" is used.
+	MainID string
 	// CitationFormatString defines how a citation is rendered. If blnck, the string
 	// <sup>[%s]</sup> is used. Where %s will be substituted with the citation target.
 	CitationFormatString string
+	// CitationResolver, if set, resolves citations to bibliography entries.
+	// Entries of all the cited keys are rendered as a list of references at
+	// the end of the document, in order of first citation.
+	CitationResolver CitationResolverFunc
 	// If set, add this text to the front of each Heading ID, to ensure uniqueness.
 	HeadingIDPrefix string
 	// If set, add this text to the back of each Heading ID, to ensure uniqueness.
 	HeadingIDSuffix string
-
-	Title string // Document title (used if CompletePage is set)
+	// HeadingLevelOffset is added to the level of each heading, e.g. with
+	// offset 1 "# foo" is rendered as <h2>. Useful for embedding fragments.
+	HeadingLevelOffset int
+	// MaxHeadingLevel is the deepest heading level rendered, deeper headings
+	// are clamped to it. If 0 (or more than 6), it's 6.
+	MaxHeadingLevel int
+	// TOCLevelMin and TOCLevelMax limit the levels of headings listed in
+	// the table of contents (used if TOC is set), e.g. 2 and 3 to list only
+	// <h2> and <h3> headings. If 0, they are 1 and 6.
+	TOCLevelMin int
+	TOCLevelMax int
+	// TOCListTag is the tag of the (nested) lists of the table of contents,
+	// "ul" or "ol". If blank, "ul" is used.
+	TOCListTag string
+	// TOCLabel and TOCClass, if set, are the aria-label and class attributes
+	// of the <nav> element wrapping the table of contents, e.g.
+	// "Table of contents" and "toc".
+	TOCLabel string
+	TOCClass string
+	// TableWrapperClass, if set, wraps each table in a <div> of this class,
+	// e.g. "table-wrapper" to make tables scroll horizontally with CSS.
+	TableWrapperClass string
+	// HorizontalRuleHTML, HardbreakHTML and SoftbreakHTML, if set, are
+	// written as is instead of the default markup of thematic breaks, hard
+	// line breaks and soft line breaks, e.g. `<hr class="divider">`,
+	// "<br/>\n" and "&#10;". Attributes of thematic breaks are ignored.
+	HorizontalRuleHTML string
+	HardbreakHTML      string
+	SoftbreakHTML      string
+	// HeadingNumberStartLevel is the level of headings numbered with a
+	// single number if NumberHeadings flag is set, e.g. 2 to leave the <h1>
+	// title unnumbered. Shallower headings are not numbered. If 0, it's 1.
+	HeadingNumberStartLevel int
+	// HeadingNumberSeparator separates parts of section numbers if
+	// NumberHeadings flag is set. If blank, "." is used.
+	HeadingNumberSeparator string
+	// CrossReferenceFormat is the text of cross references to numbered
+	// headings (see parser.CrossReferences) if NumberHeadings flag is set,
+	// with %s replaced by the section number. If blank, "Section %s" is
+	// used. Without NumberHeadings, the text of the heading is used.
+	CrossReferenceFormat string
+
+	Title string // Document title (used if CompletePage is set). If blank, the first line of the title block is used (see parser.Titleblock)
 	CSS   string // Optional CSS file URL (used if CompletePage is set)
 	Icon  string // Optional icon file URL (used if CompletePage is set)
 	Head  []byte // Optional head data injected in the <head> section (used if CompletePage is set)
+	Lang  string // Optional language of the document, emitted as <html lang> (used if CompletePage is set)
+	XMLNS string // XML namespace of <html> in XHTML output, defaults to http://www.w3.org/1999/xhtml (used if CompletePage is set)
+
+	// Meta are metadata fields of the document, e.g. from its front matter,
+	// used for <title>, description, canonical link and Open Graph tags
+	// (used if CompletePage is set). MetaMapping names the fields used for
+	// each; if nil, DefaultMetaMapping is used.
+	Meta        map[string]string
+	MetaMapping *MetaMapping
+
+	// if set, called at the end of the <head> section (used if CompletePage
+	// is set). Allows adding meta tags, stylesheets, scripts etc.
+	HeadHook func(w io.Writer)
 
 	Flags Flags // Flags allow customizing this renderer's behavior
 
+	// SmartypantsOptions, if set, configures smart punctuation substitutions
+	// (used if Smartypants flag is set) instead of SmartypantsFractions,
+	// SmartypantsDashes, SmartypantsLatexDashes, SmartypantsAngledQuotes and
+	// SmartypantsQuotesNBSP flags. Allows e.g. German or Russian quotes.
+	SmartypantsOptions *SmartypantsOptions
+
 	// if set, called at the start of RenderNode(). Allows replacing
 	// rendering of some nodes
 	RenderNodeHook RenderNodeFunc
 
+	// RenderNodeHooks are called, in order, after RenderNodeHook until one
+	// of them handles the node. Allows independent libraries (e.g. syntax
+	// highlighting and emoji) to each install a hook, see also AddHook.
+	RenderNodeHooks []RenderNodeFunc
+
+	// NodeRenderers are called, in order, for nodes RenderNode doesn't
+	// know, e.g. ast.CustomData nodes of parser extensions, until one of
+	// them handles the node. RenderNode panics on a node none of them
+	// handles.
+	NodeRenderers []RenderNodeFunc
+
+	// if set, called for every link to decide its rel and target attributes
+	// instead of using NofollowLinks, NoreferrerLinks, NoopenerLinks and
+	// HrefTargetBlank flags. Allows e.g. treating internal and external
+	// links differently.
+	LinkAttrsHook LinkAttrsFunc
+
+	// if set, called for every external link to change its destination and
+	// add attributes, e.g. to track outbound links
+	LinkWrapperHook LinkWrapperFunc
+
+	// if set, called for every image without width and height attributes
+	// to emit them, which prevents layout shift while the page loads
+	ImageSizeHook ImageSizeFunc
+
+	// if set, called for every image to render it as a <picture> with
+	// multiple sources, e.g. responsive sizes or AVIF and WebP versions
+	ImageSourcesHook ImageSourcesFunc
+
+	// if set, called for every paragraph containing only a bare link, e.g.
+	// a YouTube URL, to replace the paragraph with the returned HTML
+	URLEmbedHook URLEmbedFunc
+
+	// HTMLPolicy, if set, decides which raw HTML blocks and spans are
+	// rendered instead of SkipHTML flag. Allows e.g. keeping <!-- more -->
+	// comments while dropping scripts.
+	HTMLPolicy *HTMLPolicy
+
+	// if set, called for fenced code blocks in one of DiagramLanguages
+	// instead of rendering them as <pre><code>
+	DiagramHook DiagramFunc
+	// DiagramLanguages is a list of (case-insensitive) fence languages
+	// passed to DiagramHook. If nil, DefaultDiagramLanguages is used.
+	DiagramLanguages []string
+
 	// Comments is a list of comments the renderer should detect when
 	// parsing code blocks and detecting callouts.
 	Comments [][]byte
@@ -122,19 +357,55 @@ type Renderer struct {
 
 	// Track heading IDs to prevent ID collision in a single generation.
 	headingIDs map[string]int
+	// IDs of the last heading of each level, used by HashHeadingIDs
+	sectionIDs []string
+	// id attributes of headings, shared by the headings and the TOC
+	headingAnchors map[*ast.Heading]string
+	// levels of headings of the open sections if WrapSections flag is set
+	sectionLevels []int
+
+	// section numbers of headings if NumberHeadings flag is set, computed
+	// when the first heading is rendered
+	headingNumbers map[*ast.Heading]string
+
+	// numbers of footnote items, computed once per list of footnotes
+	footnoteNumbers map[*ast.ListItem]int
 
 	lastOutputLen int
-	disableTags   int
+
+	// true if the image being rendered is wrapped in <picture>
+	inPicture bool
+
+	// paragraph replaced with an embed by URLEmbedHook
+	embedded *ast.Paragraph
+
+	// for each open container block, true if a block was written in it,
+	// if IndentedOutput flag is set
+	indentLevels []bool
 
 	sr *SPRenderer
 
+	// scratch is re-used for building attribute values to avoid allocating
+	// a new buffer for every link
+	scratch bytes.Buffer
+
 	documentMatter ast.DocumentMatters // keep track of front/main/back matter.
+
+	// bibliography entries of cited keys, in order of first citation
+	references    [][]byte
+	referenceKeys map[string]bool
 }
 
 // NewRenderer creates and configures an Renderer object, which
 // satisfies the Renderer interface.
 func NewRenderer(opts RendererOptions) *Renderer {
 	// configure the rendering engine
+	if opts.Flags&XHTMLStrict != 0 {
+		opts.Flags |= UseXHTML
+	}
+	if opts.XMLNS == "" {
+		opts.XMLNS = "http://www.w3.org/1999/xhtml"
+	}
 	closeTag := ">"
 	if opts.Flags&UseXHTML != 0 {
 		closeTag = " />"
@@ -143,9 +414,24 @@ func NewRenderer(opts RendererOptions) *Renderer {
 	if opts.FootnoteReturnLinkContents == "" {
 		opts.FootnoteReturnLinkContents = `<sup>[return]</sup>`
 	}
+	if opts.FootnoteReturnLinkLabel == "" {
+		opts.FootnoteReturnLinkLabel = "Back to content"
+	}
+	if opts.SkipLinkText == "" {
+		opts.SkipLinkText = "Skip to content"
+	}
+	if opts.MainID == "" {
+		opts.MainID = "main"
+	}
 	if opts.CitationFormatString == "" {
 		opts.CitationFormatString = `<sup>[%s]</sup>`
 	}
+	if opts.AllowedLinkProtocols == nil {
+		opts.AllowedLinkProtocols = DefaultLinkProtocols
+	}
+	if opts.DiagramLanguages == nil {
+		opts.DiagramLanguages = DefaultDiagramLanguages
+	}
 	if opts.Generator == "" {
 		opts.Generator = `  <meta name="GENERATOR" content="github.com/gomarkdown/markdown markdown processor for Go`
 	}
@@ -156,72 +442,48 @@ func NewRenderer(opts RendererOptions) *Renderer {
 		closeTag:   closeTag,
 		headingIDs: make(map[string]int),
 
-		sr: NewSmartypantsRenderer(opts.Flags),
+		sr: newSmartypantsRenderer(opts),
 	}
 }
 
-func isHTMLTag(tag []byte, tagname string) bool {
-	found, _ := findHTMLTagPos(tag, tagname)
-	return found
-}
-
-// Look for a character, but ignore it when it's in any kind of quotes, it
-// might be JavaScript
-func skipUntilCharIgnoreQuotes(html []byte, start int, char byte) int {
-	inSingleQuote := false
-	inDoubleQuote := false
-	inGraveQuote := false
-	i := start
-	for i < len(html) {
-		switch {
-		case html[i] == char && !inSingleQuote && !inDoubleQuote && !inGraveQuote:
-			return i
-		case html[i] == '\'':
-			inSingleQuote = !inSingleQuote
-		case html[i] == '"':
-			inDoubleQuote = !inDoubleQuote
-		case html[i] == '`':
-			inGraveQuote = !inGraveQuote
-		}
-		i++
+func newSmartypantsRenderer(opts RendererOptions) *SPRenderer {
+	if opts.SmartypantsOptions != nil {
+		return NewSmartypantsRendererWithOptions(*opts.SmartypantsOptions)
 	}
-	return start
+	return NewSmartypantsRenderer(opts.Flags)
 }
 
-func findHTMLTagPos(tag []byte, tagname string) (bool, int) {
-	i := 0
-	if i < len(tag) && tag[0] != '<' {
-		return false, -1
-	}
-	i++
-	i = skipSpace(tag, i)
-
-	if i < len(tag) && tag[i] == '/' {
-		i++
-	}
-
-	i = skipSpace(tag, i)
-	j := 0
-	for ; i < len(tag); i, j = i+1, j+1 {
-		if j >= len(tagname) {
-			break
-		}
-
-		if strings.ToLower(string(tag[i]))[0] != tagname[j] {
-			return false, -1
-		}
-	}
-
-	if i == len(tag) {
-		return false, -1
-	}
+// Clone returns a new Renderer with the same options as r but with its own
+// per-document state. A Renderer is not safe for concurrent use, so
+// a configured Renderer can be cloned for each goroutine (e.g. for each
+// request in a web server) instead of being re-created from options.
+func (r *Renderer) Clone() *Renderer {
+	return NewRenderer(r.opts)
+}
 
-	rightAngle := skipUntilCharIgnoreQuotes(tag, i, '>')
-	if rightAngle >= i {
-		return true, rightAngle
-	}
+// AddHook adds hook to the end of RenderNodeHooks of r. It's called only
+// for nodes not handled by RenderNodeHook and earlier hooks.
+func (r *Renderer) AddHook(hook RenderNodeFunc) {
+	n := len(r.opts.RenderNodeHooks)
+	// don't append to a slice shared with a clone of r
+	r.opts.RenderNodeHooks = append(r.opts.RenderNodeHooks[:n:n], hook)
+}
 
-	return false, -1
+// reset clears the state accumulated while rendering a document so that
+// the renderer can be re-used for the next one.
+func (r *Renderer) reset() {
+	r.headingIDs = make(map[string]int)
+	r.sectionIDs = nil
+	r.headingAnchors = nil
+	r.sectionLevels = nil
+	r.headingNumbers = nil
+	r.footnoteNumbers = nil
+	r.lastOutputLen = 0
+	r.indentLevels = nil
+	r.documentMatter = ast.DocumentMatterNone
+	r.references = nil
+	r.referenceKeys = nil
+	r.sr = newSmartypantsRenderer(r.opts)
 }
 
 func isRelativeLink(link []byte) (yes bool) {
@@ -272,48 +534,144 @@ func (r *Renderer) ensureUniqueHeadingID(id string) string {
 	return id
 }
 
-func (r *Renderer) addAbsPrefix(link []byte) []byte {
-	if r.opts.AbsolutePrefix != "" && isRelativeLink(link) && link[0] != '.' {
-		newDest := r.opts.AbsolutePrefix
-		if link[0] != '/' {
-			newDest += "/"
+// hashHeadingID returns the ID of heading, with a hash of the IDs of the
+// enclosing headings appended if HashHeadingIDs flag is set and the ID is
+// already used. Unlike a counter, the hash doesn't depend on preceding
+// sections, so anchors stay stable when unrelated sections are added or
+// removed.
+func (r *Renderer) hashHeadingID(heading *ast.Heading) string {
+	id := heading.HeadingID
+	if r.opts.Flags&HashHeadingIDs == 0 {
+		return id
+	}
+	level := heading.Level
+	if level < 1 {
+		level = 1
+	}
+	for len(r.sectionIDs) < level {
+		r.sectionIDs = append(r.sectionIDs, "")
+	}
+	r.sectionIDs = r.sectionIDs[:level]
+	r.sectionIDs[level-1] = id
+	if _, found := r.headingIDs[id]; !found {
+		return id
+	}
+	h := fnv.New32a()
+	io.WriteString(h, strings.Join(r.sectionIDs, "/"))
+	return fmt.Sprintf("%s-%08x", id, h.Sum32())
+}
+
+// headingAnchor returns the id attribute of heading: its ID made unique in
+// the document, with HeadingIDPrefix and HeadingIDSuffix. It's computed once
+// so that the table of contents links to the ID of the rendered heading.
+func (r *Renderer) headingAnchor(heading *ast.Heading) string {
+	if id, ok := r.headingAnchors[heading]; ok {
+		return id
+	}
+	id := r.hashHeadingID(heading)
+	id = r.ensureUniqueHeadingID(id)
+	id = r.opts.HeadingIDPrefix + id + r.opts.HeadingIDSuffix
+	if r.headingAnchors == nil {
+		r.headingAnchors = map[*ast.Heading]string{}
+	}
+	r.headingAnchors[heading] = id
+	return id
+}
+
+func (r *Renderer) addAbsPrefix(link []byte, prefix string) []byte {
+	if prefix == "" || !isRelativeLink(link) {
+		return link
+	}
+	policy := r.opts.AbsolutePrefixPolicy
+	if policy == 0 {
+		policy = PrefixRootRelative | PrefixFragments
+	}
+	switch link[0] {
+	case '/':
+		if policy&PrefixRootRelative == 0 {
+			return link
+		}
+	case '#':
+		if policy&PrefixFragments == 0 {
+			return link
+		}
+	case '.':
+		if policy&PrefixDotRelative == 0 {
+			return link
+		}
+		if dest := resolveDotRelative(link, prefix); dest != nil {
+			return dest
 		}
-		newDest += string(link)
-		return []byte(newDest)
 	}
-	return link
+	newDest := prefix
+	if link[0] != '/' {
+		newDest += "/"
+	}
+	newDest += string(link)
+	return []byte(newDest)
 }
 
-func appendLinkAttrs(attrs []string, flags Flags, link []byte) []string {
+// resolveDotRelative returns link, e.g. ./x or ../x, resolved against
+// prefix taken as a directory, or nil if either can't be parsed
+func resolveDotRelative(link []byte, prefix string) []byte {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	base, err := url.Parse(prefix)
+	if err != nil {
+		return nil
+	}
+	ref, err := url.Parse(string(link))
+	if err != nil {
+		return nil
+	}
+	return []byte(base.ResolveReference(ref).String())
+}
+
+func (r *Renderer) linkAttrs(link []byte) (rel []string, target string) {
+	if r.opts.LinkAttrsHook != nil {
+		return r.opts.LinkAttrsHook(link)
+	}
 	if isRelativeLink(link) {
-		return attrs
+		return nil, ""
 	}
-	var val []string
+	flags := r.opts.Flags
 	if flags&NofollowLinks != 0 {
-		val = append(val, "nofollow")
+		rel = append(rel, "nofollow")
 	}
 	if flags&NoreferrerLinks != 0 {
-		val = append(val, "noreferrer")
+		rel = append(rel, "noreferrer")
+	}
+	if flags&NoopenerLinks != 0 {
+		rel = append(rel, "noopener")
 	}
 	if flags&HrefTargetBlank != 0 {
-		attrs = append(attrs, `target="_blank"`)
+		target = "_blank"
 	}
-	if len(val) == 0 {
+	return rel, target
+}
+
+func (r *Renderer) appendLinkAttrs(attrs []string, link []byte) []string {
+	rel, target := r.linkAttrs(link)
+	if target != "" {
+		attrs = append(attrs, `target="`+target+`"`)
+	}
+	if len(rel) == 0 {
 		return attrs
 	}
-	attr := fmt.Sprintf("rel=%q", strings.Join(val, " "))
+	attr := `rel="` + strings.Join(rel, " ") + `"`
 	return append(attrs, attr)
 }
 
-func isMailto(link []byte) bool {
-	return bytes.HasPrefix(link, []byte("mailto:"))
+func (r *Renderer) isUnsafeLink(dest []byte) bool {
+	return r.opts.Flags&Safelink != 0 && !isSafeLink(dest, r.opts.AllowedLinkProtocols)
 }
 
-func needSkipLink(flags Flags, dest []byte) bool {
-	if flags&SkipLinks != 0 {
+func (r *Renderer) needSkipLink(dest []byte) bool {
+	if r.opts.Flags&SkipLinks != 0 {
 		return true
 	}
-	return flags&Safelink != 0 && !isSafeLink(dest) && !isMailto(dest)
+	return r.isUnsafeLink(dest)
 }
 
 func isSmartypantable(node ast.Node) bool {
@@ -324,84 +682,327 @@ func isSmartypantable(node ast.Node) bool {
 	return true
 }
 
-func appendLanguageAttr(attrs []string, info []byte) []string {
-	if len(info) == 0 {
+func appendLanguageAttr(attrs []string, info []byte, flags EscapeFlags) []string {
+	if len(info) == 0 || info[0] == '{' {
 		return attrs
 	}
 	endOfLang := bytes.IndexAny(info, "\t ")
 	if endOfLang < 0 {
 		endOfLang = len(info)
 	}
-	s := `class="language-` + string(info[:endOfLang]) + `"`
+	s := `class="language-` + escAttrValue(info[:endOfLang], flags) + `"`
 	return append(attrs, s)
 }
 
+// directive renders a generic container as a div with the class of its
+// name, merged with classes of its attribute
+func (r *Renderer) directive(w io.Writer, node *ast.Directive, entering bool) {
+	if !entering {
+		r.outOneOfCr(w, false, "", "</div>")
+		return
+	}
+	attrs := mergeClass(blockAttrs(node, r.opts.EscapeFlags), r.escAttr(node.Name))
+	r.outOneOfCr(w, true, r.tag("<div", attrs), "")
+}
+
+// lineBlock renders a line block as a div of class line-block, its lines
+// separated by <br> tags
+func (r *Renderer) lineBlock(w io.Writer, node *ast.LineBlock, entering bool) {
+	if !entering {
+		r.outs(w, "</div>")
+		r.cr(w)
+		return
+	}
+	r.cr(w)
+	attrs := mergeClass(blockAttrs(node, r.opts.EscapeFlags), "line-block")
+	r.outs(w, r.tag("<div", attrs))
+}
+
+// component renders a component as its tag, so that it can be hydrated
+// from the HTML. Content is rendered between the opening and closing tag.
+func (r *Renderer) component(w io.Writer, node *ast.Component, entering bool) {
+	var tag []byte
+	switch {
+	case !entering && node.SelfClosing:
+		return
+	case !entering:
+		tag = []byte("</" + string(node.Name) + ">")
+	default:
+		tag = append([]byte("<"), node.Name...)
+		for _, prop := range node.Props {
+			tag = append(tag, ' ')
+			tag = append(tag, prop.Name...)
+			// an {expression} is quoted too, as it can contain spaces
+			// and what would be other attributes
+			if prop.Value != nil {
+				tag = append(tag, `="`+r.escAttr(prop.Value)+`"`...)
+			}
+		}
+		if node.SelfClosing {
+			tag = append(tag, " />"...)
+		} else {
+			tag = append(tag, '>')
+		}
+	}
+	if r.skipHTML(tag) {
+		return
+	}
+	tag = r.rawHTML(tag)
+	r.outOneOfCr(w, entering, string(tag), string(tag))
+	if node.SelfClosing {
+		r.cr(w)
+	}
+}
+
+// mergeClass returns attrs with escaped class added in front of the
+// classes of the class attribute, or in a new class attribute
+func mergeClass(attrs []string, class string) []string {
+	for i, attr := range attrs {
+		if strings.HasPrefix(attr, `class="`) {
+			attrs[i] = `class="` + class + " " + attr[len(`class="`):]
+			return attrs
+		}
+	}
+	return append([]string{`class="` + class + `"`}, attrs...)
+}
+
+// mergeRel returns attrs with attr appended or, if attr is a rel attribute
+// and attrs already have one, with its values added to the existing ones
+func mergeRel(attrs []string, attr string) []string {
+	if !strings.HasPrefix(attr, `rel="`) {
+		return append(attrs, attr)
+	}
+	for i, a := range attrs {
+		if strings.HasPrefix(a, `rel="`) {
+			attrs[i] = a[:len(a)-1] + " " + attr[len(`rel="`):]
+			return attrs
+		}
+	}
+	return append(attrs, attr)
+}
+
+// elementAttrs returns attrs of element name, e.g. "<table", with the
+// classes of RendererOptions.ElementClasses for it
+func (r *Renderer) elementAttrs(name string, attrs []string) []string {
+	class := r.opts.ElementClasses[strings.TrimPrefix(name, "<")]
+	if class == "" {
+		return attrs
+	}
+	return mergeClass(attrs, r.escAttr([]byte(class)))
+}
+
+// tag returns the opening tag of element name, e.g. "<table", with attrs
+// and classes of RendererOptions.ElementClasses
+func (r *Renderer) tag(name string, attrs []string) string {
+	return tagWithAttributes(name, r.elementAttrs(name, attrs))
+}
+
+// nodeTag returns opening tag, e.g. "<em>", with attributes of node and
+// classes of RendererOptions.ElementClasses added
+func (r *Renderer) nodeTag(tag string, node ast.Node) string {
+	name := tag[:len(tag)-1]
+	attrs := r.elementAttrs(name, blockAttrs(node, r.opts.EscapeFlags))
+	if len(attrs) == 0 {
+		return tag
+	}
+	return tagWithAttributes(name, attrs)
+}
+
 func (r *Renderer) outTag(w io.Writer, name string, attrs []string) {
-	s := name
-	if len(attrs) > 0 {
-		s += " " + strings.Join(attrs, " ")
+	attrs = r.elementAttrs(name, attrs)
+	io.WriteString(w, name)
+	for _, attr := range attrs {
+		io.WriteString(w, " ")
+		io.WriteString(w, attr)
 	}
-	io.WriteString(w, s+">")
+	io.WriteString(w, ">")
 	r.lastOutputLen = 1
 }
 
-func footnoteRef(prefix string, node *ast.Link) string {
-	urlFrag := prefix + string(slugify(node.Destination))
+// attrEscLink returns name="dest" with dest escaped as a link.
+func (r *Renderer) attrEscLink(name string, dest []byte) string {
+	r.scratch.Reset()
+	r.scratch.WriteString(name)
+	r.scratch.WriteString(`="`)
+	escLink(&r.scratch, dest, r.opts.EscapeFlags)
+	r.scratch.WriteByte('"')
+	return r.scratch.String()
+}
+
+// attrEscHTML returns name="val" with val html-escaped.
+func (r *Renderer) attrEscHTML(name string, val []byte) string {
+	r.scratch.Reset()
+	r.scratch.WriteString(name)
+	r.scratch.WriteString(`="`)
+	r.esc(&r.scratch, val)
+	r.scratch.WriteByte('"')
+	return r.scratch.String()
+}
+
+// footnoteID returns the id of footnote anchors of footnote number noteID
+// with reference ref
+func (r *Renderer) footnoteID(noteID int, ref []byte) string {
+	if r.opts.FootnoteIDFunc != nil {
+		return escAttrString(r.opts.FootnoteIDFunc(noteID, ref), r.opts.EscapeFlags)
+	}
+	slug := string(slugify(ref))
+	if r.opts.SlugifyFunc != nil {
+		slug = r.opts.SlugifyFunc(string(ref))
+	}
+	return escAttrString(r.opts.FootnoteAnchorPrefix+slug, r.opts.EscapeFlags)
+}
+
+func footnoteRef(id string, node *ast.Link, aria bool) string {
 	nStr := strconv.Itoa(node.NoteID)
-	anchor := `<a href="#fn:` + urlFrag + `">` + nStr + `</a>`
-	return `<sup class="footnote-ref" id="fnref:` + urlFrag + `">` + anchor + `</sup>`
+	attrs := ""
+	if aria {
+		attrs = ` role="doc-noteref" aria-describedby="fn:` + id + `"`
+	}
+	anchor := `<a href="#fn:` + id + `"` + attrs + `>` + nStr + `</a>`
+	return `<sup class="footnote-ref" id="fnref:` + id + `">` + anchor + `</sup>`
 }
 
-func footnoteItem(prefix string, slug []byte) string {
-	return `<li id="fn:` + prefix + string(slug) + `">`
+func footnoteItem(id string, accessible bool) string {
+	if accessible {
+		return `<li id="fn:` + id + `" role="doc-footnote">`
+	}
+	return `<li id="fn:` + id + `">`
+}
+
+// footnoteReturnLink returns the return link of footnote id. If label isn't
+// blank, it's the aria-label of the link.
+func footnoteReturnLink(id, returnLink string, aria bool, label string) string {
+	attrs := ""
+	if aria {
+		attrs = ` role="doc-backlink"`
+	}
+	if label != "" {
+		// the return link contents are usually a decorative glyph, so
+		// label the link and hide the glyph from assistive technology
+		attrs += ` aria-label="` + escAttrString(label, 0) + `"`
+		returnLink = `<span aria-hidden="true">` + returnLink + `</span>`
+	}
+	return ` <a class="footnote-return" href="#fnref:` + id + `"` + attrs + `>` + returnLink + `</a>`
 }
 
-func footnoteReturnLink(prefix, returnLink string, slug []byte) string {
-	return ` <a class="footnote-return" href="#fnref:` + prefix + string(slug) + `">` + returnLink + `</a>`
+// footnoteARIA returns true if footnotes get DPUB-ARIA roles, i.e. if
+// FootnoteARIA or Accessible flag is set
+func (r *Renderer) footnoteARIA() bool {
+	return r.opts.Flags&(FootnoteARIA|Accessible) != 0
 }
 
-func listItemOpenCR(listItem *ast.ListItem) bool {
+// footnoteNumber returns the number of a footnote item, its position in the
+// list of footnotes. Numbers of all items of the list are computed on first
+// use.
+func (r *Renderer) footnoteNumber(item *ast.ListItem) int {
+	if n, ok := r.footnoteNumbers[item]; ok {
+		return n
+	}
+	if r.footnoteNumbers == nil {
+		r.footnoteNumbers = make(map[*ast.ListItem]int)
+	}
+	for i, child := range item.Parent.GetChildren() {
+		if li, ok := child.(*ast.ListItem); ok {
+			r.footnoteNumbers[li] = i + 1
+		}
+	}
+	return r.footnoteNumbers[item]
+}
+
+func (r *Renderer) listItemOpenCR(listItem *ast.ListItem) bool {
 	if ast.GetPrevNode(listItem) == nil {
 		return false
 	}
 	ld := listItem.Parent.(*ast.List)
-	return !ld.Tight && ld.ListFlags&ast.ListTypeDefinition == 0
+	return !r.isListTight(ld) && ld.ListFlags&ast.ListTypeDefinition == 0
 }
 
-func skipParagraphTags(para *ast.Paragraph) bool {
+func (r *Renderer) skipParagraphTags(para *ast.Paragraph) bool {
 	parent := para.Parent
 	grandparent := parent.GetParent()
 	if grandparent == nil || !isList(grandparent) {
 		return false
 	}
 	isParentTerm := isListItemTerm(parent)
-	grandparentListData := grandparent.(*ast.List)
-	tightOrTerm := grandparentListData.Tight || isParentTerm
+	tightOrTerm := r.isListTight(grandparent) || isParentTerm
 	return tightOrTerm
 }
 
 func (r *Renderer) out(w io.Writer, d []byte) {
 	r.lastOutputLen = len(d)
-	if r.disableTags > 0 {
-		d = htmlTagRe.ReplaceAll(d, []byte{})
-	}
 	w.Write(d)
 }
 
 func (r *Renderer) outs(w io.Writer, s string) {
 	r.lastOutputLen = len(s)
-	if r.disableTags > 0 {
-		s = htmlTagRe.ReplaceAllString(s, "")
-	}
 	io.WriteString(w, s)
 }
 
+// layout returns markup s without its newlines if CompactOutput or
+// IndentedOutput flag is set
+func (r *Renderer) layout(s string) string {
+	if r.opts.Flags&(CompactOutput|IndentedOutput) == 0 {
+		return s
+	}
+	return strings.Replace(s, "\n", "", -1)
+}
+
 func (r *Renderer) cr(w io.Writer) {
-	if r.lastOutputLen > 0 {
+	if r.lastOutputLen > 0 && r.opts.Flags&(CompactOutput|IndentedOutput) == 0 {
 		r.outs(w, "\n")
 	}
 }
 
+// indentedBlock returns true if node is rendered as a block by itself
+// with IndentedOutput flag, and if its children are blocks too
+func (r *Renderer) indentedBlock(node ast.Node) (block bool, container bool) {
+	switch node := node.(type) {
+	case *ast.Paragraph:
+		return !r.skipParagraphTags(node), false
+	case *ast.Caption:
+		return !r.isQuoteFigure(node.Parent), false
+	case *ast.CaptionFigure:
+		return !r.isQuoteFigure(node), true
+	case *ast.Heading, *ast.TableCell, *ast.CodeBlock, *ast.HTMLBlock, *ast.HorizontalRule, *ast.MathBlock, *ast.LineBlock:
+		return true, false
+	case *ast.BlockQuote, *ast.Aside, *ast.Directive, *ast.Component, *ast.List, *ast.ListItem,
+		*ast.Table, *ast.TableHeader, *ast.TableBody, *ast.TableFooter, *ast.TableRow, *ast.DocumentMatter:
+		return true, true
+	}
+	return false, false
+}
+
+// indent starts a new line for a block node, indented by its depth, and
+// tracks the depth, if IndentedOutput flag is set. A container block
+// closes on a new line if a block was written in it.
+func (r *Renderer) indent(w io.Writer, node ast.Node, entering bool) {
+	block, container := r.indentedBlock(node)
+	if !block {
+		return
+	}
+	if entering {
+		if n := len(r.indentLevels); n > 0 {
+			r.indentLevels[n-1] = true
+		}
+		if r.lastOutputLen > 0 {
+			r.outs(w, "\n"+strings.Repeat("  ", len(r.indentLevels)))
+		}
+		if container {
+			r.indentLevels = append(r.indentLevels, false)
+		}
+		return
+	}
+	if !container {
+		return
+	}
+	n := len(r.indentLevels) - 1
+	hasBlocks := r.indentLevels[n]
+	r.indentLevels = r.indentLevels[:n]
+	if hasBlocks {
+		r.outs(w, "\n"+strings.Repeat("  ", n))
+	}
+}
+
 var (
 	openHTags  = []string{"<h1", "<h2", "<h3", "<h4", "<h5"}
 	closeHTags = []string{"</h1>", "</h2>", "</h3>", "</h4>", "</h5>"}
@@ -421,33 +1022,104 @@ func headingCloseTagFromLevel(level int) string {
 	return closeHTags[level-1]
 }
 
+// headingLevel returns the level of heading adjusted with HeadingLevelOffset
+// and clamped to 1...MaxHeadingLevel
+func (r *Renderer) headingLevel(heading *ast.Heading) int {
+	maxLevel := r.opts.MaxHeadingLevel
+	if maxLevel <= 0 || maxLevel > 6 {
+		maxLevel = 6
+	}
+	level := heading.Level + r.opts.HeadingLevelOffset
+	if level < 1 {
+		level = 1
+	}
+	if level > maxLevel {
+		level = maxLevel
+	}
+	return level
+}
+
 func (r *Renderer) outHRTag(w io.Writer, attrs []string) {
-	hr := tagWithAttributes("<hr", attrs)
-	r.outOneOf(w, r.opts.Flags&UseXHTML == 0, hr, "<hr />")
+	hr := r.tag("<hr", attrs)
+	if r.opts.Flags&UseXHTML != 0 {
+		hr = hr[:len(hr)-1] + " />"
+	}
+	r.outs(w, hr)
+}
+
+// outXML writes d which can contain HTML entities. If XHTMLStrict is set, they
+// are replaced by characters.
+func (r *Renderer) outXML(w io.Writer, d []byte) {
+	if r.opts.Flags&XHTMLStrict != 0 {
+		d = xmlEntities(d)
+	}
+	w.Write(d)
 }
 
 func (r *Renderer) text(w io.Writer, text *ast.Text) {
+	hardWraps := r.opts.Flags&HardWraps != 0
+	if !hardWraps && r.opts.SoftbreakHTML == "" {
+		r.textLiteral(w, text, text.Literal)
+		return
+	}
+	// soft line breaks are kept as newlines in the text
+	lines := bytes.Split(text.Literal, []byte{'\n'})
+	for i, line := range lines {
+		if i > 0 && hardWraps {
+			r.hardBreak(w, nil)
+		} else if i > 0 {
+			r.softBreak(w, nil)
+		}
+		r.textLiteral(w, text, line)
+	}
+}
+
+func (r *Renderer) textLiteral(w io.Writer, text *ast.Text, literal []byte) {
+	if r.opts.Flags&PreserveEntities != 0 && isEntity(literal) {
+		r.outXML(w, literal)
+		return
+	}
+	if r.opts.Flags&ObfuscateEmail != 0 && inMailtoLink(text) {
+		obfuscate(w, literal)
+		return
+	}
 	if r.opts.Flags&Smartypants != 0 {
-		var tmp bytes.Buffer
-		EscapeHTML(&tmp, text.Literal)
-		r.sr.Process(w, tmp.Bytes())
+		tmp, out := getBuffer(), getBuffer()
+		// ' is left for smartypants to turn into typographic quotes
+		EscapeHTMLFlags(tmp, literal, r.opts.EscapeFlags&^EscapeSingleQuotes)
+		r.sr.Process(out, tmp.Bytes())
+		r.outXML(w, out.Bytes())
+		putBuffer(tmp)
+		putBuffer(out)
 	} else {
 		_, parentIsLink := text.Parent.(*ast.Link)
 		if parentIsLink {
-			escLink(w, text.Literal)
+			escLink(w, literal, r.opts.EscapeFlags)
 		} else {
-			EscapeHTML(w, text.Literal)
+			r.esc(w, literal)
 		}
 	}
 }
 
 func (r *Renderer) hardBreak(w io.Writer, node *ast.Hardbreak) {
+	if r.opts.HardbreakHTML != "" {
+		r.outs(w, r.opts.HardbreakHTML)
+		return
+	}
 	r.outOneOf(w, r.opts.Flags&UseXHTML == 0, "<br>", "<br />")
 	r.cr(w)
 }
 
+func (r *Renderer) softBreak(w io.Writer, node *ast.Softbreak) {
+	if r.opts.SoftbreakHTML != "" {
+		r.outs(w, r.opts.SoftbreakHTML)
+		return
+	}
+	r.cr(w)
+}
+
 func (r *Renderer) nonBlockingSpace(w io.Writer, node *ast.NonBlockingSpace) {
-	r.outs(w, "&nbsp;")
+	r.outOneOf(w, r.opts.Flags&XHTMLStrict == 0, "&nbsp;", "&#160;")
 }
 
 func (r *Renderer) outOneOf(w io.Writer, outFirst bool, first string, second string) {
@@ -468,34 +1140,98 @@ func (r *Renderer) outOneOfCr(w io.Writer, outFirst bool, first string, second s
 	}
 }
 
+// skipHTML returns true if raw HTML d should not be rendered.
+func (r *Renderer) skipHTML(d []byte) bool {
+	if r.opts.HTMLPolicy != nil {
+		return !r.opts.HTMLPolicy.allows(d)
+	}
+	return r.opts.Flags&SkipHTML != 0
+}
+
+// skippedHTML returns what is rendered of skipped raw HTML d: its comments
+// if KeepHTMLComments flag is set, or nil
+func (r *Renderer) skippedHTML(d []byte) []byte {
+	if r.opts.Flags&KeepHTMLComments == 0 {
+		return nil
+	}
+	return htmlComments(d)
+}
+
+// rawHTML returns raw HTML d as it should be rendered
+func (r *Renderer) rawHTML(d []byte) []byte {
+	if r.opts.Flags&TagFilter != 0 {
+		return filterTags(d)
+	}
+	return d
+}
+
 func (r *Renderer) htmlSpan(w io.Writer, span *ast.HTMLSpan) {
-	if r.opts.Flags&SkipHTML == 0 {
-		r.out(w, span.Literal)
+	d := span.Literal
+	if r.skipHTML(d) {
+		if d = r.skippedHTML(d); d == nil {
+			return
+		}
+	}
+	r.lastOutputLen = len(d)
+	r.outXML(w, r.rawHTML(d))
+}
+
+// isMailto returns true if dest is a mailto: link
+func isMailto(dest []byte) bool {
+	return len(dest) >= 7 && bytes.EqualFold(dest[:7], []byte("mailto:"))
+}
+
+// inMailtoLink returns true if node is inside a link to a mailto: address
+func inMailtoLink(node ast.Node) bool {
+	for p := node.GetParent(); p != nil; p = p.GetParent() {
+		if link, ok := p.(*ast.Link); ok {
+			return isMailto(link.Destination)
+		}
 	}
+	return false
+}
+
+// skipFootnotes returns true if the list of footnotes should not be rendered
+func (r *Renderer) skipFootnotes() bool {
+	return r.opts.Flags&SkipFootnotes != 0 || r.opts.FootnoteHook != nil
 }
 
 func (r *Renderer) linkEnter(w io.Writer, link *ast.Link) {
-	var attrs []string
-	dest := link.Destination
-	dest = r.addAbsPrefix(dest)
-	var hrefBuf bytes.Buffer
-	hrefBuf.WriteString("href=\"")
-	escLink(&hrefBuf, dest)
-	hrefBuf.WriteByte('"')
-	attrs = append(attrs, hrefBuf.String())
 	if link.NoteID != 0 {
-		r.outs(w, footnoteRef(r.opts.FootnoteAnchorPrefix, link))
+		if r.opts.Flags&SkipFootnotes != 0 {
+			return
+		}
+		if r.opts.FootnoteHook != nil {
+			r.opts.FootnoteHook(w, link, link.Footnote)
+			return
+		}
+		id := r.footnoteID(link.NoteID, link.Destination)
+		r.outs(w, footnoteRef(id, link, r.footnoteARIA()))
 		return
 	}
-
-	attrs = appendLinkAttrs(attrs, r.opts.Flags, dest)
+	dest := link.Destination
+	dest = r.addAbsPrefix(dest, r.opts.AbsolutePrefix)
+	href := dest
+	var extraAttrs []string
+	if r.opts.LinkWrapperHook != nil && !isRelativeLink(dest) {
+		href, extraAttrs = r.opts.LinkWrapperHook(dest)
+	}
+	var attrs []string
+	if r.opts.Flags&ObfuscateEmail != 0 && isMailto(href) {
+		r.scratch.Reset()
+		obfuscateLink(&r.scratch, href)
+		attrs = []string{`href="` + r.scratch.String() + `"`}
+	} else {
+		attrs = []string{r.attrEscLink("href", href)}
+	}
+	attrs = r.appendLinkAttrs(attrs, dest)
+	for _, attr := range extraAttrs {
+		attrs = mergeRel(attrs, attr)
+	}
 	if len(link.Title) > 0 {
-		var titleBuff bytes.Buffer
-		titleBuff.WriteString("title=\"")
-		EscapeHTML(&titleBuff, link.Title)
-		titleBuff.WriteByte('"')
-		attrs = append(attrs, titleBuff.String())
+		attrs = append(attrs, r.attrEscHTML("title", link.Title))
 	}
+	attrs = append(attrs, blockAttrs(link, r.opts.EscapeFlags)...)
 	r.outTag(w, "<a", attrs)
 }
 
@@ -507,7 +1243,7 @@ func (r *Renderer) linkExit(w io.Writer, link *ast.Link) {
 
 func (r *Renderer) link(w io.Writer, link *ast.Link, entering bool) {
 	// mark it but don't link it if it is not a safe link: no smartypants
-	if needSkipLink(r.opts.Flags, link.Destination) {
+	if r.needSkipLink(link.Destination) {
 		r.outOneOf(w, entering, "<tt>", "</tt>")
 		return
 	}
@@ -521,37 +1257,114 @@ func (r *Renderer) link(w io.Writer, link *ast.Link, entering bool) {
 
 func (r *Renderer) imageEnter(w io.Writer, image *ast.Image) {
 	dest := image.Destination
-	dest = r.addAbsPrefix(dest)
-	if r.disableTags == 0 {
-		//if options.safe && potentiallyUnsafe(dest) {
-		//out(w, `<img src="" alt="`)
-		//} else {
-		r.outs(w, `<img src="`)
-		escLink(w, dest)
-		r.outs(w, `" alt="`)
-		//}
+	prefix := r.opts.ImageAbsolutePrefix
+	if prefix == "" {
+		prefix = r.opts.AbsolutePrefix
+	}
+	dest = r.addAbsPrefix(dest, prefix)
+	r.pictureEnter(w, image)
+	//if options.safe && potentiallyUnsafe(dest) {
+	//out(w, `<img src="" alt="`)
+	//} else {
+	r.outs(w, `<img src="`)
+	escLink(w, dest, r.opts.EscapeFlags)
+	r.outs(w, `" alt="`)
+	//}
+	for _, child := range image.Children {
+		r.altText(w, child)
 	}
-	r.disableTags++
 }
 
 func (r *Renderer) imageExit(w io.Writer, image *ast.Image) {
-	r.disableTags--
-	if r.disableTags == 0 {
-		if image.Title != nil {
-			r.outs(w, `" title="`)
-			EscapeHTML(w, image.Title)
+	if image.Title != nil {
+		r.outs(w, `" title="`)
+		r.esc(w, image.Title)
+	}
+	r.outs(w, `"`)
+	r.imageSize(w, image)
+	for _, attr := range r.elementAttrs("<img", blockAttrs(image, r.opts.EscapeFlags)) {
+		r.outs(w, " "+attr)
+	}
+	r.outs(w, ` />`)
+	if r.inPicture {
+		r.outs(w, "</picture>")
+		r.inPicture = false
+	}
+}
+
+// pictureEnter opens a <picture> with sources of image from
+// ImageSourcesHook, if there are any
+func (r *Renderer) pictureEnter(w io.Writer, image *ast.Image) {
+	if r.opts.ImageSourcesHook == nil {
+		return
+	}
+	sources := r.opts.ImageSourcesHook(image.Destination)
+	if len(sources) == 0 {
+		return
+	}
+	r.inPicture = true
+	r.outs(w, "<picture>")
+	for _, src := range sources {
+		attrs := []string{`srcset="` + escAttrString(src.Srcset, r.opts.EscapeFlags) + `"`}
+		if src.Type != "" {
+			attrs = append(attrs, `type="`+escAttrString(src.Type, r.opts.EscapeFlags)+`"`)
+		}
+		if src.Media != "" {
+			attrs = append(attrs, `media="`+escAttrString(src.Media, r.opts.EscapeFlags)+`"`)
 		}
-		r.outs(w, `" />`)
+		if src.Sizes != "" {
+			attrs = append(attrs, `sizes="`+escAttrString(src.Sizes, r.opts.EscapeFlags)+`"`)
+		}
+		r.outs(w, "<source "+strings.Join(attrs, " ")+r.closeTag)
 	}
 }
 
+// imageSize writes width and height attributes of image from ImageSizeHook
+func (r *Renderer) imageSize(w io.Writer, image *ast.Image) {
+	if r.opts.ImageSizeHook == nil {
+		return
+	}
+	if a := image.Attribute; a != nil && (a.Attrs["width"] != nil || a.Attrs["height"] != nil) {
+		return
+	}
+	width, height, ok := r.opts.ImageSizeHook(image.Destination)
+	if !ok {
+		return
+	}
+	r.outs(w, fmt.Sprintf(` width="%d" height="%d"`, width, height))
+}
+
+// altText renders node as plain text suitable for the alt attribute of
+// an image: markup of nested inline nodes (emphasis, links etc.) is dropped
+// and only their text content is kept.
+func (r *Renderer) altText(w io.Writer, node ast.Node) {
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Text:
+			r.text(w, node)
+		case *ast.Code, *ast.Math:
+			r.esc(w, node.AsLeaf().Literal)
+		case *ast.Subscript, *ast.Superscript:
+			Escape(w, node.AsLeaf().Literal)
+		case *ast.NonBlockingSpace:
+			r.outs(w, "&nbsp;")
+		case *ast.Softbreak, *ast.Hardbreak:
+			r.outs(w, "\n")
+		}
+		return ast.GoToNext
+	})
+}
+
 func (r *Renderer) paragraphEnter(w io.Writer, para *ast.Paragraph) {
 	// TODO: untangle this clusterfuck about when the newlines need
 	// to be added and when not.
 	prev := ast.GetPrevNode(para)
 	if prev != nil {
 		switch prev.(type) {
-		case *ast.HTMLBlock, *ast.List, *ast.Paragraph, *ast.Heading, *ast.CaptionFigure, *ast.CodeBlock, *ast.BlockQuote, *ast.Aside, *ast.HorizontalRule:
+		case *ast.HTMLBlock, *ast.List, *ast.Paragraph, *ast.Heading, *ast.CaptionFigure, *ast.CodeBlock, *ast.BlockQuote, *ast.Aside, *ast.Directive, *ast.Component, *ast.HorizontalRule, *ast.LineBlock:
 			r.cr(w)
 		}
 	}
@@ -565,10 +1378,17 @@ func (r *Renderer) paragraphEnter(w io.Writer, para *ast.Paragraph) {
 		if isParentAside {
 			r.cr(w)
 		}
+		switch para.Parent.(type) {
+		case *ast.Directive, *ast.Component:
+			r.cr(w)
+		}
 	}
 
-	tag := tagWithAttributes("<p", BlockAttrs(para))
+	tag := r.tag("<p", blockAttrs(para, r.opts.EscapeFlags))
 	r.outs(w, tag)
+	if item, ok := para.Parent.(*ast.ListItem); ok && item.IsTask && prev == nil {
+		r.taskCheckbox(w, item)
+	}
 }
 
 func (r *Renderer) paragraphExit(w io.Writer, para *ast.Paragraph) {
@@ -579,7 +1399,11 @@ func (r *Renderer) paragraphExit(w io.Writer, para *ast.Paragraph) {
 }
 
 func (r *Renderer) paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
-	if skipParagraphTags(para) {
+	if r.skipParagraphTags(para) {
+		// keep paragraphs of items of TightLists apart
+		if _, ok := ast.GetPrevNode(para).(*ast.Paragraph); ok && entering {
+			r.cr(w)
+		}
 		return
 	}
 	if entering {
@@ -588,26 +1412,101 @@ func (r *Renderer) paragraph(w io.Writer, para *ast.Paragraph, entering bool) {
 		r.paragraphExit(w, para)
 	}
 }
-func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) {
+
+// bareLink returns the link if para contains only a link whose text is its
+// destination, e.g. an autolink
+func bareLink(para *ast.Paragraph) *ast.Link {
+	var link *ast.Link
+	for _, child := range para.Children {
+		if text, ok := child.(*ast.Text); ok && len(bytes.TrimSpace(text.Literal)) == 0 {
+			continue
+		}
+		l, ok := child.(*ast.Link)
+		if !ok || link != nil {
+			return nil
+		}
+		link = l
+	}
+	if link == nil || link.NoteID != 0 || len(link.Children) != 1 {
+		return nil
+	}
+	text, ok := link.Children[0].(*ast.Text)
+	if !ok || !bytes.Equal(text.Literal, link.Destination) {
+		return nil
+	}
+	return link
+}
+
+// embedURL replaces para with HTML from URLEmbedHook if it's a bare link.
+// It returns true if para was replaced.
+func (r *Renderer) embedURL(w io.Writer, para *ast.Paragraph, entering bool) bool {
+	if r.opts.URLEmbedHook == nil {
+		return false
+	}
+	if !entering {
+		if r.embedded != para {
+			return false
+		}
+		r.embedded = nil
+		return true
+	}
+	link := bareLink(para)
+	if link == nil {
+		return false
+	}
+	embed, ok := r.opts.URLEmbedHook(link.Destination)
+	if !ok {
+		return false
+	}
+	r.embedded = para
+	r.cr(w)
+	r.out(w, embed)
+	r.cr(w)
+	return true
+}
+
+func (r *Renderer) image(w io.Writer, node *ast.Image, entering bool) ast.WalkStatus {
+	// don't emit an image with an unsafe source, only its alt text
+	if r.isUnsafeLink(node.Destination) {
+		if entering {
+			for _, child := range node.Children {
+				r.altText(w, child)
+			}
+		}
+		return ast.SkipChildren
+	}
+	_, inFigure := node.Parent.(*ast.CaptionFigure)
 	if entering {
+		if inFigure {
+			r.cr(w)
+		}
 		r.imageEnter(w, node)
-	} else {
-		r.imageExit(w, node)
+		// children are already rendered as the alt text
+		return ast.SkipChildren
+	}
+	r.imageExit(w, node)
+	if inFigure && ast.GetNextNode(node) != nil {
+		r.cr(w)
 	}
+	return ast.GoToNext
 }
 
 func (r *Renderer) code(w io.Writer, node *ast.Code) {
-	r.outs(w, "<code>")
-	EscapeHTML(w, node.Literal)
+	r.outs(w, r.nodeTag("<code>", node))
+	r.esc(w, node.Literal)
 	r.outs(w, "</code>")
 }
 
 func (r *Renderer) htmlBlock(w io.Writer, node *ast.HTMLBlock) {
-	if r.opts.Flags&SkipHTML != 0 {
-		return
+	d := node.Literal
+	if r.skipHTML(d) {
+		if d = r.skippedHTML(d); d == nil {
+			return
+		}
 	}
 	r.cr(w)
-	r.out(w, node.Literal)
+	r.lastOutputLen = len(d)
+	r.outXML(w, r.rawHTML(d))
 	r.cr(w)
 }
 
@@ -629,30 +1528,144 @@ func (r *Renderer) headingEnter(w io.Writer, nodeData *ast.Heading) {
 		attrs = []string{`class="` + class + `"`}
 	}
 	if nodeData.HeadingID != "" {
-		id := r.ensureUniqueHeadingID(nodeData.HeadingID)
-		if r.opts.HeadingIDPrefix != "" {
-			id = r.opts.HeadingIDPrefix + id
+		attrs = append(attrs, `id="`+escAttrString(r.headingAnchor(nodeData), r.opts.EscapeFlags)+`"`)
+	}
+	attrs = append(attrs, blockAttrs(nodeData, r.opts.EscapeFlags)...)
+	r.cr(w)
+	r.outTag(w, headingOpenTagFromLevel(r.headingLevel(nodeData)), attrs)
+	r.headingNumber(w, nodeData)
+}
+
+// headingNumber writes the section number of heading, if NumberHeadings
+// flag is set and the heading is numbered
+func (r *Renderer) headingNumber(w io.Writer, heading *ast.Heading) {
+	if r.opts.Flags&NumberHeadings == 0 {
+		return
+	}
+	if num := r.sectionNumber(heading); num != "" {
+		r.outs(w, `<span class="secno">`+num+`</span> `)
+	}
+}
+
+// sectionNumber returns the section number of heading, or "" if it isn't
+// numbered. Numbers of all headings are computed on first use.
+func (r *Renderer) sectionNumber(heading *ast.Heading) string {
+	if r.headingNumbers == nil {
+		var root ast.Node = heading
+		for root.GetParent() != nil {
+			root = root.GetParent()
 		}
-		if r.opts.HeadingIDSuffix != "" {
-			id = id + r.opts.HeadingIDSuffix
+		r.headingNumbers = r.numberHeadings(root)
+	}
+	return r.headingNumbers[heading]
+}
+
+func (r *Renderer) crossReferenceNumber(heading *ast.Heading) string {
+	if r.opts.Flags&NumberHeadings == 0 {
+		return ""
+	}
+	return r.sectionNumber(heading)
+}
+
+// crossReferenceText writes the text of a cross reference to a heading
+// resolved by the parser: "Section " and its number (see
+// CrossReferenceFormat) if NumberHeadings flag is set, otherwise the text
+// of the heading
+func (r *Renderer) crossReferenceText(w io.Writer, ref *ast.CrossReference) {
+	heading, ok := ref.Target.(*ast.Heading)
+	if !ok {
+		return
+	}
+	if num := r.crossReferenceNumber(heading); num != "" {
+		format := r.opts.CrossReferenceFormat
+		if format == "" {
+			format = "Section %s"
 		}
-		attrID := `id="` + id + `"`
-		attrs = append(attrs, attrID)
+		r.esc(w, []byte(fmt.Sprintf(format, num)))
+		return
+	}
+	r.esc(w, []byte(ast.HeadingText(heading)))
+}
+
+// numberHeadings returns hierarchical section numbers of headings in doc.
+// Title blocks, special headings and headings above
+// HeadingNumberStartLevel are not numbered. A skipped level is numbered 0,
+// e.g. "1.0.1" for a level 3 heading following a level 1 one.
+func (r *Renderer) numberHeadings(doc ast.Node) map[*ast.Heading]string {
+	start := r.opts.HeadingNumberStartLevel
+	if start < 1 {
+		start = 1
+	}
+	sep := r.opts.HeadingNumberSeparator
+	if sep == "" {
+		sep = "."
+	}
+	numbers := map[*ast.Heading]string{}
+	var counters []int
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering || heading.IsTitleblock || heading.IsSpecial || heading.Level < start {
+			return ast.GoToNext
+		}
+		depth := heading.Level - start + 1
+		for len(counters) < depth {
+			counters = append(counters, 0)
+		}
+		counters = counters[:depth]
+		counters[depth-1]++
+		parts := make([]string, depth)
+		for i, c := range counters {
+			parts[i] = strconv.Itoa(c)
+		}
+		numbers[heading] = strings.Join(parts, sep)
+		return ast.SkipChildren
+	})
+	return numbers
+}
+
+func (r *Renderer) headingExit(w io.Writer, heading *ast.Heading) {
+	r.outs(w, headingCloseTagFromLevel(r.headingLevel(heading)))
+	if !(isListItem(heading.Parent) && ast.GetNextNode(heading) == nil) {
+		r.cr(w)
+	}
+}
+
+// sectionEnter closes the sections of headings at the same or a deeper level
+// than heading and opens the section of heading, if WrapSections flag is
+// set. Only headings of the document (not e.g. in a block quote) start a
+// section.
+func (r *Renderer) sectionEnter(w io.Writer, heading *ast.Heading) {
+	if r.opts.Flags&WrapSections == 0 || heading.IsTitleblock {
+		return
+	}
+	switch heading.GetParent().(type) {
+	case *ast.Document, *ast.DocumentMatter:
+	default:
+		return
 	}
-	attrs = append(attrs, BlockAttrs(nodeData)...)
+	r.closeSections(w, heading.Level)
 	r.cr(w)
-	r.outTag(w, headingOpenTagFromLevel(nodeData.Level), attrs)
+	if heading.HeadingID != "" {
+		r.outs(w, `<section aria-labelledby="`+escAttrString(r.headingAnchor(heading), r.opts.EscapeFlags)+`">`)
+	} else {
+		r.outs(w, "<section>")
+	}
+	r.sectionLevels = append(r.sectionLevels, heading.Level)
 }
 
-func (r *Renderer) headingExit(w io.Writer, heading *ast.Heading) {
-	r.outs(w, headingCloseTagFromLevel(heading.Level))
-	if !(isListItem(heading.Parent) && ast.GetNextNode(heading) == nil) {
+// closeSections closes the open sections of headings at level or deeper
+func (r *Renderer) closeSections(w io.Writer, level int) {
+	for n := len(r.sectionLevels); n > 0 && r.sectionLevels[n-1] >= level; n-- {
 		r.cr(w)
+		r.outs(w, "</section>")
+		r.cr(w)
+		r.sectionLevels = r.sectionLevels[:n-1]
 	}
 }
 
 func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
 	if entering {
+		r.sectionEnter(w, node)
 		r.headingEnter(w, node)
 	} else {
 		r.headingExit(w, node)
@@ -661,7 +1674,11 @@ func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
 
 func (r *Renderer) horizontalRule(w io.Writer, node *ast.HorizontalRule) {
 	r.cr(w)
-	r.outHRTag(w, BlockAttrs(node))
+	if r.opts.HorizontalRuleHTML != "" {
+		r.outs(w, r.opts.HorizontalRuleHTML)
+	} else {
+		r.outHRTag(w, blockAttrs(node, r.opts.EscapeFlags))
+	}
 	r.cr(w)
 }
 
@@ -670,7 +1687,12 @@ func (r *Renderer) listEnter(w io.Writer, nodeData *ast.List) {
 	var attrs []string
 
 	if nodeData.IsFootnotesList {
-		r.outs(w, "\n<div class=\"footnotes\">\n\n")
+		r.closeSections(w, 0)
+		if r.footnoteARIA() {
+			r.outs(w, r.layout("\n<div class=\"footnotes\" role=\"doc-endnotes\">\n\n"))
+		} else {
+			r.outs(w, r.layout("\n<div class=\"footnotes\">\n\n"))
+		}
 		if r.opts.Flags&FootnoteNoHRTag == 0 {
 			r.outHRTag(w, nil)
 			r.cr(w)
@@ -679,7 +1701,7 @@ func (r *Renderer) listEnter(w io.Writer, nodeData *ast.List) {
 	r.cr(w)
 	if isListItem(nodeData.Parent) {
 		grand := nodeData.Parent.GetParent()
-		if isListTight(grand) {
+		if r.isListTight(grand) {
 			r.cr(w)
 		}
 	}
@@ -689,12 +1711,15 @@ func (r *Renderer) listEnter(w io.Writer, nodeData *ast.List) {
 		if nodeData.Start > 0 {
 			attrs = append(attrs, fmt.Sprintf(`start="%d"`, nodeData.Start))
 		}
+		if nodeData.Numbering != 0 && nodeData.Numbering != '1' {
+			attrs = append(attrs, `type="`+string(nodeData.Numbering)+`"`)
+		}
 		openTag = "<ol"
 	}
 	if nodeData.ListFlags&ast.ListTypeDefinition != 0 {
 		openTag = "<dl"
 	}
-	attrs = append(attrs, BlockAttrs(nodeData)...)
+	attrs = append(attrs, blockAttrs(nodeData, r.opts.EscapeFlags)...)
 	r.outTag(w, openTag, attrs)
 	r.cr(w)
 }
@@ -719,12 +1744,12 @@ func (r *Renderer) listExit(w io.Writer, list *ast.List) {
 		if ast.GetNextNode(list) != nil {
 			r.cr(w)
 		}
-	case *ast.Document, *ast.BlockQuote, *ast.Aside:
+	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.Directive, *ast.Component:
 		r.cr(w)
 	}
 
 	if list.IsFootnotesList {
-		r.outs(w, "\n</div>\n")
+		r.outs(w, r.layout("\n</div>\n"))
 	}
 }
 
@@ -737,12 +1762,12 @@ func (r *Renderer) list(w io.Writer, list *ast.List, entering bool) {
 }
 
 func (r *Renderer) listItemEnter(w io.Writer, listItem *ast.ListItem) {
-	if listItemOpenCR(listItem) {
+	if r.listItemOpenCR(listItem) {
 		r.cr(w)
 	}
 	if listItem.RefLink != nil {
-		slug := slugify(listItem.RefLink)
-		r.outs(w, footnoteItem(r.opts.FootnoteAnchorPrefix, slug))
+		id := r.footnoteID(r.footnoteNumber(listItem), listItem.RefLink)
+		r.outs(w, footnoteItem(id, r.opts.Flags&Accessible != 0))
 		return
 	}
 
@@ -753,15 +1778,39 @@ func (r *Renderer) listItemEnter(w io.Writer, listItem *ast.ListItem) {
 	if listItem.ListFlags&ast.ListTypeTerm != 0 {
 		openTag = "<dt>"
 	}
-	r.outs(w, openTag)
+	r.outs(w, r.nodeTag(openTag, listItem))
+	// in loose lists, the checkbox goes in the first paragraph
+	if para, ok := ast.GetFirstChild(listItem).(*ast.Paragraph); listItem.IsTask && (!ok || r.skipParagraphTags(para)) {
+		r.taskCheckbox(w, listItem)
+	}
+}
+
+// taskCheckbox writes the disabled checkbox of a task list item
+func (r *Renderer) taskCheckbox(w io.Writer, item *ast.ListItem) {
+	if r.opts.Flags&UseXHTML != 0 {
+		input := `<input type="checkbox" disabled="disabled"`
+		if item.Checked {
+			input += ` checked="checked"`
+		}
+		r.outs(w, input+" /> ")
+		return
+	}
+	input := `<input type="checkbox" disabled`
+	if item.Checked {
+		input += ` checked`
+	}
+	r.outs(w, input+"> ")
 }
 
 func (r *Renderer) listItemExit(w io.Writer, listItem *ast.ListItem) {
 	if listItem.RefLink != nil && r.opts.Flags&FootnoteReturnLinks != 0 {
-		slug := slugify(listItem.RefLink)
-		prefix := r.opts.FootnoteAnchorPrefix
+		id := r.footnoteID(r.footnoteNumber(listItem), listItem.RefLink)
 		link := r.opts.FootnoteReturnLinkContents
-		s := footnoteReturnLink(prefix, link, slug)
+		label := ""
+		if r.opts.Flags&Accessible != 0 {
+			label = r.opts.FootnoteReturnLinkLabel
+		}
+		s := footnoteReturnLink(id, link, r.footnoteARIA(), label)
 		r.outs(w, s)
 	}
 
@@ -788,17 +1837,35 @@ func (r *Renderer) codeBlock(w io.Writer, codeBlock *ast.CodeBlock) {
 	var attrs []string
 	// TODO(miek): this can add multiple class= attribute, they should be coalesced into one.
 	// This is probably true for some other elements as well
-	attrs = appendLanguageAttr(attrs, codeBlock.Info)
-	attrs = append(attrs, BlockAttrs(codeBlock)...)
+	lang := codeBlock.Language
+	if lang == nil && codeBlock.FenceAttrs == nil {
+		// info string not parsed, e.g. code block created by a parser hook
+		lang = codeBlock.Info
+	}
+	if r.diagram(w, codeBlock, lang) {
+		return
+	}
+	attrs = appendLanguageAttr(attrs, lang, r.opts.EscapeFlags)
+	attrs = append(attrs, blockAttrs(codeBlock, r.opts.EscapeFlags)...)
 	r.cr(w)
 
-	r.outs(w, "<pre>")
-	code := tagWithAttributes("<code", attrs)
+	pre := "<pre>"
+	if attrs := r.elementAttrs("<pre", nil); len(attrs) > 0 {
+		pre = tagWithAttributes("<pre", attrs)
+	}
+	r.outs(w, pre)
+	code := r.tag("<code", attrs)
 	r.outs(w, code)
-	if r.opts.Comments != nil {
-		r.EscapeHTMLCallouts(w, codeBlock.Literal)
+	lineNumbers := r.opts.Flags&CodeLineNumbers != 0
+	if v, ok := codeBlock.FenceAttrs["linenos"]; ok {
+		lineNumbers = string(v) != "false"
+	}
+	maxLine := bytes.Count(codeBlock.Literal, []byte{'\n'}) + 1
+	highlight := parseLineRanges(codeBlock.FenceAttrs["hl_lines"], maxLine)
+	if lineNumbers || len(highlight) > 0 {
+		r.codeLines(w, codeBlock.Literal, lineNumbers, highlight)
 	} else {
-		EscapeHTML(w, codeBlock.Literal)
+		r.codeLiteral(w, codeBlock.Literal)
 	}
 	r.outs(w, "</code>")
 	r.outs(w, "</pre>")
@@ -807,23 +1874,191 @@ func (r *Renderer) codeBlock(w io.Writer, codeBlock *ast.CodeBlock) {
 	}
 }
 
+// diagram delegates rendering of a code block in one of DiagramLanguages
+// to DiagramHook. It returns false if the code block wasn't rendered.
+func (r *Renderer) diagram(w io.Writer, codeBlock *ast.CodeBlock, lang []byte) bool {
+	if r.opts.DiagramHook == nil || !codeBlock.IsFenced {
+		return false
+	}
+	if i := bytes.IndexAny(lang, "\t "); i >= 0 {
+		lang = lang[:i]
+	}
+	for _, l := range r.opts.DiagramLanguages {
+		if !strings.EqualFold(l, string(lang)) {
+			continue
+		}
+		if !r.opts.DiagramHook(w, l, codeBlock.Literal) {
+			return false
+		}
+		r.lastOutputLen = 1
+		return true
+	}
+	return false
+}
+
+func (r *Renderer) codeLiteral(w io.Writer, literal []byte) {
+	if r.opts.Comments != nil {
+		r.EscapeHTMLCallouts(w, literal)
+	} else {
+		r.esc(w, literal)
+	}
+}
+
+// codeLines writes code line by line, prefixing lines with
+// <span class="ln">N</span> and wrapping highlighted lines in
+// <span class="hl">.
+func (r *Renderer) codeLines(w io.Writer, literal []byte, lineNumbers bool, highlight map[int]bool) {
+	lines := bytes.SplitAfter(literal, []byte{'\n'})
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		n := i + 1
+		if highlight[n] {
+			r.outs(w, `<span class="hl">`)
+		}
+		if lineNumbers {
+			r.outs(w, `<span class="ln">`+strconv.Itoa(n)+`</span>`)
+		}
+		r.codeLiteral(w, line)
+		if highlight[n] {
+			r.outs(w, "</span>")
+		}
+	}
+}
+
+// parseLineRanges parses a list of line numbers and ranges of lines, like
+// [2,5-7] or "2 5-7", into a set of line numbers not greater than maxLine.
+func parseLineRanges(v []byte, maxLine int) map[int]bool {
+	fields := strings.FieldsFunc(string(v), func(c rune) bool {
+		return strings.ContainsRune(" \t,[]\"'", c)
+	})
+	var lines map[int]bool
+	for _, f := range fields {
+		from, to := f, f
+		if i := strings.IndexByte(f, '-'); i > 0 {
+			from, to = f[:i], f[i+1:]
+		}
+		start, err1 := strconv.Atoi(from)
+		end, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if end > maxLine {
+			end = maxLine
+		}
+		if lines == nil {
+			lines = map[int]bool{}
+		}
+		for n := start; n <= end; n++ {
+			lines[n] = true
+		}
+	}
+	return lines
+}
+
 func (r *Renderer) caption(w io.Writer, caption *ast.Caption, entering bool) {
 	if entering {
-		r.outs(w, "<figcaption>")
+		r.outs(w, r.nodeTag("<figcaption>", caption))
 		return
 	}
 	r.outs(w, "</figcaption>")
 }
 
+// isQuoteFigure returns true if node is a figure of a blockquote and its
+// caption that should be rendered without the figure (NoQuoteFigures).
+func (r *Renderer) isQuoteFigure(node ast.Node) bool {
+	if r.opts.Flags&NoQuoteFigures == 0 {
+		return false
+	}
+	figure, ok := node.(*ast.CaptionFigure)
+	if !ok || len(figure.Children) != 2 {
+		return false
+	}
+	_, isQuote := figure.Children[0].(*ast.BlockQuote)
+	_, isCaption := figure.Children[1].(*ast.Caption)
+	return isQuote && isCaption
+}
+
+// quoteCaption renders the caption of a blockquote as its last paragraph.
+func (r *Renderer) quoteCaption(w io.Writer, caption *ast.Caption) {
+	r.cr(w)
+	r.outs(w, "<p>")
+	for _, child := range caption.Children {
+		ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.RenderNode(w, node, entering)
+		})
+	}
+	r.outs(w, "</p>")
+	r.cr(w)
+}
+
 func (r *Renderer) captionFigure(w io.Writer, figure *ast.CaptionFigure, entering bool) {
 	// TODO(miek): copy more generic ways of mmark over to here.
-	fig := "<figure"
+	if _, isImage := ast.GetFirstChild(figure).(*ast.Image); isImage && entering {
+		r.cr(w)
+	}
+	var attrs []string
 	if figure.HeadingID != "" {
-		fig += ` id="` + figure.HeadingID + `">`
-	} else {
-		fig += ">"
+		attrs = append(attrs, `id="`+escAttrString(figure.HeadingID, r.opts.EscapeFlags)+`"`)
+	}
+	fig := r.tag("<figure", attrs)
+	r.outOneOf(w, entering, fig, r.layout("\n</figure>\n"))
+}
+
+func (r *Renderer) table(w io.Writer, table *ast.Table, entering bool) {
+	wrapper := r.opts.TableWrapperClass
+	if !entering {
+		r.outs(w, "</table>")
+		r.cr(w)
+		if wrapper != "" {
+			r.outs(w, "</div>")
+			r.cr(w)
+		}
+		return
+	}
+	if wrapper != "" {
+		r.cr(w)
+		r.outs(w, `<div class="`+r.escAttr([]byte(wrapper))+`">`)
+	}
+	r.cr(w)
+	r.outs(w, r.tag("<table", blockAttrs(table, r.opts.EscapeFlags)))
+	if r.opts.Flags&TableColumns != 0 {
+		r.tableColumns(w, table)
+	}
+}
+
+// tableColumns writes a <colgroup> with a <col> for each cell of the first
+// row of table
+func (r *Renderer) tableColumns(w io.Writer, table *ast.Table) {
+	var row ast.Node
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		if _, ok := node.(*ast.TableRow); ok {
+			row = node
+			return ast.Terminate
+		}
+		return ast.GoToNext
+	})
+	if row == nil {
+		return
 	}
-	r.outOneOf(w, entering, fig, "\n</figure>\n")
+	r.cr(w)
+	r.outs(w, "<colgroup>")
+	r.cr(w)
+	for _, child := range row.GetChildren() {
+		cell, ok := child.(*ast.TableCell)
+		if !ok {
+			continue
+		}
+		col := "<col"
+		if align := cell.Align.String(); align != "" {
+			col += ` style="text-align: ` + align + `"`
+		}
+		r.outs(w, col+r.closeTag)
+		r.cr(w)
+	}
+	r.outs(w, "</colgroup>")
+	r.cr(w)
 }
 
 func (r *Renderer) tableCell(w io.Writer, tableCell *ast.TableCell, entering bool) {
@@ -841,8 +2076,17 @@ func (r *Renderer) tableCell(w io.Writer, tableCell *ast.TableCell, entering boo
 	}
 	align := tableCell.Align.String()
 	if align != "" {
-		attrs = append(attrs, fmt.Sprintf(`align="%s"`, align))
+		if r.opts.Flags&XHTMLStrict != 0 {
+			// align attribute is obsolete
+			attrs = append(attrs, `style="text-align: `+align+`"`)
+		} else {
+			attrs = append(attrs, `align="`+align+`"`)
+		}
+	}
+	if tableCell.IsHeader {
+		attrs = append(attrs, `scope="col"`)
 	}
+	attrs = append(attrs, blockAttrs(tableCell, r.opts.EscapeFlags)...)
 	if ast.GetPrevNode(tableCell) == nil {
 		r.cr(w)
 	}
@@ -852,7 +2096,7 @@ func (r *Renderer) tableCell(w io.Writer, tableCell *ast.TableCell, entering boo
 func (r *Renderer) tableBody(w io.Writer, node *ast.TableBody, entering bool) {
 	if entering {
 		r.cr(w)
-		r.outs(w, "<tbody>")
+		r.outs(w, r.nodeTag("<tbody>", node))
 		// XXX: this is to adhere to a rather silly test. Should fix test.
 		if ast.GetFirstChild(node) == nil {
 			r.cr(w)
@@ -867,8 +2111,9 @@ func (r *Renderer) matter(w io.Writer, node *ast.DocumentMatter, entering bool)
 	if !entering {
 		return
 	}
+	r.closeSections(w, 0)
 	if r.documentMatter != ast.DocumentMatterNone {
-		r.outs(w, "</section>\n")
+		r.outs(w, r.layout("</section>\n"))
 	}
 	switch node.Matter {
 	case ast.DocumentMatterFront:
@@ -883,6 +2128,17 @@ func (r *Renderer) matter(w io.Writer, node *ast.DocumentMatter, entering bool)
 
 func (r *Renderer) citation(w io.Writer, node *ast.Citation) {
 	for i, c := range node.Destination {
+		id := c
+		if r.opts.CitationResolver != nil {
+			if !r.resolveCitation(c) {
+				r.outs(w, "<cite>")
+				r.esc(w, c)
+				r.citationSuffix(w, node.Suffix[i])
+				r.outs(w, "</cite>")
+				continue
+			}
+			id = referenceID(c)
+		}
 		attr := []string{`class="none"`}
 		switch node.Type[i] {
 		case ast.CitationTypeNormative:
@@ -893,11 +2149,63 @@ func (r *Renderer) citation(w io.Writer, node *ast.Citation) {
 			attr[0] = `class="suppressed"`
 		}
 		r.outTag(w, "<cite", attr)
-		r.outs(w, fmt.Sprintf(`<a href="#%s">`+r.opts.CitationFormatString+`</a>`, c, c))
+		r.outs(w, fmt.Sprintf(`<a href="#%s">`+r.opts.CitationFormatString+`</a>`, r.escAttr(id), c))
+		r.citationSuffix(w, node.Suffix[i])
 		r.outs(w, "</cite>")
 	}
 }
 
+// citationSuffix writes the locator of a citation, e.g. "p. 33" of
+// [@smith2004, p. 33]
+func (r *Renderer) citationSuffix(w io.Writer, suffix []byte) {
+	if len(suffix) == 0 {
+		return
+	}
+	r.outs(w, ", ")
+	r.esc(w, suffix)
+}
+
+// referenceID returns the ID of the bibliography entry of key. It's
+// prefixed so that it can't clash with IDs of headings.
+func referenceID(key []byte) []byte {
+	return append([]byte("ref-"), key...)
+}
+
+// resolveCitation records bibliography entry of key, it returns false if the key
+// can't be resolved.
+func (r *Renderer) resolveCitation(key []byte) bool {
+	if r.referenceKeys[string(key)] {
+		return true
+	}
+	entry, ok := r.opts.CitationResolver(key)
+	if !ok {
+		return false
+	}
+	if r.referenceKeys == nil {
+		r.referenceKeys = map[string]bool{}
+	}
+	r.referenceKeys[string(key)] = true
+	var buf bytes.Buffer
+	buf.WriteString(`<li id="`)
+	r.esc(&buf, referenceID(key))
+	buf.WriteString(`">`)
+	buf.Write(entry)
+	buf.WriteString(r.layout("</li>\n"))
+	r.references = append(r.references, buf.Bytes())
+	return true
+}
+
+func (r *Renderer) writeReferences(w io.Writer) {
+	if len(r.references) == 0 {
+		return
+	}
+	io.WriteString(w, r.layout("\n<div class=\"references\">\n\n<ol>\n"))
+	for _, ref := range r.references {
+		w.Write(ref)
+	}
+	io.WriteString(w, r.layout("</ol>\n\n</div>\n"))
+}
+
 func (r *Renderer) callout(w io.Writer, node *ast.Callout) {
 	attr := []string{`class="callout"`}
 	r.outTag(w, "<span", attr)
@@ -914,57 +2222,98 @@ func (r *Renderer) index(w io.Writer, node *ast.Index) {
 
 // RenderNode renders a markdown node to HTML
 func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	if list, ok := node.(*ast.List); ok && list.IsFootnotesList && r.skipFootnotes() {
+		return ast.SkipChildren
+	}
+	if r.opts.Flags&IndentedOutput != 0 {
+		r.indent(w, node, entering)
+	}
 	if r.opts.RenderNodeHook != nil {
 		status, didHandle := r.opts.RenderNodeHook(w, node, entering)
 		if didHandle {
 			return status
 		}
 	}
+	for _, hook := range r.opts.RenderNodeHooks {
+		if status, didHandle := hook(w, node, entering); didHandle {
+			return status
+		}
+	}
 	switch node := node.(type) {
 	case *ast.Text:
 		r.text(w, node)
 	case *ast.Softbreak:
-		r.cr(w)
-		// TODO: make it configurable via out(renderer.softbreak)
+		r.softBreak(w, node)
 	case *ast.Hardbreak:
 		r.hardBreak(w, node)
 	case *ast.NonBlockingSpace:
 		r.nonBlockingSpace(w, node)
 	case *ast.Emph:
-		r.outOneOf(w, entering, "<em>", "</em>")
+		r.outOneOf(w, entering, r.nodeTag("<em>", node), "</em>")
 	case *ast.Strong:
-		r.outOneOf(w, entering, "<strong>", "</strong>")
+		r.outOneOf(w, entering, r.nodeTag("<strong>", node), "</strong>")
 	case *ast.Del:
-		r.outOneOf(w, entering, "<del>", "</del>")
+		r.outOneOf(w, entering, r.nodeTag("<del>", node), "</del>")
 	case *ast.BlockQuote:
-		tag := tagWithAttributes("<blockquote", BlockAttrs(node))
+		if !entering && r.isQuoteFigure(node.Parent) {
+			r.quoteCaption(w, ast.GetNextNode(node).(*ast.Caption))
+		}
+		var attrs []string
+		if len(node.Cite) > 0 && !r.isUnsafeLink(node.Cite) {
+			attrs = append(attrs, `cite="`+r.escAttr(node.Cite)+`"`)
+		}
+		attrs = append(attrs, blockAttrs(node, r.opts.EscapeFlags)...)
+		tag := r.tag("<blockquote", attrs)
 		r.outOneOfCr(w, entering, tag, "</blockquote>")
 	case *ast.Aside:
-		tag := tagWithAttributes("<aside", BlockAttrs(node))
+		tag := r.tag("<aside", blockAttrs(node, r.opts.EscapeFlags))
 		r.outOneOfCr(w, entering, tag, "</aside>")
+	case *ast.Directive:
+		r.directive(w, node, entering)
+	case *ast.Component:
+		r.component(w, node, entering)
+	case *ast.LineBlock:
+		r.lineBlock(w, node, entering)
 	case *ast.Link:
 		r.link(w, node, entering)
 	case *ast.CrossReference:
-		link := &ast.Link{Destination: append([]byte("#"), node.Destination...)}
+		dest := string(node.Destination)
+		if _, ok := node.Target.(*ast.Heading); ok {
+			dest = r.opts.HeadingIDPrefix + dest + r.opts.HeadingIDSuffix
+		}
+		link := &ast.Link{Destination: []byte("#" + dest)}
 		r.link(w, link, entering)
+		if entering && len(node.Children) == 0 {
+			r.crossReferenceText(w, node)
+		}
 	case *ast.Citation:
 		r.citation(w, node)
 	case *ast.Image:
 		if r.opts.Flags&SkipImages != 0 {
 			return ast.SkipChildren
 		}
-		r.image(w, node, entering)
+		return r.image(w, node, entering)
 	case *ast.Code:
 		r.code(w, node)
 	case *ast.CodeBlock:
 		r.codeBlock(w, node)
 	case *ast.Caption:
+		if r.isQuoteFigure(node.Parent) {
+			// rendered inside the blockquote
+			return ast.SkipChildren
+		}
 		r.caption(w, node, entering)
 	case *ast.CaptionFigure:
+		if r.isQuoteFigure(node) {
+			break
+		}
 		r.captionFigure(w, node, entering)
 	case *ast.Document:
 		// do nothing
 	case *ast.Paragraph:
+		if r.embedURL(w, node, entering) {
+			return ast.SkipChildren
+		}
 		r.paragraph(w, node, entering)
 	case *ast.HTMLSpan:
 		r.htmlSpan(w, node)
@@ -979,26 +2328,25 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.ListItem:
 		r.listItem(w, node, entering)
 	case *ast.Table:
-		tag := tagWithAttributes("<table", BlockAttrs(node))
-		r.outOneOfCr(w, entering, tag, "</table>")
+		r.table(w, node, entering)
 	case *ast.TableCell:
 		r.tableCell(w, node, entering)
 	case *ast.TableHeader:
-		r.outOneOfCr(w, entering, "<thead>", "</thead>")
+		r.outOneOfCr(w, entering, r.nodeTag("<thead>", node), "</thead>")
 	case *ast.TableBody:
 		r.tableBody(w, node, entering)
 	case *ast.TableRow:
-		r.outOneOfCr(w, entering, "<tr>", "</tr>")
+		r.outOneOfCr(w, entering, r.nodeTag("<tr>", node), "</tr>")
 	case *ast.TableFooter:
-		r.outOneOfCr(w, entering, "<tfoot>", "</tfoot>")
+		r.outOneOfCr(w, entering, r.nodeTag("<tfoot>", node), "</tfoot>")
 	case *ast.Math:
 		r.outOneOf(w, true, `<span class="math inline">\(`, `\)</span>`)
-		EscapeHTML(w, node.Literal)
+		r.esc(w, node.Literal)
 		r.outOneOf(w, false, `<span class="math inline">\(`, `\)</span>`)
 	case *ast.MathBlock:
 		r.outOneOf(w, entering, `<p><span class="math display">\[`, `\]</span></p>`)
 		if entering {
-			EscapeHTML(w, node.Literal)
+			r.esc(w, node.Literal)
 		}
 	case *ast.DocumentMatter:
 		r.matter(w, node, entering)
@@ -1006,14 +2354,26 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		r.callout(w, node)
 	case *ast.Index:
 		r.index(w, node)
+	case *ast.Kbd:
+		r.outOneOf(w, entering, r.nodeTag("<kbd>", node), "</kbd>")
+	case *ast.Dfn:
+		r.outOneOf(w, entering, r.nodeTag("<dfn>", node), "</dfn>")
+	case *ast.Ruby:
+		if entering {
+			r.outs(w, r.nodeTag("<ruby>", node))
+		} else {
+			r.outs(w, "<rt>")
+			r.esc(w, node.Annotation)
+			r.outs(w, "</rt></ruby>")
+		}
 	case *ast.Subscript:
-		r.outOneOf(w, true, "<sub>", "</sub>")
+		r.outOneOf(w, true, r.nodeTag("<sub>", node), "</sub>")
 		if entering {
 			Escape(w, node.Literal)
 		}
 		r.outOneOf(w, false, "<sub>", "</sub>")
 	case *ast.Superscript:
-		r.outOneOf(w, true, "<sup>", "</sup>")
+		r.outOneOf(w, true, r.nodeTag("<sup>", node), "</sup>")
 		if entering {
 			Escape(w, node.Literal)
 		}
@@ -1021,14 +2381,23 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 	case *ast.Footnotes:
 		// nothing by default; just output the list.
 	default:
+		for _, render := range r.opts.NodeRenderers {
+			if status, didHandle := render(w, node, entering); didHandle {
+				return status
+			}
+		}
 		panic(fmt.Sprintf("Unknown node %T", node))
 	}
 	return ast.GoToNext
 }
 
 // RenderHeader writes HTML document preamble and TOC if requested.
+//
+// It also resets the state left over from rendering a previous document,
+// so a Renderer can be re-used sequentially.
 func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
-	r.writeDocumentHeader(w)
+	r.reset()
+	r.writeDocumentHeader(w, ast)
 	if r.opts.Flags&TOC != 0 {
 		r.writeTOC(w, ast)
 	}
@@ -1036,37 +2405,137 @@ func (r *Renderer) RenderHeader(w io.Writer, ast ast.Node) {
 
 // RenderFooter writes HTML document footer.
 func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	r.closeSections(w, 0)
+	r.writeReferences(w)
 	if r.documentMatter != ast.DocumentMatterNone {
-		r.outs(w, "</section>\n")
+		r.outs(w, r.layout("</section>\n"))
+	}
+	if r.opts.Flags&IndentedOutput != 0 && r.lastOutputLen > 0 {
+		r.outs(w, "\n")
 	}
 
 	if r.opts.Flags&CompletePage == 0 {
 		return
 	}
+	if r.opts.Flags&Accessible != 0 {
+		io.WriteString(w, "\n</main>")
+	}
 	io.WriteString(w, "\n</body>\n</html>\n")
 }
 
-func (r *Renderer) writeDocumentHeader(w io.Writer) {
+// titleblockTitle returns the first line of the title block of doc, or ""
+func titleblockTitle(doc ast.Node) string {
+	h, ok := ast.FindFirst(doc, func(node ast.Node) bool {
+		h, ok := node.(*ast.Heading)
+		return ok && h.IsTitleblock
+	}).(*ast.Heading)
+	if !ok {
+		return ""
+	}
+	var text bytes.Buffer
+	ast.WalkFunc(h, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Text, *ast.Code:
+			text.Write(node.AsLeaf().Literal)
+		}
+		return ast.GoToNext
+	})
+	line := text.String()
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+func (r *Renderer) metaMapping() *MetaMapping {
+	if r.opts.MetaMapping != nil {
+		return r.opts.MetaMapping
+	}
+	return &DefaultMetaMapping
+}
+
+// metaField returns the value of field name of RendererOptions.Meta
+func (r *Renderer) metaField(name string) string {
+	if name == "" {
+		return ""
+	}
+	return r.opts.Meta[name]
+}
+
+// writeMetaTags writes description, canonical link and Open Graph tags
+// from RendererOptions.Meta
+func (r *Renderer) writeMetaTags(w io.Writer, ending string) {
+	if len(r.opts.Meta) == 0 {
+		return
+	}
+	m := r.metaMapping()
+	tag := func(format string, attr string, value string) {
+		if value == "" {
+			return
+		}
+		io.WriteString(w, "  ")
+		fmt.Fprintf(w, format, attr, r.escAttr([]byte(value)))
+		io.WriteString(w, ending)
+		io.WriteString(w, ">\n")
+	}
+	description := r.metaField(m.Description)
+	canonical := r.metaField(m.Canonical)
+	tag(`<meta name="%s" content="%s"`, "description", description)
+	tag(`<link rel="%s" href="%s"`, "canonical", canonical)
+	tag(`<meta property="%s" content="%s"`, "og:title", r.metaField(m.Title))
+	tag(`<meta property="%s" content="%s"`, "og:description", description)
+	tag(`<meta property="%s" content="%s"`, "og:url", canonical)
+	tag(`<meta property="%s" content="%s"`, "og:image", r.metaField(m.Image))
+}
+
+func (r *Renderer) writeDocumentHeader(w io.Writer, doc ast.Node) {
 	if r.opts.Flags&CompletePage == 0 {
 		return
 	}
+	docTitle := r.opts.Title
+	if docTitle == "" {
+		docTitle = r.metaField(r.metaMapping().Title)
+	}
+	if docTitle == "" {
+		docTitle = titleblockTitle(doc)
+	}
 	ending := ""
 	if r.opts.Flags&UseXHTML != 0 {
-		io.WriteString(w, "<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Transitional//EN\" ")
-		io.WriteString(w, "\"http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd\">\n")
-		io.WriteString(w, "<html xmlns=\"http://www.w3.org/1999/xhtml\">\n")
+		if r.opts.Flags&XHTMLStrict != 0 {
+			io.WriteString(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+			io.WriteString(w, "<!DOCTYPE html>\n")
+		} else {
+			io.WriteString(w, "<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Transitional//EN\" ")
+			io.WriteString(w, "\"http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd\">\n")
+		}
+		io.WriteString(w, "<html xmlns=\"")
+		r.esc(w, []byte(r.opts.XMLNS))
+		io.WriteString(w, "\"")
+		if r.opts.Lang != "" {
+			io.WriteString(w, " xml:lang=\"")
+			r.esc(w, []byte(r.opts.Lang))
+			io.WriteString(w, "\"")
+		}
 		ending = " /"
 	} else {
 		io.WriteString(w, "<!DOCTYPE html>\n")
-		io.WriteString(w, "<html>\n")
+		io.WriteString(w, "<html")
+	}
+	if r.opts.Lang != "" {
+		io.WriteString(w, " lang=\"")
+		r.esc(w, []byte(r.opts.Lang))
+		io.WriteString(w, "\"")
 	}
+	io.WriteString(w, ">\n")
 	io.WriteString(w, "<head>\n")
 	io.WriteString(w, "  <title>")
+	var title bytes.Buffer
 	if r.opts.Flags&Smartypants != 0 {
-		r.sr.Process(w, []byte(r.opts.Title))
+		r.sr.Process(&title, []byte(docTitle))
 	} else {
-		EscapeHTML(w, []byte(r.opts.Title))
+		r.esc(&title, []byte(docTitle))
 	}
+	r.outXML(w, title.Bytes())
 	io.WriteString(w, "</title>\n")
 	io.WriteString(w, r.opts.Generator)
 	io.WriteString(w, "\"")
@@ -1075,16 +2544,17 @@ func (r *Renderer) writeDocumentHeader(w io.Writer) {
 	io.WriteString(w, "  <meta charset=\"utf-8\"")
 	io.WriteString(w, ending)
 	io.WriteString(w, ">\n")
+	r.writeMetaTags(w, ending)
 	if r.opts.CSS != "" {
 		io.WriteString(w, "  <link rel=\"stylesheet\" type=\"text/css\" href=\"")
-		EscapeHTML(w, []byte(r.opts.CSS))
+		r.esc(w, []byte(r.opts.CSS))
 		io.WriteString(w, "\"")
 		io.WriteString(w, ending)
 		io.WriteString(w, ">\n")
 	}
 	if r.opts.Icon != "" {
 		io.WriteString(w, "  <link rel=\"icon\" type=\"image/x-icon\" href=\"")
-		EscapeHTML(w, []byte(r.opts.Icon))
+		r.esc(w, []byte(r.opts.Icon))
 		io.WriteString(w, "\"")
 		io.WriteString(w, ending)
 		io.WriteString(w, ">\n")
@@ -1092,8 +2562,18 @@ func (r *Renderer) writeDocumentHeader(w io.Writer) {
 	if r.opts.Head != nil {
 		w.Write(r.opts.Head)
 	}
+	if r.opts.HeadHook != nil {
+		r.opts.HeadHook(w)
+	}
 	io.WriteString(w, "</head>\n")
 	io.WriteString(w, "<body>\n\n")
+	if r.opts.Flags&Accessible != 0 {
+		id := escAttrString(r.ensureUniqueHeadingID(r.opts.MainID), 0)
+		io.WriteString(w, "<a class=\"skip-link\" href=\"#"+id+"\">")
+		r.esc(w, []byte(r.opts.SkipLinkText))
+		io.WriteString(w, "</a>\n")
+		io.WriteString(w, "<main id=\""+id+"\">\n\n")
+	}
 }
 
 func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
@@ -1102,32 +2582,59 @@ func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
 	inHeading := false
 	tocLevel := 0
 	headingCount := 0
+	minLevel, maxLevel := r.opts.TOCLevelMin, r.opts.TOCLevelMax
+	if minLevel < 1 {
+		minLevel = 1
+	}
+	if maxLevel < 1 {
+		maxLevel = 6
+	}
+	listTag := r.opts.TOCListTag
+	if listTag == "" {
+		listTag = "ul"
+	}
 
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
 		if nodeData, ok := node.(*ast.Heading); ok && !nodeData.IsTitleblock {
+			var anchor string
+			if entering {
+				// keep IDs given by the author or AutoHeadingIDs, number the
+				// others independently of the levels listed
+				if nodeData.HeadingID == "" && r.opts.SlugifyFunc != nil {
+					nodeData.HeadingID = r.opts.SlugifyFunc(ast.HeadingText(nodeData))
+				}
+				if nodeData.HeadingID == "" {
+					nodeData.HeadingID = fmt.Sprintf("toc_%d", headingCount)
+				}
+				headingCount++
+				anchor = r.headingAnchor(nodeData)
+			}
+			if nodeData.Level < minLevel || nodeData.Level > maxLevel {
+				return ast.SkipChildren
+			}
 			inHeading = entering
 			if !entering {
 				buf.WriteString("</a>")
 				return ast.GoToNext
 			}
-			nodeData.HeadingID = fmt.Sprintf("toc_%d", headingCount)
-			if nodeData.Level == tocLevel {
-				buf.WriteString("</li>\n\n<li>")
-			} else if nodeData.Level < tocLevel {
-				for nodeData.Level < tocLevel {
+			level := nodeData.Level - minLevel + 1
+			if level == tocLevel {
+				buf.WriteString(r.layout("</li>\n\n<li>"))
+			} else if level < tocLevel {
+				for level < tocLevel {
 					tocLevel--
-					buf.WriteString("</li>\n</ul>")
+					buf.WriteString(r.layout("</li>\n</" + listTag + ">"))
 				}
-				buf.WriteString("</li>\n\n<li>")
+				buf.WriteString(r.layout("</li>\n\n<li>"))
 			} else {
-				for nodeData.Level > tocLevel {
+				for level > tocLevel {
 					tocLevel++
-					buf.WriteString("\n<ul>\n<li>")
+					buf.WriteString(r.layout("\n<" + listTag + ">\n<li>"))
 				}
 			}
 
-			fmt.Fprintf(&buf, `<a href="#toc_%d">`, headingCount)
-			headingCount++
+			buf.WriteString(`<a href="#` + escAttrString(anchor, r.opts.EscapeFlags) + `">`)
+			r.headingNumber(&buf, nodeData)
 			return ast.GoToNext
 		}
 
@@ -1139,13 +2646,20 @@ func (r *Renderer) writeTOC(w io.Writer, doc ast.Node) {
 	})
 
 	for ; tocLevel > 0; tocLevel-- {
-		buf.WriteString("</li>\n</ul>")
+		buf.WriteString(r.layout("</li>\n</" + listTag + ">"))
 	}
 
 	if buf.Len() > 0 {
-		io.WriteString(w, "<nav>\n")
+		var attrs []string
+		if r.opts.TOCLabel != "" {
+			attrs = append(attrs, `aria-label="`+r.escAttr([]byte(r.opts.TOCLabel))+`"`)
+		}
+		if r.opts.TOCClass != "" {
+			attrs = append(attrs, `class="`+r.escAttr([]byte(r.opts.TOCClass))+`"`)
+		}
+		io.WriteString(w, r.layout(r.tag("<nav", attrs)+"\n"))
 		w.Write(buf.Bytes())
-		io.WriteString(w, "\n\n</nav>\n")
+		io.WriteString(w, r.layout("\n\n</nav>\n"))
 	}
 	r.lastOutputLen = buf.Len()
 }
@@ -1155,11 +2669,18 @@ func isList(node ast.Node) bool {
 	return ok
 }
 
-func isListTight(node ast.Node) bool {
-	if list, ok := node.(*ast.List); ok {
-		return list.Tight
+// isListTight returns true if node is a list whose paragraphs are rendered
+// without <p> tags, as set by LooseLists and TightLists flags or else by
+// the blank lines between items in the source
+func (r *Renderer) isListTight(node ast.Node) bool {
+	list, ok := node.(*ast.List)
+	switch {
+	case !ok, r.opts.Flags&LooseLists != 0:
+		return false
+	case r.opts.Flags&TightLists != 0:
+		return true
 	}
-	return false
+	return list.Tight
 }
 
 func isListItem(node ast.Node) bool {
@@ -1172,74 +2693,113 @@ func isListItemTerm(node ast.Node) bool {
 	return ok && data.ListFlags&ast.ListTypeTerm != 0
 }
 
-// TODO: move to internal package
-func skipSpace(data []byte, i int) int {
-	n := len(data)
-	for i < n && isSpace(data[i]) {
-		i++
-	}
-	return i
-}
+// DefaultLinkProtocols is the list of protocols considered safe by Safelink
+// when RendererOptions.AllowedLinkProtocols is not set.
+var DefaultLinkProtocols = []string{"http://", "https://", "ftp://", "mailto:"}
+
+// DefaultDiagramLanguages is the list of fence languages passed to
+// DiagramHook when RendererOptions.DiagramLanguages is not set.
+var DefaultDiagramLanguages = []string{"mermaid", "dot", "graphviz", "plantuml"}
 
 // TODO: move to internal package
-var validUris = [][]byte{[]byte("http://"), []byte("https://"), []byte("ftp://"), []byte("mailto://")}
 var validPaths = [][]byte{[]byte("/"), []byte("./"), []byte("../")}
 
-func isSafeLink(link []byte) bool {
+func isSafeLink(link []byte, protocols []string) bool {
+	if isRelativeURL(link) {
+		return true
+	}
 	for _, path := range validPaths {
 		if len(link) >= len(path) && bytes.Equal(link[:len(path)], path) {
 			if len(link) == len(path) {
 				return true
-			} else if isAlnum(link[len(path)]) {
+			} else if isPathStart(link[len(path):]) {
 				return true
 			}
 		}
 	}
 
-	for _, prefix := range validUris {
-		// TODO: handle unicode here
-		// case-insensitive prefix test
-		if len(link) > len(prefix) && bytes.Equal(bytes.ToLower(link[:len(prefix)]), prefix) && isAlnum(link[len(prefix)]) {
-			return true
+	for _, prefix := range protocols {
+		if len(link) <= len(prefix) || !strings.EqualFold(string(link[:len(prefix)]), prefix) {
+			continue
 		}
+		// for hierarchical protocols (http:// etc.) a host must follow
+		if strings.HasSuffix(prefix, "//") && !hasHost(link) {
+			continue
+		}
+		return true
 	}
 
 	return false
 }
 
+// isRelativeURL returns true if link is a path relative to the document,
+// without a scheme, e.g. img.jpg or docs/page.html#intro. Browsers ignore
+// tabs and newlines in URLs, so links with control characters aren't
+// considered relative.
+func isRelativeURL(link []byte) bool {
+	if len(link) == 0 || link[0] == '/' || link[0] == '\\' {
+		return false
+	}
+	for _, c := range link {
+		switch {
+		case c < ' ' || c == 0x7f || c == ':':
+			return false
+		case c == '/' || c == '?' || c == '#':
+			return true
+		}
+	}
+	return true
+}
+
+// isPathStart returns true if d starts with a letter or digit, including
+// Unicode ones, or with a percent-encoded byte
+func isPathStart(d []byte) bool {
+	if len(d) >= 3 && d[0] == '%' && isHexDigit(d[1]) && isHexDigit(d[2]) {
+		return true
+	}
+	r, _ := utf8.DecodeRune(d)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// hasHost returns true if link is a valid URL with a host starting with a
+// letter or digit, e.g. an internationalized domain name like bücher.de
+// (or its punycode form xn--bcher-kva.de), or with an IPv6 address
+func hasHost(link []byte) bool {
+	u, err := url.Parse(string(link))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Host[0] == '[' {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(u.Host)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
 // TODO: move to internal package
-// Create a url-safe slug for fragments
+// Create a url-safe slug for fragments, keeping Unicode letters and digits
 func slugify(in []byte) []byte {
-	if len(in) == 0 {
-		return in
-	}
 	out := make([]byte, 0, len(in))
 	sym := false
-
-	for _, ch := range in {
-		if isAlnum(ch) {
+	for _, r := range string(in) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop combining marks, e.g. accents of decomposed letters
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if sym && len(out) > 0 {
+				out = append(out, '-')
+			}
 			sym = false
-			out = append(out, ch)
-		} else if sym {
-			continue
-		} else {
-			out = append(out, '-')
+			out = append(out, string(r)...)
+		default:
 			sym = true
 		}
 	}
-	var a, b int
-	var ch byte
-	for a, ch = range out {
-		if ch != '-' {
-			break
-		}
-	}
-	for b = len(out) - 1; b > 0; b-- {
-		if out[b] != '-' {
-			break
-		}
-	}
-	return out[a : b+1]
+	return out
 }
 
 // TODO: move to internal package
@@ -1269,31 +2829,32 @@ func isPunctuation(c byte) bool {
 	return false
 }
 
-// BlockAttrs takes a node and checks if it has block level attributes set. If so it
-// will return a slice each containing a "key=value(s)" string.
+// BlockAttrs takes a node and checks if it has attributes set. If so it
+// will return a slice each containing a "key=value(s)" string. Despite the
+// name, it works for inline nodes too.
 func BlockAttrs(node ast.Node) []string {
-	var attr *ast.Attribute
-	if c := node.AsContainer(); c != nil && c.Attribute != nil {
-		attr = c.Attribute
-	}
-	if l := node.AsLeaf(); l != nil && l.Attribute != nil {
-		attr = l.Attribute
-	}
+	return blockAttrs(node, 0)
+}
+
+// blockAttrs returns the attributes of node like BlockAttrs, escaped
+// according to flags.
+func blockAttrs(node ast.Node, flags EscapeFlags) []string {
+	attr := ast.GetAttribute(node)
 	if attr == nil {
 		return nil
 	}
 
 	var s []string
 	if attr.ID != nil {
-		s = append(s, fmt.Sprintf(`%s="%s"`, IDTag, attr.ID))
+		s = append(s, IDTag+`="`+escAttrValue(attr.ID, flags)+`"`)
 	}
 
-	classes := ""
-	for _, c := range attr.Classes {
-		classes += " " + string(c)
-	}
-	if classes != "" {
-		s = append(s, fmt.Sprintf(`class="%s"`, classes[1:])) // skip space we added.
+	if len(attr.Classes) > 0 {
+		classes := make([]string, len(attr.Classes))
+		for i, c := range attr.Classes {
+			classes[i] = escAttrValue(c, flags)
+		}
+		s = append(s, `class="`+strings.Join(classes, " ")+`"`)
 	}
 
 	// sort the attributes so it remain stable between runs
@@ -1303,12 +2864,41 @@ func BlockAttrs(node ast.Node) []string {
 	}
 	sort.Strings(keys)
 	for _, k := range keys {
-		s = append(s, fmt.Sprintf(`%s="%s"`, k, attr.Attrs[k]))
+		s = append(s, k+`="`+escAttrValue(attr.Attrs[k], flags)+`"`)
 	}
 
 	return s
 }
 
+// escAttrValue returns v escaped for use as a quoted attribute value
+func escAttrValue(v []byte, flags EscapeFlags) string {
+	if flags == 0 && bytes.IndexAny(v, `&<>"`) < 0 {
+		return string(v)
+	}
+	var buf bytes.Buffer
+	EscapeHTMLFlags(&buf, v, flags)
+	return buf.String()
+}
+
+// escAttrString returns s escaped for use as a quoted attribute value
+func escAttrString(s string, flags EscapeFlags) string {
+	if flags == 0 && !strings.ContainsAny(s, `&<>"`) {
+		return s
+	}
+	return escAttrValue([]byte(s), flags)
+}
+
+// esc writes d html-escaped according to RendererOptions.EscapeFlags
+func (r *Renderer) esc(w io.Writer, d []byte) {
+	EscapeHTMLFlags(w, d, r.opts.EscapeFlags)
+}
+
+// escAttr returns v escaped for use as a quoted attribute value according
+// to RendererOptions.EscapeFlags
+func (r *Renderer) escAttr(v []byte) string {
+	return escAttrValue(v, r.opts.EscapeFlags)
+}
+
 func tagWithAttributes(name string, attrs []string) string {
 	s := name
 	if len(attrs) > 0 {
@@ -7,11 +7,76 @@ import (
 
 // SmartyPants rendering
 
+// SmartypantsOptions configures substitutions done by Smartypants renderer.
+// Empty strings are replaced with the defaults. E.g. German quotes are
+// configured with:
+//
+//	opts := html.SmartypantsOptions{
+//		DoubleQuotes: [2]string{"&bdquo;", "&ldquo;"},
+//		SingleQuotes: [2]string{"&sbquo;", "&lsquo;"},
+//	}
+type SmartypantsOptions struct {
+	DoubleQuotes [2]string // Opening and closing double quotes, defaults to &ldquo; and &rdquo;
+	SingleQuotes [2]string // Opening and closing single quotes, defaults to &lsquo; and &rsquo;
+	Apostrophe   string    // Apostrophe in contractions like "don't", defaults to &rsquo;
+	Ellipsis     string    // Replacement of "...", defaults to &hellip;
+	QuotesNBSP   bool      // Add &nbsp; inside double quotes, like « French guillemets »
+	Dashes       bool      // Replace -- with em dash and - between spaces with en dash
+	LatexDashes  bool      // With Dashes, replace --- with em dash and -- with en dash
+	Fractions    bool      // Replace any n/m with a fraction, not only 1/2, 1/4 and 3/4
+
+	// Callbacks, if set, add custom substitutions or replace the built-in
+	// ones for the given characters (a nil callback disables it). Allows
+	// implementing locale specific typographic rules, e.g. a non-breaking
+	// space before French punctuation:
+	//
+	//	opts.Callbacks = map[byte]html.SmartypantsCallback{
+	//		' ': func(out *bytes.Buffer, previousChar byte, text []byte) int {
+	//			if len(text) > 1 && strings.IndexByte("!?;:", text[1]) >= 0 {
+	//				out.WriteString("&nbsp;")
+	//			} else {
+	//				out.WriteByte(' ')
+	//			}
+	//			return 0
+	//		},
+	//	}
+	Callbacks map[byte]SmartypantsCallback
+}
+
+// SmartypantsCallback is a substitution done by Smartypants renderer for
+// a character. text starts with the character and continues till the end of
+// the text being processed, which is already HTML escaped. previousChar is the
+// character preceding it, or 0 at the start. The callback writes the
+// replacement to out and returns the number of bytes of text it consumed
+// in addition to the first one.
+type SmartypantsCallback func(out *bytes.Buffer, previousChar byte, text []byte) int
+
+// SmartypantsOptionsFromFlags returns SmartypantsOptions equivalent to
+// Smartypants* flags.
+func SmartypantsOptionsFromFlags(flags Flags) SmartypantsOptions {
+	opts := SmartypantsOptions{
+		QuotesNBSP:  flags&SmartypantsQuotesNBSP != 0,
+		Dashes:      flags&SmartypantsDashes != 0,
+		LatexDashes: flags&SmartypantsLatexDashes != 0,
+		Fractions:   flags&SmartypantsFractions != 0,
+	}
+	if flags&SmartypantsAngledQuotes != 0 {
+		opts.DoubleQuotes = [2]string{"&laquo;", "&raquo;"}
+	}
+	return opts
+}
+
 // SPRenderer is a struct containing state of a Smartypants renderer.
 type SPRenderer struct {
 	inSingleQuote bool
 	inDoubleQuote bool
-	callbacks     [256]smartCallback
+	callbacks     [256]SmartypantsCallback
+
+	doubleQuotes [2]string
+	singleQuotes [2]string
+	apostrophe   string
+	ellipsis     string
+	quotesNBSP   bool
 }
 
 func wordBoundary(c byte) bool {
@@ -29,7 +94,7 @@ func isdigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
-func smartQuoteHelper(out *bytes.Buffer, previousChar byte, nextChar byte, quote byte, isOpen *bool, addNBSP bool) bool {
+func smartQuoteHelper(out *bytes.Buffer, previousChar byte, nextChar byte, quotes [2]string, isOpen *bool, addNBSP bool) bool {
 	// edge of the buffer is likely to be a tag that we don't get to see,
 	// so we treat it like text sometimes
 
@@ -92,14 +157,11 @@ func smartQuoteHelper(out *bytes.Buffer, previousChar byte, nextChar byte, quote
 		out.WriteString("&nbsp;")
 	}
 
-	out.WriteByte('&')
 	if *isOpen {
-		out.WriteByte('l')
+		out.WriteString(quotes[0])
 	} else {
-		out.WriteByte('r')
+		out.WriteString(quotes[1])
 	}
-	out.WriteByte(quote)
-	out.WriteString("quo;")
 
 	if addNBSP && *isOpen {
 		out.WriteString("&nbsp;")
@@ -117,13 +179,13 @@ func (r *SPRenderer) smartSingleQuote(out *bytes.Buffer, previousChar byte, text
 			if len(text) >= 3 {
 				nextChar = text[2]
 			}
-			if smartQuoteHelper(out, previousChar, nextChar, 'd', &r.inDoubleQuote, false) {
+			if smartQuoteHelper(out, previousChar, nextChar, r.doubleQuotes, &r.inDoubleQuote, false) {
 				return 1
 			}
 		}
 
 		if (t1 == 's' || t1 == 't' || t1 == 'm' || t1 == 'd') && (len(text) < 3 || wordBoundary(text[2])) {
-			out.WriteString("&rsquo;")
+			out.WriteString(r.apostrophe)
 			return 0
 		}
 
@@ -132,7 +194,7 @@ func (r *SPRenderer) smartSingleQuote(out *bytes.Buffer, previousChar byte, text
 
 			if ((t1 == 'r' && t2 == 'e') || (t1 == 'l' && t2 == 'l') || (t1 == 'v' && t2 == 'e')) &&
 				(len(text) < 4 || wordBoundary(text[3])) {
-				out.WriteString("&rsquo;")
+				out.WriteString(r.apostrophe)
 				return 0
 			}
 		}
@@ -142,7 +204,7 @@ func (r *SPRenderer) smartSingleQuote(out *bytes.Buffer, previousChar byte, text
 	if len(text) > 1 {
 		nextChar = text[1]
 	}
-	if smartQuoteHelper(out, previousChar, nextChar, 's', &r.inSingleQuote, false) {
+	if smartQuoteHelper(out, previousChar, nextChar, r.singleQuotes, &r.inSingleQuote, false) {
 		return 0
 	}
 
@@ -206,13 +268,13 @@ func (r *SPRenderer) smartDashLatex(out *bytes.Buffer, previousChar byte, text [
 	return 0
 }
 
-func (r *SPRenderer) smartAmpVariant(out *bytes.Buffer, previousChar byte, text []byte, quote byte, addNBSP bool) int {
+func (r *SPRenderer) smartAmp(out *bytes.Buffer, previousChar byte, text []byte) int {
 	if bytes.HasPrefix(text, []byte("&quot;")) {
 		nextChar := byte(0)
 		if len(text) >= 7 {
 			nextChar = text[6]
 		}
-		if smartQuoteHelper(out, previousChar, nextChar, quote, &r.inDoubleQuote, addNBSP) {
+		if smartQuoteHelper(out, previousChar, nextChar, r.doubleQuotes, &r.inDoubleQuote, r.quotesNBSP) {
 			return 5
 		}
 	}
@@ -225,25 +287,14 @@ func (r *SPRenderer) smartAmpVariant(out *bytes.Buffer, previousChar byte, text
 	return 0
 }
 
-func (r *SPRenderer) smartAmp(angledQuotes, addNBSP bool) func(*bytes.Buffer, byte, []byte) int {
-	var quote byte = 'd'
-	if angledQuotes {
-		quote = 'a'
-	}
-
-	return func(out *bytes.Buffer, previousChar byte, text []byte) int {
-		return r.smartAmpVariant(out, previousChar, text, quote, addNBSP)
-	}
-}
-
 func (r *SPRenderer) smartPeriod(out *bytes.Buffer, previousChar byte, text []byte) int {
 	if len(text) >= 3 && text[1] == '.' && text[2] == '.' {
-		out.WriteString("&hellip;")
+		out.WriteString(r.ellipsis)
 		return 2
 	}
 
 	if len(text) >= 5 && text[1] == ' ' && text[2] == '.' && text[3] == ' ' && text[4] == '.' {
-		out.WriteString("&hellip;")
+		out.WriteString(r.ellipsis)
 		return 4
 	}
 
@@ -257,7 +308,7 @@ func (r *SPRenderer) smartBacktick(out *bytes.Buffer, previousChar byte, text []
 		if len(text) >= 3 {
 			nextChar = text[2]
 		}
-		if smartQuoteHelper(out, previousChar, nextChar, 'd', &r.inDoubleQuote, false) {
+		if smartQuoteHelper(out, previousChar, nextChar, r.doubleQuotes, &r.inDoubleQuote, false) {
 			return 1
 		}
 	}
@@ -336,26 +387,18 @@ func (r *SPRenderer) smartNumber(out *bytes.Buffer, previousChar byte, text []by
 	return 0
 }
 
-func (r *SPRenderer) smartDoubleQuoteVariant(out *bytes.Buffer, previousChar byte, text []byte, quote byte) int {
+func (r *SPRenderer) smartDoubleQuote(out *bytes.Buffer, previousChar byte, text []byte) int {
 	nextChar := byte(0)
 	if len(text) > 1 {
 		nextChar = text[1]
 	}
-	if !smartQuoteHelper(out, previousChar, nextChar, quote, &r.inDoubleQuote, false) {
+	if !smartQuoteHelper(out, previousChar, nextChar, r.doubleQuotes, &r.inDoubleQuote, false) {
 		out.WriteString("&quot;")
 	}
 
 	return 0
 }
 
-func (r *SPRenderer) smartDoubleQuote(out *bytes.Buffer, previousChar byte, text []byte) int {
-	return r.smartDoubleQuoteVariant(out, previousChar, text, 'd')
-}
-
-func (r *SPRenderer) smartAngledDoubleQuote(out *bytes.Buffer, previousChar byte, text []byte) int {
-	return r.smartDoubleQuoteVariant(out, previousChar, text, 'a')
-}
-
 func (r *SPRenderer) smartLeftAngle(out *bytes.Buffer, previousChar byte, text []byte) int {
 	i := 0
 
@@ -367,47 +410,53 @@ func (r *SPRenderer) smartLeftAngle(out *bytes.Buffer, previousChar byte, text [
 	return i
 }
 
-type smartCallback func(out *bytes.Buffer, previousChar byte, text []byte) int
-
 // NewSmartypantsRenderer constructs a Smartypants renderer object.
 func NewSmartypantsRenderer(flags Flags) *SPRenderer {
-	var (
-		r SPRenderer
-
-		smartAmpAngled      = r.smartAmp(true, false)
-		smartAmpAngledNBSP  = r.smartAmp(true, true)
-		smartAmpRegular     = r.smartAmp(false, false)
-		smartAmpRegularNBSP = r.smartAmp(false, true)
-
-		addNBSP = flags&SmartypantsQuotesNBSP != 0
-	)
+	return NewSmartypantsRendererWithOptions(SmartypantsOptionsFromFlags(flags))
+}
 
-	if flags&SmartypantsAngledQuotes == 0 {
-		r.callbacks['"'] = r.smartDoubleQuote
-		if !addNBSP {
-			r.callbacks['&'] = smartAmpRegular
-		} else {
-			r.callbacks['&'] = smartAmpRegularNBSP
-		}
-	} else {
-		r.callbacks['"'] = r.smartAngledDoubleQuote
-		if !addNBSP {
-			r.callbacks['&'] = smartAmpAngled
-		} else {
-			r.callbacks['&'] = smartAmpAngledNBSP
-		}
+// NewSmartypantsRendererWithOptions constructs a Smartypants renderer
+// object configured with opts.
+func NewSmartypantsRendererWithOptions(opts SmartypantsOptions) *SPRenderer {
+	r := SPRenderer{
+		doubleQuotes: opts.DoubleQuotes,
+		singleQuotes: opts.SingleQuotes,
+		apostrophe:   opts.Apostrophe,
+		ellipsis:     opts.Ellipsis,
+		quotesNBSP:   opts.QuotesNBSP,
+	}
+	if r.doubleQuotes[0] == "" {
+		r.doubleQuotes[0] = "&ldquo;"
+	}
+	if r.doubleQuotes[1] == "" {
+		r.doubleQuotes[1] = "&rdquo;"
+	}
+	if r.singleQuotes[0] == "" {
+		r.singleQuotes[0] = "&lsquo;"
+	}
+	if r.singleQuotes[1] == "" {
+		r.singleQuotes[1] = "&rsquo;"
 	}
+	if r.apostrophe == "" {
+		r.apostrophe = "&rsquo;"
+	}
+	if r.ellipsis == "" {
+		r.ellipsis = "&hellip;"
+	}
+
+	r.callbacks['"'] = r.smartDoubleQuote
+	r.callbacks['&'] = r.smartAmp
 	r.callbacks['\''] = r.smartSingleQuote
 	r.callbacks['('] = r.smartParens
-	if flags&SmartypantsDashes != 0 {
-		if flags&SmartypantsLatexDashes == 0 {
+	if opts.Dashes {
+		if !opts.LatexDashes {
 			r.callbacks['-'] = r.smartDash
 		} else {
 			r.callbacks['-'] = r.smartDashLatex
 		}
 	}
 	r.callbacks['.'] = r.smartPeriod
-	if flags&SmartypantsFractions == 0 {
+	if !opts.Fractions {
 		r.callbacks['1'] = r.smartNumber
 		r.callbacks['3'] = r.smartNumber
 	} else {
@@ -417,11 +466,16 @@ func NewSmartypantsRenderer(flags Flags) *SPRenderer {
 	}
 	r.callbacks['<'] = r.smartLeftAngle
 	r.callbacks['`'] = r.smartBacktick
+	for ch, cb := range opts.Callbacks {
+		r.callbacks[ch] = cb
+	}
 	return &r
 }
 
 // Process is the entry point of the Smartypants renderer.
 func (r *SPRenderer) Process(w io.Writer, text []byte) {
+	tmp := getBuffer()
+	defer putBuffer(tmp)
 	mark := 0
 	for i := 0; i < len(text); i++ {
 		if action := r.callbacks[text[i]]; action != nil {
@@ -432,8 +486,8 @@ func (r *SPRenderer) Process(w io.Writer, text []byte) {
 			if i > 0 {
 				previousChar = text[i-1]
 			}
-			var tmp bytes.Buffer
-			i += action(&tmp, previousChar, text[i:])
+			tmp.Reset()
+			i += action(tmp, previousChar, text[i:])
 			w.Write(tmp.Bytes())
 			mark = i + 1
 		}
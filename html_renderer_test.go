@@ -2,6 +2,8 @@ package markdown
 
 import (
 	"io"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
 	"github.com/gomarkdown/markdown/ast"
@@ -54,3 +56,278 @@ func TestRenderNodeHookCode(t *testing.T) {
 	}
 	doTestsParam(t, tests, params)
 }
+
+func renderHookEmph(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	if _, ok := node.(*ast.Emph); !ok {
+		return ast.GoToNext, false
+	}
+	if entering {
+		io.WriteString(w, "<i>")
+	} else {
+		io.WriteString(w, "</i>")
+	}
+	return ast.GoToNext, true
+}
+
+func TestRenderNodeHooks(t *testing.T) {
+	tests := []string{
+		"*a*\n```go\ncode\n```\n",
+		"<p><i>a</i></p>\ncode_replacement",
+	}
+	opts := html.RendererOptions{
+		RenderNodeHooks: []html.RenderNodeFunc{renderHookEmph, renderHookCodeBlock},
+	}
+	doTestsParam(t, tests, TestParams{
+		RendererOptions: opts,
+		extensions:      parser.CommonExtensions,
+	})
+
+	// the first hook handling a node wins
+	r := html.NewRenderer(html.RendererOptions{RenderNodeHook: renderHookCodeBlock})
+	r.AddHook(renderHookEmpty)
+	r.AddHook(renderHookEmph)
+	doc := Parse([]byte(tests[0]), parser.NewWithExtensions(parser.CommonExtensions))
+	if got := string(Render(doc, r)); got != "code_replacement" {
+		t.Errorf("want %q, got %q", "code_replacement", got)
+	}
+}
+
+func diagramHook(w io.Writer, lang string, src []byte) bool {
+	if lang != "mermaid" {
+		return false
+	}
+	io.WriteString(w, `<div class="mermaid">`)
+	html.EscapeHTML(w, src)
+	io.WriteString(w, "</div>")
+	return true
+}
+
+func TestDiagramHook(t *testing.T) {
+	tests := []string{
+		"a\n```Mermaid\ngraph TD; A-->B\n```\nb",
+		"<p>a</p>\n<div class=\"mermaid\">graph TD; A--&gt;B\n</div>\n<p>b</p>\n",
+
+		"```dot\ndigraph {}\n```\n",
+		"<pre><code class=\"language-dot\">digraph {}\n</code></pre>\n",
+
+		"```go\ncode\n```\n",
+		"<pre><code class=\"language-go\">code\n</code></pre>\n",
+	}
+	params := TestParams{
+		RendererOptions: html.RendererOptions{
+			DiagramHook: diagramHook,
+		},
+		extensions: parser.CommonExtensions,
+	}
+	doTestsParam(t, tests, params)
+}
+
+func TestNodeAttributes(t *testing.T) {
+	input := "* *em* [link](/url) ![img](/img.png) `code`\n\n| a |\n|---|\n| b |\n"
+	doc := Parse([]byte(input), parser.NewWithExtensions(parser.CommonExtensions))
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch node.(type) {
+		case *ast.ListItem, *ast.Emph, *ast.Link, *ast.Image, *ast.Code, *ast.TableCell:
+			ast.AddClass(node, []byte("x"))
+			ast.SetAttribute(node, "data-v", []byte(`"q"`))
+		}
+		return ast.GoToNext
+	})
+	got := string(Render(doc, html.NewRenderer(html.RendererOptions{})))
+	exp := `<ul>
+<li class="x" data-v="&quot;q&quot;"><em class="x" data-v="&quot;q&quot;">em</em> <a href="/url" class="x" data-v="&quot;q&quot;">link</a> <img src="/img.png" alt="img" class="x" data-v="&quot;q&quot;" /> <code class="x" data-v="&quot;q&quot;">code</code></li>
+</ul>
+
+<table>
+<thead>
+<tr>
+<th scope="col" class="x" data-v="&quot;q&quot;">a</th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td class="x" data-v="&quot;q&quot;">b</td>
+</tr>
+</tbody>
+</table>
+`
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+}
+
+func TestElementClasses(t *testing.T) {
+	input := "{.x}\n> quote\n\n| a |\n|---|\n| b |\n\n```\ncode\n```\n\n![img](/img.png)\n\n## Heading\n"
+	p := parser.NewWithExtensions(parser.CommonExtensions | parser.Attributes)
+	r := html.NewRenderer(html.RendererOptions{
+		ElementClasses: map[string]string{
+			"blockquote": "quote",
+			"table":      "table table-striped",
+			"td":         "cell",
+			"pre":        "code",
+			"img":        `"img"`,
+			"h2":         "title",
+		},
+	})
+	got := string(ToHTML([]byte(input), p, r))
+	exp := `<blockquote class="quote x">
+<p>quote</p>
+</blockquote>
+
+<table class="table table-striped">
+<thead>
+<tr>
+<th scope="col">a</th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td class="cell">b</td>
+</tr>
+</tbody>
+</table>
+
+<pre class="code"><code>code
+</code></pre>
+
+<p><img src="/img.png" alt="img" class="&quot;img&quot;" /></p>
+
+<h2 class="title">Heading</h2>
+`
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+}
+
+func TestAttributeEscaping(t *testing.T) {
+	heading := ast.NewHeading(1, ast.NewText("Title"))
+	heading.HeadingID = `a"onmouseover="x`
+	doc := ast.NewDocument(heading, ast.NewCodeBlock(`go"onclick="x`, "code\n"))
+	r := html.NewRenderer(html.RendererOptions{Flags: html.TOC})
+	got := string(Render(doc, r))
+	exp := `<nav>
+
+<ul>
+<li><a href="#a&quot;onmouseover=&quot;x">Title</a></li>
+</ul>
+
+</nav>
+
+<h1 id="a&quot;onmouseover=&quot;x">Title</h1>
+
+<pre><code class="language-go&quot;onclick=&quot;x">code
+</code></pre>
+`
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+}
+
+type kbdNode struct {
+	ast.Leaf
+}
+
+func (n *kbdNode) NodeName() string { return "Kbd" }
+
+type spoilerNode struct {
+	ast.Container
+}
+
+func (n *spoilerNode) NodeName() string { return "Spoiler" }
+
+func renderKbd(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	kbd, ok := node.(*kbdNode)
+	if !ok {
+		return ast.GoToNext, false
+	}
+	io.WriteString(w, "<kbd>")
+	html.EscapeHTML(w, kbd.Literal)
+	io.WriteString(w, "</kbd>")
+	return ast.GoToNext, true
+}
+
+func renderSpoiler(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	if _, ok := node.(*spoilerNode); !ok {
+		return ast.GoToNext, false
+	}
+	if entering {
+		io.WriteString(w, `<span class="spoiler">`)
+	} else {
+		io.WriteString(w, "</span>")
+	}
+	return ast.GoToNext, true
+}
+
+func TestNodeRenderers(t *testing.T) {
+	kbd := &kbdNode{}
+	kbd.Literal = []byte("Ctrl+C")
+	spoiler := &spoilerNode{}
+	ast.AppendChild(spoiler, ast.NewText("copies"))
+	doc := ast.NewDocument(ast.NewParagraph(kbd, ast.NewText(" "), spoiler))
+
+	r := html.NewRenderer(html.RendererOptions{
+		NodeRenderers: []html.RenderNodeFunc{renderKbd, renderSpoiler},
+	})
+	got := string(Render(doc, r))
+	exp := "<p><kbd>Ctrl+C</kbd> <span class=\"spoiler\">copies</span></p>\n"
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic on a node no renderer handles")
+		}
+	}()
+	r = html.NewRenderer(html.RendererOptions{
+		NodeRenderers: []html.RenderNodeFunc{renderKbd},
+	})
+	Render(doc, r)
+}
+
+func TestRendererReuse(t *testing.T) {
+	input := []byte("# Title\n\n# Title\n")
+	exp := "<h1 id=\"title\">Title</h1>\n\n<h1 id=\"title-1\">Title</h1>\n"
+
+	renderer := html.NewRenderer(html.RendererOptions{})
+	for _, r := range []*html.Renderer{renderer, renderer, renderer.Clone()} {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
+		got := string(ToHTML(input, p, r))
+		if got != exp {
+			t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+		}
+	}
+}
+
+func benchRenderFile(b *testing.B, basename string) {
+	filename := filepath.Join("testdata", basename+".text")
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		b.Fatalf("Couldn't open '%s', error: %v\n", filename, err)
+	}
+	doc := Parse(input, parser.NewWithExtensions(parser.CommonExtensions))
+	renderer := html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		benchResultAnchor = string(Render(doc, renderer))
+	}
+}
+
+func BenchmarkRenderLinksInline(b *testing.B) {
+	benchRenderFile(b, "Links, inline style")
+}
+
+func BenchmarkRenderLinksReference(b *testing.B) {
+	benchRenderFile(b, "Links, reference style")
+}
+
+func BenchmarkRenderMarkdownSyntax(b *testing.B) {
+	benchRenderFile(b, "Markdown Documentation - Syntax")
+}
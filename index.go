@@ -0,0 +1,122 @@
+package markdown
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// IndexEntry is a term in the index of a document
+type IndexEntry struct {
+	Term string
+	Refs []IndexRef // references to the term, in document order
+
+	// Subentries are the subterms of the term, e.g. "linux" of
+	// (((install, linux))), sorted like the terms
+	Subentries []*IndexEntry
+}
+
+// IndexRef is a reference to an index term in the document
+type IndexRef struct {
+	ID      string // id of the anchor of the term, see ast.Index
+	Primary bool   // the main reference of the term
+}
+
+// Index collects the index terms marked in doc (see parser.IndexTerms) into
+// a back-of-book index: terms sorted case-insensitively, each with the
+// references to it and its subterms.
+func Index(doc ast.Node) []*IndexEntry {
+	var res []*IndexEntry
+	terms := map[string]*IndexEntry{}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		idx, ok := node.(*ast.Index)
+		if !ok {
+			return ast.GoToNext
+		}
+		ref := IndexRef{ID: idx.ID, Primary: idx.Primary}
+		term := string(idx.Item)
+		entry := terms[term]
+		if entry == nil {
+			entry = &IndexEntry{Term: term}
+			terms[term] = entry
+			res = append(res, entry)
+		}
+		if len(idx.Subitem) == 0 {
+			entry.Refs = append(entry.Refs, ref)
+			return ast.GoToNext
+		}
+		subterm := string(idx.Subitem)
+		var sub *IndexEntry
+		for _, e := range entry.Subentries {
+			if e.Term == subterm {
+				sub = e
+			}
+		}
+		if sub == nil {
+			sub = &IndexEntry{Term: subterm}
+			entry.Subentries = append(entry.Subentries, sub)
+		}
+		sub.Refs = append(sub.Refs, ref)
+		return ast.GoToNext
+	})
+	sortIndex(res)
+	for _, entry := range res {
+		sortIndex(entry.Subentries)
+	}
+	return res
+}
+
+func sortIndex(entries []*IndexEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Term) < strings.ToLower(entries[j].Term)
+	})
+}
+
+// IndexDocument returns a document listing index entries, e.g. to render
+// it as the index of a book. Entries are grouped under headings of their
+// first letter, each reference is a link to the anchor of the term,
+// numbered in document order. Links of primary references are strong.
+func IndexDocument(entries []*IndexEntry) *ast.Document {
+	doc := ast.NewDocument()
+	for i := 0; i < len(entries); {
+		letter := indexLetter(entries[i].Term)
+		var items []*ast.ListItem
+		for ; i < len(entries) && indexLetter(entries[i].Term) == letter; i++ {
+			items = append(items, indexItem(entries[i]))
+		}
+		ast.AppendChild(doc, ast.NewHeading(2, ast.NewText(letter)))
+		ast.AppendChild(doc, ast.NewList(false, items...))
+	}
+	return doc
+}
+
+// indexLetter returns the upper-cased first letter of term
+func indexLetter(term string) string {
+	r, _ := utf8.DecodeRuneInString(term)
+	return string(unicode.ToUpper(r))
+}
+
+func indexItem(entry *IndexEntry) *ast.ListItem {
+	para := ast.NewParagraph(ast.NewText(entry.Term))
+	for i, ref := range entry.Refs {
+		ast.AppendChild(para, ast.NewText(", "))
+		var link ast.Node = ast.NewLink("#"+ref.ID, "", ast.NewText(strconv.Itoa(i+1)))
+		if ref.Primary {
+			link = ast.NewStrong(link)
+		}
+		ast.AppendChild(para, link)
+	}
+	item := ast.NewListItem(para)
+	if len(entry.Subentries) > 0 {
+		var items []*ast.ListItem
+		for _, sub := range entry.Subentries {
+			items = append(items, indexItem(sub))
+		}
+		ast.AppendChild(item, ast.NewList(false, items...))
+	}
+	return item
+}
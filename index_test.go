@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestIndex(t *testing.T) {
+	input := "Go (((Go, install))) is installed (((package manager))) on (((!Linux))).\n\n" +
+		"Run go (((Go))) on (((Linux))) or (((linux, distributions))).\n"
+	doc := Parse([]byte(input), parser.NewWithExtensions(parser.CommonExtensions|parser.IndexTerms))
+
+	entries := Index(doc)
+	var terms []string
+	for _, e := range entries {
+		terms = append(terms, e.Term)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("want 4 terms, got %v", terms)
+	}
+	if golang := entries[0]; golang.Term != "Go" || len(golang.Refs) != 1 || golang.Refs[0].ID != "idxref:3" ||
+		len(golang.Subentries) != 1 || golang.Subentries[0].Term != "install" {
+		t.Errorf("unexpected entry %+v", golang)
+	}
+	linux := entries[1]
+	if linux.Term != "Linux" || len(linux.Refs) != 2 || !linux.Refs[0].Primary || linux.Refs[1].Primary {
+		t.Errorf("unexpected entry %+v", linux)
+	}
+
+	got := string(Render(IndexDocument(entries), html.NewRenderer(html.RendererOptions{})))
+	exp := `<h2>G</h2>
+
+<ul>
+<li>Go, <a href="#idxref:3">1</a>
+
+<ul>
+<li>install, <a href="#idxref:0">1</a></li>
+</ul></li>
+</ul>
+
+<h2>L</h2>
+
+<ul>
+<li>Linux, <strong><a href="#idxref:2">1</a></strong>, <a href="#idxref:4">2</a></li>
+<li>linux
+
+<ul>
+<li>distributions, <a href="#idxref:5">1</a></li>
+</ul></li>
+</ul>
+
+<h2>P</h2>
+
+<ul>
+<li>package manager, <a href="#idxref:1">1</a></li>
+</ul>
+`
+	if got != exp {
+		t.Errorf("\nExpected[%#v]\nGot     [%#v]", exp, got)
+	}
+}
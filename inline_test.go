@@ -1,11 +1,16 @@
 package markdown
 
 import (
+	"bytes"
+	"io"
+	"net/url"
 	"regexp"
+	"strconv"
 	"testing"
 
 	"strings"
 
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 )
@@ -301,6 +306,46 @@ func TestLineBreak(t *testing.T) {
 		extensions: parser.BackslashLineBreak})
 }
 
+func TestHardWraps(t *testing.T) {
+	var tests = []string{
+		"this line\nhas a break\n",
+		"<p>this line<br />\nhas a break</p>\n",
+
+		"this line \nhas a break\n",
+		"<p>this line<br />\nhas a break</p>\n",
+
+		"this line  \nhas one break\n",
+		"<p>this line<br />\nhas one break</p>\n",
+
+		"*emphasis*\nand \"quotes\"\nand [a\nlink](/url)\n",
+		"<p><em>emphasis</em><br />\nand &ldquo;quotes&rdquo;<br />\nand <a href=\"/url\">a<br />\nlink</a></p>\n",
+
+		"    code\n    block\n",
+		"<pre><code>code\nblock\n</code></pre>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{Flags: html.HardWraps | html.Smartypants})
+}
+
+func TestBreakHTML(t *testing.T) {
+	var tests = []string{
+		"a\nb  \nc\n",
+		"<p>a&#10;b<br/>\nc</p>\n",
+
+		"*e\nf*\n\n---\n",
+		"<p><em>e&#10;f</em></p>\n\n<hr class=\"divider\">\n",
+
+		"    code\n    block\n",
+		"<pre><code>code\nblock\n</code></pre>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			HorizontalRuleHTML: `<hr class="divider">`,
+			HardbreakHTML:      "<br/>\n",
+			SoftbreakHTML:      "&#10;",
+		},
+	})
+}
+
 func TestInlineLink(t *testing.T) {
 	var tests = []string{
 		"[foo](/bar/)\n",
@@ -339,6 +384,12 @@ func TestInlineLink(t *testing.T) {
 		"![](img.jpg)\n",
 		"<p><img src=\"img.jpg\" alt=\"\" /></p>\n",
 
+		"![*foo* `bar` [baz](/url)](img.jpg)\n",
+		"<p><img src=\"img.jpg\" alt=\"foo bar baz\" /></p>\n",
+
+		"![a <b>tag</b>](img.jpg)\n",
+		"<p><img src=\"img.jpg\" alt=\"a tag\" /></p>\n",
+
 		"[link](url)\n",
 		"<p><a href=\"url\">link</a></p>\n",
 
@@ -463,6 +514,173 @@ func TestRelAttrLink(t *testing.T) {
 	doTestsInlineParam(t, nofollownoreferrerTests, TestParams{
 		Flags: html.Safelink | html.NofollowLinks | html.NoreferrerLinks,
 	})
+
+	var noopenerTests = []string{
+		"[foo](http://bar.com/foo/)\n",
+		"<p><a href=\"http://bar.com/foo/\" target=\"_blank\" rel=\"noopener\">foo</a></p>\n",
+
+		"[foo](/bar/)\n",
+		"<p><a href=\"/bar/\">foo</a></p>\n",
+	}
+	doTestsInlineParam(t, noopenerTests, TestParams{
+		Flags: html.HrefTargetBlank | html.NoopenerLinks,
+	})
+}
+
+func linkAttrsHookExternal(dest []byte) ([]string, string) {
+	if bytes.HasPrefix(dest, []byte("http://example.com")) {
+		return nil, ""
+	}
+	return []string{"nofollow", "noopener"}, "_blank"
+}
+
+func TestLinkAttrsHook(t *testing.T) {
+	var tests = []string{
+		"[foo](http://example.com/foo/)\n",
+		"<p><a href=\"http://example.com/foo/\">foo</a></p>\n",
+
+		"[foo](http://bar.com/foo/)\n",
+		"<p><a href=\"http://bar.com/foo/\" target=\"_blank\" rel=\"nofollow noopener\">foo</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.NofollowLinks,
+		RendererOptions: html.RendererOptions{
+			LinkAttrsHook: linkAttrsHookExternal,
+		},
+	})
+}
+
+func linkWrapperHookRedirect(dest []byte) ([]byte, []string) {
+	newDest := "/out?url=" + url.QueryEscape(string(dest))
+	if bytes.Contains(dest, []byte("shop")) {
+		return []byte(newDest), []string{`rel="sponsored"`, `data-track="shop"`}
+	}
+	return []byte(newDest), nil
+}
+
+func TestLinkWrapperHook(t *testing.T) {
+	var tests = []string{
+		"[foo](/foo/)\n",
+		"<p><a href=\"/foo/\">foo</a></p>\n",
+
+		"[foo](http://example.com/foo/?a=1&b=2)\n",
+		"<p><a href=\"/out?url=http%3A%2F%2Fexample.com%2Ffoo%2F%3Fa%3D1%26b%3D2\" rel=\"nofollow\">foo</a></p>\n",
+
+		"[shop](https://shop.com/)\n",
+		"<p><a href=\"/out?url=https%3A%2F%2Fshop.com%2F\" rel=\"nofollow sponsored\" data-track=\"shop\">shop</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.NofollowLinks,
+		RendererOptions: html.RendererOptions{
+			LinkWrapperHook: linkWrapperHookRedirect,
+		},
+	})
+}
+
+func imageSizeHook(dest []byte) (int, int, bool) {
+	if string(dest) == "/img/cat.png" {
+		return 640, 480, true
+	}
+	return 0, 0, false
+}
+
+func TestImageSizeHook(t *testing.T) {
+	var tests = []string{
+		"![cat](/img/cat.png \"Cat\")\n",
+		"<p><img src=\"/img/cat.png\" alt=\"cat\" title=\"Cat\" width=\"640\" height=\"480\" /></p>\n",
+
+		"![dog](/img/dog.png)\n",
+		"<p><img src=\"/img/dog.png\" alt=\"dog\" /></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			ImageSizeHook: imageSizeHook,
+		},
+	})
+}
+
+func imageSourcesHook(dest []byte) []html.ImageSource {
+	if string(dest) != "/img/cat.png" {
+		return nil
+	}
+	return []html.ImageSource{
+		{Srcset: "/img/cat.avif", Type: "image/avif"},
+		{Srcset: "/img/cat-640.webp 640w, /img/cat-1280.webp 1280w", Type: "image/webp", Sizes: "(max-width: 600px) 100vw, 50vw"},
+		{Srcset: "/img/cat-dark.png", Media: "(prefers-color-scheme: dark)"},
+	}
+}
+
+func TestImageSourcesHook(t *testing.T) {
+	var tests = []string{
+		"![cat](/img/cat.png)\n",
+		"<p><picture>" +
+			"<source srcset=\"/img/cat.avif\" type=\"image/avif\" />" +
+			"<source srcset=\"/img/cat-640.webp 640w, /img/cat-1280.webp 1280w\" type=\"image/webp\" sizes=\"(max-width: 600px) 100vw, 50vw\" />" +
+			"<source srcset=\"/img/cat-dark.png\" media=\"(prefers-color-scheme: dark)\" />" +
+			"<img src=\"/img/cat.png\" alt=\"cat\" width=\"640\" height=\"480\" /></picture></p>\n",
+
+		"![dog](/img/dog.png) and ![cat](/img/cat.png)\n",
+		"<p><img src=\"/img/dog.png\" alt=\"dog\" /> and <picture>" +
+			"<source srcset=\"/img/cat.avif\" type=\"image/avif\" />" +
+			"<source srcset=\"/img/cat-640.webp 640w, /img/cat-1280.webp 1280w\" type=\"image/webp\" sizes=\"(max-width: 600px) 100vw, 50vw\" />" +
+			"<source srcset=\"/img/cat-dark.png\" media=\"(prefers-color-scheme: dark)\" />" +
+			"<img src=\"/img/cat.png\" alt=\"cat\" width=\"640\" height=\"480\" /></picture></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			ImageSourcesHook: imageSourcesHook,
+			ImageSizeHook:    imageSizeHook,
+		},
+	})
+}
+
+func urlEmbedHook(url []byte) ([]byte, bool) {
+	id := bytes.TrimPrefix(url, []byte("https://youtu.be/"))
+	if len(id) == len(url) {
+		return nil, false
+	}
+	return []byte(`<iframe src="https://www.youtube.com/embed/` + string(id) + `"></iframe>`), true
+}
+
+func TestURLEmbedHook(t *testing.T) {
+	var tests = []string{
+		"intro\n\nhttps://youtu.be/abc\n\noutro\n",
+		"<p>intro</p>\n\n<iframe src=\"https://www.youtube.com/embed/abc\"></iframe>\n\n<p>outro</p>\n",
+
+		"<https://youtu.be/abc>\n",
+		"<iframe src=\"https://www.youtube.com/embed/abc\"></iframe>\n",
+
+		"https://example.com/\n",
+		"<p><a href=\"https://example.com/\">https://example.com/</a></p>\n",
+
+		"see https://youtu.be/abc\n",
+		"<p>see <a href=\"https://youtu.be/abc\">https://youtu.be/abc</a></p>\n",
+
+		"[video](https://youtu.be/abc)\n",
+		"<p><a href=\"https://youtu.be/abc\">video</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			URLEmbedHook: urlEmbedHook,
+		},
+	})
+}
+
+func TestObfuscateEmail(t *testing.T) {
+	var tests = []string{
+		"<a@b.c>\n",
+		"<p><a href=\"&#109;&#97;&#105;&#108;&#116;&#111;&#58;&#97;&#64;&#98;&#46;&#99;\">&#97;&#64;&#98;&#46;&#99;</a></p>\n",
+
+		"[*me*](MAILTO:a@b.c)\n",
+		"<p><a href=\"&#77;&#65;&#73;&#76;&#84;&#79;&#58;&#97;&#64;&#98;&#46;&#99;\"><em>&#109;&#101;</em></a></p>\n",
+
+		"[a&amp;b](http://example.com/)\n",
+		"<p><a href=\"http://example.com/\">a&amp;b</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Autolink,
+		Flags:      html.ObfuscateEmail,
+	})
 }
 
 func TestHrefTargetBlank(t *testing.T) {
@@ -520,13 +738,129 @@ func TestSafeInlineLink(t *testing.T) {
 		"[foo](mailto://bar/)\n",
 		"<p><a href=\"mailto://bar/\">foo</a></p>\n",
 
+		"[foo](mailto:me@example.com)\n",
+		"<p><a href=\"mailto:me@example.com\">foo</a></p>\n",
+
+		"[foo](https://bücher.de/straße)\n",
+		"<p><a href=\"https://bücher.de/straße\">foo</a></p>\n",
+
+		"[foo](http://xn--bcher-kva.de/)\n",
+		"<p><a href=\"http://xn--bcher-kva.de/\">foo</a></p>\n",
+
+		"[foo](http://[::1]/)\n",
+		"<p><a href=\"http://[::1]/\">foo</a></p>\n",
+
+		"[foo](/日本/)\n",
+		"<p><a href=\"/日本/\">foo</a></p>\n",
+
+		"[foo](/%E6%97%A5)\n",
+		"<p><a href=\"/%E6%97%A5\">foo</a></p>\n",
+
+		// relative URLs without a scheme
+		"[foo](docs/page.html#intro) ![img](img.jpg)\n",
+		"<p><a href=\"docs/page.html#intro\">foo</a> <img src=\"img.jpg\" alt=\"img\" /></p>\n",
+
+		"[foo](page?q=a:b)\n",
+		"<p><a href=\"page?q=a:b\">foo</a></p>\n",
+
 		// Not considered safe
+		"[foo](data:text/html,x/y)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"[foo](//evil/)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"[foo](http://-evil/)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"[foo](http://%zz/)\n",
+		"<p><tt>foo</tt></p>\n",
+
 		"[foo](baz://bar/)\n",
 		"<p><tt>foo</tt></p>\n",
+
+		"[foo](javascript:alert)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"[foo](tel:+1555)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"![foo](/bar.png)\n",
+		"<p><img src=\"/bar.png\" alt=\"foo\" /></p>\n",
+
+		"![*foo*](javascript:alert)\n",
+		"<p>foo</p>\n",
 	}
 	doSafeTestsInline(t, tests)
 }
 
+func TestSafeLinkProtocols(t *testing.T) {
+	var tests = []string{
+		"[foo](tel:+1555)\n",
+		"<p><a href=\"tel:+1555\">foo</a></p>\n",
+
+		"[foo](XMPP:me@example.com)\n",
+		"<p><a href=\"XMPP:me@example.com\">foo</a></p>\n",
+
+		"![foo](data:image/png;base64,AAAA)\n",
+		"<p><img src=\"data:image/png;base64,AAAA\" alt=\"foo\" /></p>\n",
+
+		"[foo](http://bar/)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		// mailto: is safe only if it's in the list
+		"[foo](mailto:me@example.com)\n",
+		"<p><tt>foo</tt></p>\n",
+
+		"![foo](data:text/html;base64,AAAA)\n",
+		"<p>foo</p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.Safelink,
+		RendererOptions: html.RendererOptions{
+			AllowedLinkProtocols: []string{"tel:", "xmpp:", "data:image/png;"},
+		},
+	})
+}
+
+func TestAbsolutePrefixPolicy(t *testing.T) {
+	var tests = []string{
+		"[a](/x) [b](./y) [c](../z) [d](#id) ![e](./e.png) ![f](/f.png)\n",
+		`<p><a href="http://site/x">a</a> <a href="./y">b</a> <a href="../z">c</a> <a href="http://site/#id">d</a> <img src="./e.png" alt="e" /> <img src="http://cdn/img/f.png" alt="f" /></p>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			AbsolutePrefix:      "http://site",
+			ImageAbsolutePrefix: "http://cdn/img",
+		},
+	})
+	// dot-relative URLs are prefixed only if the policy includes them
+	tests = []string{
+		"[a](/x) [b](./y) [c](../z) [d](#id) ![e](./e.png)\n",
+		`<p><a href="http://site/x">a</a> <a href="http://site/y">b</a> <a href="http://site/z">c</a> <a href="#id">d</a> <img src="http://site/e.png" alt="e" /></p>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			AbsolutePrefix:       "http://site",
+			AbsolutePrefixPolicy: html.PrefixRootRelative | html.PrefixDotRelative,
+		},
+	})
+	// they're resolved against the prefix
+	tests = []string{
+		"[b](./y?q=1#f) [c](../z) [d](../../w)\n",
+		`<p><a href="http://site/docs/v2/y?q=1#f">b</a> <a href="http://site/docs/z">c</a> <a href="http://site/w">d</a></p>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			AbsolutePrefix:       "http://site/docs/v2/",
+			AbsolutePrefixPolicy: html.PrefixDotRelative,
+		},
+	})
+}
+
 func TestReferenceLink(t *testing.T) {
 	var tests = []string{
 		"[link][ref]\n",
@@ -648,11 +982,11 @@ func TestAutoLink(t *testing.T) {
 		"<p>a link with <a href=\"http://new.com?query=foo&amp;bar\">" +
 			"http://new.com?query=foo&amp;bar</a></p>\n",
 
-		"quotes mean a tag <http://new.com?query=\"foo\"&bar>\n",
-		"<p>quotes mean a tag <http://new.com?query=\"foo\"&bar></p>\n",
+		"quotes are allowed <http://new.com?query=\"foo\"&bar>\n",
+		"<p>quotes are allowed <a href=\"http://new.com?query=&quot;foo&quot;&amp;bar\">http://new.com?query=&quot;foo&quot;&amp;bar</a></p>\n",
 
-		"quotes mean a tag <http://new.com?query='foo'&bar>\n",
-		"<p>quotes mean a tag <http://new.com?query='foo'&bar></p>\n",
+		"quotes are allowed <http://new.com?query='foo'&bar>\n",
+		"<p>quotes are allowed <a href=\"http://new.com?query='foo'&amp;bar\">http://new.com?query='foo'&amp;bar</a></p>\n",
 
 		"unless escaped <http://new.com?query=\\\"foo\\\"&bar>\n",
 		"<p>unless escaped <a href=\"http://new.com?query=&quot;foo&quot;&amp;bar\">" +
@@ -943,6 +1277,189 @@ func TestFootnotesWithParameters(t *testing.T) {
 	})
 }
 
+func TestFootnotesARIA(t *testing.T) {
+	// footnotes are numbered in order of first reference
+	var tests = []string{
+		"a[^b] c[^a] d^[inline]\n\n[^a]: first\n[^b]: second\n",
+		`<p>a<sup class="footnote-ref" id="fnref:b"><a href="#fn:b" role="doc-noteref" aria-describedby="fn:b">1</a></sup> c<sup class="footnote-ref" id="fnref:a"><a href="#fn:a" role="doc-noteref" aria-describedby="fn:a">2</a></sup> d<sup class="footnote-ref" id="fnref:inline"><a href="#fn:inline" role="doc-noteref" aria-describedby="fn:inline">3</a></sup></p>
+
+<div class="footnotes" role="doc-endnotes">
+
+<hr />
+
+<ol>
+<li id="fn:b">second <a class="footnote-return" href="#fnref:b" role="doc-backlink"><sup>[return]</sup></a></li>
+
+<li id="fn:a">first <a class="footnote-return" href="#fnref:a" role="doc-backlink"><sup>[return]</sup></a></li>
+
+<li id="fn:inline">inline <a class="footnote-return" href="#fnref:inline" role="doc-backlink"><sup>[return]</sup></a></li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Footnotes,
+		Flags:      html.FootnoteARIA | html.FootnoteReturnLinks,
+	})
+}
+
+func TestFootnoteAccessible(t *testing.T) {
+	var tests = []string{
+		"a[^b]\n\n[^b]: note\n",
+		`<p>a<sup class="footnote-ref" id="fnref:b"><a href="#fn:b" role="doc-noteref" aria-describedby="fn:b">1</a></sup></p>
+
+<div class="footnotes" role="doc-endnotes">
+
+<hr />
+
+<ol>
+<li id="fn:b" role="doc-footnote">note <a class="footnote-return" href="#fnref:b" role="doc-backlink" aria-label="Back to content"><span aria-hidden="true"><sup>[return]</sup></span></a></li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Footnotes,
+		Flags:      html.Accessible | html.FootnoteReturnLinks,
+	})
+
+	tests = []string{
+		"a[^b]\n\n[^b]: note\n",
+		`<p>a<sup class="footnote-ref" id="fnref:b"><a href="#fn:b" role="doc-noteref" aria-describedby="fn:b">1</a></sup></p>
+
+<div class="footnotes" role="doc-endnotes">
+
+<hr />
+
+<ol>
+<li id="fn:b" role="doc-footnote">note <a class="footnote-return" href="#fnref:b" role="doc-backlink" aria-label="Retour &quot;au&quot; texte"><span aria-hidden="true"><sup>[return]</sup></span></a></li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions:      parser.Footnotes,
+		Flags:           html.Accessible | html.FootnoteReturnLinks,
+		RendererOptions: html.RendererOptions{FootnoteReturnLinkLabel: `Retour "au" texte`},
+	})
+}
+
+func TestFootnoteIDFunc(t *testing.T) {
+	var tests = []string{
+		"a[^b] c^[inline]\n\n[^b]: note\n",
+		`<p>a<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup> c<sup class="footnote-ref" id="fnref:2"><a href="#fn:2">2</a></sup></p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1">note <a class="footnote-return" href="#fnref:1"><sup>[return]</sup></a></li>
+
+<li id="fn:2">inline <a class="footnote-return" href="#fnref:2"><sup>[return]</sup></a></li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Footnotes,
+		Flags:      html.FootnoteReturnLinks,
+		RendererOptions: html.RendererOptions{
+			FootnoteAnchorPrefix: "ignored-",
+			FootnoteIDFunc: func(noteID int, ref []byte) string {
+				return strconv.Itoa(noteID)
+			},
+		},
+	})
+}
+
+func TestFootnoteSlugifyFunc(t *testing.T) {
+	var tests = []string{
+		"a[^Crème Brûlée]\n\n[^Crème Brûlée]: note\n",
+		`<p>a<sup class="footnote-ref" id="fnref:creme-brulee"><a href="#fn:creme-brulee">1</a></sup></p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:creme-brulee">note</li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Footnotes,
+		RendererOptions: html.RendererOptions{
+			SlugifyFunc: parser.NewSlugify(parser.SlugOptions{Transliterate: true}),
+		},
+	})
+}
+
+func TestFootnotesUnicode(t *testing.T) {
+	var tests = []string{
+		"a^[注釈はとても長いテキストです] b[^メモ]\n\n[^メモ]: note\n",
+		`<p>a<sup class="footnote-ref" id="fnref:注釈はとて"><a href="#fn:注釈はとて">1</a></sup> b<sup class="footnote-ref" id="fnref:メモ"><a href="#fn:メモ">2</a></sup></p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:注釈はとて">注釈はとても長いテキストです</li>
+
+<li id="fn:メモ">note</li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.Footnotes})
+}
+
+func TestSkipFootnotes(t *testing.T) {
+	var tests = []string{
+		"Text.[^a] More.^[inline note]\n\n[^a]: The note.\n",
+		"<p>Text. More.</p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Footnotes,
+		Flags:      html.SkipFootnotes,
+	})
+}
+
+func TestFootnoteHook(t *testing.T) {
+	var tests = []string{
+		"Text.[^a] More.[^b]\n\n[^a]: The *note*.\n[^b]: Second.\n",
+		"<p>Text. (The <em>note</em>.) More. (Second.)</p>\n",
+	}
+	var r *html.Renderer
+	r = html.NewRenderer(html.RendererOptions{
+		Flags: html.UseXHTML,
+		FootnoteHook: func(w io.Writer, ref *ast.Link, note ast.Node) {
+			io.WriteString(w, " (")
+			for _, child := range note.GetChildren() {
+				ast.WalkFunc(child, func(node ast.Node, entering bool) ast.WalkStatus {
+					return r.RenderNode(w, node, entering)
+				})
+			}
+			io.WriteString(w, ")")
+		},
+	})
+	p := parser.NewWithExtensions(parser.Footnotes)
+	for i := 0; i < len(tests); i += 2 {
+		got := string(ToHTML([]byte(tests[i]), p, r))
+		if got != tests[i+1] {
+			t.Errorf("\nInput   [%#v]\nExpected[%#v]\nActual  [%#v]", tests[i], tests[i+1], got)
+		}
+	}
+}
+
 func TestNestedFootnotes(t *testing.T) {
 	var tests = []string{
 		`Paragraph.[^fn1]
@@ -994,12 +1511,219 @@ This uses footnote C.[^C]
 <li id="fn:B">B note, uses A to test duplicate.<sup class="footnote-ref" id="fnref:A"><a href="#fn:A">1</a></sup></li>
 </ol>
 
+</div>
+`,
+		`Text.[^1]
+
+[^1]: First paragraph.
+
+    Second paragraph with a nested note.[^2]
+
+[^2]: Nested.
+`,
+		`<p>Text.<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup></p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1"><p>First paragraph.</p>
+
+<p>Second paragraph with a nested note.<sup class="footnote-ref" id="fnref:2"><a href="#fn:2">2</a></sup></p></li>
+
+<li id="fn:2">Nested.</li>
+</ol>
+
 </div>
 `,
 	}
 	doTestsInlineParam(t, tests, TestParams{extensions: parser.Footnotes})
 }
 
+func TestFootnotesInContainers(t *testing.T) {
+	var tests = []string{
+		`| a | b[^h] |
+|---|---|
+| x[^t] | y |
+
+> quote[^q]
+
+* item[^l]
+
+[^h]: Header note.
+[^t]: Table note.
+[^q]: Quote note.
+[^l]: List note.
+`,
+		`<table>
+<thead>
+<tr>
+<th scope="col">a</th>
+<th scope="col">b<sup class="footnote-ref" id="fnref:h"><a href="#fn:h">1</a></sup></th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td>x<sup class="footnote-ref" id="fnref:t"><a href="#fn:t">2</a></sup></td>
+<td>y</td>
+</tr>
+</tbody>
+</table>
+
+<blockquote>
+<p>quote<sup class="footnote-ref" id="fnref:q"><a href="#fn:q">3</a></sup></p>
+</blockquote>
+
+<ul>
+<li>item<sup class="footnote-ref" id="fnref:l"><a href="#fn:l">4</a></sup></li>
+</ul>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:h">Header note.</li>
+
+<li id="fn:t">Table note.</li>
+
+<li id="fn:q">Quote note.</li>
+
+<li id="fn:l">List note.</li>
+</ol>
+
+</div>
+`,
+		`Text.[^1]
+
+[^1]: A table.
+
+    | a |
+    |---|
+    | b[^2] |
+
+[^2]: In a cell.
+`,
+		`<p>Text.<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup></p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1"><p>A table.</p>
+
+<table>
+<thead>
+<tr>
+<th scope="col">a</th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td>b<sup class="footnote-ref" id="fnref:2"><a href="#fn:2">2</a></sup></td>
+</tr>
+</tbody>
+</table>
+</li>
+
+<li id="fn:2">In a cell.</li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.CommonExtensions | parser.Footnotes})
+}
+
+func TestFootnotesWithBlocks(t *testing.T) {
+	var tests = []string{
+		`Text.[^1]
+
+[^1]: First paragraph
+continued lazily.
+
+    Second paragraph.
+
+        code
+
+    - item a
+    - item b
+
+After.
+`,
+		`<p>Text.<sup class="footnote-ref" id="fnref:1"><a href="#fn:1">1</a></sup></p>
+
+<p>After.</p>
+
+<div class="footnotes">
+
+<hr />
+
+<ol>
+<li id="fn:1"><p>First paragraph
+continued lazily.</p>
+
+<p>Second paragraph.</p>
+
+<pre><code>code
+</code></pre>
+<ul>
+<li>item a</li>
+<li>item b</li>
+</ul></li>
+</ol>
+
+</div>
+`,
+
+		// a line after indented code isn't a lazy continuation
+		"Text.[^1]\n\n[^1]: Note.\n\n        code\nAfter.\n",
+		"<p>Text.<sup class=\"footnote-ref\" id=\"fnref:1\"><a href=\"#fn:1\">1</a></sup></p>\n\n<p>After.</p>\n\n<div class=\"footnotes\">\n\n<hr />\n\n<ol>\n<li id=\"fn:1\"><p>Note.</p>\n\n<pre><code>code\n</code></pre></li>\n</ol>\n\n</div>\n",
+
+		// a code fence ends the footnote
+		"Text.[^1]\n\n[^1]: Note\n```\ncode\n```\n",
+		"<p>Text.<sup class=\"footnote-ref\" id=\"fnref:1\"><a href=\"#fn:1\">1</a></sup></p>\n\n<pre><code>code\n</code></pre>\n\n<div class=\"footnotes\">\n\n<hr />\n\n<ol>\n<li id=\"fn:1\">Note</li>\n</ol>\n\n</div>\n",
+
+		// a line starting with [ which isn't a footnote continues it
+		"Text.[^1] and [^2]\n\n[^1]: Note\n[link](/url) text\n[^2]: Other\n",
+		"<p>Text.<sup class=\"footnote-ref\" id=\"fnref:1\"><a href=\"#fn:1\">1</a></sup> and <sup class=\"footnote-ref\" id=\"fnref:2\"><a href=\"#fn:2\">2</a></sup></p>\n\n<div class=\"footnotes\">\n\n<hr />\n\n<ol>\n<li id=\"fn:1\">Note\n<a href=\"/url\">link</a> text</li>\n\n<li id=\"fn:2\">Other</li>\n</ol>\n\n</div>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.Footnotes | parser.FencedCode})
+}
+
+func resolveCitation(key []byte) ([]byte, bool) {
+	if string(key) == "smith2004" {
+		return []byte("Smith, J. <em>A Book</em>. 2004."), true
+	}
+	return nil, false
+}
+
+func TestCitations(t *testing.T) {
+	var tests = []string{
+		"As shown [@smith2004, p. 33] and [@doe2010, ch. 2].\n",
+		`<p>As shown <cite class="informative"><a href="#ref-smith2004"><sup>[smith2004]</sup></a>, p. 33</cite> and <cite>doe2010, ch. 2</cite>.</p>
+
+<div class="references">
+
+<ol>
+<li id="ref-smith2004">Smith, J. <em>A Book</em>. 2004.</li>
+</ol>
+
+</div>
+`,
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		extensions: parser.Citations,
+		RendererOptions: html.RendererOptions{
+			CitationResolver: resolveCitation,
+		},
+	})
+}
+
 func TestInlineComments(t *testing.T) {
 	var tests = []string{
 		"Hello <!-- there ->\n",
@@ -1025,10 +1749,33 @@ func TestInlineComments(t *testing.T) {
 
 		"blahblah\n<!--- foo -->\nrhubarb\n",
 		"<p>blahblah\n<!--- foo -->\nrhubarb</p>\n",
+
+		"a <!--> b\n",
+		"<p>a <!--> b</p>\n",
 	}
 	doTestsInlineParam(t, tests, TestParams{Flags: html.Smartypants | html.SmartypantsDashes})
 }
 
+func TestInlineRawHTML(t *testing.T) {
+	var tests = []string{
+		"a <span title=\"x > y\">b</span>\n",
+		"<p>a <span title=\"x > y\">b</span></p>\n",
+
+		"a <![CDATA[ x > y ]]> b\n",
+		"<p>a <![CDATA[ x > y ]]> b</p>\n",
+
+		"a <?php echo 1 > 2 ?> b\n",
+		"<p>a <?php echo 1 > 2 ?> b</p>\n",
+
+		"a <b c=> d\n",
+		"<p>a &lt;b c=&gt; d</p>\n",
+
+		"1 <2 and 3> 2\n",
+		"<p>1 &lt;2 and 3&gt; 2</p>\n",
+	}
+	doTestsInline(t, tests)
+}
+
 func TestSmartDoubleQuotes(t *testing.T) {
 	var tests = []string{
 		"this should be normal \"quoted\" text.\n",
@@ -1077,6 +1824,75 @@ func TestSmartAngledDoubleQuotesNBSP(t *testing.T) {
 	doTestsInlineParam(t, tests, TestParams{Flags: html.Smartypants | html.SmartypantsAngledQuotes | html.SmartypantsQuotesNBSP})
 }
 
+func TestSmartypantsOptions(t *testing.T) {
+	var tests = []string{
+		"this should be \"German\" and 'quoted' text...\n",
+		"<p>this should be &bdquo;German&ldquo; and &sbquo;quoted&lsquo; text&hellip;</p>\n",
+		"don't\n",
+		"<p>don&rsquo;t</p>\n"}
+
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.Smartypants,
+		RendererOptions: html.RendererOptions{
+			SmartypantsOptions: &html.SmartypantsOptions{
+				DoubleQuotes: [2]string{"&bdquo;", "&ldquo;"},
+				SingleQuotes: [2]string{"&sbquo;", "&lsquo;"},
+			},
+		},
+	})
+
+	tests = []string{
+		"this should be \"Russian\" text... 1/2 -- 2/3\n",
+		"<p>this should be «Russian» text… <sup>1</sup>&frasl;<sub>2</sub> &ndash; <sup>2</sup>&frasl;<sub>3</sub></p>\n"}
+
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.Smartypants,
+		RendererOptions: html.RendererOptions{
+			SmartypantsOptions: &html.SmartypantsOptions{
+				DoubleQuotes: [2]string{"«", "»"},
+				Ellipsis:     "…",
+				Dashes:       true,
+				LatexDashes:  true,
+				Fractions:    true,
+			},
+		},
+	})
+}
+
+func TestSmartypantsCallbacks(t *testing.T) {
+	var tests = []string{
+		"Bonjour ! \"Ça va ?\" -- oui.\n",
+		"<p>Bonjour&nbsp;! &laquo;&nbsp;Ça va&nbsp;?&nbsp;&raquo; &thinsp;&mdash;&thinsp; oui.</p>\n"}
+
+	doTestsInlineParam(t, tests, TestParams{
+		Flags: html.Smartypants,
+		RendererOptions: html.RendererOptions{
+			SmartypantsOptions: &html.SmartypantsOptions{
+				DoubleQuotes: [2]string{"&laquo;", "&raquo;"},
+				QuotesNBSP:   true,
+				Callbacks: map[byte]html.SmartypantsCallback{
+					' ': func(out *bytes.Buffer, previousChar byte, text []byte) int {
+						if len(text) > 1 && strings.IndexByte("!?;:", text[1]) >= 0 {
+							out.WriteString("&nbsp;")
+						} else {
+							out.WriteByte(' ')
+						}
+						return 0
+					},
+					'-': func(out *bytes.Buffer, previousChar byte, text []byte) int {
+						if len(text) >= 2 && text[1] == '-' {
+							out.WriteString("&thinsp;&mdash;&thinsp;")
+							return 1
+						}
+						out.WriteByte('-')
+						return 0
+					},
+				},
+			},
+		},
+	})
+}
+
 func TestSmartFractions(t *testing.T) {
 	var tests = []string{
 		"1/2, 1/4 and 3/4; 1/4th and 3/4ths\n",
@@ -1172,6 +1988,95 @@ func TestSkipHTML(t *testing.T) {
 	}, TestParams{Flags: html.SkipHTML})
 }
 
+func TestHTMLPolicy(t *testing.T) {
+	doTestsParam(t, []string{
+		"intro\n\n<!-- more -->\n\n<script>alert(1)</script>\n\n<div class=\"foo\">a < b</div>\n",
+		"<p>intro</p>\n\n<!-- more -->\n\n<div class=\"foo\">a < b</div>\n",
+
+		"<div><script>alert(1)</script></div>\n",
+		"",
+
+		"text <em>inline</em> <span>html</span><br> <!-- comment -->",
+		"<p>text <em>inline</em> html<br> <!-- comment --></p>\n",
+
+		"<div>\n<!--><script>alert(1)</script>-->\n</div>\n",
+		"",
+	}, TestParams{RendererOptions: html.RendererOptions{
+		HTMLPolicy: &html.HTMLPolicy{
+			AllowElements: []string{"div", "em", "br"},
+			AllowComments: true,
+		},
+	}})
+
+	doTestsParam(t, []string{
+		"<!-- more -->\n\ntext <em>inline</em> <!-- comment -->",
+		"<p>text inline </p>\n",
+	}, TestParams{RendererOptions: html.RendererOptions{
+		HTMLPolicy: &html.HTMLPolicy{},
+	}})
+
+	// full tag names and attributes are checked
+	doTestsParam(t, []string{
+		"<my-el>a</my-el> <my>b</my>\n",
+		"<p>a <my>b</my></p>\n",
+
+		"<a href=\"https://x.org/\" title='a > b'>x</a> <a href=\"page.html#y\">y</a>\n",
+		"<p><a href=\"https://x.org/\" title='a > b'>x</a> <a href=\"page.html#y\">y</a></p>\n",
+
+		"<a href=\"javascript:alert(1)\">x</a> <a href=\" JAVA&#x09;script&colon;alert(1)\">y</a>\n",
+		"<p>x</a> y</a></p>\n",
+
+		"<a href=\"/\" onclick=alert(1)>x</a> <img src=x ONERROR=\"alert(1)\">\n",
+		"<p>x</a> </p>\n",
+
+		"<img src=data:text/html,x alt=\"a\"> <img src=\"/i.png\" alt=\"onclick\">\n",
+		"<p> <img src=\"/i.png\" alt=\"onclick\"></p>\n",
+	}, TestParams{RendererOptions: html.RendererOptions{
+		HTMLPolicy: &html.HTMLPolicy{AllowElements: []string{"a", "img", "my"}},
+	}})
+}
+
+func TestKeepHTMLComments(t *testing.T) {
+	doTestsParam(t, []string{
+		"<!-- toc -->\n\n# Title\n\nintro\n\n<!-- more -->\n\n<div>x</div>\n",
+		"<!-- toc -->\n\n<h1>Title</h1>\n\n<p>intro</p>\n\n<!-- more -->\n",
+
+		"<div>\n<!-- a --> <b>x</b> <!-- b -->\n</div>\n",
+		"<!-- a -->\n<!-- b -->\n",
+
+		"text <!-- inline --> and <em>html</em>",
+		"<p>text <!-- inline --> and html</p>\n",
+
+		// malformed comments end early in browsers and are dropped
+		"<!-- x --!><script>alert(1)</script> -->",
+		"<p></p>\n",
+
+		"<div>\n<!--><script>alert(1)</script>-->\n</div>\n",
+		"",
+
+		"<div>\n<!---><b>x</b>--> <!-- ok -->\n</div>\n",
+		"<!-- ok -->\n",
+	}, TestParams{Flags: html.SkipHTML | html.KeepHTMLComments})
+}
+
+func TestTagFilter(t *testing.T) {
+	doTestsParam(t, []string{
+		"<strong> <title> <style> <em>\n\n<blockquote>\n  <xmp> is disallowed.  <XMP> is also disallowed.\n</blockquote>\n",
+		"<p><strong> &lt;title> &lt;style> <em></p>\n\n<blockquote>\n  &lt;xmp> is disallowed.  &lt;XMP> is also disallowed.\n</blockquote>\n",
+
+		"text <script src=\"x\"></script> <scripts> <iframe/>",
+		"<p>text &lt;script src=\"x\">&lt;/script> <scripts> &lt;iframe/></p>\n",
+	}, TestParams{Flags: html.TagFilter})
+
+	doTestsParam(t, []string{
+		"<Iframe src=\"javascript:alert(1)\" />\n",
+		"&lt;Iframe src=\"javascript:alert(1)\" />\n",
+
+		"<Title>\ntext\n</Title>\n",
+		"&lt;Title>\n<p>text</p>\n&lt;/Title>\n",
+	}, TestParams{Flags: html.TagFilter, extensions: parser.Components})
+}
+
 func TestInlineMath(t *testing.T) {
 	doTestsParam(t, []string{
 		"$a_b$",
@@ -1186,8 +2091,18 @@ func TestSubSuper(t *testing.T) {
 		"<p>H<sub>2</sub>O is a liquid, 2<sup>10</sup> is 1024</p>\n",
 		"2^10^ is 1024, H~2~O is a liquid\n",
 		"<p>2<sup>10</sup> is 1024, H<sub>2</sub>O is a liquid</p>\n",
+
+		// unclosed, empty or spaced delimiters are literal
+		"a^b and a~b, x^^ y~~ and 2^10 x^y z^\n",
+		"<p>a^b and a~b, x^^ y~~ and 2^10 x^y z^</p>\n",
+
+		"x^a\\ b^ and x^a\\^b^\n",
+		"<p>x<sup>a b</sup> and x<sup>a^b</sup></p>\n",
+
+		"snake_case~i~ and snake_case_var\n",
+		"<p>snake_case<sub>i</sub> and snake_case_var</p>\n",
 	}
-	doTestsInlineParam(t, tests, TestParams{extensions: parser.SuperSubscript})
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.SuperSubscript | parser.NoIntraEmphasis})
 }
 
 func BenchmarkSmartDoubleQuotes(b *testing.B) {
@@ -1199,3 +2114,85 @@ func BenchmarkSmartDoubleQuotes(b *testing.B) {
 		runMarkdown("this should be normal \"quoted\" text.\n", params)
 	}
 }
+
+func TestPreserveEntities(t *testing.T) {
+	var tests = []string{
+		"a &copy; b &#x1F600; c &#65;\n",
+		"<p>a &copy; b &#x1F600; c &#65;</p>\n",
+
+		"&amp; &bogus; &#12345678; &#xZZ;\n",
+		"<p>&amp; &amp;bogus; &amp;#12345678; &amp;#xZZ;</p>\n",
+
+		"\\&copy; `&copy;`\n",
+		"<p>&amp;copy; <code>&amp;copy;</code></p>\n",
+
+		"[&copy; link](/url)\n",
+		"<p><a href=\"/url\">&copy; link</a></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{Flags: html.PreserveEntities})
+	doTestsInlineParam(t, tests, TestParams{Flags: html.PreserveEntities | html.Smartypants})
+}
+
+func TestEscapeFlags(t *testing.T) {
+	var tests = []string{
+		"It's ü &copy; & <b>\n",
+		"<p>It&#39;s &#xfc; &copy; &amp; <b></p>\n",
+
+		"[ü](/ü \"it's ü\")\n",
+		"<p><a href=\"/&#xfc;\" title=\"it&#39;s &#xfc;\">&#xfc;</a></p>\n",
+
+		"![it's](/img.png 'ü')\n",
+		"<p><img src=\"/img.png\" alt=\"it&#39;s\" title=\"&#xfc;\" /></p>\n",
+
+		"`ü`\n",
+		"<p><code>&#xfc;</code></p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{
+		RendererOptions: html.RendererOptions{
+			EscapeFlags: html.EscapeSingleQuotes | html.EscapeNonASCII | html.EscapeSkipEntities,
+		},
+	})
+}
+
+func TestRuby(t *testing.T) {
+	var tests = []string{
+		"{漢字|かんじ}\n",
+		"<p><ruby>漢字<rt>かんじ</rt></ruby></p>\n",
+
+		"[東京]{とうきょう} and {**強**|つよ}\n",
+		"<p><ruby>東京<rt>とうきょう</rt></ruby> and <ruby><strong>強</strong><rt>つよ</rt></ruby></p>\n",
+
+		"{a & b|\"<c>\"}\n",
+		"<p><ruby>a &amp; b<rt>&quot;&lt;c&gt;&quot;</rt></ruby></p>\n",
+
+		"[link](/url) and [ref]\n\n[ref]: /ref\n",
+		"<p><a href=\"/url\">link</a> and <a href=\"/ref\">ref</a></p>\n",
+
+		"{a} {|b} {a|} [a]{} [a] {b}\n",
+		"<p>{a} {|b} {a|} [a]{} [a] {b}</p>\n",
+
+		"{a\nb|c}\n",
+		"<p>{a\nb|c}</p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.Ruby})
+}
+
+func TestKbd(t *testing.T) {
+	var tests = []string{
+		"Press [[Ctrl]]+[[C]]\n",
+		"<p>Press <kbd>Ctrl</kbd>+<kbd>C</kbd></p>\n",
+
+		"kbd:[Ctrl+Shift+T] or kbd:[ Enter ]\n",
+		"<p><kbd><kbd>Ctrl</kbd>+<kbd>Shift</kbd>+<kbd>T</kbd></kbd> or <kbd>Enter</kbd></p>\n",
+
+		"[[<]] and [[&]]\n",
+		"<p><kbd>&lt;</kbd> and <kbd>&amp;</kbd></p>\n",
+
+		"[link](/url) and [ref]\n\n[ref]: /ref\n",
+		"<p><a href=\"/url\">link</a> and <a href=\"/ref\">ref</a></p>\n",
+
+		"mykbd:[x] kbd:[] kbd:[a++] [[]] [[x]y]]\n",
+		"<p>mykbd:[x] kbd:[] kbd:[a++] [[]] [[x]y]]</p>\n",
+	}
+	doTestsInlineParam(t, tests, TestParams{extensions: parser.Kbd})
+}
@@ -0,0 +1,19 @@
+/*
+Package layout renders a parsed markdown document by driving a minimal page
+layout interface, e.g. to generate PDF reports from markdown.
+
+The Layout interface receives headings, paragraphs, tables and images with
+their text split into styled spans. FPDF adapts it to a PDF document created
+with github.com/jung-kurt/gofpdf (or a compatible fork):
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	doc := markdown.Parse(md, nil)
+	layout.Render(doc, layout.NewFPDF(pdf))
+	err := pdf.OutputFileAndClose("report.pdf")
+
+Blocks without a counterpart in the interface are flattened: list items
+become paragraphs starting with a bullet or number, code blocks become
+paragraphs with a single Code span and raw HTML is dropped.
+*/
+package layout
@@ -0,0 +1,136 @@
+package layout
+
+// PDF is the subset of methods of *gofpdf.Fpdf used by FPDF. It is declared
+// here so that this package does not depend on gofpdf.
+type PDF interface {
+	SetFont(familyStr, styleStr string, size float64)
+	Write(h float64, txtStr string)
+	WriteLinkString(h float64, displayStr, targetStr string)
+	Ln(h float64)
+	CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string)
+	Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string)
+	GetPageSize() (width, height float64)
+	GetMargins() (left, top, right, bottom float64)
+}
+
+// FPDF is a Layout writing to a gofpdf document
+type FPDF struct {
+	pdf PDF
+
+	Family     string  // font family of text, defaults to "Helvetica"
+	CodeFamily string  // font family of code, defaults to "Courier"
+	FontSize   float64 // font size of text in points, defaults to 11
+	LineHeight float64 // line height in document units, defaults to 5
+
+	// HeadingScale is the font size of headings of level 1 to 6 relative
+	// to FontSize
+	HeadingScale [6]float64
+}
+
+// NewFPDF returns a Layout adding content to pdf at its current position
+func NewFPDF(pdf PDF) *FPDF {
+	return &FPDF{
+		pdf:          pdf,
+		Family:       "Helvetica",
+		CodeFamily:   "Courier",
+		FontSize:     11,
+		LineHeight:   5,
+		HeadingScale: [6]float64{2, 1.6, 1.3, 1.1, 1, 1},
+	}
+}
+
+func (f *FPDF) setFont(style Style, size float64) {
+	family := f.Family
+	if style&Code != 0 {
+		family = f.CodeFamily
+	}
+	s := ""
+	if style&Bold != 0 {
+		s += "B"
+	}
+	if style&Italic != 0 {
+		s += "I"
+	}
+	f.pdf.SetFont(family, s, size)
+}
+
+// write writes spans as flowing text, styles of spans are combined with
+// style
+func (f *FPDF) write(spans []Span, style Style, size, h float64) {
+	for _, span := range spans {
+		f.setFont(span.Style|style, size)
+		if span.Link != "" {
+			f.pdf.WriteLinkString(h, span.Text, span.Link)
+		} else {
+			f.pdf.Write(h, span.Text)
+		}
+	}
+}
+
+// plainText returns the text of spans without styles
+func plainText(spans []Span) string {
+	s := ""
+	for _, span := range spans {
+		s += span.Text
+	}
+	return s
+}
+
+// AddHeading adds a bold heading scaled by HeadingScale
+func (f *FPDF) AddHeading(level int, spans []Span) {
+	scale := 1.0
+	if level >= 1 && level <= len(f.HeadingScale) {
+		scale = f.HeadingScale[level-1]
+	}
+	h := f.LineHeight * scale
+	f.write(spans, Bold, f.FontSize*scale, h)
+	f.pdf.Ln(h * 1.5)
+}
+
+// AddParagraph adds spans followed by half a line of space
+func (f *FPDF) AddParagraph(spans []Span) {
+	f.write(spans, 0, f.FontSize, f.LineHeight)
+	f.pdf.Ln(f.LineHeight * 1.5)
+}
+
+// AddTable adds a table with columns of equal width spanning the page
+// between margins. Header rows are set in bold.
+func (f *FPDF) AddTable(header []Row, body []Row) {
+	cols := 0
+	for _, rows := range [][]Row{header, body} {
+		for _, row := range rows {
+			if len(row) > cols {
+				cols = len(row)
+			}
+		}
+	}
+	if cols == 0 {
+		return
+	}
+	width, _ := f.pdf.GetPageSize()
+	left, _, right, _ := f.pdf.GetMargins()
+	w := (width - left - right) / float64(cols)
+	addRows := func(rows []Row, style Style) {
+		for _, row := range rows {
+			for i := 0; i < cols; i++ {
+				text := ""
+				if i < len(row) {
+					text = plainText(row[i])
+				}
+				f.setFont(style, f.FontSize)
+				f.pdf.CellFormat(w, f.LineHeight*1.4, text, "1", 0, "L", false, 0, "")
+			}
+			f.pdf.Ln(-1)
+		}
+	}
+	addRows(header, Bold)
+	addRows(body, 0)
+	f.pdf.Ln(f.LineHeight / 2)
+}
+
+// AddImage adds the image file src in its natural size at the current
+// position
+func (f *FPDF) AddImage(src string, alt string) {
+	f.pdf.Image(src, 0, 0, 0, 0, true, "", 0, "")
+	f.pdf.Ln(f.LineHeight / 2)
+}
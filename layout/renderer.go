@@ -0,0 +1,265 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Style is a bit set of text styles of a Span
+type Style int
+
+// Text styles
+const (
+	Bold   Style = 1 << iota // strong text
+	Italic                   // emphasized text
+	Code                     // code span or block, to be set in monospace font
+	Strike                   // deleted text
+)
+
+// Span is a run of text with uniform style
+type Span struct {
+	Text  string
+	Style Style
+	Link  string // destination, if the text is part of a link
+}
+
+// Row is a row of a table, each cell is a list of spans
+type Row [][]Span
+
+// Layout is the interface of a page layout driven by Render
+type Layout interface {
+	// AddHeading adds a heading of level from 1 to 6
+	AddHeading(level int, spans []Span)
+	// AddParagraph adds a block of text
+	AddParagraph(spans []Span)
+	// AddTable adds a table with optional header rows
+	AddTable(header []Row, body []Row)
+	// AddImage adds an image
+	AddImage(src string, alt string)
+}
+
+// Render walks the document and adds its blocks to l
+func Render(doc ast.Node, l Layout) {
+	r := &renderer{l: l}
+	ast.WalkFunc(doc, r.renderNode)
+	r.flush()
+}
+
+// renderer collects spans of the current block
+type renderer struct {
+	l Layout
+
+	spans  []Span
+	style  Style
+	link   string
+	prefix string // text prepended to the next paragraph, e.g. a bullet
+
+	header []Row
+	body   []Row
+}
+
+func (r *renderer) text(s string) {
+	if s == "" {
+		return
+	}
+	if n := len(r.spans); n > 0 && r.spans[n-1].Style == r.style && r.spans[n-1].Link == r.link {
+		r.spans[n-1].Text += s
+		return
+	}
+	r.spans = append(r.spans, Span{Text: s, Style: r.style, Link: r.link})
+}
+
+// takeSpans returns the collected spans, prepended with the pending prefix
+func (r *renderer) takeSpans() []Span {
+	spans := r.spans
+	if r.prefix != "" {
+		spans = append([]Span{{Text: r.prefix}}, spans...)
+		r.prefix = ""
+	}
+	r.spans = nil
+	return spans
+}
+
+// flush adds collected text as a paragraph
+func (r *renderer) flush() {
+	if len(r.spans) > 0 || r.prefix != "" {
+		r.l.AddParagraph(r.takeSpans())
+	}
+}
+
+func (r *renderer) setStyle(s Style, entering bool) {
+	if entering {
+		r.style |= s
+	} else {
+		r.style &^= s
+	}
+}
+
+// listDepth returns the number of lists containing node
+func listDepth(node ast.Node) int {
+	depth := 0
+	for p := node.GetParent(); p != nil; p = p.GetParent() {
+		if _, ok := p.(*ast.List); ok {
+			depth++
+		}
+	}
+	return depth
+}
+
+func (r *renderer) listItem(node *ast.ListItem) {
+	r.flush()
+	indent := strings.Repeat("    ", listDepth(node)-1)
+	switch {
+	case node.ListFlags&ast.ListTypeTerm != 0:
+		r.prefix = indent
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		r.prefix = indent + "    "
+	case node.ListFlags&ast.ListTypeOrdered != 0:
+		n := 1
+		if list, ok := node.Parent.(*ast.List); ok {
+			if list.Start != 0 {
+				n = list.Start
+			}
+			for _, child := range list.Children {
+				if child == ast.Node(node) {
+					break
+				}
+				n++
+			}
+		}
+		r.prefix = indent + strconv.Itoa(n) + ". "
+	default:
+		r.prefix = indent + "• "
+	}
+}
+
+func (r *renderer) tableRow(node *ast.TableRow) {
+	var row Row
+	for _, cell := range node.Children {
+		ast.WalkFunc(cell, func(n ast.Node, entering bool) ast.WalkStatus {
+			if _, ok := n.(*ast.TableCell); ok {
+				return ast.GoToNext
+			}
+			return r.renderNode(n, entering)
+		})
+		row = append(row, r.takeSpans())
+	}
+	if _, ok := node.Parent.(*ast.TableHeader); ok {
+		r.header = append(r.header, row)
+	} else {
+		r.body = append(r.body, row)
+	}
+}
+
+func (r *renderer) renderNode(node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+	case *ast.Text:
+		r.text(string(node.Literal))
+	case *ast.Softbreak:
+		r.text(" ")
+	case *ast.Hardbreak:
+		r.text("\n")
+	case *ast.NonBlockingSpace:
+		r.text(" ")
+	case *ast.Emph:
+		r.setStyle(Italic, entering)
+	case *ast.Strong:
+		r.setStyle(Bold, entering)
+	case *ast.Del:
+		r.setStyle(Strike, entering)
+	case *ast.Code, *ast.Math:
+		r.setStyle(Code, true)
+		r.text(string(node.AsLeaf().Literal))
+		r.setStyle(Code, false)
+	case *ast.Link:
+		if node.NoteID != 0 {
+			if entering {
+				r.text("[" + strconv.Itoa(node.NoteID) + "]")
+			}
+			return ast.SkipChildren
+		}
+		r.link = ""
+		if entering {
+			r.link = string(node.Destination)
+		}
+	case *ast.Image:
+		if entering {
+			r.flush()
+			var alt strings.Builder
+			ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+				if leaf := n.AsLeaf(); leaf != nil && entering {
+					alt.Write(leaf.Literal)
+				}
+				return ast.GoToNext
+			})
+			r.l.AddImage(string(node.Destination), alt.String())
+		}
+		return ast.SkipChildren
+	case *ast.Heading:
+		if entering {
+			r.flush()
+		} else {
+			r.l.AddHeading(node.Level, r.takeSpans())
+		}
+	case *ast.Paragraph, *ast.Caption:
+		// the first paragraph of a list item continues its marker
+		_, inItem := node.GetParent().(*ast.ListItem)
+		if !entering || !inItem || ast.GetPrevNode(node) != nil {
+			r.flush()
+		}
+	case *ast.ListItem:
+		if entering {
+			r.listItem(node)
+		} else {
+			r.flush()
+		}
+	case *ast.CodeBlock, *ast.MathBlock:
+		r.flush()
+		r.setStyle(Code, true)
+		r.text(strings.TrimSuffix(string(node.AsLeaf().Literal), "\n"))
+		r.setStyle(Code, false)
+		r.flush()
+	case *ast.HorizontalRule:
+		r.flush()
+	case *ast.Table:
+		r.flush()
+		if !entering {
+			r.l.AddTable(r.header, r.body)
+			r.header, r.body = nil, nil
+		}
+	case *ast.TableRow:
+		if entering {
+			r.tableRow(node)
+		}
+		return ast.SkipChildren
+	case *ast.Callout:
+		r.text("<" + string(node.ID) + ">")
+	case *ast.Citation:
+		for _, c := range node.Destination {
+			r.text("[" + string(c) + "]")
+		}
+	case *ast.Subscript, *ast.Superscript:
+		r.text(string(node.AsLeaf().Literal))
+	case *ast.Kbd:
+		// a combination of keys is styled as a whole
+		if _, ok := node.Parent.(*ast.Kbd); !ok {
+			r.setStyle(Code, entering)
+		}
+	case *ast.Dfn:
+		r.setStyle(Italic, entering)
+	case *ast.Ruby:
+		if !entering {
+			r.text("(" + string(node.Annotation) + ")")
+		}
+	default:
+		// containers like block quotes and lists only separate their
+		// content from surrounding text, raw HTML and index entries have
+		// no representation
+		if node.AsContainer() != nil {
+			r.flush()
+		}
+	}
+	return ast.GoToNext
+}
@@ -0,0 +1,155 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// recorder is a Layout recording calls as text
+type recorder struct {
+	calls []string
+}
+
+func spansString(spans []Span) string {
+	var parts []string
+	for _, s := range spans {
+		parts = append(parts, fmt.Sprintf("%q/%d/%s", s.Text, s.Style, s.Link))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *recorder) AddHeading(level int, spans []Span) {
+	r.calls = append(r.calls, fmt.Sprintf("heading %d: %s", level, spansString(spans)))
+}
+
+func (r *recorder) AddParagraph(spans []Span) {
+	r.calls = append(r.calls, "paragraph: "+spansString(spans))
+}
+
+func (r *recorder) AddTable(header []Row, body []Row) {
+	var rows []string
+	for _, row := range append(append([]Row{}, header...), body...) {
+		var cells []string
+		for _, cell := range row {
+			cells = append(cells, spansString(cell))
+		}
+		rows = append(rows, strings.Join(cells, " | "))
+	}
+	r.calls = append(r.calls, fmt.Sprintf("table %d/%d: %s", len(header), len(body), strings.Join(rows, "; ")))
+}
+
+func (r *recorder) AddImage(src string, alt string) {
+	r.calls = append(r.calls, "image: "+src+" "+alt)
+}
+
+func TestRender(t *testing.T) {
+	input := `# Report *1*
+
+Some **bold _both_** text, a [link](http://example.com) and ` + "`code`" + `.
+
+- one
+- two
+  1. nested
+
+![Chart](chart.png)
+
+| A | B |
+|---|---|
+| 1 | *2* |
+
+` + "```" + `
+x := 1
+` + "```" + `
+`
+	exp := []string{
+		`heading 1: "Report "/0/ "1"/2/`,
+		`paragraph: "Some "/0/ "bold "/1/ "both"/3/ " text, a "/0/ "link"/0/http://example.com " and "/0/ "code"/4/ "."/0/`,
+		`paragraph: "• "/0/ "one"/0/`,
+		`paragraph: "• "/0/ "two"/0/`,
+		`paragraph: "    1. "/0/ "nested"/0/`,
+		`image: chart.png Chart`,
+		`table 1/1: "A"/0/ | "B"/0/; "1"/0/ | "2"/2/`,
+		`paragraph: "x := 1"/4/`,
+	}
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	doc := p.Parse([]byte(input))
+	r := &recorder{}
+	Render(doc, r)
+	if got, want := strings.Join(r.calls, "\n"), strings.Join(exp, "\n"); got != want {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", want, got)
+	}
+}
+
+// fakePDF records calls of the PDF interface
+type fakePDF struct {
+	calls []string
+}
+
+func (p *fakePDF) SetFont(familyStr, styleStr string, size float64) {
+	p.calls = append(p.calls, fmt.Sprintf("font %s %s %g", familyStr, styleStr, size))
+}
+
+func (p *fakePDF) Write(h float64, txtStr string) {
+	p.calls = append(p.calls, fmt.Sprintf("write %q", txtStr))
+}
+
+func (p *fakePDF) WriteLinkString(h float64, displayStr, targetStr string) {
+	p.calls = append(p.calls, fmt.Sprintf("link %q %s", displayStr, targetStr))
+}
+
+func (p *fakePDF) Ln(h float64) {
+	p.calls = append(p.calls, "ln")
+}
+
+func (p *fakePDF) CellFormat(w, h float64, txtStr, borderStr string, ln int, alignStr string, fill bool, link int, linkStr string) {
+	p.calls = append(p.calls, fmt.Sprintf("cell %g %q", w, txtStr))
+}
+
+func (p *fakePDF) Image(imageNameStr string, x, y, w, h float64, flow bool, tp string, link int, linkStr string) {
+	p.calls = append(p.calls, "image "+imageNameStr)
+}
+
+func (p *fakePDF) GetPageSize() (width, height float64) {
+	return 210, 297
+}
+
+func (p *fakePDF) GetMargins() (left, top, right, bottom float64) {
+	return 10, 10, 10, 10
+}
+
+func TestFPDF(t *testing.T) {
+	input := "## Title\n\nSee *[docs](http://example.com)*.\n\n| A | B |\n|---|---|\n| 1 |\n"
+	exp := []string{
+		`font Helvetica B 17.6`,
+		`write "Title"`,
+		`ln`,
+		`font Helvetica  11`,
+		`write "See "`,
+		`font Helvetica I 11`,
+		`link "docs" http://example.com`,
+		`font Helvetica  11`,
+		`write "."`,
+		`ln`,
+		`font Helvetica B 11`,
+		`cell 95 "A"`,
+		`font Helvetica B 11`,
+		`cell 95 "B"`,
+		`ln`,
+		`font Helvetica  11`,
+		`cell 95 "1"`,
+		`font Helvetica  11`,
+		`cell 95 ""`,
+		`ln`,
+		`ln`,
+	}
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	doc := p.Parse([]byte(input))
+	pdf := &fakePDF{}
+	Render(doc, NewFPDF(pdf))
+	if got, want := strings.Join(pdf.calls, "\n"), strings.Join(exp, "\n"); got != want {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,16 @@
+/*
+Package man implements a renderer of parsed markdown document to groff man
+format, which can be displayed with man(1).
+
+	opts := man.RendererOptions{
+		Title:   "MYTOOL",
+		Section: "1",
+	}
+	renderer := man.NewRenderer(opts)
+	doc := markdown.Parse(md, nil)
+	page := markdown.Render(doc, renderer)
+
+Level 1 headings become sections (.SH), deeper headings become subsections
+(.SS). By convention names of sections are in upper case, e.g. "# NAME".
+*/
+package man
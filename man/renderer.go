@@ -0,0 +1,377 @@
+package man
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// RendererOptions is a collection of parameters of the .TH title line of
+// the man page.
+type RendererOptions struct {
+	Title   string // Name of the command, e.g. "LS"
+	Section string // Section of the manual, e.g. "1"
+	Date    string // Date of the last change
+	Source  string // Source of the command, e.g. "GNU coreutils 8.32"
+	Manual  string // Title of the manual, e.g. "User Commands"
+}
+
+// Renderer renders to groff man format, see groff_man(7).
+//
+// Do not create this directly, instead use the NewRenderer function.
+type Renderer struct {
+	opts RendererOptions
+
+	// last byte written, used to make sure requests start on a new line
+	lastByte byte
+}
+
+// NewRenderer returns a man page renderer
+func NewRenderer(opts RendererOptions) *Renderer {
+	return &Renderer{
+		opts:     opts,
+		lastByte: '\n',
+	}
+}
+
+func (r *Renderer) outs(w io.Writer, s string) {
+	if len(s) == 0 {
+		return
+	}
+	r.lastByte = s[len(s)-1]
+	io.WriteString(w, s)
+}
+
+// nl ends the current line, if there is one
+func (r *Renderer) nl(w io.Writer) {
+	if r.lastByte != '\n' {
+		r.outs(w, "\n")
+	}
+}
+
+// request writes a request (e.g. ".PP") on its own line
+func (r *Renderer) request(w io.Writer, req string) {
+	r.nl(w)
+	r.outs(w, req+"\n")
+}
+
+// escape writes text escaped for roff: backslashes and hyphens are escaped
+// and a line starting with a control character is protected with \&
+func (r *Renderer) escape(w io.Writer, text []byte) {
+	var buf bytes.Buffer
+	atLineStart := r.lastByte == '\n'
+	for _, c := range text {
+		if atLineStart && (c == '.' || c == '\'') {
+			buf.WriteString(`\&`)
+		}
+		switch c {
+		case '\\':
+			buf.WriteString(`\e`)
+		case '-':
+			buf.WriteString(`\-`)
+		default:
+			buf.WriteByte(c)
+		}
+		atLineStart = c == '\n'
+	}
+	r.outs(w, buf.String())
+}
+
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `\(dq`, -1) + `"`
+}
+
+func (r *Renderer) heading(w io.Writer, node *ast.Heading, entering bool) {
+	if !entering {
+		r.nl(w)
+		return
+	}
+	if node.Level == 1 {
+		r.nl(w)
+		r.outs(w, ".SH ")
+	} else {
+		r.nl(w)
+		r.outs(w, ".SS ")
+	}
+}
+
+func (r *Renderer) paragraph(w io.Writer, node *ast.Paragraph, entering bool) {
+	if !entering {
+		r.nl(w)
+		return
+	}
+	if _, ok := node.Parent.(*ast.ListItem); ok {
+		// the first paragraph continues .IP of the list item
+		if ast.GetPrevNode(node) != nil {
+			r.request(w, ".IP")
+		}
+		return
+	}
+	r.request(w, ".PP")
+}
+
+func (r *Renderer) list(w io.Writer, node *ast.List, entering bool) {
+	// nested lists are indented
+	if _, ok := node.Parent.(*ast.ListItem); ok {
+		r.request(w, r.oneOf(entering, ".RS", ".RE"))
+	}
+}
+
+func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
+	if !entering {
+		return
+	}
+	list, _ := node.Parent.(*ast.List)
+	switch {
+	case node.ListFlags&ast.ListTypeTerm != 0:
+		r.request(w, ".TP")
+	case node.ListFlags&ast.ListTypeDefinition != 0:
+		// the text of a definition follows the .TP term
+	case node.ListFlags&ast.ListTypeOrdered != 0 && list != nil:
+		n := list.Start
+		if n == 0 {
+			n = 1
+		}
+		for _, child := range list.Children {
+			if child == node {
+				break
+			}
+			n++
+		}
+		r.request(w, fmt.Sprintf(".IP %d. 4", n))
+	default:
+		r.request(w, `.IP \(bu 2`)
+	}
+}
+
+func (r *Renderer) codeBlock(w io.Writer, node *ast.CodeBlock) {
+	r.request(w, ".PP")
+	r.request(w, ".RS")
+	r.request(w, ".nf")
+	r.escape(w, node.Literal)
+	r.request(w, ".fi")
+	r.request(w, ".RE")
+}
+
+func (r *Renderer) link(w io.Writer, node *ast.Link, entering bool) ast.WalkStatus {
+	if node.NoteID != 0 {
+		if entering {
+			r.outs(w, fmt.Sprintf("[%d]", node.NoteID))
+		}
+		return ast.SkipChildren
+	}
+	if entering {
+		return ast.GoToNext
+	}
+	// for autolinks the text is the destination
+	var text []byte
+	for _, child := range node.Children {
+		if t, ok := child.(*ast.Text); ok {
+			text = append(text, t.Literal...)
+		}
+	}
+	dest := bytes.TrimPrefix(node.Destination, []byte("mailto:"))
+	if !bytes.Equal(text, node.Destination) && !bytes.Equal(text, dest) {
+		r.outs(w, " <")
+		r.escape(w, node.Destination)
+		r.outs(w, ">")
+	}
+	return ast.GoToNext
+}
+
+func (r *Renderer) table(w io.Writer, node *ast.Table, entering bool) {
+	if !entering {
+		r.request(w, ".TE")
+		return
+	}
+	r.request(w, ".TS")
+	// format of the columns is taken from the first row
+	row := ast.FindFirst(node, func(n ast.Node) bool {
+		_, ok := n.(*ast.TableRow)
+		return ok
+	})
+	var format []string
+	if row != nil {
+		for _, child := range row.GetChildren() {
+			cell, ok := child.(*ast.TableCell)
+			if !ok {
+				continue
+			}
+			switch cell.Align {
+			case ast.TableAlignmentRight:
+				format = append(format, "r")
+			case ast.TableAlignmentCenter:
+				format = append(format, "c")
+			default:
+				format = append(format, "l")
+			}
+		}
+	}
+	r.outs(w, strings.Join(format, " ")+".\n")
+}
+
+func (r *Renderer) tableCell(w io.Writer, node *ast.TableCell, entering bool) {
+	if !entering {
+		if node.IsHeader {
+			r.outs(w, `\fP`)
+		}
+		return
+	}
+	if ast.GetPrevNode(node) != nil {
+		r.outs(w, "\t")
+	}
+	if node.IsHeader {
+		r.outs(w, `\fB`)
+	}
+}
+
+func (r *Renderer) oneOf(first bool, s1, s2 string) string {
+	if first {
+		return s1
+	}
+	return s2
+}
+
+// RenderNode renders a markdown node to man page
+func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
+	switch node := node.(type) {
+	case *ast.Text:
+		r.escape(w, node.Literal)
+	case *ast.Softbreak:
+		r.outs(w, "\n")
+	case *ast.Hardbreak:
+		r.request(w, ".br")
+	case *ast.NonBlockingSpace:
+		r.outs(w, `\ `)
+	case *ast.Emph:
+		r.outs(w, r.oneOf(entering, `\fI`, `\fP`))
+	case *ast.Strong:
+		r.outs(w, r.oneOf(entering, `\fB`, `\fP`))
+	case *ast.Del:
+		// no strike-through in man pages
+	case *ast.Component:
+		// render the content only
+	case *ast.BlockQuote, *ast.Aside, *ast.Directive:
+		r.request(w, r.oneOf(entering, ".RS", ".RE"))
+	case *ast.Link:
+		return r.link(w, node, entering)
+	case *ast.CrossReference:
+		// render the text of the reference
+	case *ast.Citation:
+		for _, c := range node.Destination {
+			r.outs(w, "[")
+			r.escape(w, c)
+			r.outs(w, "]")
+		}
+	case *ast.Image:
+		// only the alt text is rendered
+	case *ast.Code:
+		r.outs(w, `\fB`)
+		r.escape(w, node.Literal)
+		r.outs(w, `\fP`)
+	case *ast.CodeBlock:
+		r.codeBlock(w, node)
+	case *ast.Caption:
+		if entering {
+			r.request(w, ".PP")
+		} else {
+			r.nl(w)
+		}
+	case *ast.CaptionFigure:
+		// do nothing
+	case *ast.Document:
+		// do nothing
+	case *ast.Paragraph:
+		r.paragraph(w, node, entering)
+	case *ast.LineBlock:
+		if entering {
+			r.request(w, ".PP")
+		} else {
+			r.nl(w)
+		}
+	case *ast.HTMLSpan, *ast.HTMLBlock:
+		// raw HTML has no meaning in a man page
+	case *ast.Heading:
+		r.heading(w, node, entering)
+	case *ast.HorizontalRule:
+		r.request(w, ".PP")
+		r.request(w, ".ce")
+		r.outs(w, "* * *\n")
+	case *ast.List:
+		r.list(w, node, entering)
+	case *ast.ListItem:
+		r.listItem(w, node, entering)
+	case *ast.Table:
+		r.table(w, node, entering)
+	case *ast.TableCell:
+		r.tableCell(w, node, entering)
+	case *ast.TableHeader:
+		if !entering {
+			r.request(w, "_")
+		}
+	case *ast.TableBody, *ast.TableFooter:
+		// do nothing
+	case *ast.TableRow:
+		if !entering {
+			r.nl(w)
+		}
+	case *ast.Math:
+		r.escape(w, node.Literal)
+	case *ast.MathBlock:
+		if entering {
+			r.request(w, ".PP")
+			r.escape(w, node.Literal)
+		}
+	case *ast.DocumentMatter:
+		// do nothing
+	case *ast.Callout:
+		r.outs(w, "<")
+		r.escape(w, node.ID)
+		r.outs(w, ">")
+	case *ast.Index:
+		// there is no in-text representation.
+	case *ast.Kbd:
+		// a combination of keys is bold as a whole
+		if _, ok := node.Parent.(*ast.Kbd); !ok {
+			r.outs(w, r.oneOf(entering, `\fB`, `\fP`))
+		}
+	case *ast.Dfn:
+		r.outs(w, r.oneOf(entering, `\fI`, `\fP`))
+	case *ast.Ruby:
+		if !entering {
+			r.escape(w, []byte("("+string(node.Annotation)+")"))
+		}
+	case *ast.Subscript, *ast.Superscript:
+		r.escape(w, node.AsLeaf().Literal)
+	case *ast.Footnotes:
+		if entering {
+			r.request(w, ".SH NOTES")
+		}
+	default:
+		panic(fmt.Sprintf("Unknown node %T", node))
+	}
+	return ast.GoToNext
+}
+
+// RenderHeader writes the .TH title line
+func (r *Renderer) RenderHeader(w io.Writer, _ ast.Node) {
+	args := []string{r.opts.Title, r.opts.Section, r.opts.Date, r.opts.Source, r.opts.Manual}
+	// trailing empty arguments are omitted
+	n := len(args)
+	for n > 0 && args[n-1] == "" {
+		n--
+	}
+	s := ".TH"
+	for _, arg := range args[:n] {
+		s += " " + quote(arg)
+	}
+	r.outs(w, s+"\n")
+}
+
+// RenderFooter makes sure the output ends with a new line
+func (r *Renderer) RenderFooter(w io.Writer, _ ast.Node) {
+	r.nl(w)
+}
@@ -0,0 +1,74 @@
+package man
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestRenderer(t *testing.T) {
+	input := `# NAME
+
+mytool - does *things*
+
+# OPTIONS
+
+-v
+: be **verbose**
+
+## Examples
+
+.hidden files and a [link](http://example.com):
+
+    $ mytool -v \
+        file
+
+1. one
+2. two
+   - nested
+
+| A | B |
+|---|--:|
+| 1 | 2 |
+`
+	exp := `.TH "MYTOOL" "1"
+.SH NAME
+.PP
+mytool \- does \fIthings\fP
+.SH OPTIONS
+.TP
+\-v
+be \fBverbose\fP
+.SS Examples
+.PP
+\&.hidden files and a link <http://example.com>:
+.PP
+.RS
+.nf
+$ mytool \-v \e
+    file
+.fi
+.RE
+.IP 1. 4
+one
+.IP 2. 4
+two
+.RS
+.IP \(bu 2
+nested
+.RE
+.TS
+l r.
+\fBA\fP	\fBB\fP
+_
+1	2
+.TE
+`
+	p := parser.NewWithExtensions(parser.CommonExtensions)
+	r := NewRenderer(RendererOptions{Title: "MYTOOL", Section: "1"})
+	got := string(markdown.ToHTML([]byte(input), p, r))
+	if got != exp {
+		t.Errorf("\nExpected:\n%s\nGot:\n%s", exp, got)
+	}
+}
@@ -2,7 +2,11 @@ package markdown
 
 import (
 	"bytes"
+	"errors"
 	"io"
+	"io/ioutil"
+	"runtime"
+	"sync"
 
 	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
@@ -57,13 +61,43 @@ func Parse(markdown []byte, p *parser.Parser) ast.Node {
 //
 // To convert to HTML, pass html.Renderer
 func Render(doc ast.Node, renderer Renderer) []byte {
-	var buf bytes.Buffer
-	renderer.RenderHeader(&buf, doc)
+	buf := outputPool.Get().(*bytes.Buffer)
+	renderer.RenderHeader(buf, doc)
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
-		return renderer.RenderNode(&buf, node, entering)
+		return renderer.RenderNode(buf, node, entering)
 	})
-	renderer.RenderFooter(&buf, doc)
-	return buf.Bytes()
+	renderer.RenderFooter(buf, doc)
+	res := append([]byte(nil), buf.Bytes()...)
+	if buf.Cap() <= maxPooledOutputSize {
+		buf.Reset()
+		outputPool.Put(buf)
+	}
+	return res
+}
+
+// outputPool holds output buffers of Render, so that the output of a
+// document is allocated once instead of growing it
+var outputPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// output buffers that grew larger than this aren't reused
+const maxPooledOutputSize = 1 << 20
+
+// Convert parses markdown document once and renders it with each of the
+// renderers. It returns outputs of renderers, in the same order.
+//
+// If p is nil, we use parser configured with parser.CommonExtensions.
+// The AST is shared so renderers should not modify it.
+func Convert(markdown []byte, p *parser.Parser, renderers ...Renderer) [][]byte {
+	doc := Parse(markdown, p)
+	res := make([][]byte, len(renderers))
+	for i, renderer := range renderers {
+		res[i] = Render(doc, renderer)
+	}
+	return res
 }
 
 // ToHTML converts markdownDoc to HTML.
@@ -76,10 +110,130 @@ func Render(doc ast.Node, renderer Renderer) []byte {
 func ToHTML(markdown []byte, p *parser.Parser, renderer Renderer) []byte {
 	doc := Parse(markdown, p)
 	if renderer == nil {
-		opts := html.RendererOptions{
-			Flags: html.CommonFlags,
-		}
-		renderer = html.NewRenderer(opts)
+		renderer = defaultRenderer()
 	}
 	return Render(doc, renderer)
 }
+
+// ErrInputTooLarge is returned by ParseReader and ToHTMLReader if the input
+// is larger than ReaderOptions.MaxSize.
+var ErrInputTooLarge = errors.New("markdown: input too large")
+
+// ReaderOptions configures ParseReader and ToHTMLReader.
+type ReaderOptions struct {
+	// NewParser creates a parser for each document, since a parser keeps
+	// per-document state and can be used only once. If nil, a parser with
+	// parser.CommonExtensions is used.
+	NewParser func() *parser.Parser
+	// MaxSize is the maximum size of the input in bytes, e.g. to limit
+	// memory used by documents uploaded by users. If 0, the size of the
+	// input isn't limited.
+	MaxSize int64
+}
+
+// ParseReader reads a markdown document from r and parses it, see Parse.
+// opts can be nil. It returns ErrInputTooLarge if the input is larger
+// than opts.MaxSize, or the error reading r.
+func ParseReader(r io.Reader, opts *ReaderOptions) (ast.Node, error) {
+	var o ReaderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxSize > 0 {
+		r = io.LimitReader(r, o.MaxSize+1)
+	}
+	markdown, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if o.MaxSize > 0 && int64(len(markdown)) > o.MaxSize {
+		return nil, ErrInputTooLarge
+	}
+	var p *parser.Parser
+	if o.NewParser != nil {
+		p = o.NewParser()
+	}
+	return Parse(markdown, p), nil
+}
+
+// ToHTMLReader reads a markdown document from r and converts it to HTML,
+// see ToHTML and ParseReader. opts and renderer can be nil.
+func ToHTMLReader(r io.Reader, opts *ReaderOptions, renderer Renderer) ([]byte, error) {
+	doc, err := ParseReader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if renderer == nil {
+		renderer = defaultRenderer()
+	}
+	return Render(doc, renderer), nil
+}
+
+// defaultRenderer returns html.Renderer configured with html.CommonFlags
+func defaultRenderer() Renderer {
+	opts := html.RendererOptions{
+		Flags: html.CommonFlags,
+	}
+	return html.NewRenderer(opts)
+}
+
+// BatchOptions configures ConvertAll.
+type BatchOptions struct {
+	// NewParser and NewRenderer create a parser and a renderer for each
+	// document, since they keep per-document state. If nil, a parser with
+	// parser.CommonExtensions and an html.Renderer with html.CommonFlags are
+	// used, like in ToHTML.
+	NewParser   func() *parser.Parser
+	NewRenderer func() Renderer
+	// Workers is the number of documents converted concurrently. If 0, it's
+	// runtime.GOMAXPROCS(0).
+	Workers int
+}
+
+// ConvertAll converts a batch of markdown documents, e.g. pages of a static
+// site, across multiple goroutines. It returns outputs in the same order as
+// inputs. opts can be nil.
+//
+// NewParser and NewRenderer are called concurrently and should only share
+// configuration that isn't modified.
+func ConvertAll(inputs [][]byte, opts *BatchOptions) [][]byte {
+	var o BatchOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.NewParser == nil {
+		o.NewParser = parser.New
+	}
+	if o.NewRenderer == nil {
+		o.NewRenderer = func() Renderer {
+			return html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})
+		}
+	}
+	workers := o.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	res := make([][]byte, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				doc := o.NewParser().Parse(inputs[i])
+				res[i] = Render(doc, o.NewRenderer())
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return res
+}
@@ -1,6 +1,15 @@
 package markdown
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
 
 func TestDocument(t *testing.T) {
 	var tests = []string{
@@ -23,3 +32,112 @@ func TestDocument(t *testing.T) {
 	}
 	doTests(t, tests)
 }
+
+func TestConvert(t *testing.T) {
+	input := []byte("# Title\n\nSome *text*.\n")
+	htmlRenderer := html.NewRenderer(html.RendererOptions{})
+	xhtmlRenderer := html.NewRenderer(html.RendererOptions{Flags: html.UseXHTML})
+	out := Convert(input, nil, htmlRenderer, xhtmlRenderer)
+	if len(out) != 2 {
+		t.Fatalf("want 2 outputs, got %d", len(out))
+	}
+	for i, r := range []Renderer{htmlRenderer, xhtmlRenderer} {
+		exp := string(ToHTML(input, nil, r))
+		if got := string(out[i]); got != exp {
+			t.Errorf("output %d:\nExpected[%#v]\nGot     [%#v]", i, exp, got)
+		}
+	}
+}
+
+func TestConvertAll(t *testing.T) {
+	var inputs [][]byte
+	for i := 0; i < 50; i++ {
+		inputs = append(inputs, []byte(fmt.Sprintf("# Page %d\n\nSome \"*text*\" [link][%d].\n\n[%d]: /page/%d\n", i, i, i, i)))
+	}
+	for _, opts := range []*BatchOptions{
+		nil,
+		{Workers: 3},
+		{
+			NewParser: func() *parser.Parser {
+				return parser.NewWithExtensions(parser.CommonExtensions | parser.AutoHeadingIDs)
+			},
+			NewRenderer: func() Renderer {
+				return html.NewRenderer(html.RendererOptions{Flags: html.UseXHTML})
+			},
+		},
+	} {
+		out := ConvertAll(inputs, opts)
+		if len(out) != len(inputs) {
+			t.Fatalf("want %d outputs, got %d", len(inputs), len(out))
+		}
+		for i, input := range inputs {
+			var p *parser.Parser
+			var r Renderer
+			if opts != nil && opts.NewParser != nil {
+				p, r = opts.NewParser(), opts.NewRenderer()
+			}
+			if exp := string(ToHTML(input, p, r)); string(out[i]) != exp {
+				t.Errorf("document %d: want:\n%s\ngot:\n%s", i, exp, out[i])
+			}
+		}
+	}
+	if out := ConvertAll(nil, nil); len(out) != 0 {
+		t.Errorf("want no outputs, got %d", len(out))
+	}
+}
+
+func TestToHTMLReader(t *testing.T) {
+	input := "# Title\n\nSome *text*.\n"
+	exp := string(ToHTML([]byte(input), nil, nil))
+	out, err := ToHTMLReader(iotest.OneByteReader(strings.NewReader(input)), nil, nil)
+	if err != nil || string(out) != exp {
+		t.Errorf("want:\n%s\ngot (%v):\n%s", exp, err, out)
+	}
+
+	opts := &ReaderOptions{
+		NewParser: func() *parser.Parser {
+			return parser.NewWithExtensions(parser.NoExtensions)
+		},
+		MaxSize: int64(len(input)),
+	}
+	want := ast.ToString(Parse([]byte(input), parser.NewWithExtensions(parser.NoExtensions)))
+	// options can be reused
+	for i := 0; i < 2; i++ {
+		doc, err := ParseReader(strings.NewReader(input), opts)
+		if err != nil || ast.ToString(doc) != want {
+			t.Errorf("%d: unexpected document (%v):\n%s", i, err, ast.ToString(doc))
+		}
+	}
+
+	opts.MaxSize--
+	if _, err := ToHTMLReader(strings.NewReader(input), opts, nil); err != ErrInputTooLarge {
+		t.Errorf("want %v, got %v", ErrInputTooLarge, err)
+	}
+
+	if _, err := ParseReader(iotest.TimeoutReader(strings.NewReader(input)), nil); err != iotest.ErrTimeout {
+		t.Errorf("want %v, got %v", iotest.ErrTimeout, err)
+	}
+}
+
+func TestRenderBuiltDocument(t *testing.T) {
+	doc := ast.NewDocument(
+		ast.NewHeading(1, ast.NewText("Changelog")),
+		ast.NewParagraph(ast.NewText("Changes of "), ast.NewLink("/v2", "", ast.NewStrong(ast.NewText("v2")))),
+		ast.NewList(false,
+			ast.NewListItem(ast.NewParagraph(ast.NewText("fixed "), ast.NewCode("Parse"))),
+			ast.NewListItem(ast.NewParagraph(ast.NewEmph(ast.NewText("faster")))),
+		),
+		ast.NewList(true,
+			ast.NewListItem(ast.NewParagraph(ast.NewText("one"))),
+		),
+		ast.NewBlockQuote(ast.NewParagraph(ast.NewText("quote"))),
+		ast.NewCodeBlock("go", "x := 1\n"),
+		ast.NewHorizontalRule(),
+	)
+	src := "# Changelog\n\nChanges of [**v2**](/v2)\n\n* fixed `Parse`\n* *faster*\n\n1. one\n\n> quote\n\n```go\nx := 1\n```\n\n---\n"
+	want := string(ToHTML([]byte(src), nil, nil))
+	got := string(Render(doc, html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})))
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -73,14 +73,18 @@ func (r *Renderer) listItem(w io.Writer, node *ast.ListItem, entering bool) {
 	if entering {
 		if flags&ast.ListTypeOrdered != 0 {
 			fmt.Fprintf(w, "%d.", r.orderedListCounter[r.listDepth])
-			//indentwriter.New(w, 1).Write(text)
-			fmt.Fprintf(w, "%s", text)
 			r.orderedListCounter[r.listDepth]++
 		} else {
 			r.outs(w, "-")
-			//indentwriter.New(w, 1).Write(text)
-			fmt.Fprintf(w, "%s", text)
 		}
+		switch {
+		case node.Checked:
+			r.outs(w, " [x]")
+		case node.IsTask:
+			r.outs(w, " [ ]")
+		}
+		//indentwriter.New(w, 1).Write(text)
+		fmt.Fprintf(w, "%s", text)
 		if false && !node.Tight {
 			r.outs(w, "\n")
 		}
@@ -211,6 +215,30 @@ func (r *Renderer) del(w io.Writer, node *ast.Del) {
 	r.outs(w, "~~")
 }
 
+// kbd renders a key as [[key]] and a combination of keys as kbd:[keys]
+func (r *Renderer) kbd(w io.Writer, node *ast.Kbd, entering bool) ast.WalkStatus {
+	var keys [][]byte
+	for _, child := range node.Children {
+		if key, ok := child.(*ast.Kbd); ok && len(key.Children) > 0 && key.Children[0].AsLeaf() != nil {
+			keys = append(keys, key.Children[0].AsLeaf().Literal)
+		}
+	}
+	if len(keys) == 0 {
+		if entering {
+			r.outs(w, "[[")
+		} else {
+			r.outs(w, "]]")
+		}
+		return ast.GoToNext
+	}
+	if entering {
+		r.outs(w, "kbd:[")
+		r.out(w, bytes.Join(keys, []byte("+")))
+		r.outs(w, "]")
+	}
+	return ast.SkipChildren
+}
+
 func (r *Renderer) strong(w io.Writer, node *ast.Strong) {
 	text := node.Literal
 	r.outs(w, "**")
@@ -280,30 +308,39 @@ func (r *Renderer) image(w io.Writer, node *ast.Image) {
 	r.out(w, alt)
 	r.outs(w, "](")
 	r.out(w, escape(link))
-	if len(title) != 0 {
-		r.outs(w, ` "`)
-		r.out(w, title)
-		r.outs(w, `"`)
-	}
+	r.title(w, title, node.TitleDelimiter)
 	r.outs(w, ")")
 }
 
 func (r *Renderer) link(w io.Writer, node *ast.Link) {
 	link := string(escape(node.Destination))
-	title := string(node.Title)
 	content := string(node.Literal)
 	r.outs(w, "[")
 	r.outs(w, content)
 	r.outs(w, "](")
 	r.outs(w, link)
-	if len(title) != 0 {
-		r.outs(w, ` "`)
-		r.outs(w, title)
-		r.outs(w, `"`)
-	}
+	r.title(w, node.Title, node.TitleDelimiter)
 	r.outs(w, ")")
 }
 
+// title writes the title of a link or an image in the delimiters it had
+// in the source, or in double quotes
+func (r *Renderer) title(w io.Writer, title []byte, delim byte) {
+	if len(title) == 0 {
+		return
+	}
+	open, end := `"`, `"`
+	switch delim {
+	case '\'':
+		open, end = "'", "'"
+	case '(':
+		open, end = "(", ")"
+	}
+	r.outs(w, " "+open)
+	r.out(w, title)
+	r.outs(w, end)
+}
+
 // RenderNode renders markdown node
 func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.WalkStatus {
 	switch node := node.(type) {
@@ -321,7 +358,7 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		r.del(w, node)
 	case *ast.BlockQuote:
 		panic(fmt.Sprintf("node %T NYI", node))
-	case *ast.Aside:
+	case *ast.Aside, *ast.Directive, *ast.Component, *ast.LineBlock:
 		panic(fmt.Sprintf("node %T NYI", node))
 	case *ast.Link:
 		r.link(w, node)
@@ -377,6 +414,18 @@ func (r *Renderer) RenderNode(w io.Writer, node ast.Node, entering bool) ast.Wal
 		panic(fmt.Sprintf("node %T NYI", node))
 	case *ast.Index:
 		panic(fmt.Sprintf("node %T NYI", node))
+	case *ast.Kbd:
+		return r.kbd(w, node, entering)
+	case *ast.Dfn:
+		// markdown has no syntax for it, render the term only
+	case *ast.Ruby:
+		if entering {
+			r.outs(w, "{")
+		} else {
+			r.outs(w, "|")
+			r.out(w, node.Annotation)
+			r.outs(w, "}")
+		}
 	case *ast.Subscript:
 		panic(fmt.Sprintf("node %T NYI", node))
 	case *ast.Superscript:
@@ -0,0 +1,50 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// DocumentMeta is metadata of a document given in its pandoc-style title
+// block (see parser.Titleblock), e.g.:
+//
+//	% Title of the document
+//	% Jane Doe; John Doe
+//	% 2020-01-02
+//
+// Lines can be empty, e.g. "%" for a document without authors.
+type DocumentMeta struct {
+	Title   string
+	Authors []string
+	Date    string
+}
+
+// Meta returns metadata from the first title block of doc, or nil if there
+// is none.
+func Meta(doc ast.Node) *DocumentMeta {
+	h, ok := ast.FindFirst(doc, func(node ast.Node) bool {
+		h, ok := node.(*ast.Heading)
+		return ok && h.IsTitleblock
+	}).(*ast.Heading)
+	if !ok {
+		return nil
+	}
+	lines := strings.Split(ast.HeadingText(h), "\n")
+	line := func(i int) string {
+		if i >= len(lines) {
+			return ""
+		}
+		return strings.TrimSpace(strings.TrimPrefix(lines[i], "%"))
+	}
+	meta := &DocumentMeta{
+		Title: line(0),
+		Date:  line(2),
+	}
+	for _, author := range strings.Split(line(1), ";") {
+		if author = strings.TrimSpace(author); author != "" {
+			meta.Authors = append(meta.Authors, author)
+		}
+	}
+	return meta
+}
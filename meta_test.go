@@ -0,0 +1,44 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestMeta(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *DocumentMeta
+	}{
+		{"% The *Title*\n% Jane Doe; John Doe\n% 2020-01-02\n\ntext\n",
+			&DocumentMeta{Title: "The Title", Authors: []string{"Jane Doe", "John Doe"}, Date: "2020-01-02"}},
+		{"% Title\n%\n% 2020\n", &DocumentMeta{Title: "Title", Date: "2020"}},
+		{"% Title\n", &DocumentMeta{Title: "Title"}},
+		{"# Heading\n", nil},
+	}
+	for _, test := range tests {
+		doc := Parse([]byte(test.input), parser.NewWithExtensions(parser.CommonExtensions|parser.Titleblock))
+		if got := Meta(doc); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: want %+v, got %+v", test.input, test.want, got)
+		}
+	}
+}
+
+func TestCompletePageTitleblock(t *testing.T) {
+	input := "% Title & more\n% Jane Doe\n\ntext\n"
+	render := func(title string) string {
+		p := parser.NewWithExtensions(parser.CommonExtensions | parser.Titleblock)
+		r := html.NewRenderer(html.RendererOptions{Flags: html.CompletePage, Title: title})
+		return string(ToHTML([]byte(input), p, r))
+	}
+	if got := render(""); !strings.Contains(got, "<title>Title &amp; more</title>") {
+		t.Errorf("want title of the title block, got:\n%s", got)
+	}
+	if got := render("Option"); !strings.Contains(got, "<title>Option</title>") {
+		t.Errorf("want title of options, got:\n%s", got)
+	}
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"bytes"
+	"math"
+	"time"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// OutlineEntry is a heading in the outline of a document
+type OutlineEntry struct {
+	Level   int    // level of the heading, 1 for # heading
+	Text    string // plain text of the heading
+	ID      string // heading ID, if set by the parser (see parser.HeadingIDs and parser.AutoHeadingIDs)
+	Heading *ast.Heading
+
+	// Children are the headings nested under this one
+	Children []*OutlineEntry
+}
+
+// Outline returns the hierarchy of headings in the document. A heading
+// is nested under the closest preceding heading with a lower level.
+// Title block is not part of the outline.
+func Outline(doc ast.Node) []*OutlineEntry {
+	var res []*OutlineEntry
+	var stack []*OutlineEntry
+	for _, h := range ast.Headings(doc) {
+		if h.IsTitleblock {
+			continue
+		}
+		entry := &OutlineEntry{
+			Level:   h.Level,
+			Text:    ast.HeadingText(h),
+			ID:      h.HeadingID,
+			Heading: h,
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			res = append(res, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+	return res
+}
+
+// WordCount returns the number of words in the text of the document.
+// Code blocks and raw HTML are not counted. Words are split in the text of
+// each block, so that e.g. **Go**lang is one word.
+func WordCount(doc ast.Node) int {
+	n := 0
+	var text []byte
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Text, *ast.Code:
+			text = append(text, node.AsLeaf().Literal...)
+		case *ast.Softbreak, *ast.Hardbreak, *ast.NonBlockingSpace:
+			text = append(text, ' ')
+		case *ast.Emph, *ast.Strong, *ast.Del, *ast.Link, *ast.CrossReference,
+			*ast.Image, *ast.Kbd, *ast.Ruby, *ast.Dfn, *ast.HTMLSpan:
+			// inline markup doesn't separate words
+		default:
+			n += len(bytes.FieldsFunc(text, unicode.IsSpace))
+			text = text[:0]
+		}
+		return ast.GoToNext
+	})
+	return n + len(bytes.FieldsFunc(text, unicode.IsSpace))
+}
+
+// ReadingTime estimates time needed to read the document at wordsPerMinute.
+// If wordsPerMinute is <= 0, 200 is used. The result is rounded up to
+// a whole minute.
+func ReadingTime(doc ast.Node, wordsPerMinute int) time.Duration {
+	if wordsPerMinute <= 0 {
+		wordsPerMinute = 200
+	}
+	minutes := math.Ceil(float64(WordCount(doc)) / float64(wordsPerMinute))
+	return time.Duration(minutes) * time.Minute
+}
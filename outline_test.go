@@ -0,0 +1,54 @@
+package markdown
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestOutline(t *testing.T) {
+	input := "# One\n\nSome *words* here.\n\n## Two `code`\n\n### Three\n\n## Four\n\n# Five\n"
+	doc := Parse([]byte(input), parser.NewWithExtensions(parser.CommonExtensions|parser.AutoHeadingIDs))
+
+	outline := Outline(doc)
+	if len(outline) != 2 {
+		t.Fatalf("want 2 top level entries, got %d", len(outline))
+	}
+	one := outline[0]
+	if one.Text != "One" || one.ID != "one" || one.Level != 1 || len(one.Children) != 2 {
+		t.Errorf("unexpected entry %+v", one)
+	}
+	two := one.Children[0]
+	if two.Text != "Two code" || two.ID != "two-code" || len(two.Children) != 1 {
+		t.Errorf("unexpected entry %+v", two)
+	}
+	if three := two.Children[0]; three.Text != "Three" || three.Level != 3 {
+		t.Errorf("unexpected entry %+v", three)
+	}
+
+	if n := WordCount(doc); n != 9 {
+		t.Errorf("want 9 words, got %d", n)
+	}
+	if d := ReadingTime(doc, 0); d != time.Minute {
+		t.Errorf("want reading time 1m, got %s", d)
+	}
+}
+
+func TestWordCount(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"**Go**lang is *fun*.\n", 3},
+		{"a [link](/x)s <b>x</b>y\nnext line\n", 5},
+		{"# Head\n\npara\n\n- one\n- two\n", 4},
+		{"```\nnot counted\n```\n\n| a | b |\n|---|---|\n| c | d |\n", 4},
+	}
+	for _, test := range tests {
+		doc := Parse([]byte(test.input), nil)
+		if n := WordCount(doc); n != test.want {
+			t.Errorf("%q: want %d words, got %d", test.input, test.want, n)
+		}
+	}
+}
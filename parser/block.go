@@ -5,7 +5,6 @@ import (
 	"html"
 	"regexp"
 	"strconv"
-	"unicode"
 
 	"github.com/gomarkdown/markdown/ast"
 )
@@ -70,21 +69,32 @@ var (
 // sanitizeAnchorName returns a sanitized anchor name for the given text.
 // Taken from https://github.com/shurcooL/sanitized_anchor_name/blob/master/main.go#L14:1
 func sanitizeAnchorName(text string) string {
-	var anchorName []rune
-	var futureDash = false
-	for _, r := range text {
-		switch {
-		case unicode.IsLetter(r) || unicode.IsNumber(r):
-			if futureDash && len(anchorName) > 0 {
-				anchorName = append(anchorName, '-')
-			}
-			futureDash = false
-			anchorName = append(anchorName, unicode.ToLower(r))
-		default:
-			futureDash = true
-		}
+	return slug(text, SlugOptions{})
+}
+
+// headingID returns the automatic ID of a heading with text
+func (p *Parser) headingID(text string) string {
+	if p.Opts.SlugifyFunc != nil {
+		return p.Opts.SlugifyFunc(text)
 	}
-	return string(anchorName)
+	return sanitizeAnchorName(text)
+}
+
+// autoHeadingID records heading without an explicit ID to get one with
+// AutoHeadingIDs. The ID is created from ast.HeadingText once the inline
+// content of the heading is parsed, see setAutoHeadingIDs.
+func (p *Parser) autoHeadingID(heading *ast.Heading) {
+	if heading.HeadingID == "" && p.extensions&AutoHeadingIDs != 0 {
+		p.autoIDs = append(p.autoIDs, heading)
+	}
+}
+
+// setAutoHeadingIDs sets IDs of headings recorded by autoHeadingID
+func (p *Parser) setAutoHeadingIDs() {
+	for _, heading := range p.autoIDs {
+		heading.HeadingID = p.headingID(ast.HeadingText(heading))
+	}
+	p.autoIDs = nil
 }
 
 // Parse block-level data.
@@ -99,6 +109,9 @@ func (p *Parser) block(data []byte) {
 
 	// parse out one block-level construct at a time
 	for len(data) > 0 {
+		if p.input != nil && p.nesting == 1 {
+			p.trackOffset(len(p.input) - len(data))
+		}
 		// attributes that can be specific before a block element:
 		//
 		// {#id .class1 .class2 key="value"}
@@ -123,21 +136,10 @@ func (p *Parser) block(data []byte) {
 			}
 		}
 
-		// user supplied parser function
-		if p.Opts.ParserHook != nil {
-			node, blockdata, consumed := p.Opts.ParserHook(data)
-			if consumed > 0 {
-				data = data[consumed:]
-
-				if node != nil {
-					p.addBlock(node)
-					if blockdata != nil {
-						p.block(blockdata)
-						p.finalize(node)
-					}
-				}
-				continue
-			}
+		// user supplied parser functions
+		if consumed := p.userBlock(data); consumed > 0 {
+			data = data[consumed:]
+			continue
 		}
 
 		// prefixed heading:
@@ -160,6 +162,18 @@ func (p *Parser) block(data []byte) {
 			continue
 		}
 
+		// component:
+		//
+		// <Tabs default="one">
+		// ...
+		// </Tabs>
+		if p.extensions&Components != 0 && data[0] == '<' {
+			if i := p.component(data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// block of preformatted HTML:
 		//
 		// <div>
@@ -222,6 +236,18 @@ func (p *Parser) block(data []byte) {
 			}
 		}
 
+		// generic container:
+		//
+		// ::: warning
+		// Be careful.
+		// :::
+		if p.extensions&Directives != 0 {
+			if i := p.directive(data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// horizontal rule:
 		//
 		// ------
@@ -282,6 +308,17 @@ func (p *Parser) block(data []byte) {
 			}
 		}
 
+		// line block:
+		//
+		// | 221B Baker Street
+		// | London
+		if p.extensions&LineBlocks != 0 {
+			if i := p.lineBlock(data); i > 0 {
+				data = data[i:]
+				continue
+			}
+		}
+
 		// an itemized/unordered list:
 		//
 		// * Item 1
@@ -297,14 +334,10 @@ func (p *Parser) block(data []byte) {
 		//
 		// 1. Item 1
 		// 2. Item 2
-		if i := p.oliPrefix(data); i > 0 {
+		if m := p.olMarker(data); m.size > 0 {
 			start := 0
-			if i > 2 && p.extensions&OrderedListStart != 0 {
-				s := string(data[:i-2])
-				start, _ = strconv.Atoi(s)
-				if start == 1 {
-					start = 0
-				}
+			if p.extensions&OrderedListStart != 0 && m.number != 1 {
+				start = m.number
 			}
 			data = data[p.list(data, ast.ListTypeOrdered, start):]
 			continue
@@ -348,9 +381,45 @@ func (p *Parser) block(data []byte) {
 		data = data[idx:]
 	}
 
+	if p.input != nil && p.nesting == 1 {
+		p.trackOffset(len(p.input))
+	}
 	p.nesting--
 }
 
+// userBlock tries Opts.ParserHook and then the registered block parsers,
+// adds the node of the first one that matches and returns the number of
+// consumed bytes, or 0.
+func (p *Parser) userBlock(data []byte) int {
+	if p.Opts.ParserHook != nil {
+		if consumed := p.addUserBlock(p.Opts.ParserHook, data); consumed > 0 {
+			return consumed
+		}
+	}
+	for _, bp := range p.blockParsers {
+		if consumed := p.addUserBlock(bp.fn, data); consumed > 0 {
+			return consumed
+		}
+	}
+	return 0
+}
+
+func (p *Parser) addUserBlock(fn BlockFunc, data []byte) int {
+	node, blockdata, consumed := fn(data)
+	if consumed > 0 && node != nil {
+		p.addBlock(node)
+		if blockdata != nil {
+			// the node can be of a custom type, unknown to canNodeContain
+			userTip := p.userTip
+			p.userTip = node
+			p.block(blockdata)
+			p.userTip = userTip
+			p.finalize(node)
+		}
+	}
+	return consumed
+}
+
 func (p *Parser) addBlock(n ast.Node) ast.Node {
 	p.closeUnmatchedBlocks()
 
@@ -413,15 +482,13 @@ func (p *Parser) prefixHeading(data []byte) int {
 		end--
 	}
 	if end > i {
-		if id == "" && p.extensions&AutoHeadingIDs != 0 {
-			id = sanitizeAnchorName(string(data[i:end]))
-		}
 		block := &ast.Heading{
 			HeadingID: id,
 			Level:     level,
 		}
 		block.Content = data[i:end]
 		p.addBlock(block)
+		p.autoHeadingID(block)
 	}
 	return skip
 }
@@ -483,9 +550,6 @@ func (p *Parser) prefixSpecialHeading(data []byte) int {
 		end--
 	}
 	if end > i {
-		if id == "" && p.extensions&AutoHeadingIDs != 0 {
-			id = sanitizeAnchorName(string(data[i:end]))
-		}
 		block := &ast.Heading{
 			HeadingID: id,
 			IsSpecial: true,
@@ -494,6 +558,7 @@ func (p *Parser) prefixSpecialHeading(data []byte) int {
 		block.Literal = data[i:end]
 		block.Content = data[i:end]
 		p.addBlock(block)
+		p.autoHeadingID(block)
 	}
 	return skip
 }
@@ -653,7 +718,7 @@ func finalizeHTMLBlock(block *ast.HTMLBlock) {
 
 // HTML comment, lax form
 func (p *Parser) htmlComment(data []byte, doRender bool) int {
-	i := p.inlineHTMLComment(data)
+	i := htmlCommentLength(data)
 	// needs to end with a blank line
 	if j := p.isEmpty(data[i:]); j > 0 {
 		size := i + j
@@ -844,8 +909,10 @@ func isFenceLine(data []byte, syntax *string, oldmarker string) (end int, marker
 		}
 
 		syntaxStart := i
+		var attrs []byte
 
 		if data[i] == '{' {
+			braceStart := i
 			i++
 			syntaxStart++
 
@@ -870,14 +937,31 @@ func isFenceLine(data []byte, syntax *string, oldmarker string) (end int, marker
 			}
 
 			i++
+
+			// {key=value ...} holds attributes, not the syntax
+			if bytes.IndexByte(data[syntaxStart:syntaxStart+syn], '=') >= 0 {
+				attrs = data[braceStart:i]
+				syn = 0
+			}
 		} else {
-			for i < n && !isSpace(data[i]) {
+			// the info string is the rest of the line, e.g. the syntax
+			// followed by key=value attributes
+			for i < n && data[i] != '\n' {
 				syn++
 				i++
 			}
+			for syn > 0 && isSpace(data[syntaxStart+syn-1]) {
+				syn--
+			}
+			if c == '`' && bytes.IndexByte(data[syntaxStart:syntaxStart+syn], '`') >= 0 {
+				return 0, ""
+			}
 		}
 
 		*syntax = string(data[syntaxStart : syntaxStart+syn])
+		if attrs != nil {
+			*syntax = string(attrs)
+		}
 	}
 
 	i = skipChar(data, i, ' ')
@@ -900,9 +984,10 @@ func (p *Parser) fencedCodeBlock(data []byte, doRender bool) int {
 		return 0
 	}
 
+	// the content is a slice of data unless tabs are expanded
 	var work bytes.Buffer
-	work.WriteString(syntax)
-	work.WriteByte('\n')
+	expand := p.Opts.Flags&ExpandCodeTabs != 0
+	start, contentEnd := beg, beg
 
 	for {
 		// safe to assume beg < len(data)
@@ -910,6 +995,7 @@ func (p *Parser) fencedCodeBlock(data []byte, doRender bool) int {
 		// check for the end of the code block
 		fenceEnd, _ := isFenceLine(data[beg:], nil, marker)
 		if fenceEnd != 0 {
+			contentEnd = beg
 			beg += fenceEnd
 			break
 		}
@@ -922,9 +1008,8 @@ func (p *Parser) fencedCodeBlock(data []byte, doRender bool) int {
 			return 0
 		}
 
-		// verbatim copy to the working buffer
-		if doRender {
-			work.Write(data[beg:end])
+		if doRender && expand {
+			p.writeCodeLine(&work, data[beg:end], false)
 		}
 		beg = end
 	}
@@ -933,7 +1018,11 @@ func (p *Parser) fencedCodeBlock(data []byte, doRender bool) int {
 		codeBlock := &ast.CodeBlock{
 			IsFenced: true,
 		}
-		codeBlock.Content = work.Bytes() // TODO: get rid of temp buffer
+		codeBlock.Info = []byte(syntax)
+		codeBlock.Content = data[start:contentEnd]
+		if expand {
+			codeBlock.Content = work.Bytes()
+		}
 
 		if p.extensions&Mmark == 0 {
 			p.addBlock(codeBlock)
@@ -983,19 +1072,93 @@ func unescapeString(str []byte) []byte {
 }
 
 func finalizeCodeBlock(code *ast.CodeBlock) {
-	c := code.Content
 	if code.IsFenced {
-		newlinePos := bytes.IndexByte(c, '\n')
-		firstLine := c[:newlinePos]
-		rest := c[newlinePos+1:]
-		code.Info = unescapeString(bytes.Trim(firstLine, "\n"))
-		code.Literal = rest
-	} else {
-		code.Literal = c
+		code.Info = unescapeString(code.Info)
+		code.Language, code.FenceAttrs = parseFenceInfo(code.Info)
 	}
+	code.Literal = code.Content
 	code.Content = nil
 }
 
+// parseFenceInfo parses the info string of a fenced code block into the
+// language and key=value attributes, e.g.
+// python title="example.py" linenos {hl_lines=[2,5-7]}.
+// Attributes are separated by spaces or commas and can be grouped in braces,
+// values can be bracketed or quoted. Attributes without value, like linenos,
+// have nil value.
+func parseFenceInfo(info []byte) (lang []byte, attrs map[string][]byte) {
+	i, inBraces := 0, false
+	for i < len(info) {
+		switch info[i] {
+		case ' ', '\t', ',':
+			i++
+			continue
+		case '{':
+			inBraces = true
+			i++
+			continue
+		case '}':
+			inBraces = false
+			i++
+			continue
+		}
+		keyStart := i
+		for i < len(info) && !isFenceInfoSeparator(info[i]) && info[i] != '=' {
+			i++
+		}
+		key := info[keyStart:i]
+		if i >= len(info) || info[i] != '=' {
+			if lang == nil && attrs == nil && !inBraces {
+				lang = key
+			} else {
+				attrs = addFenceAttr(attrs, string(key), nil)
+			}
+			continue
+		}
+		i++ // skip '='
+
+		var value []byte
+		switch {
+		case i < len(info) && info[i] == '[':
+			valueEnd := bytes.IndexByte(info[i:], ']')
+			if valueEnd < 0 {
+				valueEnd = len(info) - i - 1
+			}
+			value = info[i : i+valueEnd+1]
+			i += valueEnd + 1
+		case i < len(info) && (info[i] == '"' || info[i] == '\''):
+			quote := info[i]
+			i++
+			valueEnd := bytes.IndexByte(info[i:], quote)
+			if valueEnd < 0 {
+				valueEnd = len(info) - i
+			}
+			value = info[i : i+valueEnd]
+			i += valueEnd + 1
+		default:
+			valueStart := i
+			for i < len(info) && !isFenceInfoSeparator(info[i]) {
+				i++
+			}
+			value = info[valueStart:i]
+		}
+		attrs = addFenceAttr(attrs, string(key), value)
+	}
+	return lang, attrs
+}
+
+func isFenceInfoSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == ',' || c == '{' || c == '}'
+}
+
+func addFenceAttr(attrs map[string][]byte, key string, value []byte) map[string][]byte {
+	if attrs == nil {
+		attrs = map[string][]byte{}
+	}
+	attrs[key] = value
+	return attrs
+}
+
 func (p *Parser) table(data []byte) int {
 	i, columns, table := p.tableHeader(data)
 	if i == 0 {
@@ -1062,6 +1225,8 @@ func isBackslashEscaped(data []byte, i int) bool {
 }
 
 // tableHeaders parses the header. If recognized it will also add a table.
+// A header row with only empty cells is omitted, and a table can start with
+// the delimiter row to have no header at all.
 func (p *Parser) tableHeader(data []byte) (size int, columns []ast.CellAlignFlags, table ast.Node) {
 	i := 0
 	colCount := 1
@@ -1088,15 +1253,43 @@ func (p *Parser) tableHeader(data []byte) (size int, columns []ast.CellAlignFlag
 		colCount--
 	}
 
-	columns = make([]ast.CellAlignFlags, colCount)
+	// a table without a header starts with the delimiter row, it needs a row
+	// after it
+	if cols, end := tableDelimiter(data, colCount); end > 0 {
+		if next := data[end:skipUntilChar(data, end, '\n')]; bytes.IndexByte(next, '|') < 0 {
+			return
+		}
+		table = &ast.Table{}
+		p.addBlock(table)
+		return end, cols, table
+	}
 
 	// move on to the header underline
-	i++
-	if i >= len(data) {
+	if j == i || j >= len(data) {
+		return
+	}
+	columns, end := tableDelimiter(data[j:], colCount)
+	if end == 0 {
 		return
 	}
 
-	if data[i] == '|' && !isBackslashEscaped(data, i) {
+	table = &ast.Table{}
+	p.addBlock(table)
+	if !isEmptyTableRow(header) {
+		p.addBlock(&ast.TableHeader{})
+		p.tableRow(header, columns, true)
+	}
+	size = j + end
+	return
+}
+
+// tableDelimiter parses the delimiter row of a table with colCount columns
+// at the start of data. It returns the alignment of the columns and the
+// length of the row with its newline, or 0 if it's not a delimiter row.
+func tableDelimiter(data []byte, colCount int) (columns []ast.CellAlignFlags, size int) {
+	columns = make([]ast.CellAlignFlags, colCount)
+	i := 0
+	if i < len(data) && data[i] == '|' && !isBackslashEscaped(data, i) {
 		i++
 	}
 	i = skipChar(data, i, ' ')
@@ -1126,13 +1319,13 @@ func (p *Parser) tableHeader(data []byte) (size int, columns []ast.CellAlignFlag
 			i++
 		}
 		if i == n {
-			return
+			return nil, 0
 		}
 		// end of column test is messy
 		switch {
 		case dashes < 3:
 			// not a valid column
-			return
+			return nil, 0
 
 		case data[i] == '|' && !isBackslashEscaped(data, i):
 			// marker found, now skip past trailing whitespace
@@ -1144,12 +1337,12 @@ func (p *Parser) tableHeader(data []byte) (size int, columns []ast.CellAlignFlag
 
 			// trailing junk found after last column
 			if col >= colCount && i < len(data) && data[i] != '\n' {
-				return
+				return nil, 0
 			}
 
 		case (data[i] != '|' || isBackslashEscaped(data, i)) && col+1 < colCount:
 			// something else found where marker was required
-			return
+			return nil, 0
 
 		case data[i] == '\n':
 			// marker is optional for the last column
@@ -1157,19 +1350,23 @@ func (p *Parser) tableHeader(data []byte) (size int, columns []ast.CellAlignFlag
 
 		default:
 			// trailing junk found after last column
-			return
+			return nil, 0
 		}
 	}
 	if col != colCount {
-		return
+		return nil, 0
 	}
+	return columns, skipCharN(data, i, '\n', 1)
+}
 
-	table = &ast.Table{}
-	p.addBlock(table)
-	p.addBlock(&ast.TableHeader{})
-	p.tableRow(header, columns, true)
-	size = skipCharN(data, i, '\n', 1)
-	return
+// isEmptyTableRow returns true if all cells of the table row are empty
+func isEmptyTableRow(data []byte) bool {
+	for i, c := range data {
+		if c != '|' && c != ' ' && c != '\t' && c != '\n' || c == '|' && isBackslashEscaped(data, i) {
+			return false
+		}
+	}
+	return true
 }
 
 func (p *Parser) tableRow(data []byte, columns []ast.CellAlignFlags, header bool) {
@@ -1304,9 +1501,37 @@ func (p *Parser) quote(data []byte) int {
 		beg = end
 	}
 
+	quoted := raw.Bytes()
+	var cite []byte
+	if p.extensions&QuoteCite != 0 {
+		quoted, cite = quoteCite(quoted)
+	}
+
+	if p.extensions&QuoteAttribution != 0 {
+		if content, attribution := quoteAttribution(quoted); attribution != nil {
+			figure := &ast.CaptionFigure{}
+			caption := &ast.Caption{}
+			p.Inline(caption, attribution)
+
+			p.addBlock(figure) // this discard any attributes
+			block := &ast.BlockQuote{}
+			block.AsContainer().Attribute = figure.AsContainer().Attribute
+			setQuoteCite(block, cite)
+			p.addChild(block)
+			p.block(content)
+			p.finalize(block)
+
+			p.addChild(caption)
+			p.finalize(figure)
+			return end
+		}
+	}
+
 	if p.extensions&Mmark == 0 {
-		block := p.addBlock(&ast.BlockQuote{})
-		p.block(raw.Bytes())
+		block := &ast.BlockQuote{}
+		p.addBlock(block)
+		setQuoteCite(block, cite)
+		p.block(quoted)
 		p.finalize(block)
 		return end
 	}
@@ -1320,8 +1545,9 @@ func (p *Parser) quote(data []byte) int {
 		p.addBlock(figure) // this discard any attributes
 		block := &ast.BlockQuote{}
 		block.AsContainer().Attribute = figure.AsContainer().Attribute
+		setQuoteCite(block, cite)
 		p.addChild(block)
-		p.block(raw.Bytes())
+		p.block(quoted)
 		p.finalize(block)
 
 		p.addChild(caption)
@@ -1332,25 +1558,120 @@ func (p *Parser) quote(data []byte) int {
 		return end
 	}
 
-	block := p.addBlock(&ast.BlockQuote{})
-	p.block(raw.Bytes())
+	block := &ast.BlockQuote{}
+	p.addBlock(block)
+	setQuoteCite(block, cite)
+	p.block(quoted)
 	p.finalize(block)
 
 	return end
 }
 
-// returns prefix length for block code
-func (p *Parser) codePrefix(data []byte) int {
-	n := len(data)
-	if n >= 1 && data[0] == '\t' {
-		return 1
+var quoteCitePrefix = []byte("%cite:")
+
+// quoteCite splits a leading "%cite: url" line off quoted content of a
+// blockquote. The URL may be enclosed in angle brackets.
+func quoteCite(data []byte) (content, cite []byte) {
+	if !bytes.HasPrefix(data, quoteCitePrefix) {
+		return data, nil
+	}
+	end := bytes.IndexByte(data, '\n') + 1
+	if end == 0 {
+		end = len(data)
+	}
+	cite = bytes.TrimSpace(data[len(quoteCitePrefix):end])
+	if len(cite) > 1 && cite[0] == '<' && cite[len(cite)-1] == '>' {
+		cite = cite[1 : len(cite)-1]
+	}
+	if len(cite) == 0 || bytes.IndexAny(cite, " \t") >= 0 {
+		return data, nil
+	}
+	return data[end:], cite
+}
+
+// setQuoteCite sets the cite URL of block, or if there's none, moves it
+// from a cite block attribute
+func setQuoteCite(block *ast.BlockQuote, cite []byte) {
+	if attr := block.Attribute; attr != nil {
+		if v, ok := attr.Attrs["cite"]; ok {
+			if cite == nil {
+				cite = v
+			}
+			delete(attr.Attrs, "cite")
+		}
+	}
+	block.Cite = cite
+}
+
+var attributionDashes = [][]byte{[]byte("—"), []byte("―"), []byte("---"), []byte("--")}
+
+// quoteAttribution splits the last line of blockquote content data off if it
+// is an attribution like "— Author". The attribution includes the dash.
+func quoteAttribution(data []byte) (content, attribution []byte) {
+	data = bytes.TrimRight(data, " \t\n")
+	start := bytes.LastIndexByte(data, '\n') + 1
+	if start == 0 {
+		// nothing is quoted
+		return nil, nil
 	}
-	if n >= 4 && data[3] == ' ' && data[2] == ' ' && data[1] == ' ' && data[0] == ' ' {
-		return 4
+	line := data[start:]
+	for _, dash := range attributionDashes {
+		if !bytes.HasPrefix(line, dash) {
+			continue
+		}
+		author := bytes.TrimLeft(line[len(dash):], " \t")
+		if len(author) == 0 || len(author) == len(line)-len(dash) {
+			// no author or no space after the dash
+			return nil, nil
+		}
+		return data[:start], line
+	}
+	return nil, nil
+}
+
+// codePrefix returns the length of the indentation of a line of an indented
+// code block: spaces and tabs reaching the fourth column, with tabs advancing
+// to the next tab stop. It returns 0 if the line isn't indented enough.
+func (p *Parser) codePrefix(data []byte) int {
+	tabSize := p.tabSize()
+	column := 0
+	for i, c := range data {
+		switch c {
+		case ' ':
+			column++
+		case '\t':
+			column += tabSize - column%tabSize
+		default:
+			return 0
+		}
+		if column >= 4 {
+			return i + 1
+		}
 	}
 	return 0
 }
 
+// writeCodeLine writes a line of a code block to out, without the
+// indentation if indented. Tabs are kept verbatim unless ExpandCodeTabs flag
+// is set.
+func (p *Parser) writeCodeLine(out *bytes.Buffer, line []byte, indented bool) {
+	if p.Opts.Flags&ExpandCodeTabs == 0 {
+		if indented {
+			line = line[p.codePrefix(line):]
+		}
+		out.Write(line)
+		return
+	}
+	var buf bytes.Buffer
+	expandTabs(&buf, line, p.tabSize())
+	line = buf.Bytes()
+	if indented {
+		// the indentation is 4 columns, now spaces
+		line = line[4:]
+	}
+	out.Write(line)
+}
+
 func (p *Parser) code(data []byte) int {
 	var work bytes.Buffer
 
@@ -1362,19 +1683,16 @@ func (p *Parser) code(data []byte) int {
 		i = skipCharN(data, i, '\n', 1)
 
 		blankline := p.isEmpty(data[beg:i]) > 0
-		if pre := p.codePrefix(data[beg:i]); pre > 0 {
-			beg += pre
-		} else if !blankline {
+		if !blankline && p.codePrefix(data[beg:i]) == 0 {
 			// non-empty, non-prefixed line breaks the pre
 			i = beg
 			break
 		}
 
-		// verbatim copy to the working buffer
 		if blankline {
 			work.WriteByte('\n')
 		} else {
-			work.Write(data[beg:i])
+			p.writeCodeLine(&work, data[beg:i], true)
 		}
 	}
 
@@ -1418,6 +1736,21 @@ func (p *Parser) uliPrefix(data []byte) int {
 
 // returns ordered list item prefix
 func (p *Parser) oliPrefix(data []byte) int {
+	return p.olMarker(data).size
+}
+
+// olMarker is the marker of an ordered list item, e.g. "5." or "iv)"
+type olMarker struct {
+	size      int  // size of the marker with the space around it, 0 if none
+	number    int  // number of the item
+	numbering byte // '1', 'a', 'A', 'i' or 'I'
+	delim     byte // '.' or ')'
+}
+
+// olMarker parses the ordered list item marker at the start of data. Only
+// numbers followed by a dot are markers, unless FancyLists extension is
+// enabled.
+func (p *Parser) olMarker(data []byte) olMarker {
 	// start with up to 3 spaces
 	i := skipCharN(data, 0, ' ', 3)
 
@@ -1426,15 +1759,129 @@ func (p *Parser) oliPrefix(data []byte) int {
 	for i < len(data) && data[i] >= '0' && data[i] <= '9' {
 		i++
 	}
-	if start == i || i >= len(data)-1 {
-		return 0
+	m := olMarker{numbering: '1'}
+	fancy := p.extensions&FancyLists != 0
+	if start != i {
+		m.number, _ = strconv.Atoi(string(data[start:i]))
+	} else if fancy {
+		for i < len(data) && isLetter(data[i]) {
+			i++
+		}
+		m.numbering, m.number = letterNumber(data[start:i])
+	}
+	if start == i || m.number < 0 || i >= len(data)-1 {
+		return olMarker{}
+	}
+
+	// we need a dot (or a parenthesis) followed by a space or a tab
+	m.delim = data[i]
+	if m.delim != '.' && (m.delim != ')' || !fancy) {
+		return olMarker{}
+	}
+	if !(data[i+1] == ' ' || data[i+1] == '\t') {
+		return olMarker{}
+	}
+	// like in Pandoc, a capital letter followed by a dot must be followed
+	// by two spaces, so that initials like "B. Russell" don't start a list
+	if m.numbering == 'A' && m.delim == '.' && i-start == 1 && (i+2 >= len(data) || data[i+2] != ' ') {
+		return olMarker{}
 	}
+	m.size = i + 2
+	return m
+}
+
+// letterNumber returns the numbering and the number of an ordered list item
+// marker of letters, e.g. 'a' and 3 for "c" or 'I' and 4 for "IV". A single
+// letter other than i or I is alphabetical. The number is -1 if marker is
+// neither a letter nor a roman numeral.
+func letterNumber(marker []byte) (numbering byte, number int) {
+	// the longest roman numeral below 4000 is mmmdccclxxxviii
+	if len(marker) == 0 || len(marker) > 15 {
+		return 0, -1
+	}
+	upper := marker[0] >= 'A' && marker[0] <= 'Z'
+	lower := make([]byte, 0, 15)
+	for _, c := range marker {
+		if (c >= 'A' && c <= 'Z') != upper {
+			return 0, -1
+		}
+		lower = append(lower, c|0x20)
+	}
+	if len(lower) == 1 && lower[0] != 'i' {
+		numbering = 'a'
+		number = int(lower[0]-'a') + 1
+	} else {
+		numbering = 'i'
+		number = romanNumber(lower)
+		if number == 0 {
+			return 0, -1
+		}
+	}
+	if upper {
+		numbering -= 'a' - 'A'
+	}
+	return numbering, number
+}
 
-	// we need >= 1 digits followed by a dot and a space or a tab
-	if data[i] != '.' || !(data[i+1] == ' ' || data[i+1] == '\t') {
+// romanNumber returns the value of the lowercase roman numeral s, or 0 if it
+// isn't a valid one
+func romanNumber(s []byte) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		v := romanDigit(s[i])
+		if v == 0 {
+			return 0
+		}
+		if i+1 < len(s) && v < romanDigit(s[i+1]) {
+			n -= v
+		} else {
+			n += v
+		}
+	}
+	// reject non-canonical numerals like "iiii" or "ic"
+	if n <= 0 || n >= 4000 || !bytes.Equal(s, romanNumeral(n)) {
 		return 0
 	}
-	return i + 2
+	return n
+}
+
+func romanDigit(c byte) int {
+	switch c {
+	case 'i':
+		return 1
+	case 'v':
+		return 5
+	case 'x':
+		return 10
+	case 'l':
+		return 50
+	case 'c':
+		return 100
+	case 'd':
+		return 500
+	case 'm':
+		return 1000
+	}
+	return 0
+}
+
+// romanNumeral returns n as a lowercase roman numeral
+func romanNumeral(n int) []byte {
+	numerals := []struct {
+		value   int
+		numeral string
+	}{
+		{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"}, {100, "c"}, {90, "xc"},
+		{50, "l"}, {40, "xl"}, {10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+	}
+	var b []byte
+	for _, r := range numerals {
+		for n >= r.value {
+			b = append(b, r.numeral...)
+			n -= r.value
+		}
+	}
+	return b
 }
 
 // returns definition list item prefix
@@ -1459,10 +1906,15 @@ func (p *Parser) list(data []byte, flags ast.ListType, start int) int {
 		Tight:     true,
 		Start:     start,
 	}
+	if flags&ast.ListTypeOrdered != 0 {
+		m := p.olMarker(data)
+		list.Numbering = m.numbering
+		list.Delimiter = m.delim
+	}
 	block := p.addBlock(list)
 
 	for i < len(data) {
-		skip := p.listItem(data[i:], &flags)
+		skip := p.listItem(data[i:], list, &flags)
 		if flags&ast.ListItemContainsBlock != 0 {
 			list.Tight = false
 		}
@@ -1480,10 +1932,13 @@ func (p *Parser) list(data []byte, flags ast.ListType, start int) int {
 }
 
 // Returns true if the list item is not the same type as its parent list
-func (p *Parser) listTypeChanged(data []byte, flags *ast.ListType) bool {
+func (p *Parser) listTypeChanged(data []byte, list *ast.List, flags *ast.ListType) bool {
+	m := p.olMarker(data)
 	if p.dliPrefix(data) > 0 && *flags&ast.ListTypeDefinition == 0 {
 		return true
-	} else if p.oliPrefix(data) > 0 && *flags&ast.ListTypeOrdered == 0 {
+	} else if m.size > 0 && *flags&ast.ListTypeOrdered == 0 {
+		return true
+	} else if m.size > 0 && (m.delim != list.Delimiter || !sameNumbering(list.Numbering, m)) {
 		return true
 	} else if p.uliPrefix(data) > 0 && (*flags&ast.ListTypeOrdered != 0 || *flags&ast.ListTypeDefinition != 0) {
 		return true
@@ -1491,6 +1946,21 @@ func (p *Parser) listTypeChanged(data []byte, flags *ast.ListType) bool {
 	return false
 }
 
+// sameNumbering returns true if an item with marker m continues a list
+// numbered with numbering. Single letters are ambiguous, e.g. "i." can be
+// the ninth item of a list numbered with letters.
+func sameNumbering(numbering byte, m olMarker) bool {
+	switch {
+	case m.numbering == numbering:
+		return true
+	case numbering == 'a' && m.numbering == 'i', numbering == 'A' && m.numbering == 'I':
+		return m.number == 1
+	case numbering == 'i' && m.numbering == 'a', numbering == 'I' && m.numbering == 'A':
+		return romanDigit(byte('a'+m.number-1)) != 0
+	}
+	return false
+}
+
 // Returns true if block ends with a blank line, descending if needed
 // into lists and sublists.
 func endsWithBlankLine(block ast.Node) bool {
@@ -1510,7 +1980,7 @@ func endsWithBlankLine(block ast.Node) bool {
 }
 
 func finalizeList(list *ast.List) {
-	items := list.Parent.GetChildren()
+	items := list.GetChildren()
 	lastItemIdx := len(items) - 1
 	for i, item := range items {
 		isLastItem := i == lastItemIdx
@@ -1521,7 +1991,7 @@ func finalizeList(list *ast.List) {
 		}
 		// recurse into children of list item, to see if there are spaces
 		// between any of them:
-		subItems := item.GetParent().GetChildren()
+		subItems := item.GetChildren()
 		lastSubItemIdx := len(subItems) - 1
 		for j, subItem := range subItems {
 			isLastSubItem := j == lastSubItemIdx
@@ -1535,7 +2005,7 @@ func finalizeList(list *ast.List) {
 
 // Parse a single list item.
 // Assumes initial prefix is already removed if this is a sublist.
-func (p *Parser) listItem(data []byte, flags *ast.ListType) int {
+func (p *Parser) listItem(data []byte, list *ast.List, flags *ast.ListType) int {
 	// keep track of the indentation of the first line
 	itemIndent := 0
 	if data[0] == '\t' {
@@ -1547,9 +2017,14 @@ func (p *Parser) listItem(data []byte, flags *ast.ListType) int {
 	}
 
 	var bulletChar byte = '*'
+	var delim byte = '.'
 	i := p.uliPrefix(data)
 	if i == 0 {
-		i = p.oliPrefix(data)
+		m := p.olMarker(data)
+		i = m.size
+		if i > 0 {
+			delim = m.delim
+		}
 	} else {
 		bulletChar = data[i-2]
 	}
@@ -1630,7 +2105,7 @@ gatherlines:
 			// if not, it is either a different kind of list
 			// or the next item in the same list
 			if indent <= itemIndent {
-				if p.listTypeChanged(chunk, flags) {
+				if p.listTypeChanged(chunk, list, flags) {
 					*flags |= ast.ListItemEndOfList
 				} else if containsBlankLine {
 					*flags |= ast.ListItemContainsBlock
@@ -1709,7 +2184,17 @@ gatherlines:
 		ListFlags:  *flags,
 		Tight:      false,
 		BulletChar: bulletChar,
-		Delimiter:  '.', // Only '.' is possible in Markdown, but ')' will also be possible in CommonMark
+		Delimiter:  delim,
+	}
+	if p.extensions&TaskLists != 0 && *flags&ast.ListTypeDefinition == 0 {
+		if n := taskMarker(rawBytes); n > 0 && (sublist == 0 || sublist > n) {
+			listItem.IsTask = true
+			listItem.Checked = rawBytes[1] != ' '
+			rawBytes = rawBytes[n:]
+			if sublist > 0 {
+				sublist -= n
+			}
+		}
 	}
 	p.addBlock(listItem)
 
@@ -1724,7 +2209,7 @@ gatherlines:
 		}
 	} else {
 		// intermediate render of inline item
-		para := &ast.Paragraph{}
+		para := p.Opts.Arena.NewParagraph()
 		if sublist > 0 {
 			para.Content = rawBytes[:sublist]
 		} else {
@@ -1738,6 +2223,29 @@ gatherlines:
 	return line
 }
 
+// taskMarker returns the size of the [ ] or [x] marker of a task list item,
+// with the whitespace following it, at the start of data or 0 if there's
+// none
+func taskMarker(data []byte) int {
+	if len(data) < 4 || data[0] != '[' || data[2] != ']' {
+		return 0
+	}
+	if c := data[1]; c != ' ' && c != 'x' && c != 'X' {
+		return 0
+	}
+	if data[3] == '\n' {
+		return 3
+	}
+	i := 3
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
+		i++
+	}
+	if i == 3 {
+		return 0
+	}
+	return i
+}
+
 // render a single paragraph that has already been parsed out
 func (p *Parser) renderParagraph(data []byte) {
 	if len(data) == 0 {
@@ -1757,7 +2265,7 @@ func (p *Parser) renderParagraph(data []byte) {
 	for end > beg && data[end-1] == ' ' {
 		end--
 	}
-	para := &ast.Paragraph{}
+	para := p.Opts.Arena.NewParagraph()
 	para.Content = data[beg:end]
 	p.addBlock(para)
 }
@@ -1791,10 +2299,7 @@ func (p *Parser) paragraph(data []byte) int {
 	// line: index of 1st char of current line
 	// i: index of cursor/end of current line
 	var prev, line, i int
-	tabSize := tabSizeDefault
-	if p.extensions&TabSizeEight != 0 {
-		tabSize = tabSizeDouble
-	}
+	tabSize := p.tabSize()
 	// keep going until we find something to mark the end of the paragraph
 	for i < len(data) {
 		// mark the beginning of the current line
@@ -1839,17 +2344,12 @@ func (p *Parser) paragraph(data []byte) int {
 					eol--
 				}
 
-				id := ""
-				if p.extensions&AutoHeadingIDs != 0 {
-					id = sanitizeAnchorName(string(data[prev:eol]))
-				}
-
 				block := &ast.Heading{
-					Level:     level,
-					HeadingID: id,
+					Level: level,
 				}
 				block.Content = data[prev:eol]
 				p.addBlock(block)
+				p.autoHeadingID(block)
 
 				// find the end of the underline
 				return skipUntilChar(data, i, '\n')
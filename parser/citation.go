@@ -50,7 +50,7 @@ func citation(p *Parser, data []byte, offset int) (int, ast.Node) {
 		var suffix []byte
 		citation = bytes.TrimSpace(citation)
 		j := 0
-		if citation[j] != '@' {
+		if len(citation) == 0 || citation[j] != '@' {
 			// not a citation, drop out entirely.
 			return 0, nil
 		}
@@ -64,6 +64,9 @@ func citation(p *Parser, data []byte, offset int) (int, ast.Node) {
 			suffix = suff
 		}
 
+		if len(citation) < 2 {
+			return 0, nil
+		}
 		citeType := ast.CitationTypeInformative
 		j = 1
 		switch citation[j] {
@@ -65,3 +65,14 @@ func TestCitationSuffixMultiple(t *testing.T) {
 		t.Errorf("failed to find citation suffix, want %s, got %s", "p. 144, more", suff)
 	}
 }
+
+func TestCitationEmpty(t *testing.T) {
+	p := New()
+	p.extensions |= Mmark
+
+	for _, data := range []string{`[@]`, `[@a;]`, `[@, p. 1]`} {
+		if n, node := citation(p, []byte(data), 0); n != 0 || node != nil {
+			t.Errorf("%s: want no citation, got %d %v", data, n, node)
+		}
+	}
+}
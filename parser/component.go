@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// componentTag parses an opening tag of a component, e.g.
+// <Tabs default="one" count={2}>, which can span lines. It returns the node
+// and the end of the tag, or 0 if data doesn't start with a component tag.
+func componentTag(data []byte) (*ast.Component, int) {
+	n := len(data)
+	if n < 3 || data[0] != '<' || data[1] < 'A' || data[1] > 'Z' {
+		return nil, 0
+	}
+	i := 1
+	for i < n && (isAlnum(data[i]) || data[i] == '.' || data[i] == '_') {
+		i++
+	}
+	node := &ast.Component{Name: data[1:i]}
+	for {
+		start := i
+		i = skipSpace(data, i)
+		if i >= n {
+			return nil, 0
+		}
+		switch {
+		case data[i] == '>':
+			return node, i + 1
+		case data[i] == '/' && i+1 < n && data[i+1] == '>':
+			node.SelfClosing = true
+			return node, i + 2
+		case i == start:
+			// props must be separated by space
+			return nil, 0
+		}
+		end := i
+		for end < n && (isAlnum(data[end]) || bytes.IndexByte([]byte("-_:."), data[end]) >= 0) {
+			end++
+		}
+		if end == i {
+			return nil, 0
+		}
+		prop := ast.ComponentProp{Name: data[i:end]}
+		i = end
+		if i < n && data[i] == '=' {
+			i++
+			valueEnd := componentPropValue(data, i)
+			if valueEnd == 0 {
+				return nil, 0
+			}
+			prop.Value = data[i:valueEnd]
+			if data[i] == '"' || data[i] == '\'' {
+				value := html.UnescapeString(string(data[i+1 : valueEnd-1]))
+				prop.Value = []byte(value)
+			}
+			i = valueEnd
+		}
+		node.Props = append(node.Props, prop)
+	}
+}
+
+// componentPropValue returns the end of a quoted or {expression} value
+// starting at data[i], or 0
+func componentPropValue(data []byte, i int) int {
+	if i >= len(data) {
+		return 0
+	}
+	switch data[i] {
+	case '"', '\'':
+		end := skipUntilChar(data, i+1, data[i])
+		if end >= len(data) {
+			return 0
+		}
+		return end + 1
+	case '{':
+		depth := 0
+		for end := i; end < len(data); end++ {
+			switch data[end] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return end + 1
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// isComponentLine returns true if line starts with prefix followed by a
+// delimiter of a tag name
+func isComponentLine(line, prefix []byte) bool {
+	if !bytes.HasPrefix(line, prefix) || len(line) == len(prefix) {
+		return false
+	}
+	c := line[len(prefix)]
+	return isSpace(c) || c == '>' || c == '/'
+}
+
+// parse a component: the opening tag must end its line and the closing tag
+// must be on its own line. The content is parsed as blocks.
+func (p *Parser) component(data []byte) int {
+	node, i := componentTag(data)
+	if node == nil {
+		return 0
+	}
+	beg := skipUntilChar(data, i, '\n')
+	if len(bytes.TrimSpace(data[i:beg])) > 0 {
+		return 0
+	}
+	beg = skipCharN(data, beg, '\n', 1)
+	if node.SelfClosing {
+		p.addBlock(node)
+		p.finalize(node)
+		return beg
+	}
+
+	open := append([]byte("<"), node.Name...)
+	closing := append([]byte("</"), node.Name...)
+	depth := 1
+	for end := beg; end < len(data); {
+		if p.extensions&FencedCode != 0 {
+			if n := p.fencedCodeBlock(data[end:], false); n > 0 {
+				end += n
+				continue
+			}
+		}
+		lineEnd := skipCharN(data, skipUntilChar(data, end, '\n'), '\n', 1)
+		line := bytes.TrimSpace(data[end:lineEnd])
+		switch {
+		case isComponentLine(line, open) && !bytes.HasSuffix(line, []byte("/>")):
+			depth++
+		case bytes.Equal(line, append(closing, '>')):
+			depth--
+		}
+		if depth == 0 {
+			p.addBlock(node)
+			p.block(data[beg:end])
+			p.finalize(node)
+			return lineEnd
+		}
+		end = lineEnd
+	}
+	return 0
+}
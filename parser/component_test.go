@@ -0,0 +1,55 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestComponentTag(t *testing.T) {
+	tests := []struct {
+		data        string
+		end         int
+		name        string
+		props       []string
+		selfClosing bool
+	}{
+		{`<Tabs>`, 6, "Tabs", nil, false},
+		{`<UI.Card title='x' n={f({a: 1})}
+  wide />`, 42, "UI.Card", []string{"title=x", "n={f({a: 1})}", "wide"}, true},
+		{`<Card title="Fish &amp; Chips" n={a &amp; b}>`, 45, "Card", []string{"title=Fish & Chips", "n={a &amp; b}"}, false},
+		// fail
+		{`<tabs>`, 0, "", nil, false},
+		{`<Tabs title="x>`, 0, "", nil, false},
+		{`<Tabs a="1"b>`, 0, "", nil, false},
+	}
+	for i, test := range tests {
+		node, end := componentTag([]byte(test.data))
+		if end != test.end {
+			t.Errorf("test %d: want end %d, got %d", i, test.end, end)
+			continue
+		}
+		if node == nil {
+			continue
+		}
+		if string(node.Name) != test.name || node.SelfClosing != test.selfClosing {
+			t.Errorf("test %d: want %s self-closing %v, got %s %v", i, test.name, test.selfClosing, node.Name, node.SelfClosing)
+		}
+		var props []string
+		for _, prop := range node.Props {
+			s := string(prop.Name)
+			if prop.Value != nil {
+				s += "=" + string(prop.Value)
+			}
+			props = append(props, s)
+		}
+		if len(props) != len(test.props) {
+			t.Errorf("test %d: want props %q, got %q", i, test.props, props)
+			continue
+		}
+		for j := range props {
+			if props[j] != test.props[j] {
+				t.Errorf("test %d: want props %q, got %q", i, test.props, props)
+				break
+			}
+		}
+	}
+}
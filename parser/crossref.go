@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// crossReference parses [@label], a reference to the heading with ID label
+func crossReference(p *Parser, data []byte) (int, ast.Node) {
+	// data[0] is '[', data[1] is '@'
+	i := 2
+	for i < len(data) && data[i] != ']' {
+		if isSpace(data[i]) || data[i] == '[' {
+			return 0, nil
+		}
+		i++
+	}
+	if i >= len(data) || i == 2 {
+		return 0, nil
+	}
+	node := &ast.CrossReference{Destination: data[2:i]}
+	p.crossRefs = append(p.crossRefs, node)
+	return i + 1, node
+}
+
+// resolveCrossReferences sets Target of cross references to the headings
+// with their IDs. Citations of a single key that is an ID of a heading
+// become cross references. [@label] references to unknown labels are
+// kept as text.
+func (p *Parser) resolveCrossReferences() {
+	labels := map[string]ast.Node{}
+	for _, h := range ast.Headings(p.Doc) {
+		if _, ok := labels[h.HeadingID]; !ok && h.HeadingID != "" {
+			labels[h.HeadingID] = h
+		}
+	}
+	var citations []*ast.Citation
+	ast.WalkFunc(p.Doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.CrossReference:
+			if entering {
+				node.Target = labels[string(node.Destination)]
+			}
+		case *ast.Citation:
+			if len(node.Destination) == 1 && len(node.Suffix[0]) == 0 {
+				citations = append(citations, node)
+			}
+		}
+		return ast.GoToNext
+	})
+	for _, c := range citations {
+		if target := labels[string(c.Destination[0])]; target != nil {
+			ref := &ast.CrossReference{Destination: c.Destination[0], Target: target}
+			ast.Replace(c, ref)
+		}
+	}
+	for _, ref := range p.crossRefs {
+		if ref.Target == nil {
+			text := &ast.Text{}
+			text.Literal = append([]byte("[@"), ref.Destination...)
+			text.Literal = append(text.Literal, ']')
+			ast.Replace(ref, text)
+		}
+	}
+	p.crossRefs = nil
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// directiveFence checks if data starts with a line of at least 3 colons,
+// optionally followed by the name and arguments of a container. It returns
+// the number of colons, the rest of the line and the end of the line, or 0
+// if it's not a fence.
+func directiveFence(data []byte) (colons int, info []byte, end int) {
+	i := 0
+	n := len(data)
+	for i < 3 && i < n && data[i] == ' ' {
+		i++
+	}
+	for i+colons < n && data[i+colons] == ':' {
+		colons++
+	}
+	if colons < 3 {
+		return 0, nil, 0
+	}
+	end = skipUntilChar(data, i+colons, '\n')
+	info = bytes.TrimSpace(data[i+colons : end])
+	if end < n {
+		end++
+	}
+	return colons, info, end
+}
+
+// parse a generic container. Containers can be nested, the closing fence
+// must have at least as many colons as the opening one. A container that
+// isn't closed ends with the data.
+func (p *Parser) directive(data []byte) int {
+	colons, info, beg := directiveFence(data)
+	if colons == 0 || len(info) == 0 {
+		return 0
+	}
+	node := &ast.Directive{}
+	node.Name, node.Args = info, nil
+	if i := bytes.IndexAny(info, "\t "); i >= 0 {
+		node.Name = info[:i]
+		node.Args = bytes.TrimSpace(info[i:])
+	}
+
+	// colons of the opening fences of the container and nested ones
+	open := []int{colons}
+	contentEnd, end := len(data), len(data)
+	for i := beg; i < len(data); {
+		if p.extensions&FencedCode != 0 {
+			if n := p.fencedCodeBlock(data[i:], false); n > 0 {
+				i += n
+				continue
+			}
+		}
+		lineEnd := skipUntilChar(data, i, '\n')
+		if lineEnd < len(data) {
+			lineEnd++
+		}
+		c, info, _ := directiveFence(data[i:])
+		switch {
+		case c > 0 && len(info) > 0:
+			open = append(open, c)
+		case c > 0 && c >= open[len(open)-1]:
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			contentEnd, end = i, lineEnd
+			break
+		}
+		i = lineEnd
+	}
+
+	p.addBlock(node)
+	p.block(data[beg:contentEnd])
+	p.finalize(node)
+	return end
+}
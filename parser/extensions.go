@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// extensionNames maps names of extensions to their values
+var extensionNames = map[string]Extensions{
+	"NoIntraEmphasis":        NoIntraEmphasis,
+	"Tables":                 Tables,
+	"FencedCode":             FencedCode,
+	"Autolink":               Autolink,
+	"Strikethrough":          Strikethrough,
+	"LaxHTMLBlocks":          LaxHTMLBlocks,
+	"SpaceHeadings":          SpaceHeadings,
+	"HardLineBreak":          HardLineBreak,
+	"NonBlockingSpace":       NonBlockingSpace,
+	"TabSizeEight":           TabSizeEight,
+	"Footnotes":              Footnotes,
+	"NoEmptyLineBeforeBlock": NoEmptyLineBeforeBlock,
+	"HeadingIDs":             HeadingIDs,
+	"Titleblock":             Titleblock,
+	"AutoHeadingIDs":         AutoHeadingIDs,
+	"BackslashLineBreak":     BackslashLineBreak,
+	"DefinitionLists":        DefinitionLists,
+	"MathJax":                MathJax,
+	"OrderedListStart":       OrderedListStart,
+	"Attributes":             Attributes,
+	"SuperSubscript":         SuperSubscript,
+	"EmptyLinesBreakList":    EmptyLinesBreakList,
+	"Includes":               Includes,
+	"Mmark":                  Mmark,
+	"Citations":              Citations,
+	"QuoteAttribution":       QuoteAttribution,
+	"ImageFigures":           ImageFigures,
+	"Directives":             Directives,
+	"Components":             Components,
+	"QuoteCite":              QuoteCite,
+	"FancyLists":             FancyLists,
+	"TaskLists":              TaskLists,
+	"CrossReferences":        CrossReferences,
+	"IndexTerms":             IndexTerms,
+	"LineBlocks":             LineBlocks,
+	"Ruby":                   Ruby,
+	"Kbd":                    Kbd,
+	"CommonExtensions":       CommonExtensions,
+}
+
+// ParseExtensions returns extensions named in comma-separated list s, e.g.
+// "CommonExtensions,Footnotes,AutoHeadingIDs". Names are the names of the
+// constants and are case-insensitive.
+func ParseExtensions(s string) (Extensions, error) {
+	var exts Extensions
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ext, ok := extensionByName(name)
+		if !ok {
+			var names []string
+			for s := range extensionNames {
+				names = append(names, s)
+			}
+			sort.Strings(names)
+			return 0, fmt.Errorf("unknown extension '%s', known extensions: %s", name, strings.Join(names, ", "))
+		}
+		exts |= ext
+	}
+	return exts, nil
+}
+
+func extensionByName(name string) (Extensions, bool) {
+	for s, ext := range extensionNames {
+		if strings.EqualFold(s, name) {
+			return ext, true
+		}
+	}
+	return 0, false
+}
@@ -117,3 +117,37 @@ func (p *Parser) figureBlock(data []byte, doRender bool) int {
 	p.finalize(figure)
 	return beg
 }
+
+// imageFigure replaces paragraph para containing only an image with a figure
+// of the image, captioned with the image title.
+func imageFigure(para *ast.Paragraph) {
+	var image *ast.Image
+	for _, child := range para.Children {
+		switch child := child.(type) {
+		case *ast.Image:
+			if image != nil {
+				return
+			}
+			image = child
+		case *ast.Text:
+			if len(bytes.TrimSpace(child.Literal)) > 0 {
+				return
+			}
+		default:
+			return
+		}
+	}
+	if image == nil {
+		return
+	}
+
+	figure := &ast.CaptionFigure{}
+	figure.Attribute = para.Attribute
+	ast.Replace(para, figure)
+	ast.AppendChild(figure, image)
+	if len(image.Title) > 0 {
+		caption := &ast.Caption{}
+		ast.AppendChild(caption, &ast.Text{Leaf: ast.Leaf{Literal: image.Title}})
+		ast.AppendChild(figure, caption)
+	}
+}
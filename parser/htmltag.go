@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+)
+
+// htmlTagLength returns the length of raw HTML at the start of data, or 0.
+// It follows the CommonMark grammar of an open tag, a closing tag, a
+// comment, a processing instruction, a declaration or a CDATA section.
+func htmlTagLength(data []byte) int {
+	if len(data) < 3 || data[0] != '<' {
+		return 0
+	}
+	switch {
+	case data[1] == '/':
+		return closingTagLength(data)
+	case data[1] == '?':
+		return htmlEndLength(data, 2, "?>")
+	case bytes.HasPrefix(data, []byte("<!--")):
+		return htmlCommentLength(data)
+	case bytes.HasPrefix(data, []byte("<![CDATA[")):
+		return htmlEndLength(data, 9, "]]>")
+	case data[1] == '!':
+		// declaration, e.g. <!DOCTYPE html>
+		if !isLetter(data[2]) {
+			return 0
+		}
+		return htmlEndLength(data, 3, ">")
+	}
+	return openTagLength(data)
+}
+
+// htmlEndLength returns the length of data up to and including the first end
+// marker after start, or 0 if there's none
+func htmlEndLength(data []byte, start int, end string) int {
+	i := bytes.Index(data[start:], []byte(end))
+	if i < 0 {
+		return 0
+	}
+	return start + i + len(end)
+}
+
+// htmlCommentLength returns the length of the comment at the start of data,
+// or 0. <!--> and <!---> are empty comments.
+func htmlCommentLength(data []byte) int {
+	if !bytes.HasPrefix(data, []byte("<!--")) {
+		return 0
+	}
+	if bytes.HasPrefix(data, []byte("<!-->")) {
+		return 5
+	}
+	if bytes.HasPrefix(data, []byte("<!--->")) {
+		return 6
+	}
+	return htmlEndLength(data, 4, "-->")
+}
+
+// tagNameEnd returns the end of the tag name starting at data[i], or i if
+// there's none. A tag name is an ASCII letter followed by letters, digits
+// and '-'.
+func tagNameEnd(data []byte, i int) int {
+	if i >= len(data) || !isLetter(data[i]) {
+		return i
+	}
+	i++
+	for i < len(data) && (isAlnum(data[i]) || data[i] == '-') {
+		i++
+	}
+	return i
+}
+
+func closingTagLength(data []byte) int {
+	i := tagNameEnd(data, 2)
+	if i == 2 {
+		return 0
+	}
+	i = skipHTMLSpace(data, i)
+	if i < len(data) && data[i] == '>' {
+		return i + 1
+	}
+	return 0
+}
+
+func openTagLength(data []byte) int {
+	i := tagNameEnd(data, 1)
+	if i == 1 {
+		return 0
+	}
+	for {
+		j := skipHTMLSpace(data, i)
+		if j >= len(data) {
+			return 0
+		}
+		switch data[j] {
+		case '>':
+			return j + 1
+		case '/':
+			if j+1 < len(data) && data[j+1] == '>' {
+				return j + 2
+			}
+			return 0
+		}
+		// attributes must be separated by white space
+		if j == i {
+			return 0
+		}
+		if i = attributeLength(data, j); i == j {
+			return 0
+		}
+	}
+}
+
+// attributeLength returns the end of the attribute starting at data[i], or i
+// if there's none
+func attributeLength(data []byte, i int) int {
+	start := i
+	if !isLetter(data[i]) && data[i] != '_' && data[i] != ':' {
+		return start
+	}
+	i++
+	for i < len(data) && (isAlnum(data[i]) || bytes.IndexByte([]byte("_.:-"), data[i]) >= 0) {
+		i++
+	}
+	nameEnd := i
+
+	// optional value
+	i = skipHTMLSpace(data, i)
+	if i >= len(data) || data[i] != '=' {
+		return nameEnd
+	}
+	i = skipHTMLSpace(data, i+1)
+	if i >= len(data) {
+		return start
+	}
+	switch c := data[i]; c {
+	case '"', '\'':
+		end := bytes.IndexByte(data[i+1:], c)
+		if end < 0 {
+			return start
+		}
+		return i + 1 + end + 1
+	}
+	valueStart := i
+	for i < len(data) && !isSpace(data[i]) && bytes.IndexByte([]byte("\"'=<>`"), data[i]) < 0 {
+		i++
+	}
+	if i == valueStart {
+		return start
+	}
+	return i
+}
+
+func skipHTMLSpace(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestHTMLTagLength(t *testing.T) {
+	tests := []struct {
+		data string
+		want int
+	}{
+		{"<a>", 3},
+		{"<a href=\"x>y\">z", 14},
+		{"<img src='a.png' alt=b />", 25},
+		{"<my-tag data-x = 1\n  checked>", 29},
+		{"</div >", 7},
+		{"<!-- a -- b -->", 15},
+		{"<!-->", 5},
+		{"<!--->", 6},
+		{"<![CDATA[ x > y ]]>", 19},
+		{"<?php echo 1 > 2 ?>", 19},
+		{"<!DOCTYPE html>", 15},
+		{"<a b=>", 0},
+		{"<a b='c>", 0},
+		{"<a href=\"x\"title=\"y\">", 0},
+		{"<33>", 0},
+		{"< a>", 0},
+		{"</a b>", 0},
+		{"<a/b>", 0},
+		{"<!-- open", 0},
+		{"<![CDATA[ open", 0},
+		{"<!>", 0},
+		{"<http://example.com>", 0},
+	}
+	for _, test := range tests {
+		if got := htmlTagLength([]byte(test.data)); got != test.want {
+			t.Errorf("htmlTagLength(%q): got %d, want %d", test.data, got, test.want)
+		}
+	}
+}
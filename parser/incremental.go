@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Incremental re-parses a document after text edits, parsing again only the
+// parts of the source affected by an edit and reusing unchanged AST
+// subtrees. It is meant for live-preview editors of long documents.
+//
+// The source is split into chunks of top-level blocks. A chunk boundary is
+// an empty line followed by an unindented line that cannot continue the
+// preceding block (e.g. not a list item, block quote, table row or
+// definition), outside of fenced code and HTML blocks. Each chunk is parsed
+// with a new parser, and a chunk whose text didn't change keeps its nodes.
+//
+// Link references and footnotes are resolved across the whole document, so
+// a source containing them is parsed as a single chunk.
+//
+// Incremental is experimental, its API may change.
+type Incremental struct {
+	newParser func() *Parser
+
+	src    []byte
+	chunks []incChunk
+	doc    *ast.Document
+}
+
+// incChunk is a part of the source and the top-level nodes parsed from it
+type incChunk struct {
+	src   string
+	nodes []ast.Node
+}
+
+// NewIncremental returns an Incremental parser of an empty document.
+// newParser is called to create a parser for every re-parsed chunk, e.g.
+//
+//	inc := parser.NewIncremental(func() *parser.Parser {
+//		return parser.NewWithExtensions(parser.CommonExtensions)
+//	})
+func NewIncremental(newParser func() *Parser) *Incremental {
+	return &Incremental{
+		newParser: newParser,
+		doc:       &ast.Document{},
+	}
+}
+
+// Source returns the current source of the document. It must not be
+// modified.
+func (inc *Incremental) Source() []byte {
+	return inc.src
+}
+
+// Doc returns the root of the tree of the current document.
+func (inc *Incremental) Doc() ast.Node {
+	return inc.doc
+}
+
+// Parse replaces the source of the document with input and returns the
+// root of the tree. Chunks equal to those of the previous source are not
+// parsed again.
+func (inc *Incremental) Parse(input []byte) ast.Node {
+	inc.src = append([]byte(nil), input...)
+	inc.update()
+	return inc.doc
+}
+
+// Edit replaces removed bytes at offset in the source with inserted and
+// returns the root of the tree. offset and offset+removed must be within the
+// source.
+//
+// The returned *ast.Document is the same for all edits, its children are
+// updated. Nodes of chunks that were not affected by the edit are reused.
+func (inc *Incremental) Edit(offset, removed int, inserted []byte) ast.Node {
+	src := make([]byte, 0, len(inc.src)-removed+len(inserted))
+	src = append(src, inc.src[:offset]...)
+	src = append(src, inserted...)
+	src = append(src, inc.src[offset+removed:]...)
+	inc.src = src
+	inc.update()
+	return inc.doc
+}
+
+// update splits the source into chunks, parses the new ones and rebuilds
+// children of the document
+func (inc *Incremental) update() {
+	old := map[string][]int{}
+	for i, c := range inc.chunks {
+		old[c.src] = append(old[c.src], i)
+	}
+	var chunks []incChunk
+	for _, d := range splitChunks(inc.src) {
+		c := incChunk{src: string(d)}
+		if idx := old[c.src]; len(idx) > 0 {
+			c.nodes = inc.chunks[idx[0]].nodes
+			old[c.src] = idx[1:]
+		} else {
+			doc := inc.newParser().Parse(d)
+			c.nodes = doc.GetChildren()
+		}
+		chunks = append(chunks, c)
+	}
+	inc.chunks = chunks
+
+	inc.doc.Children = nil
+	for i, c := range chunks {
+		for _, n := range c.nodes {
+			n.SetParent(inc.doc)
+			inc.doc.Children = append(inc.doc.Children, n)
+		}
+		if len(c.nodes) > 0 {
+			setListEnd(c.nodes[len(c.nodes)-1], i < len(chunks)-1)
+		}
+	}
+}
+
+// setListEnd sets or clears the end of list flag of the last item of a
+// list ending a chunk. The flag is set by the parser only when a block
+// follows the list, which depends on the position of the chunk.
+func setListEnd(node ast.Node, end bool) {
+	list, ok := node.(*ast.List)
+	if !ok || len(list.Children) == 0 {
+		return
+	}
+	item, ok := list.Children[len(list.Children)-1].(*ast.ListItem)
+	if !ok {
+		return
+	}
+	if end {
+		item.ListFlags |= ast.ListItemEndOfList
+	} else {
+		item.ListFlags &^= ast.ListItemEndOfList
+	}
+}
+
+// reDocumentScope matches a link reference or footnote definition, or an
+// inline footnote, which are resolved across chunks
+var reDocumentScope = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:|\^\[|\[\^`)
+
+// splitChunks splits data at chunk boundaries, see Incremental
+func splitChunks(data []byte) [][]byte {
+	if reDocumentScope.Match(data) {
+		return [][]byte{data}
+	}
+	var chunks [][]byte
+	var split chunkSplitter
+	start := 0
+	for i := 0; i < len(data); {
+		end := i + bytes.IndexByte(data[i:], '\n') + 1
+		if end == i {
+			end = len(data)
+		}
+		if split.isStart(data[i:end], data[end:], i > start) {
+			chunks = append(chunks, data[start:i])
+			start = i
+		}
+		i = end
+	}
+	if start < len(data) {
+		chunks = append(chunks, data[start:])
+	}
+	return chunks
+}
+
+// chunkSplitter finds chunk boundaries in the source, line by line
+type chunkSplitter struct {
+	prevEmpty bool
+	fence     []byte // opening marker of the current fenced code block
+	htmlTag   string // tag of the current HTML block
+}
+
+// isStart returns true if a new chunk starts at line. rest is the source
+// following line, at least its next line, and inChunk is true if the
+// current chunk isn't empty.
+func (s *chunkSplitter) isStart(line, rest []byte, inChunk bool) bool {
+	start := false
+	trimmed := bytes.TrimLeft(line, " ")
+	switch {
+	case s.fence != nil:
+		if bytes.HasPrefix(trimmed, s.fence) && len(bytes.TrimSpace(bytes.TrimLeft(trimmed, string(s.fence[:1])))) == 0 {
+			s.fence = nil
+		}
+	case s.htmlTag != "":
+		if bytes.Contains(line, []byte("</"+s.htmlTag)) {
+			s.htmlTag = ""
+		}
+	default:
+		start = s.prevEmpty && inChunk && isChunkStart(line, rest)
+		s.fence = fenceMarker(trimmed)
+		s.htmlTag = htmlBlockTag(line)
+	}
+	s.prevEmpty = len(bytes.TrimSpace(line)) == 0
+	return start
+}
+
+// isChunkStart returns true if line can start a chunk. rest is the source
+// following line.
+func isChunkStart(line, rest []byte) bool {
+	if len(line) == 0 || strings.IndexByte("-*+>:|<$%{! \t\n0123456789", line[0]) >= 0 {
+		return false
+	}
+	// a definition on the next line continues a definition list
+	return len(rest) == 0 || rest[0] != ':'
+}
+
+// fenceMarker returns the opening marker of a fenced code block starting at
+// line, or nil
+func fenceMarker(line []byte) []byte {
+	if len(line) == 0 || (line[0] != '`' && line[0] != '~') {
+		return nil
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return nil
+	}
+	return line[:n]
+}
+
+// htmlBlockTag returns the name of the tag opening an HTML block at line,
+// unless it's closed on the same line
+func htmlBlockTag(line []byte) string {
+	if len(line) < 2 || line[0] != '<' || !isLetter(line[1]) {
+		return ""
+	}
+	n := 1
+	for n < len(line) && isAlnum(line[n]) {
+		n++
+	}
+	tag := string(line[1:n])
+	if bytes.Contains(line[n:], []byte("</"+tag)) || bytes.HasSuffix(bytes.TrimSpace(line), []byte("/>")) {
+		return ""
+	}
+	return tag
+}
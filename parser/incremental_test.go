@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func newIncrementalTestParser() *Parser {
+	return NewWithExtensions(CommonExtensions)
+}
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		data   string
+		chunks []string
+	}{
+		{"# A\n\npara\n\n# B\n", []string{"# A\n\n", "para\n\n", "# B\n"}},
+		{"- a\n\n- b\n\nc", []string{"- a\n\n- b\n\n", "c"}},
+		{"```\na\n\nb\n```\n\nc\n", []string{"```\na\n\nb\n```\n\n", "c\n"}},
+		{"<div>\n\na\n\n</div>\n\nb\n", []string{"<div>\n\na\n\n</div>\n\n", "b\n"}},
+		{"Term\n: def\n\nTerm2\n: def2\n", []string{"Term\n: def\n\nTerm2\n: def2\n"}},
+		{"a [b][1]\n\nc\n\n[1]: /url\n", []string{"a [b][1]\n\nc\n\n[1]: /url\n"}},
+	}
+	for i, test := range tests {
+		got := splitChunks([]byte(test.data))
+		if len(got) != len(test.chunks) {
+			t.Errorf("test %d: want %q, got %q", i, test.chunks, got)
+			continue
+		}
+		for j := range got {
+			if string(got[j]) != test.chunks[j] {
+				t.Errorf("test %d: want %q, got %q", i, test.chunks, got)
+				break
+			}
+		}
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	inc := NewIncremental(newIncrementalTestParser)
+	doc := inc.Parse([]byte("# Title\n\nSome *text*.\n\n- a\n- b\n\nEnd\n"))
+	heading := doc.GetChildren()[0]
+
+	edits := []struct {
+		offset, removed int
+		inserted        string
+	}{
+		{14, 6, "**bold**"},                      // edit inside a paragraph
+		{len("# Title\n\n"), 0, "```\ncode\n\n"}, // open a fence covering the rest
+		{len("# Title\n\n"), len("```\ncode\n\n"), ""},
+		{0, 0, "Intro\n\n"},
+	}
+	for i, e := range edits {
+		doc = inc.Edit(e.offset, e.removed, []byte(e.inserted))
+		want := ast.ToString(newIncrementalTestParser().Parse(inc.Source()))
+		if got := ast.ToString(doc); got != want {
+			t.Errorf("edit %d of %q:\nwant:\n%s\ngot:\n%s", i, inc.Source(), want, got)
+		}
+		for _, child := range doc.GetChildren() {
+			if child.GetParent() != doc {
+				t.Errorf("edit %d: wrong parent of %T", i, child)
+			}
+		}
+	}
+	if doc.GetChildren()[1] != heading {
+		t.Errorf("heading was parsed again")
+	}
+}
@@ -2,8 +2,10 @@ package parser
 
 import (
 	"bytes"
+	"html"
 	"regexp"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/gomarkdown/markdown/ast"
 )
@@ -42,7 +44,7 @@ func (p *Parser) Inline(currBlock ast.Node, data []byte) {
 			continue
 		}
 		// copy inactive chars into the output
-		ast.AppendChild(currBlock, newTextNode(data[beg:end]))
+		ast.AppendChild(currBlock, p.newTextNode(data[beg:end]))
 		if node != nil {
 			ast.AppendChild(currBlock, node)
 		}
@@ -54,7 +56,7 @@ func (p *Parser) Inline(currBlock ast.Node, data []byte) {
 		if data[end-1] == '\n' {
 			end--
 		}
-		ast.AppendChild(currBlock, newTextNode(data[beg:end]))
+		ast.AppendChild(currBlock, p.newTextNode(data[beg:end]))
 	}
 	p.nesting--
 }
@@ -72,18 +74,11 @@ func emphasis(p *Parser, data []byte, offset int) (int, ast.Node) {
 			return 0, nil
 		}
 		if p.extensions&SuperSubscript != 0 && c == '~' {
-			// potential subscript, no spaces, except when escaped, helperEmphasis does
-			// not check that for us, so walk the bytes and check.
-			ret := skipUntilChar(data[1:], 0, c)
+			// potential subscript, helperEmphasis doesn't check for spaces
+			ret := superSubscriptEnd(data)
 			if ret == 0 {
 				return 0, nil
 			}
-			ret++ // we started with data[1:] above.
-			for i := 1; i < ret; i++ {
-				if isSpace(data[i]) && !isEscape(data, i) {
-					return 0, nil
-				}
-			}
 			sub := &ast.Subscript{}
 			sub.Literal = data[1:ret]
 			return ret + 1, sub
@@ -157,7 +152,7 @@ func codeSpan(p *Parser, data []byte, offset int) (int, ast.Node) {
 
 	// render the code span
 	if fBegin != fEnd {
-		code := &ast.Code{}
+		code := p.Opts.Arena.NewCode()
 		code.Literal = data[fBegin:fEnd]
 		return end, code
 	}
@@ -195,6 +190,7 @@ const (
 	linkDeferredFootnote
 	linkInlineFootnote
 	linkCitation
+	linkCrossReference
 )
 
 func isReferenceStyleLink(data []byte, pos int, t linkType) bool {
@@ -217,15 +213,10 @@ func maybeInlineFootnoteOrSuper(p *Parser, data []byte, offset int) (int, ast.No
 	}
 
 	if p.extensions&SuperSubscript != 0 {
-		ret := skipUntilChar(data[offset:], 1, '^')
+		ret := superSubscriptEnd(data[offset:])
 		if ret == 0 {
 			return 0, nil
 		}
-		for i := offset; i < offset+ret; i++ {
-			if isSpace(data[i]) && !isEscape(data, i) {
-				return 0, nil
-			}
-		}
 		sup := &ast.Superscript{}
 		sup.Literal = data[offset+1 : offset+ret]
 		return ret + 1, sup
@@ -234,6 +225,28 @@ func maybeInlineFootnoteOrSuper(p *Parser, data []byte, offset int) (int, ast.No
 	return 0, nil
 }
 
+// superSubscriptEnd returns the index of the delimiter closing a super- or
+// subscript opened by data[0], or 0 if there's none. The content can't be
+// empty or contain spaces, except when escaped, and escaped delimiters
+// don't close it.
+func superSubscriptEnd(data []byte) int {
+	c := data[0]
+	for i := 1; i < len(data); i++ {
+		switch {
+		case data[i] == '\\':
+			i++
+		case data[i] == c:
+			if i == 1 {
+				return 0
+			}
+			return i
+		case isSpace(data[i]):
+			return 0
+		}
+	}
+	return 0
+}
+
 // '[': parse a link or an image or a footnote or a citation
 func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 	// no links allowed inside regular links, footnote, and deferred footnotes
@@ -252,8 +265,11 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 		t = linkImg
 		offset++
 	// [@citation], [@-citation], [@?citation], [@!citation]
-	case p.extensions&Mmark != 0 && len(data)-1 > offset && data[offset+1] == '@':
+	case p.extensions&(Mmark|Citations) != 0 && len(data)-1 > offset && data[offset+1] == '@':
 		t = linkCitation
+	// [@label]
+	case p.extensions&CrossReferences != 0 && len(data)-1 > offset && data[offset+1] == '@':
+		t = linkCrossReference
 	// [text] == regular link
 	// ^[text] == inline footnote
 	// [^refId] == deferred footnote
@@ -273,11 +289,15 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 	if t == linkCitation {
 		return citation(p, data, 0)
 	}
+	if t == linkCrossReference {
+		return crossReference(p, data)
+	}
 
 	var (
 		i                               = 1
 		noteID                          int
 		title, link, linkID, altContent []byte
+		titleDelim                      byte
 		textHasNl                       = false
 	)
 
@@ -326,15 +346,19 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 		i = skipSpace(data, i)
 
 		linkB := i
+		titles := p.linkTitles(false)
 
-		// look for link end: ' " )
+		// look for link end: ) or the start of a title
 	findlinkend:
 		for i < len(data) {
 			switch {
 			case data[i] == '\\':
 				i += 2
 
-			case data[i] == ')' || data[i] == '\'' || data[i] == '"':
+			case data[i] == ')' || (data[i] != '(' && titles.has(data[i])):
+				break findlinkend
+
+			case data[i] == '(' && titles.has('(') && i > linkB && isSpace(data[i-1]):
 				break findlinkend
 
 			default:
@@ -349,7 +373,7 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 
 		// look for title end if present
 		titleB, titleE := 0, 0
-		if data[i] == '\'' || data[i] == '"' {
+		if delim := data[i]; delim != ')' {
 			i++
 			titleB = i
 
@@ -371,16 +395,30 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 				return 0, nil
 			}
 
-			// skip whitespace after title
-			titleE = i - 1
-			for titleE > titleB && isSpace(data[titleE]) {
-				titleE--
-			}
+			if delim == '(' {
+				// the title ends at this ) and the link at the next one
+				titleE = i
+				if j := skipSpace(data, i+1); j < len(data) && data[j] == ')' {
+					i = j
+					titleDelim = delim
+				} else {
+					titleB, titleE = 0, 0
+					linkE = i
+				}
+			} else {
+				// skip whitespace after title
+				titleE = i - 1
+				for titleE > titleB && isSpace(data[titleE]) {
+					titleE--
+				}
 
-			// check for closing quote presence
-			if data[titleE] != '\'' && data[titleE] != '"' {
-				titleB, titleE = 0, 0
-				linkE = i
+				// check for closing quote presence
+				if p.titleCloses(delim, data[titleE], `'"`) {
+					titleDelim = delim
+				} else {
+					titleB, titleE = 0, 0
+					linkE = i
+				}
 			}
 		}
 
@@ -456,6 +494,7 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 		linkID = id
 		link = lr.link
 		title = lr.title
+		titleDelim = lr.titleDelim
 		if altContentConsidered {
 			altContent = lr.text
 		}
@@ -492,15 +531,17 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 			// create a new reference
 			noteID = len(p.notes) + 1
 
-			var fragment []byte
-			if len(id) > 0 {
-				if len(id) < 16 {
-					fragment = make([]byte, len(id))
-				} else {
-					fragment = make([]byte, 16)
+			// the fragment is the slug of the footnote, cut to 16 bytes at a
+			// character boundary
+			fragment := slugify(id)
+			if len(fragment) > 16 {
+				n := 16
+				for n > 0 && !utf8.RuneStart(fragment[n]) {
+					n--
 				}
-				copy(fragment, slugify(id))
-			} else {
+				fragment = bytes.TrimRight(fragment[:n], "-")
+			}
+			if len(fragment) == 0 {
 				fragment = append([]byte("footnote-"), []byte(strconv.Itoa(noteID))...)
 			}
 
@@ -535,6 +576,7 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 			link = lr.link
 			// if inline footnote, title == footnote contents
 			title = lr.title
+			titleDelim = lr.titleDelim
 			noteID = lr.noteID
 			if len(lr.text) > 0 {
 				altContent = lr.text
@@ -548,9 +590,7 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 	var uLink []byte
 	if t == linkNormal || t == linkImg {
 		if len(link) > 0 {
-			var uLinkBuf bytes.Buffer
-			unescapeText(&uLinkBuf, link)
-			uLink = uLinkBuf.Bytes()
+			uLink = unescapeLink(link)
 		}
 
 		// links need something to click on and somewhere to go
@@ -562,13 +602,13 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 	// call the relevant rendering function
 	switch t {
 	case linkNormal:
-		link := &ast.Link{
-			Destination: normalizeURI(uLink),
-			Title:       title,
-			DeferredID:  linkID,
-		}
+		link := p.Opts.Arena.NewLink()
+		link.Destination = normalizeURI(uLink)
+		link.Title = title
+		link.TitleDelimiter = titleDelim
+		link.DeferredID = linkID
 		if len(altContent) > 0 {
-			ast.AppendChild(link, newTextNode(altContent))
+			ast.AppendChild(link, p.newTextNode(altContent))
 		} else {
 			// links cannot contain other links, so turn off link parsing
 			// temporarily and recurse
@@ -581,51 +621,34 @@ func link(p *Parser, data []byte, offset int) (int, ast.Node) {
 
 	case linkImg:
 		image := &ast.Image{
-			Destination: uLink,
-			Title:       title,
+			Destination:    uLink,
+			Title:          title,
+			TitleDelimiter: titleDelim,
 		}
-		ast.AppendChild(image, newTextNode(data[1:txtE]))
+		// the label is parsed so that renderers can turn it into
+		// plain text for the alt attribute
+		p.Inline(image, data[1:txtE])
 		return i + 1, image
 
 	case linkInlineFootnote, linkDeferredFootnote:
-		link := &ast.Link{
-			Destination: link,
-			Title:       title,
-			NoteID:      noteID,
-			Footnote:    footnoteNode,
-		}
+		node := p.Opts.Arena.NewLink()
+		node.Destination = link
+		node.Title = title
+		node.NoteID = noteID
+		node.Footnote = footnoteNode
 		if t == linkDeferredFootnote {
-			link.DeferredID = data[2:txtE]
+			node.DeferredID = data[2:txtE]
 		}
 		if t == linkInlineFootnote {
 			i++
 		}
-		return i, link
+		return i, node
 
 	default:
 		return 0, nil
 	}
 }
 
-func (p *Parser) inlineHTMLComment(data []byte) int {
-	if len(data) < 5 {
-		return 0
-	}
-	if data[0] != '<' || data[1] != '!' || data[2] != '-' || data[3] != '-' {
-		return 0
-	}
-	i := 5
-	// scan for an end-of-comment marker, across lines if necessary
-	for i < len(data) && !(data[i-2] == '-' && data[i-1] == '-' && data[i] == '>') {
-		i++
-	}
-	// no end-of-comment marker
-	if i >= len(data) {
-		return 0
-	}
-	return i + 1
-}
-
 func stripMailto(link []byte) []byte {
 	if bytes.HasPrefix(link, []byte("mailto://")) {
 		return link[9:]
@@ -660,8 +683,8 @@ func leftAngle(p *Parser, data []byte, offset int) (int, ast.Node) {
 	}
 
 	altype, end := tagLength(data)
-	if size := p.inlineHTMLComment(data); size > 0 {
-		end = size
+	if altype == notAutolink {
+		end = htmlTagLength(data)
 	}
 	if end <= 2 {
 		return end, nil
@@ -672,19 +695,16 @@ func leftAngle(p *Parser, data []byte, offset int) (int, ast.Node) {
 		return end, htmlTag
 	}
 
-	var uLink bytes.Buffer
-	unescapeText(&uLink, data[1:end+1-2])
-	if uLink.Len() <= 0 {
+	link := unescapeLink(data[1 : end+1-2])
+	if len(link) == 0 {
 		return end, nil
 	}
-	link := uLink.Bytes()
-	node := &ast.Link{
-		Destination: link,
-	}
+	node := p.Opts.Arena.NewLink()
+	node.Destination = link
 	if altype == emailAutolink {
 		node.Destination = append([]byte("mailto:"), link...)
 	}
-	ast.AppendChild(node, newTextNode(stripMailto(link)))
+	ast.AppendChild(node, p.newTextNode(stripMailto(link)))
 	return end, node
 }
 
@@ -710,7 +730,18 @@ func escape(p *Parser, data []byte, offset int) (int, ast.Node) {
 		return 0, nil
 	}
 
-	return 2, newTextNode(data[1:2])
+	return 2, p.newTextNode(data[1:2])
+}
+
+// unescapeLink returns src without backslash escapes. It's src itself if it
+// has none.
+func unescapeLink(src []byte) []byte {
+	if bytes.IndexByte(src, '\\') < 0 {
+		return src
+	}
+	var b bytes.Buffer
+	unescapeText(&b, src)
+	return b.Bytes()
 }
 
 func unescapeText(ob *bytes.Buffer, src []byte) {
@@ -754,8 +785,12 @@ func entity(p *Parser, data []byte, offset int) (int, ast.Node) {
 	if bytes.Equal(ent, []byte("&amp;")) {
 		ent = []byte{'&'}
 	}
+	if p.Opts.Flags&DecodeEntities != 0 {
+		// unknown entities are left as they are
+		ent = []byte(html.UnescapeString(string(ent)))
+	}
 
-	return end, newTextNode(ent)
+	return end, p.newTextNode(ent)
 }
 
 func linkEndsWithEntity(data []byte, linkEnd int) bool {
@@ -914,14 +949,10 @@ func autoLink(p *Parser, data []byte, offset int) (int, ast.Node) {
 		}
 	}
 
-	var uLink bytes.Buffer
-	unescapeText(&uLink, data[:linkEnd])
-
-	if uLink.Len() > 0 {
-		node := &ast.Link{
-			Destination: uLink.Bytes(),
-		}
-		ast.AppendChild(node, newTextNode(uLink.Bytes()))
+	if uLink := unescapeLink(data[:linkEnd]); len(uLink) > 0 {
+		node := p.Opts.Arena.NewLink()
+		node.Destination = uLink
+		ast.AppendChild(node, p.newTextNode(uLink))
 		return linkEnd, node
 	}
 
@@ -964,7 +995,8 @@ func isSafeLink(link []byte) bool {
 	return false
 }
 
-// return the length of the given tag, or 0 is it's not valid
+// tagLength returns the kind and the length of the autolink at the start of
+// data, or notAutolink
 func tagLength(data []byte) (autolink autolinkType, end int) {
 	var i, j int
 
@@ -1006,7 +1038,7 @@ func tagLength(data []byte) (autolink autolinkType, end int) {
 		i++
 	}
 
-	// complete autolink test: no whitespace or ' or "
+	// complete autolink test: no whitespace or '<'
 	switch {
 	case i >= len(data):
 		autolink = notAutolink
@@ -1016,7 +1048,7 @@ func tagLength(data []byte) (autolink autolinkType, end int) {
 		for i < len(data) {
 			if data[i] == '\\' {
 				i += 2
-			} else if data[i] == '>' || data[i] == '\'' || data[i] == '"' || isSpace(data[i]) {
+			} else if data[i] == '>' || data[i] == '<' || isSpace(data[i]) {
 				break
 			} else {
 				i++
@@ -1034,11 +1066,7 @@ func tagLength(data []byte) (autolink autolinkType, end int) {
 		// one of the forbidden chars has been found
 		autolink = notAutolink
 	}
-	i += bytes.IndexByte(data[i:], '>')
-	if i < 0 {
-		return autolink, 0
-	}
-	return autolink, i + 1
+	return notAutolink, 0
 }
 
 // look for the address part of a mail autolink and '>'
@@ -1177,7 +1205,7 @@ func helperEmphasis(p *Parser, data []byte, c byte) (int, ast.Node) {
 				}
 			}
 
-			emph := &ast.Emph{}
+			emph := p.Opts.Arena.NewEmph()
 			p.Inline(emph, data[:i])
 			return i + 1, emph
 		}
@@ -1197,7 +1225,7 @@ func helperDoubleEmphasis(p *Parser, data []byte, c byte) (int, ast.Node) {
 		i += length
 
 		if i+1 < len(data) && data[i] == c && data[i+1] == c && i > 0 && !isSpace(data[i-1]) {
-			var node ast.Node = &ast.Strong{}
+			var node ast.Node = p.Opts.Arena.NewStrong()
 			if c == '~' {
 				node = &ast.Del{}
 			}
@@ -1229,8 +1257,8 @@ func helperTripleEmphasis(p *Parser, data []byte, offset int, c byte) (int, ast.
 		switch {
 		case i+2 < len(data) && data[i+1] == c && data[i+2] == c:
 			// triple symbol found
-			strong := &ast.Strong{}
-			em := &ast.Emph{}
+			strong := p.Opts.Arena.NewStrong()
+			em := p.Opts.Arena.NewEmph()
 			ast.AppendChild(strong, em)
 			p.Inline(em, data[:i])
 			return i + 3, strong
@@ -1278,8 +1306,10 @@ func math(p *Parser, data []byte, offset int) (int, ast.Node) {
 	return end + 1, math
 }
 
-func newTextNode(d []byte) *ast.Text {
-	return &ast.Text{ast.Leaf{Literal: d}}
+func (p *Parser) newTextNode(d []byte) *ast.Text {
+	text := p.Opts.Arena.NewText()
+	text.Literal = d
+	return text
 }
 
 func normalizeURI(s []byte) []byte {
@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// parse '[[key]]', a key of keyboard input. Other '[' are ruby annotations,
+// if enabled, and links.
+func maybeKbd(p *Parser, data []byte, offset int) (int, ast.Node) {
+	d := data[offset:]
+	if bytes.HasPrefix(d, []byte("[[")) {
+		end := bytes.Index(d, []byte("]]"))
+		if end > 0 {
+			if key := kbdKey(p, d[2:end]); key != nil {
+				return end + 2, key
+			}
+		}
+	}
+	if p.extensions&Ruby != 0 {
+		return maybeRuby(p, data, offset)
+	}
+	return link(p, data, offset)
+}
+
+// parse 'kbd:[keys]', a key or a combination of keys separated by '+',
+// e.g. 'kbd:[Ctrl+Shift+T]'
+func maybeKbdMacro(p *Parser, data []byte, offset int) (int, ast.Node) {
+	if offset > 0 && isAlnum(data[offset-1]) {
+		return 0, nil
+	}
+	d := data[offset:]
+	if !bytes.HasPrefix(d, []byte("kbd:[")) {
+		return 0, nil
+	}
+	end := bytes.IndexByte(d, ']')
+	if end < 0 {
+		return 0, nil
+	}
+	keys := bytes.Split(d[len("kbd:["):end], []byte("+"))
+	if len(keys) == 1 {
+		if key := kbdKey(p, keys[0]); key != nil {
+			return end + 1, key
+		}
+		return 0, nil
+	}
+	combo := &ast.Kbd{}
+	for i, k := range keys {
+		key := kbdKey(p, k)
+		if key == nil {
+			return 0, nil
+		}
+		if i > 0 {
+			ast.AppendChild(combo, p.newTextNode([]byte("+")))
+		}
+		ast.AppendChild(combo, key)
+	}
+	return end + 1, combo
+}
+
+// kbdKey returns a Kbd node of key, or nil if it's empty, spans lines or
+// contains brackets
+func kbdKey(p *Parser, key []byte) *ast.Kbd {
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 || bytes.ContainsAny(key, "[]\n") {
+		return nil
+	}
+	node := &ast.Kbd{}
+	ast.AppendChild(node, p.newTextNode(key))
+	return node
+}
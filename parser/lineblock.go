@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// lineBlockPrefix returns the length of the "| " prefix of a line of a line
+// block, or 0 if line isn't one. A lone "|" is an empty line.
+func lineBlockPrefix(line []byte) int {
+	switch {
+	case len(line) == 0 || line[0] != '|':
+		return 0
+	case len(line) == 1 || line[1] == '\n':
+		return 1
+	case line[1] == ' ':
+		return 2
+	}
+	return 0
+}
+
+// parse a line block, e.g. poetry or an address, whose lines keep their
+// line breaks and leading spaces. A line starting with a space continues
+// the previous one.
+func (p *Parser) lineBlock(data []byte) int {
+	if lineBlockPrefix(data) == 0 {
+		return 0
+	}
+	var lines [][]byte
+	end := 0
+	for end < len(data) {
+		lineEnd := skipUntilChar(data, end, '\n')
+		line := bytes.TrimRight(data[end:lineEnd], " \t\r")
+		if n := lineBlockPrefix(line); n > 0 {
+			lines = append(lines, line[n:])
+		} else if len(lines) > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			last := lines[len(lines)-1]
+			cont := make([]byte, 0, len(last)+len(line))
+			cont = append(append(cont, last...), ' ')
+			lines[len(lines)-1] = append(cont, bytes.TrimLeft(line, " \t")...)
+		} else {
+			break
+		}
+		end = lineEnd
+		if end < len(data) {
+			end++
+		}
+	}
+	block := &ast.LineBlock{}
+	block.Content = bytes.Join(lines, []byte{'\n'})
+	p.addBlock(block)
+	return end
+}
+
+// inlineLines parses the inline content of the lines of block, separated
+// by hard breaks. Leading spaces of a line are kept as non-blocking spaces.
+func (p *Parser) inlineLines(block *ast.LineBlock) {
+	for i, line := range bytes.Split(block.Content, []byte{'\n'}) {
+		if i > 0 {
+			ast.AppendChild(block, &ast.Hardbreak{})
+		}
+		for len(line) > 0 && line[0] == ' ' {
+			ast.AppendChild(block, &ast.NonBlockingSpace{})
+			line = line[1:]
+		}
+		p.Inline(block, line)
+	}
+	block.Content = nil
+}
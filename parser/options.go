@@ -11,6 +11,30 @@ type Flags int
 type Options struct {
 	ParserHook    BlockFunc
 	ReadIncludeFn ReadIncludeFunc
+	// SlugifyFunc, if set, creates IDs of headings with AutoHeadingIDs
+	// instead of the default, which keeps lowercased Unicode letters and
+	// digits separated by dashes. NewSlugify creates variants of it.
+	SlugifyFunc SlugifyFunc
+	// TabSize is the width of tab stops, e.g. 2, 4 or 8, used to measure
+	// the indentation of code blocks and to expand tabs if ExpandCodeTabs
+	// flag is set. If 0, it's 4, or 8 with TabSizeEight extension.
+	TabSize int
+	// CopyInput makes Parse copy the input first, so that the tree doesn't
+	// alias the caller's input, see Parser.Parse.
+	CopyInput bool
+	// RecoverFromPanics makes Parse recover from a panic of the parser,
+	// which is a bug, and return the tree parsed so far. The error is
+	// returned by Parser.Err as a *ParseError.
+	RecoverFromPanics bool
+	// LinkTitles are delimiters allowed around titles of inline links and
+	// images, e.g. [a](/url "title"), and of link reference definitions.
+	// If 0, inline links allow double and single quotes, and definitions
+	// also parentheses.
+	LinkTitles TitleDelimiters
+	// Arena, if set, allocates the most common nodes, which reduces the
+	// number of allocations when parsing many documents. The tree must not
+	// be used after the arena is reset, see ast.Arena.
+	Arena *ast.Arena
 
 	Flags Flags // Flags allow customizing parser's behavior
 }
@@ -19,12 +43,43 @@ type Options struct {
 const (
 	FlagsNone        Flags = 0
 	SkipFootnoteList Flags = 1 << iota // Skip adding the footnote list (regardless if they are parsed)
+	ExpandCodeTabs                     // Replace tabs in code blocks with spaces up to the next tab stop instead of keeping them verbatim
+	StrictLinkTitles                   // A link title must end with the delimiter it starts with, e.g. "title' is not a title
+	DecodeEntities                     // Replace named and numeric character references in text, e.g. &copy;, with the characters they stand for
 )
 
+// TitleDelimiters is a set of delimiters of link titles, see
+// Options.LinkTitles.
+type TitleDelimiters int
+
+// Delimiters of link titles.
+const (
+	TitleDoubleQuotes TitleDelimiters = 1 << iota // "title"
+	TitleSingleQuotes                             // 'title'
+	TitleParens                                   // (title)
+)
+
+// has returns true if c opens a title delimited by one of d
+func (d TitleDelimiters) has(c byte) bool {
+	switch c {
+	case '"':
+		return d&TitleDoubleQuotes != 0
+	case '\'':
+		return d&TitleSingleQuotes != 0
+	case '(':
+		return d&TitleParens != 0
+	}
+	return false
+}
+
 // BlockFunc allows to registration of a parser function. If successful it
 // returns an ast.Node, a buffer that should be parsed as a block and the the number of bytes consumed.
 type BlockFunc func(data []byte) (ast.Node, []byte, int)
 
+// SlugifyFunc returns an ID for text of a heading, e.g. "hello-world" for
+// "Hello, World!".
+type SlugifyFunc func(text string) string
+
 // ReadIncludeFunc should read the file under path and returns the read bytes,
 // from will be set to the name of the current file being parsed. Initially
 // this will be empty. address is the optional address specifier of which lines
@@ -6,6 +6,7 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode/utf8"
 
@@ -43,6 +44,19 @@ const (
 	EmptyLinesBreakList                           // 2 empty lines break out of list
 	Includes                                      // Support including other files.
 	Mmark                                         // Support Mmark syntax, see https://mmark.nl/syntax
+	Citations                                     // Pandoc-style citations: [@key, p. 33] (always on with Mmark)
+	QuoteAttribution                              // A trailing "— Author" line in a blockquote becomes its caption
+	ImageFigures                                  // A paragraph with only an image becomes a figure captioned with the image title
+	Directives                                    // Generic fenced containers: ::: name args ... :::
+	Components                                    // MDX-style components: capitalized tags like <Tabs> become ast.Component
+	QuoteCite                                     // A leading "%cite: url" line in a blockquote sets its cite URL
+	FancyLists                                    // Pandoc-style ordered lists numbered with letters or roman numerals, e.g. a. or iv), and ) after numbers
+	TaskLists                                     // GFM task list items: - [ ] to do, - [x] done
+	CrossReferences                               // [@sec:intro] references a heading with ID sec:intro, e.g. # Intro {#sec:intro}
+	IndexTerms                                    // (((term))) and (((term, subterm))) mark index terms, see ast.Index
+	LineBlocks                                    // Pandoc line blocks: lines starting with "| " keep their line breaks
+	Ruby                                          // Ruby annotations of East Asian text: {漢字|かんじ} or [漢字]{かんじ}
+	Kbd                                           // Keyboard input: [[Ctrl]]+[[C]] or kbd:[Ctrl+C]
 
 	CommonExtensions Extensions = NoIntraEmphasis | Tables | FencedCode |
 		Autolink | Strikethrough | SpaceHeadings | HeadingIDs |
@@ -55,8 +69,22 @@ const (
 	tabSizeDouble  = 8
 )
 
-// for each character that triggers a response when parsing inline data.
-type inlineParser func(p *Parser, data []byte, offset int) (int, ast.Node)
+// tabSize returns the width of tab stops
+func (p *Parser) tabSize() int {
+	if p.Opts.TabSize > 0 {
+		return p.Opts.TabSize
+	}
+	if p.extensions&TabSizeEight != 0 {
+		return tabSizeDouble
+	}
+	return tabSizeDefault
+}
+
+// InlineParserFunc parses inline data starting at data[offset], where the
+// character that triggered it is. It returns the number of consumed bytes
+// and the node to add, or 0 if there's no match. The node can be of a
+// custom type; a custom renderer or RenderNodeHook must then render it.
+type InlineParserFunc func(p *Parser, data []byte, offset int) (int, ast.Node)
 
 // ReferenceOverrideFunc is expected to be called with a reference string and
 // return either a valid Reference type that the reference string maps to or
@@ -93,11 +121,16 @@ type Parser struct {
 
 	refs           map[string]*reference
 	refsRecord     map[string]struct{}
-	inlineCallback [256]inlineParser
+	refsUsed       []string // ids of used references, in order of first use
+	inlineCallback [256]InlineParserFunc
+	blockParsers   []blockParser // sorted by descending priority
+	userTip        ast.Node      // node of a user block parser whose content is parsed
 	nesting        int
 	maxNesting     int
 	insideLink     bool
-	indexCnt       int // incremented after every index
+	indexCnt       int                   // incremented after every index
+	autoIDs        []*ast.Heading        // headings waiting for AutoHeadingIDs
+	crossRefs      []*ast.CrossReference // [@label] references, resolved after parsing
 
 	// Footnotes need to be ordered as well as available to quickly check for
 	// presence. If a ref is also a footnote, it's stored both in refs and here
@@ -113,6 +146,13 @@ type Parser struct {
 	attr *ast.Attribute
 
 	includeStack *incStack
+
+	// set with Opts.RecoverFromPanics, see recover.go
+	err     error
+	input   []byte           // input of Parse while parsing blocks
+	offset  int              // offset in input of the current top-level block
+	offsets map[ast.Node]int // offsets of top-level blocks
+	tracked int              // number of top-level blocks in offsets
 }
 
 // New creates a markdown parser with CommonExtensions.
@@ -156,6 +196,9 @@ func NewWithExtensions(extension Extensions) *Parser {
 	if p.extensions&Mmark != 0 {
 		p.inlineCallback['('] = maybeShortRefOrIndex
 	}
+	if p.extensions&IndexTerms != 0 {
+		p.inlineCallback['('] = maybeIndexTerm
+	}
 	p.inlineCallback['^'] = maybeInlineFootnoteOrSuper
 	if p.extensions&Autolink != 0 {
 		p.inlineCallback['h'] = maybeAutoLink
@@ -168,10 +211,54 @@ func NewWithExtensions(extension Extensions) *Parser {
 	if p.extensions&MathJax != 0 {
 		p.inlineCallback['$'] = math
 	}
+	if p.extensions&Ruby != 0 {
+		p.inlineCallback['{'] = ruby
+		p.inlineCallback['['] = maybeRuby
+	}
+	if p.extensions&Kbd != 0 {
+		p.inlineCallback['['] = maybeKbd
+		p.inlineCallback['k'] = maybeKbdMacro
+	}
 
 	return &p
 }
 
+// RegisterInline registers fn as the parser of inline data starting with
+// trigger, e.g. '+' for "++kbd++" spans, and returns the previously
+// registered parser, if any. fn can call it to keep the built-in syntax
+// for input it doesn't recognize.
+//
+// Content of a custom node can be parsed for inline markdown with p.Inline.
+func (p *Parser) RegisterInline(trigger byte, fn InlineParserFunc) InlineParserFunc {
+	prev := p.inlineCallback[trigger]
+	p.inlineCallback[trigger] = fn
+	return prev
+}
+
+// blockParser is a block parser registered with RegisterBlock
+type blockParser struct {
+	priority int
+	fn       BlockFunc
+}
+
+// RegisterBlock registers fn as a parser of custom block constructs, e.g.
+// directives or containers. At the start of every block, after
+// Opts.ParserHook, registered parsers are tried in order of descending
+// priority (in order of registration for equal priorities) before the
+// built-in syntax. The first one returning a non-zero number of consumed
+// bytes wins.
+//
+// The returned node can be of a custom type; a custom renderer or
+// RenderNodeHook must then render it.
+func (p *Parser) RegisterBlock(priority int, fn BlockFunc) {
+	i := sort.Search(len(p.blockParsers), func(i int) bool {
+		return p.blockParsers[i].priority < priority
+	})
+	p.blockParsers = append(p.blockParsers, blockParser{})
+	copy(p.blockParsers[i+1:], p.blockParsers[i:])
+	p.blockParsers[i] = blockParser{priority: priority, fn: fn}
+}
+
 func (p *Parser) getRef(refid string) (ref *reference, found bool) {
 	if p.ReferenceOverride != nil {
 		r, overridden := p.ReferenceOverride(refid)
@@ -188,7 +275,12 @@ func (p *Parser) getRef(refid string) (ref *reference, found bool) {
 		}
 	}
 	// refs are case insensitive
-	ref, found = p.refs[strings.ToLower(refid)]
+	id := strings.ToLower(refid)
+	ref, found = p.refs[id]
+	if found && ref.noteID == 0 && !ref.used {
+		ref.used = true
+		p.refsUsed = append(p.refsUsed, id)
+	}
 	return ref, found
 }
 
@@ -202,7 +294,7 @@ func (p *Parser) finalize(block ast.Node) {
 }
 
 func (p *Parser) addChild(node ast.Node) ast.Node {
-	for !canNodeContain(p.tip, node) {
+	for p.tip != p.userTip && !canNodeContain(p.tip, node) {
 		p.finalize(p.tip)
 	}
 	ast.AppendChild(p.tip, node)
@@ -214,7 +306,7 @@ func canNodeContain(n ast.Node, v ast.Node) bool {
 	switch n.(type) {
 	case *ast.List:
 		return isListItem(v)
-	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.ListItem, *ast.CaptionFigure:
+	case *ast.Document, *ast.BlockQuote, *ast.Aside, *ast.ListItem, *ast.CaptionFigure, *ast.Directive, *ast.Component:
 		return !isListItem(v)
 	case *ast.Table:
 		switch v.(type) {
@@ -257,31 +349,108 @@ type Reference struct {
 	Text string
 }
 
+// AddReference adds a definition of reference link id, as if the document
+// contained:
+//
+//    [id]: link "title"
+//
+// It allows sharing reference definitions between many documents. It must
+// be called before Parse. Definitions in the document take precedence.
+func (p *Parser) AddReference(id string, ref *Reference) {
+	p.refs[strings.ToLower(id)] = &reference{
+		link:  []byte(ref.Link),
+		title: []byte(ref.Title),
+		text:  []byte(ref.Text),
+	}
+}
+
+// References returns definitions of reference links, both added with
+// AddReference and defined in the parsed document. Ids are lower-cased.
+// Footnotes are not included.
+func (p *Parser) References() map[string]*Reference {
+	refs := map[string]*Reference{}
+	for id, ref := range p.refs {
+		if ref.noteID != 0 {
+			continue
+		}
+		refs[id] = &Reference{
+			Link:  string(ref.link),
+			Title: string(ref.title),
+			Text:  string(ref.text),
+		}
+	}
+	return refs
+}
+
+// UsedReferences returns (lower-cased) ids of reference links used in the
+// parsed document, in order of first use.
+func (p *Parser) UsedReferences() []string {
+	return p.refsUsed
+}
+
 // Parse generates AST (abstract syntax tree) representing markdown document.
 //
 // The result is a root of the tree whose underlying type is *ast.Document
 //
 // You can then convert AST to html using html.Renderer, to some other format
 // using a custom renderer or transform the tree.
+//
+// To avoid copying, Literal, Content and Destination of nodes are mostly
+// slices of input, so input must not be modified while the tree is in use
+// and the tree keeps input from being garbage collected. Set
+// Options.CopyInput to parse a private copy of input instead. Slices of
+// the tree must not be appended to, since that could overwrite input.
+//
+// With Options.RecoverFromPanics, a panic while parsing is returned by Err
+// instead of crashing the program.
 func (p *Parser) Parse(input []byte) ast.Node {
+	if p.Opts.CopyInput {
+		input = append([]byte(nil), input...)
+	}
+	if p.Opts.RecoverFromPanics {
+		p.parseRecover(input)
+		return p.Doc
+	}
+	return p.parse(input)
+}
+
+func (p *Parser) parse(input []byte) ast.Node {
 	p.block(input)
+	p.input = nil
 	// Walk the tree and finish up some of unfinished blocks
 	for p.tip != nil {
 		p.finalize(p.tip)
 	}
 	// Walk the tree again and process inline markdown in each block
+	var paras []*ast.Paragraph
 	ast.WalkFunc(p.Doc, func(node ast.Node, entering bool) ast.WalkStatus {
-		switch node.(type) {
+		if entering && p.offsets != nil && node.GetParent() == p.Doc {
+			p.setInlineOffset(node)
+		}
+		switch node := node.(type) {
 		case *ast.Paragraph, *ast.Heading, *ast.TableCell:
 			p.Inline(node, node.AsContainer().Content)
 			node.AsContainer().Content = nil
+		case *ast.LineBlock:
+			p.inlineLines(node)
+		}
+		if para, ok := node.(*ast.Paragraph); ok && entering && p.extensions&ImageFigures != 0 {
+			paras = append(paras, para)
 		}
 		return ast.GoToNext
 	})
+	for _, para := range paras {
+		imageFigure(para)
+	}
 
 	if p.Opts.Flags&SkipFootnoteList == 0 {
+		p.offset = -1
 		p.parseRefsToAST()
 	}
+	p.setAutoHeadingIDs()
+	if p.extensions&CrossReferences != 0 {
+		p.resolveCrossReferences()
+	}
 	return p.Doc
 }
 
@@ -295,7 +464,7 @@ func (p *Parser) parseRefsToAST() {
 		ListFlags:       ast.ListTypeOrdered,
 	}
 	p.addBlock(&ast.Footnotes{})
-	block := p.addBlock(list)
+	p.addBlock(list)
 	flags := ast.ListItemBeginningOfList
 	// Note: this loop is intentionally explicit, not range-form. This is
 	// because the body of the loop will append nested footnotes to p.notes and
@@ -311,6 +480,10 @@ func (p *Parser) parseRefsToAST() {
 		if ref.hasBlock {
 			flags |= ast.ListItemContainsBlock
 			p.block(ref.title)
+			// parse the inline content right away so that references to
+			// other footnotes made from a block footnote are appended to
+			// p.notes while this loop is still running
+			p.inlineBlocks(block)
 		} else {
 			p.Inline(block, ref.title)
 		}
@@ -319,12 +492,18 @@ func (p *Parser) parseRefsToAST() {
 	above := list.Parent
 	finalizeList(list)
 	p.tip = above
+}
 
-	ast.WalkFunc(block, func(node ast.Node, entering bool) ast.WalkStatus {
-		switch node.(type) {
-		case *ast.Paragraph, *ast.Heading:
+// inlineBlocks parses the inline content of all paragraphs, headings,
+// table cells and line blocks under node.
+func (p *Parser) inlineBlocks(node ast.Node) {
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Paragraph, *ast.Heading, *ast.TableCell:
 			p.Inline(node, node.AsContainer().Content)
 			node.AsContainer().Content = nil
+		case *ast.LineBlock:
+			p.inlineLines(node)
 		}
 		return ast.GoToNext
 	})
@@ -397,11 +576,13 @@ func (p *Parser) parseRefsToAST() {
 // TODO: As you can see, it begs for splitting into two dedicated structures
 // for refs and for footnotes.
 type reference struct {
-	link     []byte
-	title    []byte
-	noteID   int // 0 if not a footnote ref
-	hasBlock bool
-	footnote ast.Node // a link to the Item node within a list of footnotes
+	link       []byte
+	title      []byte
+	titleDelim byte // delimiter opening the title, see ast.Link.TitleDelimiter
+	noteID     int  // 0 if not a footnote ref
+	hasBlock   bool
+	footnote   ast.Node // a link to the Item node within a list of footnotes
+	used       bool     // true if a link refers to it
 
 	text []byte // only gets populated by refOverride feature with Reference.Text
 }
@@ -509,6 +690,9 @@ func isReference(p *Parser, data []byte, tabSize int) int {
 	} else {
 		ref.link = data[linkOffset:linkEnd]
 		ref.title = data[titleOffset:titleEnd]
+		if titleEnd > titleOffset {
+			ref.titleDelim = data[titleOffset-1]
+		}
 	}
 
 	// id matches are case-insensitive
@@ -538,7 +722,8 @@ func scanLinkRef(p *Parser, data []byte, i int) (linkOffset, linkEnd, titleOffse
 	for i < len(data) && (data[i] == ' ' || data[i] == '\t') {
 		i++
 	}
-	if i < len(data) && data[i] != '\n' && data[i] != '\r' && data[i] != '\'' && data[i] != '"' && data[i] != '(' {
+	titles := p.linkTitles(true)
+	if i < len(data) && data[i] != '\n' && data[i] != '\r' && !titles.has(data[i]) {
 		return
 	}
 
@@ -559,7 +744,8 @@ func scanLinkRef(p *Parser, data []byte, i int) (linkOffset, linkEnd, titleOffse
 	}
 
 	// optional title: any non-newline sequence enclosed in '"() alone on its line
-	if i+1 < len(data) && (data[i] == '\'' || data[i] == '"' || data[i] == '(') {
+	if i+1 < len(data) && titles.has(data[i]) {
+		delim := data[i]
 		i++
 		titleOffset = i
 
@@ -578,7 +764,7 @@ func scanLinkRef(p *Parser, data []byte, i int) (linkOffset, linkEnd, titleOffse
 		for i > titleOffset && (data[i] == ' ' || data[i] == '\t') {
 			i--
 		}
-		if i > titleOffset && (data[i] == '\'' || data[i] == '"' || data[i] == ')') {
+		if i > titleOffset && p.titleCloses(delim, data[i], `'")`) {
 			lineEnd = titleEnd
 			titleEnd = i
 		}
@@ -587,6 +773,30 @@ func scanLinkRef(p *Parser, data []byte, i int) (linkOffset, linkEnd, titleOffse
 	return
 }
 
+// linkTitles returns delimiters of titles allowed by Options.LinkTitles in
+// link reference definitions if ref is true, or in inline links
+func (p *Parser) linkTitles(ref bool) TitleDelimiters {
+	if p.Opts.LinkTitles != 0 {
+		return p.Opts.LinkTitles
+	}
+	if ref {
+		return TitleDoubleQuotes | TitleSingleQuotes | TitleParens
+	}
+	return TitleDoubleQuotes | TitleSingleQuotes
+}
+
+// titleCloses returns true if c ends a title that starts with delimiter
+// open. Without StrictLinkTitles flag any of lax ends it.
+func (p *Parser) titleCloses(open, c byte, lax string) bool {
+	if p.Opts.Flags&StrictLinkTitles == 0 {
+		return strings.IndexByte(lax, c) >= 0
+	}
+	if open == '(' {
+		return c == ')'
+	}
+	return c == open
+}
+
 // The first bit of this logic is the same as Parser.listItem, but the rest
 // is much simpler. This function simply finds the entire block and shifts it
 // over by one tab if it is indeed a block (just returns the line if it's not).
@@ -620,6 +830,8 @@ func scanFootnote(p *Parser, data []byte, i, indentSize int) (blockStart, blockE
 
 	// process the following lines
 	containsBlankLine := false
+	// the last line is text of a paragraph, which can be continued lazily
+	inParagraph := true
 
 gatherLines:
 	for blockEnd < len(data) {
@@ -634,12 +846,21 @@ gatherLines:
 		// and move on to the next line
 		if p.isEmpty(data[blockEnd:i]) > 0 {
 			containsBlankLine = true
+			inParagraph = false
 			blockEnd = i
 			continue
 		}
 
 		n := 0
 		if n = isIndented(data[blockEnd:i], indentSize); n == 0 {
+			// a non-indented line directly following a line of text is
+			// a lazy continuation of the paragraph, unless it starts
+			// another footnote or block
+			if inParagraph && !p.startsBlock(data[blockEnd:i]) {
+				raw.Write(data[blockEnd:i])
+				blockEnd = i
+				continue
+			}
 			// this is the end of the block.
 			// we don't want to include this last line in the index.
 			break gatherLines
@@ -652,8 +873,16 @@ gatherLines:
 		}
 
 		// get rid of that first tab, write to buffer
-		raw.Write(data[blockEnd+n : i])
+		line := data[blockEnd+n : i]
+		raw.Write(line)
 		hasBlock = true
+		if !inParagraph {
+			// an indented code block can't interrupt a paragraph
+			inParagraph = p.codePrefix(line) == 0
+		}
+		if p.isCodeFence(line) || p.isPrefixHeading(line) || p.isHRule(line) {
+			inParagraph = false
+		}
 
 		blockEnd = i
 	}
@@ -667,6 +896,37 @@ gatherLines:
 	return
 }
 
+// startsBlock returns true if line can't be a lazy continuation line of
+// a paragraph because it starts another footnote or a different block.
+func (p *Parser) startsBlock(line []byte) bool {
+	return isFootnoteDefinition(line) || p.isCodeFence(line) ||
+		p.isPrefixHeading(line) || p.isHRule(line) ||
+		p.quotePrefix(line) > 0 || p.uliPrefix(line) > 0 || p.oliPrefix(line) > 0
+}
+
+// isCodeFence returns true if line is a fence of a fenced code block
+func (p *Parser) isCodeFence(line []byte) bool {
+	if p.extensions&FencedCode == 0 {
+		return false
+	}
+	end, _ := isFenceLine(line, nil, "")
+	return end > 0
+}
+
+// isFootnoteDefinition returns true if line starts a footnote definition,
+// e.g. [^note]: text
+func isFootnoteDefinition(line []byte) bool {
+	i := 0
+	for i < 3 && i < len(line) && line[i] == ' ' {
+		i++
+	}
+	if !bytes.HasPrefix(line[i:], []byte("[^")) {
+		return false
+	}
+	end := bytes.IndexByte(line[i:], ']')
+	return end > 0 && i+end+1 < len(line) && line[i+end+1] == ':'
+}
+
 // isPunctuation returns true if c is a punctuation symbol.
 func isPunctuation(c byte) bool {
 	for _, r := range []byte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~") {
@@ -693,9 +953,7 @@ func isAlnum(c byte) bool {
 	return (c >= '0' && c <= '9') || isLetter(c)
 }
 
-// TODO: this is not used
 // Replace tab characters with spaces, aligning to the next TAB_SIZE column.
-// always ends output with a newline
 func expandTabs(out *bytes.Buffer, line []byte, tabSize int) {
 	// first, check for common cases: no tabs, or only tabs at beginning of line
 	i, prefix := 0, 0
@@ -772,38 +1030,9 @@ func isIndented(data []byte, indentSize int) int {
 	return indentSize
 }
 
-// Create a url-safe slug for fragments
+// Create a url-safe slug for fragments, keeping Unicode letters and digits
 func slugify(in []byte) []byte {
-	if len(in) == 0 {
-		return in
-	}
-	out := make([]byte, 0, len(in))
-	sym := false
-
-	for _, ch := range in {
-		if isAlnum(ch) {
-			sym = false
-			out = append(out, ch)
-		} else if sym {
-			continue
-		} else {
-			out = append(out, '-')
-			sym = true
-		}
-	}
-	var a, b int
-	var ch byte
-	for a, ch = range out {
-		if ch != '-' {
-			break
-		}
-	}
-	for b = len(out) - 1; b > 0; b-- {
-		if out[b] != '-' {
-			break
-		}
-	}
-	return out[a : b+1]
+	return []byte(slug(string(in), SlugOptions{KeepCase: true}))
 }
 
 func isListItem(d ast.Node) bool {
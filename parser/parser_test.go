@@ -1,7 +1,12 @@
 package parser
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
 )
 
 func TestIsFenceLine(t *testing.T) {
@@ -45,6 +50,32 @@ func TestIsFenceLine(t *testing.T) {
 			wantMarker:      "```",
 			wantSyntax:      "go",
 		},
+		{
+			data:            []byte("``` go {hl_lines=[2]}\n"),
+			syntaxRequested: true,
+			wantEnd:         22,
+			wantMarker:      "```",
+			wantSyntax:      "go {hl_lines=[2]}",
+		},
+		{
+			data:            []byte("``` python title=\"example.py\" linenos  \n"),
+			syntaxRequested: true,
+			wantEnd:         40,
+			wantMarker:      "```",
+			wantSyntax:      "python title=\"example.py\" linenos",
+		},
+		{
+			data:            []byte("``` go `foo`\n"),
+			syntaxRequested: true,
+			wantEnd:         0,
+		},
+		{
+			data:            []byte("``` {linenos=true}\n"),
+			syntaxRequested: true,
+			wantEnd:         19,
+			wantMarker:      "```",
+			wantSyntax:      "{linenos=true}",
+		},
 	}
 
 	for _, test := range tests {
@@ -67,6 +98,44 @@ func TestIsFenceLine(t *testing.T) {
 	}
 }
 
+func TestParseFenceInfo(t *testing.T) {
+	tests := []struct {
+		info     string
+		wantLang string
+		want     map[string]string
+	}{
+		{"go", "go", nil},
+		{"go {}", "go", nil},
+		{"go {hl_lines=[2,5-7]}", "go", map[string]string{"hl_lines": "[2,5-7]"}},
+		{`python title="example.py" linenos`, "python", map[string]string{
+			"title":   "example.py",
+			"linenos": "",
+		}},
+		{`{linenos=true, hl_lines="1 3" title='a b'}`, "", map[string]string{
+			"linenos":  "true",
+			"hl_lines": "1 3",
+			"title":    "a b",
+		}},
+		{"go {linenos hl_lines=[1", "go", map[string]string{"linenos": "", "hl_lines": "[1"}},
+		{"{linenos} go", "", map[string]string{"linenos": "", "go": ""}},
+	}
+	for _, test := range tests {
+		lang, got := parseFenceInfo([]byte(test.info))
+		if string(lang) != test.wantLang {
+			t.Errorf("parseFenceInfo(%q): got language %q, want %q", test.info, lang, test.wantLang)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("parseFenceInfo(%q): got %q, want %q", test.info, got, test.want)
+			continue
+		}
+		for k, v := range test.want {
+			if value, ok := got[k]; !ok || string(value) != v {
+				t.Errorf("parseFenceInfo(%q)[%q]: got %q, want %q", test.info, k, value, v)
+			}
+		}
+	}
+}
+
 func TestSanitizedAnchorName(t *testing.T) {
 	tests := []struct {
 		text string
@@ -100,6 +169,14 @@ func TestSanitizedAnchorName(t *testing.T) {
 			text: "Hello, 世界",
 			want: "hello-世界",
 		},
+		{
+			text: "Cafe\u0301 Ärger",
+			want: "cafe-ärger",
+		},
+		{
+			text: "Привет, мир!",
+			want: "привет-мир",
+		},
 	}
 	for _, test := range tests {
 		if got := sanitizeAnchorName(test.text); got != test.want {
@@ -107,3 +184,367 @@ func TestSanitizedAnchorName(t *testing.T) {
 		}
 	}
 }
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"note", "note"},
+		{"  a long, *note*!", "a-long-note"},
+		{"注釈 1", "注釈-1"},
+		{"--", ""},
+	}
+	for _, test := range tests {
+		if got := string(slugify([]byte(test.text))); got != test.want {
+			t.Errorf("slugify(%q): got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSlugifyFunc(t *testing.T) {
+	p := NewWithExtensions(CommonExtensions | AutoHeadingIDs)
+	p.Opts.SlugifyFunc = func(text string) string {
+		return "sec-" + strings.ToUpper(text)
+	}
+	doc := p.Parse([]byte("# Intro\n"))
+	if id := doc.GetChildren()[0].(*ast.Heading).HeadingID; id != "sec-INTRO" {
+		t.Errorf("want sec-INTRO, got %q", id)
+	}
+}
+
+func TestNewSlugify(t *testing.T) {
+	var tests = []struct {
+		opts       SlugOptions
+		text, want string
+	}{
+		{SlugOptions{}, "Hello, World!", "hello-world"},
+		{SlugOptions{KeepCase: true}, "Hello, World!", "Hello-World"},
+		{SlugOptions{}, "Ärger Straße", "ärger-straße"},
+		{SlugOptions{Transliterate: true}, "Ärger Straße", "arger-strasse"},
+		{SlugOptions{Transliterate: true, KeepCase: true}, "Ærø Þing", "AEro-THing"},
+		{SlugOptions{Transliterate: true}, "日本語 テキスト", "日本語-テキスト"},
+	}
+	for _, test := range tests {
+		if got := NewSlugify(test.opts)(test.text); got != test.want {
+			t.Errorf("NewSlugify(%+v)(%q): got %q, want %q", test.opts, test.text, got, test.want)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	p := New()
+	p.AddReference("Go", &Reference{Link: "https://golang.org", Title: "Go"})
+	p.AddReference("unused", &Reference{Link: "https://example.com"})
+	doc := p.Parse([]byte("[foo][bar] and [Go][] and [Bar]\n\n[bar]: /url\n"))
+
+	link := doc.GetChildren()[0].GetChildren()[3].(*ast.Link)
+	if got := string(link.Destination); got != "https://golang.org" {
+		t.Errorf("want link to https://golang.org, got %s", got)
+	}
+
+	refs := p.References()
+	if len(refs) != 3 {
+		t.Errorf("want 3 references, got %d", len(refs))
+	}
+	if ref := refs["bar"]; ref == nil || ref.Link != "/url" {
+		t.Errorf("want reference bar with link /url, got %v", ref)
+	}
+
+	used := p.UsedReferences()
+	if len(used) != 2 || used[0] != "bar" || used[1] != "go" {
+		t.Errorf("want used references [bar go], got %v", used)
+	}
+}
+
+// kbd is a custom inline node
+type kbd struct {
+	ast.Leaf
+}
+
+func TestRegisterInline(t *testing.T) {
+	p := NewWithExtensions(CommonExtensions)
+	prev := p.RegisterInline('+', func(p *Parser, data []byte, offset int) (int, ast.Node) {
+		data = data[offset:]
+		if len(data) < 5 || data[1] != '+' {
+			return 0, nil
+		}
+		end := 2
+		for end+1 < len(data) && !(data[end] == '+' && data[end+1] == '+') {
+			end++
+		}
+		if end+1 >= len(data) {
+			return 0, nil
+		}
+		node := &kbd{}
+		node.Literal = data[2:end]
+		return end + 2, node
+	})
+	if prev != nil {
+		t.Errorf("expected no previous parser of '+'")
+	}
+	// unregister and restore the built-in emphasis
+	emphasis := p.RegisterInline('*', nil)
+	if emphasis == nil {
+		t.Errorf("expected previous parser of '*'")
+	}
+	p.RegisterInline('*', emphasis)
+
+	var lit string
+	doc := p.Parse([]byte("Press ++Ctrl++ and *go* a+b"))
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if k, ok := node.(*kbd); ok {
+			lit = string(k.Literal)
+		}
+		return ast.GoToNext
+	})
+	if lit != "Ctrl" {
+		t.Errorf("want kbd 'Ctrl', got %q", lit)
+	}
+	got := ast.ToString(doc)
+	if want := "Emph"; !strings.Contains(got, want) {
+		t.Errorf("restored emphasis parser not used:\n%s", got)
+	}
+}
+
+// directive is a custom block node
+type directive struct {
+	ast.Container
+	Name string
+}
+
+func TestRegisterBlock(t *testing.T) {
+	// parses "@name\n" followed by block content up to "@end\n"
+	directiveParser := func(name string) BlockFunc {
+		return func(data []byte) (ast.Node, []byte, int) {
+			start := "@" + name + "\n"
+			if !bytes.HasPrefix(data, []byte(start)) {
+				return nil, nil, 0
+			}
+			end := bytes.Index(data, []byte("\n@end\n"))
+			if end < 0 {
+				return nil, nil, 0
+			}
+			return &directive{Name: name}, data[len(start) : end+1], end + len("\n@end\n")
+		}
+	}
+	var order []string
+	p := NewWithExtensions(CommonExtensions)
+	p.RegisterBlock(0, func(data []byte) (ast.Node, []byte, int) {
+		order = append(order, "low")
+		return nil, nil, 0
+	})
+	p.RegisterBlock(10, func(data []byte) (ast.Node, []byte, int) {
+		order = append(order, "high")
+		return nil, nil, 0
+	})
+	p.RegisterBlock(5, directiveParser("note"))
+
+	doc := p.Parse([]byte("@note\n# Title\n\nSome *text*\n@end\n"))
+	// the directive matches at the top level, blocks inside it are tried by
+	// all parsers
+	if len(order) < 2 || order[0] != "high" || order[1] != "high" {
+		t.Errorf("want the high priority parser first, got %v", order)
+	}
+	got := ast.ToString(doc)
+	want := "directive\n  Heading\n    Text 'Title'\n  Paragraph\n    Text 'Some'\n    Emph\n      Text 'text'\n"
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestTabSize(t *testing.T) {
+	tests := []struct {
+		tabSize int
+		flags   Flags
+		input   string
+		want    string
+	}{
+		{0, 0, "\tfoo\tbar\n", "foo\tbar\n"},
+		{0, 0, "  \tfoo\n", "foo\n"},
+		{0, ExpandCodeTabs, "\tfoo\tbar\n", "foo bar\n"},
+		{0, ExpandCodeTabs, "    a\tb\n\t\tc\n", "a   b\n    c\n"},
+		{8, ExpandCodeTabs, "\tfoo\tbar\n", "    foo     bar\n"},
+		{2, 0, "\t\tfoo\n", "foo\n"},
+		{2, ExpandCodeTabs, "```\n\tfoo\n```\n", "  foo\n"},
+		{0, 0, "```\n\tfoo\n```\n", "\tfoo\n"},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions)
+		p.Opts.TabSize = test.tabSize
+		p.Opts.Flags = test.flags
+		doc := p.Parse([]byte(test.input))
+		code, ok := doc.GetChildren()[0].(*ast.CodeBlock)
+		if !ok {
+			t.Errorf("%q: want a code block, got:\n%s", test.input, ast.ToString(doc))
+			continue
+		}
+		if got := string(code.Literal); got != test.want {
+			t.Errorf("%q with tab size %d: want %q, got %q", test.input, test.tabSize, test.want, got)
+		}
+	}
+	// with tab size 2 a tab doesn't indent a code block
+	p := NewWithExtensions(CommonExtensions)
+	p.Opts.TabSize = 2
+	doc := p.Parse([]byte("\tfoo\n"))
+	if _, ok := doc.GetChildren()[0].(*ast.Paragraph); !ok {
+		t.Errorf("want a paragraph, got:\n%s", ast.ToString(doc))
+	}
+}
+
+func TestParseAliasesInput(t *testing.T) {
+	for _, copyInput := range []bool{false, true} {
+		input := []byte("# Title\n\nSome [link](/url) text\n\n```go\ncode\n```\n")
+		p := NewWithExtensions(CommonExtensions)
+		p.Opts.CopyInput = copyInput
+		doc := p.Parse(input)
+		var literals [][]byte
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			switch n := node.(type) {
+			case *ast.Text:
+				literals = append(literals, n.Literal)
+			case *ast.Link:
+				literals = append(literals, n.Destination)
+			case *ast.CodeBlock:
+				literals = append(literals, n.Literal)
+			}
+			return ast.GoToNext
+		})
+		// the literals change with the input unless it's copied
+		for i := range input {
+			input[i] = '!'
+		}
+		for _, b := range literals {
+			aliased := len(b) > 0 && strings.Trim(string(b), "!") == ""
+			if len(b) > 0 && aliased == copyInput {
+				t.Errorf("CopyInput %v: literal %q", copyInput, b)
+			}
+		}
+	}
+}
+
+func TestLinkTitles(t *testing.T) {
+	tests := []struct {
+		titles TitleDelimiters
+		flags  Flags
+		input  string
+		dest   string
+		title  string
+		delim  byte
+	}{
+		{0, 0, `[a](/url "title")`, "/url", "title", '"'},
+		{0, 0, `[a](/url 'title')`, "/url", "title", '\''},
+		{0, 0, `[a](/url "title')`, "/url", "title", '"'},
+		{0, 0, `[a](/url)`, "/url", "", 0},
+		{0, StrictLinkTitles, `[a](/url "title')`, `/url "title'`, "", 0},
+		{0, StrictLinkTitles, `[a](/url "it's")`, "/url", "it's", '"'},
+		{TitleParens, 0, `[a](/url (title))`, "/url", "title", '('},
+		{TitleParens, 0, `[a](/url(x))`, "/url(x", "", 0},
+		{TitleDoubleQuotes, 0, `[a](/url'x' "title")`, "/url'x'", "title", '"'},
+		{0, 0, "[a]\n\n[a]: /url (title)", "/url", "title", '('},
+		{0, 0, "[a]\n\n[a]: /url\n  'title'", "/url", "title", '\''},
+		{0, StrictLinkTitles, "[a]\n\n[a]: /url (title)", "/url", "title", '('},
+		{0, StrictLinkTitles, "[a]\n\n[a]: /url (title\"", "", "", 0},
+		{TitleDoubleQuotes, 0, "[a]\n\n[a]: /url (title)", "", "", 0},
+	}
+	for _, test := range tests {
+		p := NewWithExtensions(CommonExtensions)
+		p.Opts.LinkTitles = test.titles
+		p.Opts.Flags = test.flags
+		doc := p.Parse([]byte(test.input))
+		links := ast.Links(doc)
+		if test.dest == "" {
+			if len(links) != 0 {
+				t.Errorf("%q: want no link, got %q", test.input, links[0].Destination)
+			}
+			continue
+		}
+		if len(links) != 1 {
+			t.Errorf("%q: want a link, got:\n%s", test.input, ast.ToString(doc))
+			continue
+		}
+		l := links[0]
+		if string(l.Destination) != test.dest || string(l.Title) != test.title || l.TitleDelimiter != test.delim {
+			t.Errorf("%q: want %q %q %q, got %q %q %q", test.input, test.dest, test.title, test.delim,
+				l.Destination, l.Title, l.TitleDelimiter)
+		}
+	}
+}
+
+func TestDecodeEntities(t *testing.T) {
+	tests := []struct {
+		flags Flags
+		input string
+		want  string
+	}{
+		{0, "a &copy; b", "a &copy; b"},
+		{DecodeEntities, "a &copy; b", "a © b"},
+		{DecodeEntities, "&#x1F600; &#65; &amp;", "😀 A &"},
+		{DecodeEntities, "&bogus; \\&copy;", "&bogus; &copy;"},
+	}
+	for _, test := range tests {
+		p := New()
+		p.Opts.Flags = test.flags
+		doc := p.Parse([]byte(test.input))
+		var got []byte
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			if text, ok := node.(*ast.Text); ok && entering {
+				got = append(got, text.Literal...)
+			}
+			return ast.GoToNext
+		})
+		if string(got) != test.want {
+			t.Errorf("%q: want %q, got %q", test.input, test.want, got)
+		}
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	exts, err := ParseExtensions("CommonExtensions, footnotes,,AutoHeadingIDs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := CommonExtensions | Footnotes | AutoHeadingIDs; exts != want {
+		t.Errorf("want %d, got %d", want, exts)
+	}
+	if _, err := ParseExtensions("Tables,Nope"); err == nil {
+		t.Errorf("expected error for unknown extension")
+	}
+}
+
+func TestArena(t *testing.T) {
+	docs := []string{
+		"# Title\n\nSome *emphasis*, **strong**, ***both***, `code` and [a link](/url \"title\").\n\n- item\n\n  para\n",
+		"Auto <http://example.com> link[^1] and ^[inline] note.\n\n[^1]: The note.\n",
+	}
+	arena := &ast.Arena{}
+	for i := 0; i < 2; i++ {
+		for _, doc := range docs {
+			want := ast.ToString(NewWithExtensions(CommonExtensions | Footnotes).Parse([]byte(doc)))
+			p := NewWithExtensions(CommonExtensions | Footnotes)
+			p.Opts.Arena = arena
+			if got := ast.ToString(p.Parse([]byte(doc))); got != want {
+				t.Errorf("%q: want:\n%s\ngot:\n%s", doc, want, got)
+			}
+			arena.Reset()
+		}
+	}
+}
+
+// TestManyLists guards against finalizing a list scanning the blocks of the
+// whole document, which made parsing documents with many lists cubic. It
+// takes milliseconds; the limit only catches that.
+func TestManyLists(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing in short mode")
+	}
+	data := []byte(strings.Repeat("- item\n\npara\n\n", 2000))
+	start := time.Now()
+	doc := New().Parse(data)
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("parsing took %s", d)
+	}
+	if n := len(doc.GetChildren()); n != 4000 {
+		t.Errorf("want 4000 blocks, got %d", n)
+	}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// ParseError is the error of Parse that panicked with
+// Options.RecoverFromPanics set. A panic is a bug of the parser, please
+// report it with the input.
+type ParseError struct {
+	// Offset is the offset in the input of the top-level block that was
+	// being parsed, or -1 if it's not known, e.g. while parsing footnotes
+	Offset int
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the panic
+	Stack []byte
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("markdown: parser panic in block at offset %d: %v", e.Offset, e.Value)
+}
+
+// Unwrap returns Value if it's an error, e.g. a runtime.Error
+func (e *ParseError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Err returns the *ParseError of the last Parse, or nil if it didn't panic.
+// It's always nil without Options.RecoverFromPanics.
+func (p *Parser) Err() error {
+	return p.err
+}
+
+// startTracking starts recording offsets of top-level blocks of input
+func (p *Parser) startTracking(input []byte) {
+	p.err = nil
+	p.input = input
+	p.offset = 0
+	p.offsets = map[ast.Node]int{}
+	p.tracked = len(p.Doc.GetChildren())
+}
+
+// trackOffset records the current offset for top-level blocks added since
+// the last call and sets it to offset of the next block
+func (p *Parser) trackOffset(offset int) {
+	children := p.Doc.GetChildren()
+	if p.tracked < len(children) {
+		for _, child := range children[p.tracked:] {
+			p.offsets[child] = p.offset
+		}
+	}
+	p.tracked = len(children)
+	p.offset = offset
+}
+
+// setInlineOffset sets the current offset to that of top-level block node
+// while parsing its inlines
+func (p *Parser) setInlineOffset(node ast.Node) {
+	offset, ok := p.offsets[node]
+	if !ok {
+		offset = -1
+	}
+	p.offset = offset
+}
+
+// parseRecover parses input, turning a panic into p.err
+func (p *Parser) parseRecover(input []byte) {
+	defer func() {
+		if v := recover(); v != nil {
+			p.err = &ParseError{Offset: p.offset, Value: v, Stack: debug.Stack()}
+		}
+		p.input = nil
+		p.offsets = nil
+	}()
+	p.startTracking(input)
+	p.parse(input)
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestRecoverFromPanics(t *testing.T) {
+	boom := errors.New("boom")
+	newParser := func() *Parser {
+		p := NewWithExtensions(CommonExtensions)
+		p.Opts.RecoverFromPanics = true
+		p.Opts.ParserHook = func(data []byte) (ast.Node, []byte, int) {
+			if bytes.HasPrefix(data, []byte("@block")) {
+				panic(boom)
+			}
+			return nil, nil, 0
+		}
+		p.RegisterInline('@', func(p *Parser, data []byte, offset int) (int, ast.Node) {
+			panic("inline")
+		})
+		return p
+	}
+
+	tests := []struct {
+		input  string
+		offset int
+		value  interface{}
+	}{
+		{"# Title\n\npara\n\n@block\n", 15, boom},
+		{"# Title\n\n> quote\n>\n> @block\n", 9, boom},
+		{"# Title\n\n* item\n\nsome @inline\n", 17, "inline"},
+		{"# Title\n\n@@@\n", 9, "inline"},
+	}
+	for _, test := range tests {
+		p := newParser()
+		doc := p.Parse([]byte(test.input))
+		var perr *ParseError
+		if !errors.As(p.Err(), &perr) {
+			t.Errorf("%q: want *ParseError, got %v", test.input, p.Err())
+			continue
+		}
+		if perr.Offset != test.offset || perr.Value != test.value {
+			t.Errorf("%q: want panic %v at %d, got %v at %d", test.input, test.value, test.offset, perr.Value, perr.Offset)
+		}
+		if len(perr.Stack) == 0 {
+			t.Errorf("%q: no stack", test.input)
+		}
+		if _, ok := doc.GetChildren()[0].(*ast.Heading); !ok {
+			t.Errorf("%q: want the heading parsed before the panic", test.input)
+		}
+	}
+
+	p := newParser()
+	p.Parse([]byte("no panic\n"))
+	if err := p.Err(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+
+	perr := &ParseError{Offset: 3, Value: boom}
+	if !errors.Is(perr, boom) {
+		t.Errorf("want ParseError to unwrap to its value")
+	}
+}
@@ -87,3 +87,40 @@ func maybeShortRefOrIndex(p *Parser, data []byte, offset int) (int, ast.Node) {
 
 	return 0, nil
 }
+
+// parse '(((term)))' or '(((term, subterm)))', an index term. '(((!term)))'
+// marks the primary reference of the term. Other '(' are mmark short refs
+// and indexes, if enabled.
+func maybeIndexTerm(p *Parser, data []byte, offset int) (int, ast.Node) {
+	data = data[offset:]
+	if !bytes.HasPrefix(data, []byte("(((")) {
+		if p.extensions&Mmark != 0 {
+			return maybeShortRefOrIndex(p, data, 0)
+		}
+		return 0, nil
+	}
+	end := bytes.Index(data, []byte(")))"))
+	if end < 0 || bytes.IndexByte(data[:end], '\n') >= 0 {
+		return 0, nil
+	}
+	buf := data[3:end]
+	idx := &ast.Index{}
+	if len(buf) > 0 && buf[0] == '!' {
+		idx.Primary = true
+		buf = buf[1:]
+	}
+	items := bytes.Split(buf, []byte(","))
+	if len(items) > 2 {
+		return 0, nil
+	}
+	idx.Item = bytes.TrimSpace(items[0])
+	if len(items) == 2 {
+		idx.Subitem = bytes.TrimSpace(items[1])
+	}
+	if len(idx.Item) == 0 {
+		return 0, nil
+	}
+	idx.ID = fmt.Sprintf("idxref:%d", p.indexCnt)
+	p.indexCnt++
+	return end + 3, idx
+}
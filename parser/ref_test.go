@@ -91,3 +91,59 @@ func TestIndex(t *testing.T) {
 		}
 	}
 }
+
+func TestIndexTerm(t *testing.T) {
+	p := NewWithExtensions(IndexTerms)
+
+	tests := []struct {
+		data []byte
+		i    *ast.Index
+		fail bool
+	}{
+		// ok
+		{
+			data: []byte("(((yes)))"),
+			i:    &ast.Index{Item: []byte("yes")},
+		},
+		{
+			data: []byte("((( yes , no )))"),
+			i:    &ast.Index{Item: []byte("yes"), Subitem: []byte("no")},
+		},
+		{
+			data: []byte("(((!yes)))"),
+			i:    &ast.Index{Item: []byte("yes"), Primary: true},
+		},
+		// fails
+		{data: []byte("(((yes))"), fail: true},
+		{data: []byte("(((yes\nno)))"), fail: true},
+		{data: []byte("((()))"), fail: true},
+		{data: []byte("(((a, b, c)))"), fail: true},
+		// mmark indexes need Mmark
+		{data: []byte("(!yes)"), fail: true},
+	}
+
+	for i, test := range tests {
+		_, n := maybeIndexTerm(p, test.data, 0)
+		if test.fail {
+			if n != nil {
+				t.Errorf("test %d, should have failed to parse %s", i, test.data)
+			}
+			continue
+		}
+
+		idx, ok := n.(*ast.Index)
+		if !ok {
+			t.Errorf("test %d, failed to parse %s", i, test.data)
+			continue
+		}
+		if string(test.i.Item) != string(idx.Item) {
+			t.Errorf("test %d, got item %s, wanted %s", i, idx.Item, test.i.Item)
+		}
+		if string(test.i.Subitem) != string(idx.Subitem) {
+			t.Errorf("test %d, got subitem %s, wanted %s", i, idx.Subitem, test.i.Subitem)
+		}
+		if test.i.Primary != idx.Primary {
+			t.Errorf("test %d, got primary %t, wanted %t", i, idx.Primary, test.i.Primary)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// parse '{base|annotation}', a ruby annotation
+func ruby(p *Parser, data []byte, offset int) (int, ast.Node) {
+	data = data[offset:]
+	end := bytes.IndexByte(data, '}')
+	if end < 0 {
+		return 0, nil
+	}
+	sep := bytes.IndexByte(data[:end], '|')
+	if sep < 0 {
+		return 0, nil
+	}
+	node := newRuby(p, data[1:sep], data[sep+1:end])
+	if node == nil {
+		return 0, nil
+	}
+	return end + 1, node
+}
+
+// parse '[base]{annotation}', a ruby annotation. Other '[' are links.
+func maybeRuby(p *Parser, data []byte, offset int) (int, ast.Node) {
+	d := data[offset:]
+	mid := bytes.IndexAny(d[1:], "[]")
+	if mid < 0 || d[mid+1] != ']' || len(d) < mid+3 || d[mid+2] != '{' {
+		return link(p, data, offset)
+	}
+	mid++
+	end := bytes.IndexByte(d[mid:], '}')
+	if end < 0 {
+		return link(p, data, offset)
+	}
+	end += mid
+	node := newRuby(p, d[1:mid], d[mid+2:end])
+	if node == nil {
+		return link(p, data, offset)
+	}
+	return end + 1, node
+}
+
+// newRuby returns a ruby node of base annotated with annotation, or nil if
+// either is empty or spans lines
+func newRuby(p *Parser, base, annotation []byte) *ast.Ruby {
+	annotation = bytes.TrimSpace(annotation)
+	if len(bytes.TrimSpace(base)) == 0 || len(annotation) == 0 {
+		return nil
+	}
+	if bytes.IndexByte(base, '\n') >= 0 || bytes.IndexByte(annotation, '\n') >= 0 {
+		return nil
+	}
+	node := &ast.Ruby{Annotation: annotation}
+	p.Inline(node, base)
+	return node
+}
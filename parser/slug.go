@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SlugOptions configures slugs of NewSlugify.
+type SlugOptions struct {
+	KeepCase      bool // keep the case of letters instead of lowercasing them
+	Transliterate bool // replace Latin letters with diacritics with ASCII, e.g. "é" with "e" and "ß" with "ss"
+}
+
+// NewSlugify returns a SlugifyFunc, e.g. for Options.SlugifyFunc, which
+// keeps Unicode letters and digits, e.g. of CJK text, separated by dashes
+// and drops combining marks, like the default of AutoHeadingIDs does.
+func NewSlugify(opts SlugOptions) SlugifyFunc {
+	return func(text string) string {
+		return slug(text, opts)
+	}
+}
+
+// slug returns letters and digits of text separated by dashes
+func slug(text string, opts SlugOptions) string {
+	var out strings.Builder
+	dash := false
+	add := func(r rune) {
+		if dash && out.Len() > 0 {
+			out.WriteByte('-')
+		}
+		dash = false
+		if !opts.KeepCase {
+			r = unicode.ToLower(r)
+		}
+		out.WriteRune(r)
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// drop combining marks, e.g. accents of decomposed letters
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if ascii, ok := latinASCII[r]; ok && opts.Transliterate {
+				for _, r := range ascii {
+					add(r)
+				}
+				continue
+			}
+			add(r)
+		default:
+			dash = true
+		}
+	}
+	return out.String()
+}
+
+// latinASCII maps Latin letters with diacritics and ligatures to ASCII
+var latinASCII = map[rune]string{}
+
+func init() {
+	for _, m := range []struct{ letters, ascii string }{
+		{"ÀÁÂÃÄÅĀĂĄ", "A"}, {"àáâãäåāăą", "a"}, {"ÇĆĈĊČ", "C"}, {"çćĉċč", "c"},
+		{"ÐĎĐ", "D"}, {"ðďđ", "d"}, {"ÈÉÊËĒĔĖĘĚ", "E"}, {"èéêëēĕėęě", "e"},
+		{"ĜĞĠĢ", "G"}, {"ĝğġģ", "g"}, {"ĤĦ", "H"}, {"ĥħ", "h"},
+		{"ÌÍÎÏĨĪĬĮİ", "I"}, {"ìíîïĩīĭįı", "i"}, {"Ĵ", "J"}, {"ĵ", "j"},
+		{"Ķ", "K"}, {"ķ", "k"}, {"ĹĻĽĿŁ", "L"}, {"ĺļľŀł", "l"},
+		{"ÑŃŅŇ", "N"}, {"ñńņň", "n"}, {"ÒÓÔÕÖØŌŎŐ", "O"}, {"òóôõöøōŏő", "o"},
+		{"ŔŖŘ", "R"}, {"ŕŗř", "r"}, {"ŚŜŞŠ", "S"}, {"śŝşš", "s"},
+		{"ŢŤŦ", "T"}, {"ţťŧ", "t"}, {"ÙÚÛÜŨŪŬŮŰŲ", "U"}, {"ùúûüũūŭůűų", "u"},
+		{"Ŵ", "W"}, {"ŵ", "w"}, {"ÝŶŸ", "Y"}, {"ýÿŷ", "y"},
+		{"ŹŻŽ", "Z"}, {"źżž", "z"},
+		{"Æ", "AE"}, {"æ", "ae"}, {"Œ", "OE"}, {"œ", "oe"},
+		{"Þ", "TH"}, {"þ", "th"}, {"ß", "ss"},
+	} {
+		for _, r := range m.letters {
+			latinASCII[r] = m.ascii
+		}
+	}
+}
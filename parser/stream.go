@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// BlockIterator parses a document read from an io.Reader and returns its
+// top-level blocks one by one, see Blocks.
+type BlockIterator struct {
+	newParser func() *Parser
+	r         *bufio.Reader
+	split     chunkSplitter
+
+	started bool
+	chunk   []byte     // source of the chunk being read
+	next    []byte     // line read ahead, nil at the end of the input
+	nodes   []ast.Node // parsed blocks not returned yet
+	node    ast.Node
+	err     error
+}
+
+// Blocks returns an iterator of the top-level blocks of the document read
+// from r, for converting documents too large to be read into memory. The
+// source is split into chunks of top-level blocks like Incremental does,
+// and a chunk is parsed with a parser returned by newParser as soon as it
+// has been read, so memory use is bounded by the size of the largest chunk
+// rather than of the document, e.g.
+//
+//	it := parser.Blocks(r, func() *parser.Parser {
+//		return parser.NewWithExtensions(parser.CommonExtensions)
+//	})
+//	for it.Next() {
+//		w.Write(markdown.Render(it.Node(), renderer))
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// Unlike with Incremental, link references and footnotes are resolved only
+// within their chunk.
+func Blocks(r io.Reader, newParser func() *Parser) *BlockIterator {
+	return &BlockIterator{
+		newParser: newParser,
+		r:         bufio.NewReader(r),
+	}
+}
+
+// Next advances to the next block, which is then returned by Node. It
+// returns false at the end of the input or after a read error.
+func (it *BlockIterator) Next() bool {
+	for len(it.nodes) == 0 {
+		if !it.readChunk() {
+			it.node = nil
+			return false
+		}
+	}
+	it.node, it.nodes = it.nodes[0], it.nodes[1:]
+	return true
+}
+
+// Node returns the current block. Its parent is a document of the chunk it
+// was parsed from.
+func (it *BlockIterator) Node() ast.Node {
+	return it.node
+}
+
+// Err returns the first error reading the input, other than io.EOF.
+func (it *BlockIterator) Err() error {
+	return it.err
+}
+
+// readChunk reads and parses the next chunk. It returns false at the end
+// of the input.
+func (it *BlockIterator) readChunk() bool {
+	if !it.started {
+		it.started = true
+		it.next = it.readLine()
+	}
+	for it.next != nil {
+		line := it.next
+		it.next = it.readLine()
+		if it.split.isStart(line, it.next, len(it.chunk) > 0) {
+			chunk := it.chunk
+			it.chunk = append([]byte(nil), line...)
+			it.parse(chunk, true)
+			return true
+		}
+		it.chunk = append(it.chunk, line...)
+	}
+	if len(it.chunk) == 0 {
+		return false
+	}
+	chunk := it.chunk
+	it.chunk = nil
+	it.parse(chunk, false)
+	return true
+}
+
+// readLine returns the next line of the input, including the newline, or
+// nil at the end of the input or on error
+func (it *BlockIterator) readLine() []byte {
+	if it.err != nil {
+		return nil
+	}
+	line, err := it.r.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		it.err = err
+	}
+	if len(line) == 0 {
+		return nil
+	}
+	return line
+}
+
+// parse parses chunk, more is true if other chunks follow it
+func (it *BlockIterator) parse(chunk []byte, more bool) {
+	doc := it.newParser().Parse(chunk)
+	it.nodes = doc.GetChildren()
+	if len(it.nodes) > 0 {
+		setListEnd(it.nodes[len(it.nodes)-1], more)
+	}
+}
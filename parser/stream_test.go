@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestBlocks(t *testing.T) {
+	src := "# Title\n\nSome *text*\nover lines.\n\n- a\n- b\n\n```\ncode\n\nmore\n```\n\nTerm\n: def\n\n> quote\n\nEnd"
+	want := newIncrementalTestParser().Parse([]byte(src)).GetChildren()
+
+	readers := map[string]io.Reader{
+		"string":   strings.NewReader(src),
+		"one byte": iotest.OneByteReader(strings.NewReader(src)),
+	}
+	for name, r := range readers {
+		var got []ast.Node
+		it := Blocks(r, newIncrementalTestParser)
+		for it.Next() {
+			got = append(got, it.Node())
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: want %d blocks, got %d", name, len(want), len(got))
+			continue
+		}
+		for i := range got {
+			if g, w := ast.ToString(got[i]), ast.ToString(want[i]); g != w {
+				t.Errorf("%s: block %d:\nwant:\n%s\ngot:\n%s", name, i, w, g)
+			}
+		}
+		if got[0].GetParent() == got[len(got)-1].GetParent() {
+			t.Errorf("%s: the document was parsed as a single chunk", name)
+		}
+	}
+}
+
+type errReader struct {
+	data string
+	err  error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestBlocksError(t *testing.T) {
+	errRead := errors.New("read error")
+	it := Blocks(&errReader{"para\n\n# Head", errRead}, newIncrementalTestParser)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("want 2 blocks read before the error, got %d", n)
+	}
+	if it.Err() != errRead {
+		t.Errorf("want %v, got %v", errRead, it.Err())
+	}
+	if it.Node() != nil {
+		t.Errorf("want no node after the end")
+	}
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package markdown
+
+// raceEnabled is true if the race detector is on, which makes allocation
+// counts unreliable
+const raceEnabled = true
@@ -0,0 +1,106 @@
+// Package spec runs the examples of CommonMark and GFM specs against
+// a parser and a renderer and reports how many of them pass.
+//
+// The examples are read from JSON, in the format of spec.json published
+// with the CommonMark spec (e.g. https://spec.commonmark.org/0.31.2/spec.json),
+// which can also be generated from GFM spec with its spec_tests.py --dump-tests.
+package spec
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Example is an example from the spec
+type Example struct {
+	Example   int    `json:"example"` // number of the example
+	Section   string `json:"section"`
+	Markdown  string `json:"markdown"`
+	HTML      string `json:"html"` // expected HTML
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// Load reads examples from spec JSON
+func Load(r io.Reader) ([]Example, error) {
+	var examples []Example
+	err := json.NewDecoder(r).Decode(&examples)
+	return examples, err
+}
+
+// Result is the result of running an example
+type Result struct {
+	Example
+	Got    string // HTML rendered by the renderer
+	Passed bool
+}
+
+// Report is the result of running examples
+type Report struct {
+	Results []Result
+}
+
+// Passed returns the number of passed examples
+func (r *Report) Passed() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Passed {
+			n++
+		}
+	}
+	return n
+}
+
+// Percent returns the percentage of passed examples
+func (r *Report) Percent() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	return 100 * float64(r.Passed()) / float64(len(r.Results))
+}
+
+// Run converts markdown of examples to HTML with a parser created by
+// newParser (a parser can only be used once) and renderer r, and compares
+// it to the expected HTML. Both are normalized with NormalizeHTML first.
+//
+// A panic while converting an example fails the example.
+func Run(examples []Example, newParser func() *parser.Parser, r markdown.Renderer) *Report {
+	report := &Report{}
+	for _, ex := range examples {
+		got, ok := convert(ex.Markdown, newParser(), r)
+		report.Results = append(report.Results, Result{
+			Example: ex,
+			Got:     got,
+			Passed:  ok && NormalizeHTML(got) == NormalizeHTML(ex.HTML),
+		})
+	}
+	return report
+}
+
+func convert(md string, p *parser.Parser, r markdown.Renderer) (html string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return string(markdown.ToHTML([]byte(md), p, r)), true
+}
+
+var (
+	reSelfClosing = regexp.MustCompile(`\s*/>`)
+	reBetweenTags = regexp.MustCompile(`>\s+<`)
+)
+
+// NormalizeHTML removes differences in HTML that don't matter when
+// comparing output with the spec: "<br />" becomes "<br>", white-space
+// between tags and around the HTML is removed.
+func NormalizeHTML(s string) string {
+	s = reSelfClosing.ReplaceAllString(s, ">")
+	s = reBetweenTags.ReplaceAllString(s, "><")
+	return strings.TrimSpace(s)
+}
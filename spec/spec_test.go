@@ -0,0 +1,39 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+const examplesJSON = `[
+  {"markdown": "a  \nb\n", "html": "<p>a<br />\nb</p>\n", "example": 1, "start_line": 10, "end_line": 15, "section": "Hard line breaks"},
+  {"markdown": "~~a~~\n", "html": "<p><del>a</del></p>\n", "example": 2, "start_line": 20, "end_line": 25, "section": "Strikethrough"},
+  {"markdown": "> q\n", "html": "<blockquote>\n<p>q</p>\n</blockquote>\n", "example": 3, "start_line": 30, "end_line": 35, "section": "Block quotes"}
+]`
+
+func TestRun(t *testing.T) {
+	examples, err := Load(strings.NewReader(examplesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(examples) != 3 || examples[1].Section != "Strikethrough" || examples[2].StartLine != 30 {
+		t.Fatalf("unexpected examples %+v", examples)
+	}
+	newParser := func() *parser.Parser {
+		return parser.NewWithExtensions(parser.NoExtensions)
+	}
+	report := Run(examples, newParser, html.NewRenderer(html.RendererOptions{}))
+	var passed []bool
+	for _, res := range report.Results {
+		passed = append(passed, res.Passed)
+	}
+	if !passed[0] || passed[1] || !passed[2] {
+		t.Errorf("want examples 1 and 3 to pass, got %v", passed)
+	}
+	if report.Passed() != 2 || int(report.Percent()) != 66 {
+		t.Errorf("want 2 passed (66%%), got %d (%f%%)", report.Passed(), report.Percent())
+	}
+}
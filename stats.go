@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// Stats are statistics of converting a document. They allow monitoring
+// pathological documents, e.g. very deeply nested ones, and tuning limits.
+type Stats struct {
+	InputSize  int // size of markdown in bytes
+	OutputSize int // size of the rendered document in bytes
+
+	Nodes     map[string]int // number of nodes by type, e.g. "Paragraph"
+	NodeCount int            // total number of nodes
+	MaxDepth  int            // depth of the most nested node, 0 for the document
+
+	ParseDuration  time.Duration
+	RenderDuration time.Duration
+}
+
+// ToHTMLWithStats is like ToHTML but also returns statistics of the
+// conversion.
+func ToHTMLWithStats(markdown []byte, p *parser.Parser, renderer Renderer) ([]byte, *Stats) {
+	stats := &Stats{
+		InputSize: len(markdown),
+		Nodes:     map[string]int{},
+	}
+	start := time.Now()
+	doc := Parse(markdown, p)
+	stats.ParseDuration = time.Since(start)
+
+	if renderer == nil {
+		renderer = defaultRenderer()
+	}
+	start = time.Now()
+	res := Render(doc, renderer)
+	stats.RenderDuration = time.Since(start)
+	stats.OutputSize = len(res)
+
+	stats.countNodes(doc)
+	return res, stats
+}
+
+func (s *Stats) countNodes(doc ast.Node) {
+	ast.WalkWithStack(doc, func(node ast.Node, ancestors []ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		s.Nodes[nodeTypeName(node)]++
+		s.NodeCount++
+		if len(ancestors) > s.MaxDepth {
+			s.MaxDepth = len(ancestors)
+		}
+		return ast.GoToNext
+	})
+}
+
+// nodeTypeName returns the name of the type of node, without the package
+func nodeTypeName(node ast.Node) string {
+	if custom, ok := node.(ast.CustomData); ok {
+		return custom.NodeName()
+	}
+	t := reflect.TypeOf(node)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
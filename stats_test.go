@@ -0,0 +1,30 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestToHTMLWithStats(t *testing.T) {
+	input := "# Title\n\n> quote with *emph* and *more*\n\n- item\n"
+	res, stats := ToHTMLWithStats([]byte(input), nil, nil)
+	if string(res) != string(ToHTML([]byte(input), nil, nil)) {
+		t.Errorf("unexpected output %q", res)
+	}
+	if stats.InputSize != len(input) || stats.OutputSize != len(res) {
+		t.Errorf("want sizes %d/%d, got %d/%d", len(input), len(res), stats.InputSize, stats.OutputSize)
+	}
+	if stats.Nodes["Emph"] != 2 || stats.Nodes["Heading"] != 1 || stats.Nodes["Document"] != 1 {
+		t.Errorf("unexpected node counts %v", stats.Nodes)
+	}
+	total := 0
+	for _, n := range stats.Nodes {
+		total += n
+	}
+	if stats.NodeCount != total {
+		t.Errorf("want %d nodes, got %d", total, stats.NodeCount)
+	}
+	// Document > BlockQuote > Paragraph > Emph > Text
+	if stats.MaxDepth != 4 {
+		t.Errorf("want max depth 4, got %d", stats.MaxDepth)
+	}
+}
@@ -0,0 +1,121 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// SplitSummary splits doc at the first HTML comment equal to marker (ignoring
+// spaces), e.g. "<!--more-->", so that a summary (teaser) of a document can be
+// rendered on its own. See SplitSummaryFunc.
+func SplitSummary(doc ast.Node, marker string) (summary, rest ast.Node) {
+	want := removeSpaces([]byte(marker))
+	return SplitSummaryFunc(doc, func(node ast.Node) bool {
+		var literal []byte
+		switch node := node.(type) {
+		case *ast.HTMLBlock:
+			literal = node.Literal
+		case *ast.HTMLSpan:
+			literal = node.Literal
+		default:
+			return false
+		}
+		return bytes.Equal(removeSpaces(literal), want)
+	})
+}
+
+// SplitSummaryFunc splits doc at the first node for which isMarker returns
+// true. Only top-level blocks and inline nodes of top-level paragraphs are
+// checked; a paragraph containing the marker is split in two.
+//
+// The children of doc are moved to two new documents, summary with nodes
+// before the marker and rest with nodes after it. The marker is dropped.
+// If there's no marker, doc is returned unchanged as summary and rest is nil.
+func SplitSummaryFunc(doc ast.Node, isMarker func(node ast.Node) bool) (summary, rest ast.Node) {
+	children := doc.GetChildren()
+	split, inlineSplit := -1, -1
+	for i, child := range children {
+		if isMarker(child) {
+			split = i
+			break
+		}
+		if para, ok := child.(*ast.Paragraph); ok {
+			if j := indexOfMarker(para.Children, isMarker); j >= 0 {
+				split, inlineSplit = i, j
+				break
+			}
+		}
+	}
+	if split < 0 {
+		return doc, nil
+	}
+
+	children = append([]ast.Node(nil), children...)
+	summaryDoc, restDoc := &ast.Document{}, &ast.Document{}
+	for _, child := range children[:split] {
+		ast.AppendChild(summaryDoc, child)
+	}
+	if inlineSplit >= 0 {
+		para := children[split]
+		inlines := append([]ast.Node(nil), para.GetChildren()...)
+		before, after := inlines[:inlineSplit], inlines[inlineSplit+1:]
+		if !isBlankInline(before) {
+			trimTextRight(before[len(before)-1])
+			para.SetChildren(nil)
+			for _, n := range before {
+				ast.AppendChild(para, n)
+			}
+			ast.AppendChild(summaryDoc, para)
+		}
+		if !isBlankInline(after) {
+			trimTextLeft(after[0])
+			restPara := &ast.Paragraph{}
+			for _, n := range after {
+				ast.AppendChild(restPara, n)
+			}
+			ast.AppendChild(restDoc, restPara)
+		}
+	}
+	for _, child := range children[split+1:] {
+		ast.AppendChild(restDoc, child)
+	}
+	doc.SetChildren(nil)
+	return summaryDoc, restDoc
+}
+
+func indexOfMarker(nodes []ast.Node, isMarker func(node ast.Node) bool) int {
+	for i, n := range nodes {
+		if isMarker(n) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isBlankInline returns true if nodes are only whitespace text
+func isBlankInline(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		text, ok := n.(*ast.Text)
+		if !ok || len(bytes.TrimSpace(text.Literal)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func trimTextLeft(n ast.Node) {
+	if text, ok := n.(*ast.Text); ok {
+		text.Literal = bytes.TrimLeft(text.Literal, " \t\n")
+	}
+}
+
+func trimTextRight(n ast.Node) {
+	if text, ok := n.(*ast.Text); ok {
+		text.Literal = bytes.TrimRight(text.Literal, " \t\n")
+	}
+}
+
+func removeSpaces(d []byte) []byte {
+	return bytes.Join(bytes.Fields(d), nil)
+}
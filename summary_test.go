@@ -0,0 +1,51 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestSplitSummary(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantSummary string
+		wantRest    string
+	}{
+		{
+			"intro\n\n<!--more-->\n\nrest\n",
+			"<p>intro</p>\n",
+			"<p>rest</p>\n",
+		},
+		{
+			"intro <!-- more -->\nrest\n\nmore rest\n",
+			"<p>intro</p>\n",
+			"<p>rest</p>\n\n<p>more rest</p>\n",
+		},
+		{
+			"intro\n<!--more-->\n",
+			"<p>intro</p>\n",
+			"",
+		},
+	}
+	for _, test := range tests {
+		doc := Parse([]byte(test.input), parser.New())
+		summary, rest := SplitSummary(doc, "<!--more-->")
+		if rest == nil {
+			t.Errorf("%q: marker not found", test.input)
+			continue
+		}
+		if got := string(Render(summary, html.NewRenderer(html.RendererOptions{}))); got != test.wantSummary {
+			t.Errorf("%q: want summary %q, got %q", test.input, test.wantSummary, got)
+		}
+		if got := string(Render(rest, html.NewRenderer(html.RendererOptions{}))); got != test.wantRest {
+			t.Errorf("%q: want rest %q, got %q", test.input, test.wantRest, got)
+		}
+	}
+
+	doc := Parse([]byte("no marker\n"), parser.New())
+	if summary, rest := SplitSummary(doc, "<!--more-->"); summary != doc || rest != nil {
+		t.Errorf("want doc unchanged without marker, got %v, %v", summary, rest)
+	}
+}